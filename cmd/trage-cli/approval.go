@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/lineedit"
+	"trage-agent-go/pkg/llm"
+)
+
+// terminalApprover 在终端上逐个询问是否执行工具调用，用于approval_policy为ask的代理
+type terminalApprover struct {
+	console    agent.Console
+	editor     *lineedit.Editor
+	approveAll bool // 用户选择了a，之后的工具调用不再询问
+}
+
+// newTerminalApprover 创建通过editor读取按键的审批者，问题和结果通过console输出。
+// editor为nil时从标准输入读取；交互式模式传入自己的编辑器，避免两个编辑器争抢缓冲的输入
+func newTerminalApprover(console agent.Console, editor *lineedit.Editor) *terminalApprover {
+	if editor == nil {
+		editor = lineedit.NewEditor(os.Stdin, os.Stdout, nil)
+	}
+	return &terminalApprover{console: console, editor: editor}
+}
+
+// Approve 显示工具名称和参数并等待按键：y执行，a执行并不再询问，其他按键拒绝
+func (a *terminalApprover) Approve(ctx context.Context, toolCall llm.ToolCall) (bool, error) {
+	if a.approveAll {
+		return true, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	args, _ := json.Marshal(toolCall.Function.Arguments)
+	a.console.Print(fmt.Sprintf("❓ 执行工具 %s %s? [y/N/a]", toolCall.Function.Name, args))
+	key, err := a.editor.ReadKey("")
+	if err != nil {
+		a.console.Print("🚫 已拒绝")
+		return false, err
+	}
+
+	switch key {
+	case 'a', 'A':
+		a.approveAll = true
+		a.console.Print("✅ 已批准，本次运行不再询问")
+		return true, nil
+	case 'y', 'Y':
+		a.console.Print("✅ 已批准")
+		return true, nil
+	default:
+		a.console.Print("🚫 已拒绝")
+		return false, nil
+	}
+}
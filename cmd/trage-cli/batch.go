@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/batch"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// batch命令参数
+	batchConcurrency   int
+	batchOutput        string
+	batchTrajectoryDir string
+)
+
+// batch命令
+var batchCmd = &cobra.Command{
+	Use:   "batch <tasks.jsonl>",
+	Short: "批量执行任务",
+	Long: `从JSONL文件读取任务并按并发限制执行，每个任务使用独立的代理和轨迹文件。
+
+每行一个任务：
+  {"id": "task-1", "prompt": "...", "working_dir": "repo", "base_commit": "abc123",
+   "allowed_tools": ["bash", "edit_file"], "max_steps": 30, "must_patch": true}
+
+每个任务结束后向结果文件追加一行，包含success、patch、usage、steps、duration_seconds
+和error。working_dir相同的任务会依次运行。有任务失败时以非零状态退出。`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBatch,
+}
+
+func init() {
+	batchCmd.Flags().IntVar(&batchConcurrency, "concurrency", 4, "同时运行的最大任务数")
+	batchCmd.Flags().StringVar(&batchOutput, "output", "batch_results.jsonl", "结果JSONL文件路径")
+	batchCmd.Flags().StringVar(&batchTrajectoryDir, "trajectory-dir", "trajectories", "轨迹文件目录")
+	rootCmd.AddCommand(batchCmd)
+}
+
+// runBatch 批量执行任务
+func runBatch(cmd *cobra.Command, args []string) error {
+	tasks, err := batch.LoadTasks(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to load tasks: %v", err)
+	}
+	if len(tasks) == 0 {
+		return fmt.Errorf("no tasks found in %s", args[0])
+	}
+
+	// 加载配置
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	// 验证配置
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("config validation failed: %v", err)
+	}
+
+	factory := agent.NewAgentFactory()
+	runner, err := batch.NewRunner(batch.Options{
+		NewAgent: func(trajectoryFile string) (agent.Agent, error) {
+			return factory.CreateAgent(agent.AgentType(agentType), agentName, cfg, trajectoryFile)
+		},
+		Concurrency:   batchConcurrency,
+		TrajectoryDir: batchTrajectoryDir,
+		WorkingDir:    workingDir,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(batchOutput), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	output, err := os.Create(batchOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer output.Close()
+	encoder := json.NewEncoder(output)
+
+	// Ctrl+C时停止正在运行的任务，已完成的结果保留在结果文件中
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("开始执行 %d 个任务，并发数 %d\n", len(tasks), batchConcurrency)
+	completed, failed := 0, 0
+	var writeErr error
+	runner.Run(ctx, tasks, func(result batch.Result) {
+		completed++
+		status := "✅"
+		if !result.Success {
+			failed++
+			status = "❌"
+		}
+		fmt.Printf("[%d/%d] %s %s (%.1fs, %d 步, %d tokens)\n",
+			completed, len(tasks), status, result.ID, result.Duration, result.Steps, result.Usage.TotalTokens)
+		if result.Error != "" {
+			fmt.Printf("    错误: %s\n", result.Error)
+		}
+		if err := encoder.Encode(result); err != nil && writeErr == nil {
+			writeErr = err
+		}
+	})
+
+	if writeErr != nil {
+		return fmt.Errorf("failed to write results: %v", writeErr)
+	}
+	fmt.Printf("完成: %d 成功, %d 失败，结果已写入 %s\n", len(tasks)-failed, failed, batchOutput)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tasks failed", failed, len(tasks))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"trage-agent-go/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+// config命令
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "配置文件工具",
+	Long:  "验证配置文件并导出配置文件的JSON Schema",
+}
+
+// config validate命令
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "验证配置",
+	Long: `加载所有配置层并应用命令行参数和环境变量后验证配置，一次列出所有问题及其所在的文件、行和列。
+未知字段和已废弃字段作为警告输出，不影响验证结果。`,
+	Args: cobra.NoArgs,
+	RunE: validateConfig,
+	// 验证失败时已经列出了所有问题，不再输出用法
+	SilenceUsage: true,
+}
+
+// config schema命令
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "输出配置文件的JSON Schema",
+	Long: `输出配置文件的JSON Schema，可以保存后在编辑器中用于补全和校验，例如在YAML文件开头添加：
+  # yaml-language-server: $schema=./trae_config.schema.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := os.Stdout.Write(config.JSONSchema())
+		return err
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd, configSchemaCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// validateConfig 验证配置并逐行输出问题
+func validateConfig(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
+
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		fmt.Printf("❌ 配置有 %d 个问题:\n", len(validationErr.Problems))
+		for _, problem := range validationErr.Problems {
+			fmt.Printf("  %s\n", problem)
+		}
+		return fmt.Errorf("invalid configuration")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	fmt.Printf("✅ 配置有效（%d 个配置文件，%d 个警告）\n", len(cfg.Layers()), len(cfg.Warnings()))
+	return nil
+}
+
+// printConfigWarnings 将加载配置时发现的未知字段和已废弃字段输出到标准错误
+func printConfigWarnings(cfg *config.Config) {
+	for _, warning := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "⚠️  配置警告: %s\n", warning)
+	}
+}
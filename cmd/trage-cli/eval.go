@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/eval"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// eval命令参数
+	evalConcurrency   int
+	evalOutput        string
+	evalSummary       string
+	evalTrajectoryDir string
+	evalScratchDir    string
+	evalTestTimeout   time.Duration
+	evalKeepWorktrees bool
+)
+
+// eval命令
+var evalCmd = &cobra.Command{
+	Use:   "eval <dataset>",
+	Short: "评测代理",
+	Long: `SWE-bench风格的评测。数据集为JSONL或JSON数组，每个实例包含：
+
+  {"instance_id": "...", "repo": "path/to/repo", "base_commit": "...",
+   "problem_statement": "...", "test_patch": "...",
+   "fail_to_pass": ["go test ./pkg/... -run TestFix"], "pass_to_pass": ["go test ./..."]}
+
+对每个实例，在基准提交的临时worktree中以must_patch运行代理，再把补丁应用到另一个
+干净的worktree（以及可选的test_patch），依次运行测试命令。fail_to_pass和pass_to_pass
+全部通过即视为已解决。结果逐行写入--output，汇总输出到控制台和--summary。`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEval,
+}
+
+func init() {
+	evalCmd.Flags().IntVar(&evalConcurrency, "concurrency", 1, "同时评测的实例数")
+	evalCmd.Flags().StringVar(&evalOutput, "output", "eval_results.jsonl", "结果JSONL文件路径")
+	evalCmd.Flags().StringVar(&evalSummary, "summary", "", "汇总JSON文件路径")
+	evalCmd.Flags().StringVar(&evalTrajectoryDir, "trajectory-dir", "eval_trajectories", "轨迹文件目录")
+	evalCmd.Flags().StringVar(&evalScratchDir, "scratch-dir", "eval_workspaces", "临时worktree目录")
+	evalCmd.Flags().DurationVar(&evalTestTimeout, "test-timeout", 10*time.Minute, "单条测试命令的超时时间")
+	evalCmd.Flags().BoolVar(&evalKeepWorktrees, "keep-worktrees", false, "评测后保留worktree")
+	rootCmd.AddCommand(evalCmd)
+}
+
+// runEval 运行评测
+func runEval(cmd *cobra.Command, args []string) error {
+	instances, err := eval.LoadDataset(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to load dataset: %v", err)
+	}
+	if len(instances) == 0 {
+		return fmt.Errorf("no instances found in %s", args[0])
+	}
+
+	// 加载配置
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	// 验证配置
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("config validation failed: %v", err)
+	}
+
+	factory := agent.NewAgentFactory()
+	harness, err := eval.NewHarness(eval.Options{
+		NewAgent: func(trajectoryFile string) (agent.Agent, error) {
+			return factory.CreateAgent(agent.AgentType(agentType), agentName, cfg, trajectoryFile)
+		},
+		ScratchDir:    evalScratchDir,
+		TrajectoryDir: evalTrajectoryDir,
+		Concurrency:   evalConcurrency,
+		MaxSteps:      maxSteps,
+		TestTimeout:   evalTestTimeout,
+		KeepWorktrees: evalKeepWorktrees,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(evalOutput), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	output, err := os.Create(evalOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer output.Close()
+	encoder := json.NewEncoder(output)
+
+	// Ctrl+C时停止评测，已完成的结果保留在结果文件中
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("开始评测 %d 个实例，并发数 %d\n", len(instances), evalConcurrency)
+	completed := 0
+	var writeErr error
+	results := harness.Run(ctx, instances, func(result eval.Result) {
+		completed++
+		status := "❌"
+		if result.Resolved {
+			status = "✅"
+		}
+		fmt.Printf("[%d/%d] %s %s (fail_to_pass %d/%d, pass_to_pass %d/%d, %.1fs)\n",
+			completed, len(instances), status, result.InstanceID,
+			countPassed(result.FailToPass), len(result.FailToPass),
+			countPassed(result.PassToPass), len(result.PassToPass), result.Duration)
+		if result.Error != "" {
+			fmt.Printf("    错误: %s\n", result.Error)
+		}
+		if err := encoder.Encode(result); err != nil && writeErr == nil {
+			writeErr = err
+		}
+	})
+	if writeErr != nil {
+		return fmt.Errorf("failed to write results: %v", writeErr)
+	}
+
+	summary := eval.Summarize(results)
+	summary.Dataset = args[0]
+	if agentConfig, err := cfg.GetAgentConfig(agentName); err == nil {
+		if modelConfig, err := cfg.GetModelConfig(agentConfig.Model); err == nil {
+			summary.Model = modelConfig.Model
+		}
+	}
+
+	fmt.Println()
+	fmt.Println(summary.String())
+	fmt.Printf("结果已写入 %s\n", evalOutput)
+
+	if evalSummary != "" {
+		data, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal summary: %v", err)
+		}
+		if err := os.WriteFile(evalSummary, data, 0644); err != nil {
+			return fmt.Errorf("failed to write summary: %v", err)
+		}
+		fmt.Printf("汇总已写入 %s\n", evalSummary)
+	}
+	return nil
+}
+
+// countPassed 统计通过的测试数
+func countPassed(results []eval.TestResult) int {
+	passed := 0
+	for _, result := range results {
+		if result.Passed {
+			passed++
+		}
+	}
+	return passed
+}
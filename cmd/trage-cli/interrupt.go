@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"trage-agent-go/pkg/tools"
+)
+
+// interruptExitCode 被Ctrl-C强制退出时的退出码
+const interruptExitCode = 130
+
+// withInterrupt 返回在第一次收到Ctrl-C（或SIGTERM）时取消的上下文，当前的LLM调用和工具执行随之中止；
+// 再次收到信号时终止工具启动的子进程并强制退出。stop用于恢复默认的信号处理
+func withInterrupt(parent context.Context) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 2)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		interrupted := false
+		for {
+			select {
+			case <-done:
+				return
+			case <-signals:
+				if interrupted {
+					fmt.Fprintln(os.Stderr, "\n⛔ 强制退出")
+					tools.KillProcessGroups()
+					os.Exit(interruptExitCode)
+				}
+				interrupted = true
+				fmt.Fprintln(os.Stderr, "\n⏸️  正在中断当前任务，再次按 Ctrl-C 强制退出")
+				cancel()
+			}
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
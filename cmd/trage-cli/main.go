@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"os"
@@ -9,7 +8,9 @@ import (
 
 	"trage-agent-go/pkg/agent"
 	"trage-agent-go/pkg/config"
-	"trage-agent-go/pkg/tools"
+	"trage-agent-go/pkg/console"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/session"
 
 	"github.com/spf13/cobra"
 )
@@ -28,9 +29,11 @@ var (
 	patchPath      string
 	consoleType    string
 	agentType      string
+	agentName      string
 	task           string
 	filePath       string
 	interactive    bool
+	showOrigin     bool
 )
 
 // 根命令
@@ -64,8 +67,10 @@ var runCmd = &cobra.Command{
 var showConfigCmd = &cobra.Command{
 	Use:   "show-config",
 	Short: "显示配置",
-	Long:  "显示当前加载的配置信息",
-	RunE:  showConfig,
+	Long: `显示当前加载的配置信息。配置按以下顺序加载并深度合并，后面的层覆盖前面的层：
+  /etc/trage/config.yaml、~/.config/trage/config.yaml、仓库的.trae/config.yaml、--config-file。
+--origin 列出每个配置项及其来源。`,
+	RunE: showConfig,
 }
 
 // interactive命令
@@ -81,7 +86,7 @@ func init() {
 	rootCmd.AddCommand(runCmd, showConfigCmd, interactiveCmd)
 
 	// 全局标志
-	rootCmd.PersistentFlags().StringVarP(&configFile, "config-file", "c", "trae_config.yaml", "配置文件路径")
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config-file", "c", "trae_config.yaml", "配置文件路径，覆盖系统、用户和项目配置")
 	rootCmd.PersistentFlags().StringVarP(&provider, "provider", "p", "", "LLM提供商")
 	rootCmd.PersistentFlags().StringVarP(&model, "model", "m", "", "特定模型")
 	rootCmd.PersistentFlags().StringVar(&modelBaseURL, "model-base-url", "", "模型API的基础URL")
@@ -93,13 +98,25 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&patchPath, "patch-path", "j", "", "补丁文件路径")
 	rootCmd.PersistentFlags().StringVarP(&consoleType, "console-type", "o", "simple", "控制台类型（simple或rich）")
 	rootCmd.PersistentFlags().StringVarP(&agentType, "agent-type", "g", "trae_agent", "代理类型")
+	rootCmd.PersistentFlags().StringVar(&agentName, "agent", config.DefaultAgentName, "使用配置文件agents中的哪个代理")
 
 	// run命令标志
 	runCmd.Flags().StringVarP(&filePath, "file", "f", "", "包含任务描述的文件路径")
 	runCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "交互式模式")
 
-	// 绑定环境变量
-	rootCmd.PersistentFlags().Lookup("config-file").Value.Set(os.Getenv("TRAE_CONFIG_FILE"))
+	// show-config命令标志
+	showConfigCmd.Flags().BoolVar(&showOrigin, "origin", false, "列出每个配置项及其来源")
+
+	// 未指定--config-file时使用TRAE_CONFIG_FILE环境变量
+	if envConfigFile := os.Getenv("TRAE_CONFIG_FILE"); envConfigFile != "" {
+		rootCmd.PersistentFlags().Lookup("config-file").DefValue = envConfigFile
+		rootCmd.PersistentFlags().Lookup("config-file").Value.Set(envConfigFile)
+	}
+	// 未指定--agent时使用TRAE_AGENT环境变量
+	if envAgent := os.Getenv(config.EnvAgent); envAgent != "" {
+		rootCmd.PersistentFlags().Lookup("agent").DefValue = envAgent
+		rootCmd.PersistentFlags().Lookup("agent").Value.Set(envAgent)
+	}
 }
 
 func main() {
@@ -127,7 +144,7 @@ func runTask(cmd *cobra.Command, args []string) error {
 	}
 
 	// 加载配置
-	cfg, err := config.LoadConfig(configFile)
+	cfg, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %v", err)
 	}
@@ -137,22 +154,17 @@ func runTask(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("config validation failed: %v", err)
 	}
 
-	// 解析命令行参数覆盖配置
-	if err := parseCommandLineOverrides(cfg); err != nil {
-		return fmt.Errorf("failed to parse command line overrides: %v", err)
-	}
-
 	// 创建代理工厂
 	factory := agent.NewAgentFactory()
 
 	// 创建代理
-	agentInstance, err := factory.CreateAgent(agent.AgentType(agentType), cfg, trajectoryFile)
+	agentInstance, err := factory.CreateAgent(agent.AgentType(agentType), agentName, cfg, trajectoryFile)
 	if err != nil {
 		return fmt.Errorf("failed to create agent: %v", err)
 	}
 
-	// 注册工具
-	registerTools(agentInstance)
+	// 显示代理的工具
+	printTools(agentInstance)
 
 	// 设置工作目录
 	if workingDir != "" {
@@ -161,39 +173,43 @@ func runTask(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// 构建额外参数
-	extraArgs := buildExtraArgs()
+	// 创建或恢复会话
+	store := session.NewStore(sessionsDir)
+	sess, err := openSession(store, agentInstance, "", continueLast)
+	if err != nil {
+		return err
+	}
+
+	// 创建控制台
+	cliConsole, err := newCLIConsole(agentInstance)
+	if err != nil {
+		return err
+	}
+
+	agentInstance.SetApprover(newTerminalApprover(cliConsole, nil))
 
 	// 运行代理
-	ctx := context.Background()
-	execution, err := agentInstance.Run(ctx, taskDescription, extraArgs, nil)
+	execution, err := runWithConsole(cliConsole, agentInstance, taskDescription, nil)
 	if err != nil {
 		return fmt.Errorf("agent execution failed: %v", err)
 	}
+	printTrajectoryPath(cliConsole, agentInstance)
+	recordSessionTask(store, sess, agentInstance, taskDescription, execution)
+	cliConsole.Print(fmt.Sprintf("会话: %s", sess.ID))
 
-	// 输出结果
-	if execution.Success {
-		fmt.Printf("✅ 任务执行成功！\n")
-		fmt.Printf("输出: %s\n", execution.Output)
-	} else {
-		fmt.Printf("❌ 任务执行失败！\n")
-		fmt.Printf("错误: %s\n", execution.Error)
+	if execution.Cancelled {
+		return fmt.Errorf("task interrupted, use 'trage-cli run --continue' to resume")
 	}
-
-	fmt.Printf("执行时间: %v\n", execution.Duration)
-	fmt.Printf("执行步数: %d\n", len(execution.Steps))
-
 	return nil
 }
 
 // showConfig 显示配置
 func showConfig(cmd *cobra.Command, args []string) error {
 	// 加载配置
-	cfg, err := config.LoadConfig(configFile)
+	cfg, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %v", err)
 	}
-	fmt.Println("cfg", *cfg)
 	// 验证配置
 	if err := cfg.Validate(); err != nil {
 		fmt.Printf("⚠️  配置验证警告: %v\n", err)
@@ -201,6 +217,19 @@ func showConfig(cmd *cobra.Command, args []string) error {
 
 	fmt.Println("=== Trae Agent 配置 ===")
 
+	// 显示加载的配置层
+	fmt.Println("\n配置文件:")
+	for _, layer := range cfg.Layers() {
+		fmt.Printf("  %s: %s\n", layer.Name, layer.Path)
+	}
+
+	if showOrigin {
+		return printConfigOrigins(cfg)
+	}
+
+	// 显示生效的配置及其来源
+	printEffectiveConfig(cfg, agentName)
+
 	// 显示代理配置
 	fmt.Println("\n代理配置:")
 	for name, agentCfg := range cfg.Agents {
@@ -211,6 +240,15 @@ func showConfig(cmd *cobra.Command, args []string) error {
 		fmt.Printf("    工具: %s\n", strings.Join(agentCfg.Tools, ", "))
 	}
 
+	// 显示Lakeview配置
+	fmt.Println("\nLakeview配置:")
+	if cfg.Lakeview.Model != "" {
+		fmt.Printf("  模型: %s\n", cfg.Lakeview.Model)
+	} else {
+		fmt.Println("  模型: （使用代理的模型）")
+	}
+	fmt.Printf("  最大行数: %d\n", cfg.Lakeview.MaxLines)
+
 	// 显示模型提供商配置
 	fmt.Println("\n模型提供商配置:")
 	for name, provider := range cfg.ModelProviders {
@@ -223,7 +261,11 @@ func showConfig(cmd *cobra.Command, args []string) error {
 			fmt.Printf("    API版本: %s\n", provider.APIVersion)
 		}
 		if provider.APIKey != "" {
-			fmt.Printf("    API密钥: %s...\n", provider.APIKey[:min(8, len(provider.APIKey))])
+			fmt.Printf("    API密钥: %s\n", config.RedactSecret(provider.APIKey))
+		} else if provider.APIKeyFile != "" {
+			fmt.Printf("    API密钥文件: %s\n", provider.APIKeyFile)
+		} else if provider.APIKeyCommand != "" {
+			fmt.Printf("    API密钥命令: %s\n", provider.APIKeyCommand)
 		}
 	}
 
@@ -245,21 +287,70 @@ func showConfig(cmd *cobra.Command, args []string) error {
 	envVars := []string{"OPENAI_API_KEY", "ANTHROPIC_API_KEY", "GOOGLE_API_KEY", "OPENROUTER_API_KEY", "DOUBAO_API_KEY"}
 	for _, envVar := range envVars {
 		if value := os.Getenv(envVar); value != "" {
-			fmt.Printf("  %s: %s...\n", envVar, value[:min(8, len(value))])
+			fmt.Printf("  %s: %s\n", envVar, config.RedactSecret(value))
 		}
 	}
 
 	return nil
 }
 
+// printEffectiveConfig 显示代理实际使用的配置（已应用命令行和环境变量覆盖）以及每个值的来源
+func printEffectiveConfig(cfg *config.Config, agentName string) {
+	agentConfig, exists := cfg.Agents[agentName]
+	if !exists {
+		return
+	}
+
+	fmt.Printf("\n生效配置 (%s):\n", agentName)
+	agentPath := "agents." + agentName
+	fmt.Printf("  最大步数: %d [%s]\n", agentConfig.MaxSteps, cfg.Origin(agentPath+".max_steps"))
+	fmt.Printf("  模型配置: %s [%s]\n", agentConfig.Model, cfg.Origin(agentPath+".model"))
+
+	modelConfig, err := cfg.GetModelConfig(agentConfig.Model)
+	if err != nil {
+		return
+	}
+	modelPath := "models." + agentConfig.Model
+	fmt.Printf("  模型: %s [%s]\n", modelConfig.Model, cfg.Origin(modelPath+".model"))
+	fmt.Printf("  提供商: %s [%s]\n", modelConfig.ModelProvider, cfg.Origin(modelPath+".model_provider"))
+	if provider := modelConfig.ResolvedProvider; provider != nil {
+		if provider.BaseURL != "" {
+			fmt.Printf("  基础URL: %s [%s]\n", provider.BaseURL, cfg.Origin(modelPath+".base_url"))
+		}
+		if provider.HasAPIKey() {
+			key := config.RedactSecret(provider.APIKey)
+			if key == "" {
+				key = "（创建客户端时读取）"
+			}
+			fmt.Printf("  API密钥: %s [%s]\n", key, cfg.Origin(modelPath+".api_key"))
+		}
+	}
+}
+
+// printConfigOrigins 列出每个生效的配置项及其来源
+func printConfigOrigins(cfg *config.Config) error {
+	settings, err := cfg.Settings()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n配置项来源:")
+	for _, setting := range settings {
+		fmt.Printf("  %s: %s [%s]\n", setting.Path, setting.Value, setting.Origin)
+	}
+
+	printEffectiveConfig(cfg, agentName)
+	return nil
+}
+
 // startInteractive 启动交互式模式
 func startInteractive(cmd *cobra.Command, args []string) error {
 	fmt.Println("🚀 启动 Trae Agent 交互式模式")
-	fmt.Println("输入 'help' 查看可用命令，输入 'exit' 退出")
+	fmt.Println("输入 '/help' 查看可用命令，输入 '/exit' 退出")
 	fmt.Println()
 
 	// 加载配置
-	cfg, err := config.LoadConfig(configFile)
+	cfg, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -271,17 +362,23 @@ func startInteractive(cmd *cobra.Command, args []string) error {
 
 	// 显示配置信息
 	fmt.Println("📋 当前配置:")
-	if agentConfig, err := cfg.GetTraeAgentConfig(); err == nil {
-		fmt.Printf("  • 代理类型: %s\n", "trae_agent")
-		fmt.Printf("  • 最大步数: %d\n", agentConfig.MaxSteps)
-		fmt.Printf("  • 启用工具: %s\n", strings.Join(agentConfig.Tools, ", "))
+	agentConfig, err := cfg.GetAgentConfig(agentName)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("  • 代理: %s\n", agentConfig.Name)
+	fmt.Printf("  • 代理类型: %s\n", "trae_agent")
+	fmt.Printf("  • 最大步数: %d\n", agentConfig.MaxSteps)
+	fmt.Printf("  • 启用工具: %s\n", strings.Join(agentConfig.Tools, ", "))
+	if agentConfig.ApprovalPolicy != "" {
+		fmt.Printf("  • 审批策略: %s\n", agentConfig.ApprovalPolicy)
 	}
 
-	if modelConfig, err := cfg.GetModelConfig("trae_agent_model"); err == nil {
+	if modelConfig, err := cfg.GetModelConfig(agentConfig.Model); err == nil {
 		fmt.Printf("  • 模型: %s\n", modelConfig.Model)
 		fmt.Printf("  • 提供商: %s\n", modelConfig.ModelProvider)
 		if modelConfig.ResolvedProvider != nil {
-			fmt.Printf("  • API密钥: %s...\n", modelConfig.ResolvedProvider.APIKey[:min(8, len(modelConfig.ResolvedProvider.APIKey))])
+			fmt.Printf("  • API密钥: %s\n", config.RedactSecret(modelConfig.ResolvedProvider.APIKey))
 		}
 	}
 	fmt.Println()
@@ -290,184 +387,119 @@ func startInteractive(cmd *cobra.Command, args []string) error {
 	factory := agent.NewAgentFactory()
 
 	// 创建代理
-	agentInstance, err := factory.CreateAgent(agent.AgentTypeTraeAgent, cfg, trajectoryFile)
+	agentInstance, err := factory.CreateAgent(agent.AgentTypeTraeAgent, agentName, cfg, trajectoryFile)
 	if err != nil {
 		return fmt.Errorf("failed to create agent: %w", err)
 	}
 
-	// 注册工具
-	registerTools(agentInstance)
+	// 显示代理的工具
+	printTools(agentInstance)
 
-	// 启动交互式循环
-	return runInteractiveLoop(agentInstance, cfg)
-}
-
-// runInteractiveLoop 运行交互式循环
-func runInteractiveLoop(agentInstance agent.Agent, cfg *config.Config) error {
-	scanner := bufio.NewScanner(os.Stdin)
-
-	fmt.Println("✅ 交互式模式已启动！")
-	fmt.Println("可用命令: help, status, clear, exit/quit")
-	fmt.Println()
-
-	for {
-		fmt.Print("trae-agent> ")
-
-		if !scanner.Scan() {
-			break
-		}
-
-		input := strings.TrimSpace(scanner.Text())
-		if input == "" {
-			continue
-		}
-
-		switch strings.ToLower(input) {
-		case "help":
-			showHelp()
-		case "status":
-			showStatus(agentInstance, cfg)
-		case "clear":
-			clearScreen()
-		case "clear-history":
-			clearConversationHistory(agentInstance)
-		case "exit", "quit":
-			fmt.Println("👋 再见！")
-			return nil
-		default:
-			// 执行任务
-			if err := executeTask(agentInstance, input); err != nil {
-				fmt.Printf("❌ 任务执行失败: %v\n", err)
-			}
+	// 设置工作目录
+	if workingDir != "" {
+		if err := os.Chdir(workingDir); err != nil {
+			return fmt.Errorf("failed to change working directory: %w", err)
 		}
+	}
 
-		fmt.Println()
+	// 创建或恢复会话
+	store := session.NewStore(sessionsDir)
+	sess, err := openSession(store, agentInstance, resumeSession, false)
+	if err != nil {
+		return err
 	}
 
-	return scanner.Err()
+	// 启动交互式循环
+	r, err := newREPL(agentInstance, cfg, store, sess)
+	if err != nil {
+		return err
+	}
+	return r.run()
 }
 
-// showHelp 显示帮助信息
-func showHelp() {
-	fmt.Println("📖 可用命令:")
-	fmt.Println("• 输入任何任务描述来执行任务")
-	fmt.Println("• 'help' - 显示此帮助信息")
-	fmt.Println("• 'status' - 显示代理状态")
-	fmt.Println("• 'clear' - 清屏")
-	fmt.Println("• 'clear-history' - 清空对话历史")
-	fmt.Println("• 'exit' 或 'quit' - 退出会话")
+// newCLIConsole 根据--console-type创建控制台并设置到代理
+func newCLIConsole(agentInstance agent.Agent) (agent.Console, error) {
+	cliConsole, err := console.NewConsole(consoleType, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	agentInstance.SetCLIConsole(cliConsole)
+	return cliConsole, nil
 }
 
-// showStatus 显示代理状态
-func showStatus(agentInstance agent.Agent, cfg *config.Config) {
-	fmt.Println("📊 代理状态:")
-
-	agentConfig := agentInstance.GetConfig()
-	if agentConfig != nil {
-		fmt.Printf("• 模型: %s\n", agentConfig.Model)
-		fmt.Printf("• 最大步数: %d\n", agentConfig.MaxSteps)
-		fmt.Printf("• 工具数量: %d\n", len(agentConfig.Tools))
+// runWithConsole 显示任务详情并运行代理，执行过程和结果都通过控制台输出；toolNames为空时使用全部工具。
+// 运行期间第一次Ctrl-C中断任务，第二次强制退出
+func runWithConsole(cliConsole agent.Console, agentInstance agent.Agent, task string, toolNames []string) (*agent.AgentExecution, error) {
+	details := map[string]string{"任务": task}
+	if agentConfig := agentInstance.GetConfig(); agentConfig != nil {
+		details["模型"] = agentConfig.Model
+		details["最大步数"] = fmt.Sprintf("%d", agentConfig.MaxSteps)
 	}
-
-	fmt.Printf("• 配置文件: %s\n", configFile)
 	if workingDir, err := os.Getwd(); err == nil {
-		fmt.Printf("• 工作目录: %s\n", workingDir)
-	}
-}
-
-// clearScreen 清屏
-func clearScreen() {
-	fmt.Print("\033[H\033[2J")
+		details["工作目录"] = workingDir
+	}
+	cliConsole.PrintTaskDetails(details)
+
+	if err := cliConsole.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start console: %w", err)
+	}
+	ctx, stop := withInterrupt(context.Background())
+	defer stop()
+	// 重试、轨迹写入失败等警告通过控制台输出，避免打乱实时面板
+	ctx = llm.WithWarningHandler(ctx, func(message string) {
+		cliConsole.Print("⚠️  " + message)
+	})
+	execution, err := agentInstance.Run(ctx, task, buildExtraArgs(), toolNames)
+	cliConsole.Finish(execution)
+	return execution, err
 }
 
-// clearConversationHistory 清空对话历史
-func clearConversationHistory(agentInstance agent.Agent) {
-	if traeAgent, ok := agentInstance.(*agent.TraeAgent); ok {
-		traeAgent.ClearConversationHistory()
-		fmt.Println("🗑️  对话历史已清空")
-	} else {
-		fmt.Println("⚠️  此代理类型不支持清空对话历史")
+// printTrajectoryPath 输出轨迹文件路径
+func printTrajectoryPath(cliConsole agent.Console, agentInstance agent.Agent) {
+	if recorder := agentInstance.GetTrajectoryRecorder(); recorder != nil {
+		cliConsole.Print(fmt.Sprintf("轨迹文件: %s", recorder.GetTrajectoryPath()))
 	}
 }
 
-// executeTask 执行任务
-func executeTask(agentInstance agent.Agent, task string) error {
-	fmt.Printf("🚀 执行任务: %s\n", task)
-
-	ctx := context.Background()
-	extraArgs := buildExtraArgs()
-
-	// 执行任务
-	execution, err := agentInstance.Run(ctx, task, extraArgs, nil)
-	if err != nil {
-		return fmt.Errorf("agent execution failed: %w", err)
+// loadConfig 依次加载系统、用户、项目和--config-file配置层，并应用命令行参数和环境变量的覆盖，
+// 优先级：命令行 > 环境变量 > --config-file > 项目 > 用户 > 系统
+func loadConfig() (*config.Config, error) {
+	dir := workingDir
+	if dir == "" {
+		dir = "."
 	}
-
-	// 显示结果
-	if execution.Success {
-		fmt.Printf("✅ 任务执行成功！\n")
-		if execution.Output != "" {
-			fmt.Printf("输出: %s\n", execution.Output)
-		}
-	} else {
-		fmt.Printf("❌ 任务执行失败！\n")
-		if execution.Error != "" {
-			fmt.Printf("错误: %s\n", execution.Error)
-		}
+	// 明确指定的配置文件必须存在，默认的trae_config.yaml不存在时只使用其他配置层
+	explicit := rootCmd.PersistentFlags().Changed("config-file") || os.Getenv("TRAE_CONFIG_FILE") != ""
+	layers, err := config.DiscoverLayers(dir, configFile, explicit)
+	if err != nil {
+		return nil, err
 	}
-
-	fmt.Printf("执行时间: %v\n", execution.Duration)
-	fmt.Printf("执行步数: %d\n", len(execution.Steps))
-
-	return nil
-}
-
-// parseCommandLineOverrides 解析命令行参数覆盖配置
-func parseCommandLineOverrides(cfg *config.Config) error {
-	// 如果指定了提供商，更新配置
-	if provider != "" {
-		// 这里需要实现具体的配置覆盖逻辑
-		fmt.Printf("使用提供商: %s\n", provider)
+	cfg, err := config.LoadLayers(layers)
+	if err != nil {
+		return nil, err
 	}
+	printConfigWarnings(cfg)
 
-	// 如果指定了模型，更新配置
-	if model != "" {
-		fmt.Printf("使用模型: %s\n", model)
+	overrides := config.Overrides{
+		Provider:     provider,
+		Model:        model,
+		ModelBaseURL: modelBaseURL,
+		APIKey:       apiKey,
+		MaxSteps:     maxSteps,
 	}
-
-	// 如果指定了API密钥，更新配置
-	if apiKey != "" {
-		fmt.Printf("使用API密钥: %s...\n", apiKey[:min(8, len(apiKey))])
+	if err := cfg.ApplyOverrides(agentName, overrides); err != nil {
+		return nil, err
 	}
-
-	return nil
+	return cfg, nil
 }
 
-// registerTools 注册工具
-func registerTools(agentInstance agent.Agent) {
-	// 创建工具实例
-	bashTool := tools.NewBashTool()
-	editTool := tools.NewEditTool()
-	sequentialThinkingTool := tools.NewSequentialThinkingTool()
-	taskDoneTool := tools.NewTaskDoneTool()
-
-	// 检查代理类型并注册工具
-	switch ag := agentInstance.(type) {
-	case *agent.BaseAgent:
-		ag.AddTool(bashTool)
-		ag.AddTool(editTool)
-		ag.AddTool(sequentialThinkingTool)
-		ag.AddTool(taskDoneTool)
-		fmt.Printf("已注册工具: %s\n", strings.Join(ag.GetToolRegistry().ListTools(), ", "))
-	case *agent.TraeAgent:
-		ag.AddTool(bashTool)
-		ag.AddTool(editTool)
-		ag.AddTool(sequentialThinkingTool)
-		ag.AddTool(taskDoneTool)
-		fmt.Printf("已注册工具: %s\n", strings.Join(ag.GetToolRegistry().ListTools(), ", "))
-	default:
-		fmt.Printf("警告: 未知的代理类型 %T，无法注册工具\n", agentInstance)
+// printTools 输出代理的工具，工具由代理配置中的tools决定
+func printTools(agentInstance agent.Agent) {
+	names := make([]string, 0)
+	for _, tool := range agentInstance.GetTools() {
+		names = append(names, tool.GetName())
 	}
+	fmt.Printf("已注册工具: %s\n", strings.Join(names, ", "))
 }
 
 // buildExtraArgs 构建额外参数
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/lineedit"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/session"
+	"trage-agent-go/pkg/slash"
+	"trage-agent-go/pkg/utils"
+)
+
+// legacyCommands 不带/也能使用的旧命令
+var legacyCommands = map[string]bool{
+	"help": true, "status": true, "clear": true, "clear-history": true, "exit": true, "quit": true,
+}
+
+// repl 交互式模式的状态
+type repl struct {
+	agent         *agent.TraeAgent
+	cfg           *config.Config
+	factory       *agent.AgentFactory
+	store         *session.Store
+	sess          *session.Session
+	commands      *slash.Registry
+	editor        *lineedit.Editor
+	modelName     string          // 当前使用的模型配置名称
+	disabledTools map[string]bool // 通过/tools禁用的工具
+	snapshots     []string        // 每个任务开始前工作目录的git快照，用于/undo
+	done          bool
+}
+
+// newREPL 创建交互式模式，注册内置命令和项目中的自定义命令
+func newREPL(agentInstance agent.Agent, cfg *config.Config, store *session.Store, sess *session.Session) (*repl, error) {
+	traeAgent, ok := agentInstance.(*agent.TraeAgent)
+	if !ok {
+		return nil, fmt.Errorf("agent type %T does not support interactive mode", agentInstance)
+	}
+
+	r := &repl{
+		agent:         traeAgent,
+		cfg:           cfg,
+		factory:       agent.NewAgentFactory(),
+		store:         store,
+		sess:          sess,
+		commands:      slash.NewRegistry(),
+		disabledTools: make(map[string]bool),
+		snapshots:     make([]string, 0),
+	}
+	if agentConfig := traeAgent.GetConfig(); agentConfig != nil {
+		r.modelName = agentConfig.Model
+	}
+
+	for _, command := range r.builtinCommands() {
+		if err := r.commands.Register(command); err != nil {
+			return nil, err
+		}
+	}
+	r.loadTemplates()
+	return r, nil
+}
+
+// run 运行交互式循环，每个任务结束后保存会话
+func (r *repl) run() error {
+	history, err := lineedit.LoadHistory(lineedit.DefaultHistoryPath(), 0)
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+		history = nil
+	}
+	editor := lineedit.NewEditor(os.Stdin, os.Stdout, history)
+	editor.SetCompleter(r.complete)
+	r.editor = editor
+
+	fmt.Println("✅ 交互式模式已启动！")
+	fmt.Println("输入任务描述来执行任务，输入 /help 查看命令")
+	fmt.Println("行尾的 \\ 表示续行，\"\"\" 包围多行输入，@path 引用文件内容")
+	fmt.Println()
+
+	var readErr error
+	for !r.done {
+		input, err := editor.ReadInput("trae-agent> ", "... ")
+		if errors.Is(err, lineedit.ErrInterrupted) {
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				readErr = err
+			}
+			break
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+		if legacyCommands[strings.ToLower(input)] {
+			input = "/" + strings.ToLower(input)
+		}
+
+		if handled, err := r.commands.Execute(input); handled {
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+			}
+		} else {
+			r.runTask(input)
+		}
+
+		fmt.Println()
+	}
+
+	r.printSessionHint()
+	if r.done {
+		fmt.Println("👋 再见！")
+	}
+	return readErr
+}
+
+// complete Tab补全：行首补全斜杠命令，其他位置（包括@之后）补全文件路径
+func (r *repl) complete(line []rune, pos int) (int, []string) {
+	word, start := lineedit.CurrentWord(line, pos)
+	if start == 0 && strings.HasPrefix(word, "/") {
+		if commands := r.commands.Complete(word); len(commands) > 0 {
+			return start, commands
+		}
+	}
+	if strings.HasPrefix(word, "@") {
+		return start + 1, lineedit.CompletePath(word[1:])
+	}
+	if word == "" {
+		return start, nil
+	}
+	return start, lineedit.CompletePath(word)
+}
+
+// runTask 执行任务：展开@文件引用，记录工作目录快照以便撤销，结束后保存会话
+func (r *repl) runTask(task string) {
+	dir, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("❌ 获取工作目录失败: %v\n", err)
+		return
+	}
+	prompt, references, err := lineedit.ExpandFileReferences(task, dir)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if len(references) > 0 {
+		fmt.Printf("📎 已附加文件: %s\n", strings.Join(references, ", "))
+	}
+
+	// 不是git仓库时无法撤销，忽略错误
+	snapshot, _ := utils.GitSnapshot(context.Background(), dir)
+	r.snapshots = append(r.snapshots, snapshot)
+
+	execution, err := r.executeTask(prompt)
+	if err != nil {
+		fmt.Printf("❌ 任务执行失败: %v\n", err)
+	}
+	recordSessionTask(r.store, r.sess, r.agent, task, execution)
+}
+
+// executeTask 执行任务，只使用未被禁用的工具
+func (r *repl) executeTask(task string) (*agent.AgentExecution, error) {
+	cliConsole, err := newCLIConsole(r.agent)
+	if err != nil {
+		return nil, err
+	}
+	r.agent.SetApprover(newTerminalApprover(cliConsole, r.editor))
+
+	execution, err := runWithConsole(cliConsole, r.agent, task, r.enabledTools())
+	if err != nil {
+		return nil, fmt.Errorf("agent execution failed: %w", err)
+	}
+	printTrajectoryPath(cliConsole, r.agent)
+
+	return execution, nil
+}
+
+// enabledTools 返回未被禁用的工具名称，没有禁用任何工具时返回nil
+func (r *repl) enabledTools() []string {
+	if len(r.disabledTools) == 0 {
+		return nil
+	}
+	names := make([]string, 0)
+	for _, tool := range r.agent.GetTools() {
+		if !r.disabledTools[tool.GetName()] {
+			names = append(names, tool.GetName())
+		}
+	}
+	return names
+}
+
+// save 保存会话，还没有执行过任务时不创建会话文件
+func (r *repl) save() {
+	if len(r.sess.Tasks) == 0 {
+		return
+	}
+	if err := saveSession(r.store, r.sess, r.agent); err != nil {
+		fmt.Printf("⚠️  保存会话失败: %v\n", err)
+	}
+}
+
+// printSessionHint 提示如何恢复已保存的会话
+func (r *repl) printSessionHint() {
+	if len(r.sess.Tasks) > 0 {
+		fmt.Printf("💾 会话已保存，使用 'trage-cli interactive --resume %s' 继续\n", r.sess.ID)
+	}
+}
+
+// loadTemplates 注册项目中.trae/commands下的自定义命令，与已有命令重名的模板会被跳过
+func (r *repl) loadTemplates() {
+	dir, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	templates, err := slash.LoadTemplates(filepath.Join(dir, slash.TemplateDir))
+	if err != nil {
+		fmt.Printf("⚠️  加载自定义命令失败: %v\n", err)
+		return
+	}
+	for i := range templates {
+		command := templates[i].Command(func(prompt string) error {
+			r.runTask(prompt)
+			return nil
+		})
+		if err := r.commands.Register(command); err != nil {
+			fmt.Printf("⚠️  跳过自定义命令 %s: %v\n", templates[i].Path, err)
+		}
+	}
+}
+
+// builtinCommands 内置的斜杠命令
+func (r *repl) builtinCommands() []*slash.Command {
+	return []*slash.Command{
+		{Name: "help", Description: "显示此帮助信息", Run: r.showHelp},
+		{Name: "status", Description: "显示代理状态", Run: r.showStatus},
+		{Name: "clear", Description: "清屏", Run: func(string) error {
+			fmt.Print("\033[H\033[2J")
+			return nil
+		}},
+		{Name: "clear-history", Description: "清空对话历史", Run: r.clearHistory},
+		{Name: "exit", Aliases: []string{"quit"}, Description: "退出会话", Run: func(string) error {
+			r.done = true
+			return nil
+		}},
+		{Name: "model", Usage: "[name]", Description: "列出模型配置，或切换到指定的模型配置", Run: r.switchModel},
+		{Name: "tools", Usage: "[enable|disable <name>...]", Description: "列出工具，或启用/禁用工具", Run: r.toggleTools},
+		{Name: "undo", Description: "撤销上一个任务对文件的修改（需要git仓库）", Run: r.undo},
+		{Name: "diff", Description: "显示工作目录中未提交的修改", Run: r.showDiff},
+		{Name: "cost", Description: "显示令牌用量和费用", Run: r.showCost},
+		{Name: "save", Usage: "[name]", Description: "保存会话，可以同时设置名称", Run: r.saveSession},
+		{Name: "load", Usage: "<id|name>", Description: "加载已保存的会话", Run: r.loadSession},
+		{Name: "compact", Usage: "[instructions]", Description: "让模型总结对话历史以节省上下文", Run: r.compact},
+		{Name: "trajectory", Usage: "[stats]", Description: "显示轨迹文件路径或统计", Run: r.showTrajectory},
+	}
+}
+
+// showHelp 显示帮助信息
+func (r *repl) showHelp(string) error {
+	fmt.Println("📖 可用命令:")
+	fmt.Println(r.commands.Help())
+	fmt.Println()
+	fmt.Println("输入其他内容将作为任务交给代理执行。")
+	fmt.Printf("在项目的 %s 目录中添加Markdown文件即可定义自己的命令，文件中的 $ARGUMENTS 会替换为命令参数。\n", slash.TemplateDir)
+	fmt.Println("会话在每个任务结束后自动保存，可以用 'trage-cli interactive --resume <id>' 恢复")
+	return nil
+}
+
+// showStatus 显示代理状态
+func (r *repl) showStatus(string) error {
+	fmt.Println("📊 代理状态:")
+
+	agentConfig := r.agent.GetConfig()
+	if agentConfig != nil {
+		fmt.Printf("• 最大步数: %d\n", agentConfig.MaxSteps)
+	}
+	if modelConfig := r.agent.GetModelConfig(); modelConfig != nil {
+		fmt.Printf("• 模型: %s (%s)\n", modelConfig.Model, r.modelName)
+	}
+	fmt.Printf("• 工具数量: %d（禁用 %d）\n", len(r.agent.GetTools()), len(r.disabledTools))
+
+	for _, layer := range r.cfg.Layers() {
+		fmt.Printf("• 配置文件: %s (%s)\n", layer.Path, layer.Name)
+	}
+	if workingDir, err := os.Getwd(); err == nil {
+		fmt.Printf("• 工作目录: %s\n", workingDir)
+	}
+	fmt.Printf("• 会话: %s（%d 个任务，累计 %d 令牌）\n", r.sess.ID, len(r.sess.Tasks), r.sess.Usage.TotalTokens)
+	return nil
+}
+
+// clearHistory 清空对话历史
+func (r *repl) clearHistory(string) error {
+	r.agent.ClearConversationHistory()
+	r.save()
+	fmt.Println("🗑️  对话历史已清空")
+	return nil
+}
+
+// switchModel 列出模型配置或切换模型
+func (r *repl) switchModel(args string) error {
+	if args == "" {
+		names := make([]string, 0, len(r.cfg.Models))
+		for name := range r.cfg.Models {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Println("🤖 模型配置:")
+		for _, name := range names {
+			marker := " "
+			if name == r.modelName {
+				marker = "*"
+			}
+			modelConfig := r.cfg.Models[name]
+			fmt.Printf("  %s %s: %s (%s)\n", marker, name, modelConfig.Model, modelConfig.ModelProvider)
+		}
+		return nil
+	}
+
+	modelConfig, err := r.cfg.GetModelConfig(args)
+	if err != nil {
+		return err
+	}
+	client, err := r.factory.CreateLLMClient(modelConfig)
+	if err != nil {
+		return err
+	}
+	r.agent.SetModel(modelConfig, client)
+	r.modelName = args
+	r.save()
+	fmt.Printf("🤖 已切换到 %s: %s (%s)\n", args, modelConfig.Model, modelConfig.ModelProvider)
+	return nil
+}
+
+// toggleTools 列出工具或启用/禁用工具
+func (r *repl) toggleTools(args string) error {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		fmt.Println("🔧 工具:")
+		for _, tool := range r.agent.GetTools() {
+			status := "✅"
+			if r.disabledTools[tool.GetName()] {
+				status = "⛔"
+			}
+			fmt.Printf("  %s %s\n", status, tool.GetName())
+		}
+		return nil
+	}
+
+	action, names := fields[0], fields[1:]
+	if (action != "enable" && action != "disable") || len(names) == 0 {
+		return fmt.Errorf("usage: /tools [enable|disable <name>...]")
+	}
+	known := make(map[string]bool)
+	for _, tool := range r.agent.GetTools() {
+		known[tool.GetName()] = true
+	}
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("unknown tool: %s", name)
+		}
+	}
+
+	disabled := make(map[string]bool)
+	for name := range r.disabledTools {
+		disabled[name] = true
+	}
+	for _, name := range names {
+		if action == "disable" {
+			disabled[name] = true
+		} else {
+			delete(disabled, name)
+		}
+	}
+	if len(disabled) == len(r.agent.GetTools()) {
+		return fmt.Errorf("at least one tool must stay enabled")
+	}
+	r.disabledTools = disabled
+
+	fmt.Printf("🔧 已%s: %s\n", map[string]string{"enable": "启用", "disable": "禁用"}[action], strings.Join(names, ", "))
+	return nil
+}
+
+// undo 将工作目录恢复到上一个任务开始前的状态
+func (r *repl) undo(string) error {
+	if len(r.snapshots) == 0 {
+		return fmt.Errorf("nothing to undo")
+	}
+	snapshot := r.snapshots[len(r.snapshots)-1]
+	if snapshot == "" {
+		r.snapshots = r.snapshots[:len(r.snapshots)-1]
+		return fmt.Errorf("cannot undo: working directory was not a git repository when the task started")
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := utils.GitRestoreSnapshot(context.Background(), dir, snapshot); err != nil {
+		return fmt.Errorf("failed to undo changes: %w", err)
+	}
+	r.snapshots = r.snapshots[:len(r.snapshots)-1]
+
+	// 让代理知道之前的修改已经不存在
+	r.agent.AddToConversationHistory(llm.LLMMessage{Role: "user", Content: "（用户撤销了上一个任务对文件的修改，相关文件已恢复到该任务开始前的状态）"})
+	r.save()
+	fmt.Println("↩️  已撤销上一个任务对文件的修改")
+	return nil
+}
+
+// showDiff 显示工作目录中未提交的修改
+func (r *repl) showDiff(string) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	patch, err := utils.GitPatch(context.Background(), dir, "")
+	if err != nil {
+		return fmt.Errorf("failed to get diff: %w", err)
+	}
+	if len(patch) == 0 {
+		fmt.Println("没有未提交的修改")
+		return nil
+	}
+	fmt.Print(string(patch))
+	return nil
+}
+
+// showCost 显示会话的累计令牌用量，以及本次运行按轨迹统计的费用
+func (r *repl) showCost(string) error {
+	usage := r.sess.Usage
+	fmt.Println("💰 用量:")
+	fmt.Printf("  会话累计令牌: %d（输入 %d / 输出 %d）\n", usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens)
+
+	recorder := r.agent.GetTrajectoryRecorder()
+	if recorder == nil {
+		return nil
+	}
+	stats := utils.NewTrajectoryStats(nil)
+	if err := stats.AddFile(recorder.GetTrajectoryPath()); err != nil {
+		// 还没有执行过任务时轨迹文件不存在
+		return nil
+	}
+	report := stats.Report(0)
+	fmt.Printf("  本次运行: %d 次LLM调用，%d 令牌，费用 $%.4f\n", report.LLMCalls, report.TotalTokens, report.Cost)
+	if len(report.UnpricedModels) > 0 {
+		fmt.Printf("  未计价的模型: %s\n", strings.Join(report.UnpricedModels, ", "))
+	}
+	return nil
+}
+
+// saveSession 保存会话，可以同时设置名称
+func (r *repl) saveSession(args string) error {
+	if args != "" {
+		r.sess.Name = args
+	}
+	if err := saveSession(r.store, r.sess, r.agent); err != nil {
+		return err
+	}
+	fmt.Printf("💾 会话已保存: %s\n", r.sess.ID)
+	return nil
+}
+
+// loadSession 加载已保存的会话，替换当前的对话历史
+func (r *repl) loadSession(args string) error {
+	if args == "" {
+		return fmt.Errorf("usage: /load <id|name>")
+	}
+	r.save()
+
+	sess, err := openSession(r.store, r.agent, args, false)
+	if err != nil {
+		return err
+	}
+	r.sess = sess
+	r.snapshots = make([]string, 0)
+	return nil
+}
+
+// compact 让模型总结对话历史以节省上下文
+func (r *repl) compact(args string) error {
+	before := len(r.agent.GetConversationHistory())
+	ctx, stop := withInterrupt(context.Background())
+	summary, usage, err := r.agent.CompactConversationHistory(ctx, args)
+	stop()
+	r.sess.AddUsage(usage)
+	if err != nil {
+		return err
+	}
+	r.save()
+	fmt.Printf("🗜️  已将 %d 条消息压缩为总结:\n%s\n", before, summary)
+	return nil
+}
+
+// showTrajectory 显示轨迹文件路径，参数为stats时显示统计
+func (r *repl) showTrajectory(args string) error {
+	recorder := r.agent.GetTrajectoryRecorder()
+	if recorder == nil {
+		return fmt.Errorf("trajectory recording is disabled")
+	}
+	path := recorder.GetTrajectoryPath()
+	fmt.Printf("轨迹文件: %s\n", path)
+
+	switch args {
+	case "":
+		return nil
+	case "stats":
+		stats := utils.NewTrajectoryStats(nil)
+		if err := stats.AddFile(path); err != nil {
+			return err
+		}
+		printStatsReport(stats.Report(5))
+		return nil
+	default:
+		return fmt.Errorf("usage: /trajectory [stats]")
+	}
+}
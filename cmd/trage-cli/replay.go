@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// replay命令参数
+	replayStrict bool
+)
+
+// replay命令
+var replayCmd = &cobra.Command{
+	Use:   "replay <trajectory>",
+	Short: "回放轨迹",
+	Long: `使用轨迹中录制的LLM响应重新运行一次任务，工具会按当前代码真实执行。
+
+回放不访问网络，可用于复现问题和回归测试。实际请求与录制不一致时会输出差异，
+并以非零状态退出；使用 --strict 时在第一次不一致处停止。`,
+	Args: cobra.ExactArgs(1),
+	RunE: replayTrajectory,
+}
+
+func init() {
+	replayCmd.Flags().BoolVar(&replayStrict, "strict", false, "请求与录制不一致时立即停止")
+	rootCmd.AddCommand(replayCmd)
+}
+
+// replayTrajectory 回放轨迹
+func replayTrajectory(cmd *cobra.Command, args []string) error {
+	// 加载录制的轨迹
+	recorded, err := utils.LoadTrajectory(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to load trajectory: %v", err)
+	}
+
+	interactions := recorded.GetLLMInteractions()
+	if len(interactions) == 0 {
+		return fmt.Errorf("trajectory %s contains no recorded LLM calls", args[0])
+	}
+
+	taskDescription := recordedTask(recorded)
+	if taskDescription == "" {
+		return fmt.Errorf("trajectory %s does not record the task", args[0])
+	}
+
+	// 加载配置，回放不需要API密钥，因此不做完整校验
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	// 创建回放客户端和代理
+	replayClient := llm.NewReplayClient(interactions)
+	replayClient.SetStrict(replayStrict)
+
+	factory := agent.NewAgentFactory()
+	agentInstance, err := factory.CreateAgentWithClient(agent.AgentType(agentType), recordedAgent(recorded), cfg, trajectoryFile, replayClient)
+	if err != nil {
+		return fmt.Errorf("failed to create agent: %v", err)
+	}
+
+	// 显示代理的工具
+	printTools(agentInstance)
+
+	// 系统提示中包含日期和Git状态，回放时使用录制的系统提示
+	if traeAgent, ok := agentInstance.(*agent.TraeAgent); ok {
+		traeAgent.SetSystemPrompt(recordedSystemPrompt(interactions))
+	}
+
+	// 设置工作目录
+	if workingDir != "" {
+		if err := os.Chdir(workingDir); err != nil {
+			return fmt.Errorf("failed to change working directory: %v", err)
+		}
+	}
+
+	// 创建控制台
+	cliConsole, err := newCLIConsole(agentInstance)
+	if err != nil {
+		return err
+	}
+	cliConsole.Print(fmt.Sprintf("🔁 回放轨迹: %s（%d 次LLM调用）", args[0], len(interactions)))
+
+	// 运行代理
+	execution, err := runWithConsole(cliConsole, agentInstance, taskDescription, nil)
+	if err != nil {
+		return fmt.Errorf("agent execution failed: %v", err)
+	}
+
+	// 输出与录制的对比
+	if original := recorded.GetExecution(); original != nil && original.Success != execution.Success {
+		fmt.Printf("⚠️  结果与录制不同: 录制成功=%t, 回放成功=%t\n", original.Success, execution.Success)
+	}
+	if remaining := replayClient.Remaining(); remaining > 0 {
+		fmt.Printf("⚠️  还有 %d 次录制的LLM调用未被使用\n", remaining)
+	}
+	printTrajectoryPath(cliConsole, agentInstance)
+
+	// 报告请求差异
+	divergences := replayClient.GetDivergences()
+	if len(divergences) == 0 {
+		fmt.Println("所有LLM请求与录制一致")
+		return nil
+	}
+	for _, divergence := range divergences {
+		fmt.Printf("\n第 %d 次LLM调用的请求与录制不一致:\n%s", divergence.Index+1, divergence.Diff)
+	}
+	return fmt.Errorf("replay diverged in %d of %d LLM calls", len(divergences), len(interactions))
+}
+
+// recordedSystemPrompt 获取第一次LLM调用中的系统提示，没有时返回空字符串
+func recordedSystemPrompt(interactions []llm.LLMInteraction) string {
+	for _, message := range interactions[0].InputMessages {
+		if message.Role == "system" {
+			return message.Content
+		}
+	}
+	return ""
+}
+
+// recordedAgent 获取录制轨迹时使用的代理名称，--agent和TRAE_AGENT优先，旧轨迹没有记录时使用当前选择的代理
+func recordedAgent(recorded *utils.TrajectoryRecorder) string {
+	if rootCmd.PersistentFlags().Changed("agent") || os.Getenv(config.EnvAgent) != "" {
+		return agentName
+	}
+	if value, ok := recorded.GetMetadata("agent"); ok {
+		if name, ok := value.(string); ok && name != "" {
+			return name
+		}
+	}
+	return agentName
+}
+
+// recordedTask 从轨迹中取出任务描述
+func recordedTask(recorded *utils.TrajectoryRecorder) string {
+	if value, ok := recorded.GetMetadata("task"); ok {
+		if task, ok := value.(string); ok && task != "" {
+			return task
+		}
+	}
+
+	// 旧轨迹没有任务元数据时使用第一条用户消息
+	for _, message := range recorded.GetMessages() {
+		if message.Role == "user" {
+			return message.Content
+		}
+	}
+	return ""
+}
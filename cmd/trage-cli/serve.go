@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/server"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// serve命令参数
+	serveAddr          string
+	serveWorkspaces    string
+	serveMaxConcurrent int
+	serveAllowLocal    bool
+	serveToken         string
+	serveRetention     time.Duration
+)
+
+// envAPIToken 未指定--token时使用的API令牌环境变量
+const envAPIToken = "TRAE_API_TOKEN"
+
+// serve命令
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "启动HTTP API服务",
+	Long: `启动REST API服务，通过HTTP提交任务、查询状态、以SSE订阅步骤事件、取消任务，
+以及下载轨迹和补丁。每个任务使用独立的代理实例和工作目录。
+
+接口：
+  POST   /api/tasks                  提交任务 {"task": "...", "repo": "...", "base_commit": "..."}
+  GET    /api/tasks                  列出任务
+  GET    /api/tasks/{id}             查询任务状态
+  POST   /api/tasks/{id}/cancel      取消任务
+  GET    /api/tasks/{id}/events      以SSE推送步骤事件
+  GET    /api/tasks/{id}/trajectory  下载轨迹（?format=json|yaml|md|html|txt）
+  GET    /api/tasks/{id}/patch       下载补丁
+  GET    /healthz                    健康检查
+  GET    /metrics                    Prometheus指标
+
+默认只监听本机地址。指定--token或TRAE_API_TOKEN后，除/healthz外的请求都需要携带
+Authorization: Bearer <token>。已结束的任务在--retention之后连同工作目录一起删除。`,
+	Args: cobra.NoArgs,
+	RunE: serve,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "监听地址")
+	serveCmd.Flags().StringVar(&serveWorkspaces, "workspaces", "workspaces", "任务工作目录和轨迹的根目录")
+	serveCmd.Flags().IntVar(&serveMaxConcurrent, "max-concurrent", 4, "同时运行的最大任务数")
+	serveCmd.Flags().BoolVar(&serveAllowLocal, "allow-local-repos", false, "允许任务克隆file://和服务器本地路径的仓库")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "API的Bearer令牌（默认使用"+envAPIToken+"环境变量）")
+	serveCmd.Flags().DurationVar(&serveRetention, "retention", 24*time.Hour, "已结束任务的保留时长，为0时不删除")
+	rootCmd.AddCommand(serveCmd)
+}
+
+// serve 启动HTTP API服务，收到SIGINT或SIGTERM时优雅退出
+func serve(cmd *cobra.Command, args []string) error {
+	// 加载配置
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	// 验证配置
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("config validation failed: %v", err)
+	}
+
+	token := serveToken
+	if token == "" {
+		token = os.Getenv(envAPIToken)
+	}
+	if token == "" && !isLoopbackAddr(serveAddr) {
+		fmt.Printf("⚠️  API服务监听在 %s 且未设置令牌，任何能访问该地址的人都可以提交任务\n", serveAddr)
+	}
+
+	factory := agent.NewAgentFactory()
+	apiServer, err := server.NewServer(server.Options{
+		NewAgent: func(trajectoryFile string) (agent.Agent, error) {
+			return factory.CreateAgent(agent.AgentType(agentType), agentName, cfg, trajectoryFile)
+		},
+		WorkspaceRoot:   serveWorkspaces,
+		MaxConcurrent:   serveMaxConcurrent,
+		AllowLocalRepos: serveAllowLocal,
+		Token:           token,
+		Retention:       serveRetention,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create server: %v", err)
+	}
+
+	httpServer := &http.Server{
+		Addr:              serveAddr,
+		Handler:           apiServer.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		fmt.Printf("API服务已启动: %s\n", serveAddr)
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("server failed: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	fmt.Println("正在关闭API服务...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// 先取消任务，使SSE连接随任务结束而关闭
+	if err := apiServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to stop tasks: %w", err)
+	}
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+	return nil
+}
+
+// isLoopbackAddr 检查监听地址是否只在本机可访问
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
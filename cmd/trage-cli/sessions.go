@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/session"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// 会话参数
+	sessionsDir   string
+	sessionName   string
+	resumeSession string
+	continueLast  bool
+)
+
+// sessions命令
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "管理会话",
+	Long: `管理interactive和run保存的会话。会话包含对话历史、工具状态、工作目录、模型和令牌用量，
+可以通过 interactive --resume <id> 或 run --continue 恢复并继续工作。`,
+}
+
+// sessions list命令
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出会话",
+	Args:  cobra.NoArgs,
+	RunE:  listSessions,
+}
+
+// sessions show命令
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show <id|name>",
+	Short: "显示会话详情",
+	Args:  cobra.ExactArgs(1),
+	RunE:  showSession,
+}
+
+// sessions delete命令
+var sessionsDeleteCmd = &cobra.Command{
+	Use:   "delete <id|name>...",
+	Short: "删除会话",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  deleteSessions,
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&sessionsDir, "sessions-dir", "", "会话目录（默认~/.trae/sessions）")
+
+	interactiveCmd.Flags().StringVar(&resumeSession, "resume", "", "恢复指定ID或名称的会话")
+	interactiveCmd.Flags().StringVar(&sessionName, "session-name", "", "新会话的名称")
+	runCmd.Flags().BoolVar(&continueLast, "continue", false, "在最近的会话基础上继续")
+	runCmd.Flags().StringVar(&sessionName, "session-name", "", "新会话的名称")
+
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsShowCmd, sessionsDeleteCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
+
+// listSessions 列出会话
+func listSessions(cmd *cobra.Command, args []string) error {
+	store := session.NewStore(sessionsDir)
+	sessions, warnings, err := store.List()
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %v", err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "⚠️  跳过会话文件: %v\n", warning)
+	}
+	if len(sessions) == 0 {
+		fmt.Printf("%s 中没有会话\n", store.Dir())
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\t更新时间\t任务数\t消息数\t令牌数\t标题")
+	for _, sess := range sessions {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%d\t%s\n",
+			sess.ID, sess.UpdatedAt.Format("2006-01-02 15:04"), len(sess.Tasks), len(sess.Messages),
+			sess.Usage.TotalTokens, truncateLine(sess.Title(), 60))
+	}
+	return writer.Flush()
+}
+
+// showSession 显示会话详情
+func showSession(cmd *cobra.Command, args []string) error {
+	sess, err := session.NewStore(sessionsDir).Load(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to load session: %v", err)
+	}
+
+	fmt.Printf("ID:       %s\n", sess.ID)
+	if sess.Name != "" {
+		fmt.Printf("名称:     %s\n", sess.Name)
+	}
+	fmt.Printf("创建时间: %s\n", sess.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("更新时间: %s\n", sess.UpdatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("工作目录: %s\n", sess.WorkingDir)
+	if sess.Model != "" {
+		fmt.Printf("模型:     %s (%s)\n", sess.Model, sess.Provider)
+	}
+	fmt.Printf("令牌用量: %d (输入 %d，输出 %d)\n", sess.Usage.TotalTokens, sess.Usage.PromptTokens, sess.Usage.CompletionTokens)
+
+	fmt.Println("\n任务:")
+	for i, task := range sess.Tasks {
+		fmt.Printf("  %d. %s\n", i+1, truncateLine(task, 100))
+	}
+
+	fmt.Printf("\n对话历史 (%d 条消息):\n", len(sess.Messages))
+	for _, message := range sess.Messages {
+		content := message.Content
+		if len(message.ToolCalls) > 0 {
+			names := make([]string, 0, len(message.ToolCalls))
+			for _, toolCall := range message.ToolCalls {
+				names = append(names, toolCall.Function.Name)
+			}
+			content = strings.TrimSpace(content + " [调用工具: " + strings.Join(names, ", ") + "]")
+		}
+		fmt.Printf("  [%s] %s\n", message.Role, truncateLine(content, 100))
+	}
+	return nil
+}
+
+// deleteSessions 删除会话
+func deleteSessions(cmd *cobra.Command, args []string) error {
+	store := session.NewStore(sessionsDir)
+	for _, ref := range args {
+		sess, err := store.Delete(ref)
+		if err != nil {
+			return fmt.Errorf("failed to delete session: %v", err)
+		}
+		fmt.Printf("已删除会话 %s\n", sess.ID)
+	}
+	return nil
+}
+
+// openSession 恢复ref指定的会话（latest为true时恢复最近的会话），否则创建新会话
+func openSession(store *session.Store, agentInstance agent.Agent, ref string, latest bool) (*session.Session, error) {
+	if ref == "" && !latest {
+		return session.New(sessionName)
+	}
+
+	var sess *session.Session
+	var err error
+	if latest {
+		sess, err = store.Latest()
+	} else {
+		sess, err = store.Load(ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %v", err)
+	}
+	if err := restoreSession(agentInstance, sess); err != nil {
+		return nil, err
+	}
+	fmt.Printf("🔁 已恢复会话 %s（%d 个任务，%d 条消息）\n", sess.ID, len(sess.Tasks), len(sess.Messages))
+	return sess, nil
+}
+
+// restoreSession 将会话的对话历史和工具状态恢复到代理，未指定--working-dir时切换到会话的工作目录
+func restoreSession(agentInstance agent.Agent, sess *session.Session) error {
+	traeAgent, ok := agentInstance.(*agent.TraeAgent)
+	if !ok {
+		return fmt.Errorf("agent type %T does not support sessions", agentInstance)
+	}
+
+	traeAgent.SetConversationHistory(sess.Messages)
+	if err := traeAgent.RestoreToolStates(sess.ToolState); err != nil {
+		return fmt.Errorf("failed to restore session: %v", err)
+	}
+
+	if workingDir == "" && sess.WorkingDir != "" {
+		if err := os.Chdir(sess.WorkingDir); err != nil {
+			return fmt.Errorf("failed to change to session working directory: %v", err)
+		}
+	}
+
+	if modelConfig := traeAgent.GetModelConfig(); modelConfig != nil && sess.Model != "" && sess.Model != modelConfig.Model {
+		fmt.Printf("⚠️  会话原先使用模型 %s，现在使用 %s\n", sess.Model, modelConfig.Model)
+	}
+	return nil
+}
+
+// saveSession 将代理的对话历史、工具状态和当前工作目录写入会话并保存
+func saveSession(store *session.Store, sess *session.Session, agentInstance agent.Agent) error {
+	traeAgent, ok := agentInstance.(*agent.TraeAgent)
+	if !ok {
+		return fmt.Errorf("agent type %T does not support sessions", agentInstance)
+	}
+
+	sess.Messages = traeAgent.GetConversationHistory()
+	toolState, err := traeAgent.SaveToolStates()
+	if err != nil {
+		return err
+	}
+	sess.ToolState = toolState
+	if modelConfig := traeAgent.GetModelConfig(); modelConfig != nil {
+		sess.Model = modelConfig.Model
+		sess.Provider = modelConfig.ModelProvider
+	}
+	if dir, err := os.Getwd(); err == nil {
+		sess.WorkingDir = dir
+	}
+	return store.Save(sess)
+}
+
+// recordSessionTask 记录任务和令牌用量后保存会话，失败时只输出警告
+func recordSessionTask(store *session.Store, sess *session.Session, agentInstance agent.Agent, task string, execution *agent.AgentExecution) {
+	sess.Tasks = append(sess.Tasks, task)
+	if execution != nil {
+		if usage, ok := execution.Metadata["usage"].(llm.Usage); ok {
+			sess.AddUsage(usage)
+		}
+	}
+	if err := saveSession(store, sess, agentInstance); err != nil {
+		fmt.Printf("⚠️  保存会话失败: %v\n", err)
+	}
+}
+
+// truncateLine 将文本压缩为一行并截断到maxChars个字符
+func truncateLine(text string, maxChars int) string {
+	line := strings.Join(strings.Fields(text), " ")
+	runes := []rune(line)
+	if len(runes) <= maxChars {
+		return line
+	}
+	return string(runes[:maxChars]) + "..."
+}
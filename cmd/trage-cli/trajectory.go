@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"trage-agent-go/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// trajectory命令
+var trajectoryCmd = &cobra.Command{
+	Use:   "trajectory",
+	Short: "轨迹文件工具",
+	Long:  "查看和处理代理执行时生成的轨迹文件",
+}
+
+// trajectory convert命令
+var trajectoryConvertCmd = &cobra.Command{
+	Use:   "convert <input.json> <output.jsonl>",
+	Short: "将旧版JSON轨迹转换为JSONL",
+	Long:  "将旧版单文档JSON轨迹转换为逐行追加的JSONL事件格式",
+	Args:  cobra.ExactArgs(2),
+	RunE:  convertTrajectory,
+}
+
+var (
+	// trajectory export命令参数
+	exportFormat string
+	exportOutput string
+)
+
+// trajectory export命令
+var trajectoryExportCmd = &cobra.Command{
+	Use:   "export <trajectory>",
+	Short: "导出轨迹",
+	Long: `将轨迹导出为其他格式：
+  json      旧版单文档JSON
+  yaml      与JSON结构相同的YAML
+  md        适合粘贴到PR描述的Markdown摘要
+  html      可离线查看的HTML报告
+  txt       纯文本`,
+	Args: cobra.ExactArgs(1),
+	RunE: exportTrajectory,
+}
+
+var (
+	// trajectory stats命令参数
+	statsPriceFile string
+	statsJSON      bool
+	statsTop       int
+)
+
+// trajectory stats命令
+var trajectoryStatsCmd = &cobra.Command{
+	Use:   "stats <files or dirs...>",
+	Short: "统计轨迹",
+	Long: `汇总一个或多个轨迹的统计信息：每个任务的步数、工具使用频率和失败率、
+令牌用量和费用、LLM与工具的耗时、最常见的错误以及重复执行的命令。
+
+参数可以是轨迹文件或目录，目录会递归查找 .json 和 .jsonl 文件。
+费用按内置价格计算，可用 --prices 指定YAML价格文件覆盖（美元/百万令牌）：
+  gpt-4o: {input: 2.5, output: 10}`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: trajectoryStats,
+}
+
+func init() {
+	trajectoryStatsCmd.Flags().StringVar(&statsPriceFile, "prices", "", "模型价格文件（YAML）")
+	trajectoryStatsCmd.Flags().BoolVar(&statsJSON, "json", false, "以JSON格式输出")
+	trajectoryStatsCmd.Flags().IntVar(&statsTop, "top", 10, "显示的常见错误条数")
+	trajectoryCmd.AddCommand(trajectoryStatsCmd)
+
+	trajectoryExportCmd.Flags().StringVarP(&exportFormat, "format", "f", "md", "导出格式（"+strings.Join(utils.ExportFormats(), "、")+"）")
+	trajectoryExportCmd.Flags().StringVar(&exportOutput, "output", "", "输出文件路径，默认与轨迹文件同名")
+
+	trajectoryCmd.AddCommand(trajectoryConvertCmd, trajectoryExportCmd)
+	rootCmd.AddCommand(trajectoryCmd)
+}
+
+// convertTrajectory 转换轨迹文件格式
+func convertTrajectory(cmd *cobra.Command, args []string) error {
+	count, err := utils.ConvertJSONToJSONL(args[0], args[1])
+	if err != nil {
+		return fmt.Errorf("failed to convert trajectory: %v", err)
+	}
+
+	fmt.Printf("已转换 %d 个事件: %s\n", count, args[1])
+	return nil
+}
+
+// exportTrajectory 导出轨迹文件
+func exportTrajectory(cmd *cobra.Command, args []string) error {
+	recorder, err := utils.LoadTrajectory(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to load trajectory: %v", err)
+	}
+
+	output := exportOutput
+	if output == "" {
+		output = strings.TrimSuffix(args[0], filepath.Ext(args[0])) + "." + exportFormat
+		if output == args[0] {
+			return fmt.Errorf("output path would overwrite the trajectory, use --output")
+		}
+	}
+
+	if err := recorder.ExportToFormat(exportFormat, output); err != nil {
+		return fmt.Errorf("failed to export trajectory: %v", err)
+	}
+
+	fmt.Printf("已导出: %s\n", output)
+	return nil
+}
+
+// trajectoryStats 汇总轨迹统计
+func trajectoryStats(cmd *cobra.Command, args []string) error {
+	prices, err := utils.LoadModelPrices(statsPriceFile)
+	if err != nil {
+		return err
+	}
+
+	files, err := collectTrajectoryFiles(args)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no trajectory files found")
+	}
+
+	stats := utils.NewTrajectoryStats(prices)
+	for _, file := range files {
+		// 单个文件损坏不影响整体统计，错误记录在报告中
+		_ = stats.AddFile(file)
+	}
+	report := stats.Report(statsTop)
+
+	if statsJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printStatsReport(report)
+	return nil
+}
+
+// collectTrajectoryFiles 展开参数中的目录
+func collectTrajectoryFiles(args []string) ([]string, error) {
+	files := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(path string) {
+		// 同一文件可能既被直接指定又在目录中出现
+		if abs, err := filepath.Abs(path); err == nil && !seen[abs] {
+			seen[abs] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to access %s: %v", arg, err)
+		}
+		if !info.IsDir() {
+			add(arg)
+			continue
+		}
+
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := filepath.Ext(path)
+			if !info.IsDir() && (ext == ".json" || ext == ".jsonl") {
+				add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %v", arg, err)
+		}
+	}
+	return files, nil
+}
+
+// printStatsReport 输出统计报告
+func printStatsReport(report *utils.StatsReport) {
+	fmt.Println("=== 轨迹统计 ===")
+	fmt.Printf("轨迹数: %d（成功 %d，失败 %d，未完成 %d）\n",
+		report.Trajectories, report.Succeeded, report.Failed, report.Incomplete)
+	fmt.Printf("每个任务的步数: 最少 %d，最多 %d，平均 %.1f，中位数 %.1f\n",
+		report.Steps.Min, report.Steps.Max, report.Steps.Mean, report.Steps.Median)
+
+	fmt.Println("\n用量:")
+	fmt.Printf("  LLM调用: %d\n", report.LLMCalls)
+	fmt.Printf("  令牌: %d（输入 %d / 输出 %d）\n", report.TotalTokens, report.PromptTokens, report.CompletionTokens)
+	fmt.Printf("  费用: $%.4f\n", report.Cost)
+	if len(report.UnpricedModels) > 0 {
+		fmt.Printf("  未计价的模型: %s\n", strings.Join(report.UnpricedModels, ", "))
+	}
+
+	total := report.LLMTime + report.ToolTime
+	fmt.Println("\n耗时:")
+	fmt.Printf("  LLM: %s（%s）\n", report.LLMTime.Round(time.Second), percent(report.LLMTime, total))
+	fmt.Printf("  工具: %s（%s）\n", report.ToolTime.Round(time.Second), percent(report.ToolTime, total))
+
+	if len(report.Tools) > 0 {
+		fmt.Println("\n工具:")
+		fmt.Printf("  %-24s %8s %8s %8s %12s\n", "名称", "调用", "失败", "失败率", "耗时")
+		for _, tool := range report.Tools {
+			fmt.Printf("  %-24s %8d %8d %7.1f%% %12s\n",
+				tool.Name, tool.Calls, tool.Failures, tool.FailureRate*100, tool.Duration.Round(time.Millisecond))
+		}
+	}
+
+	if len(report.TopErrors) > 0 {
+		fmt.Println("\n常见错误:")
+		for _, entry := range report.TopErrors {
+			fmt.Printf("  %5d  %s\n", entry.Count, entry.Message)
+		}
+	}
+
+	if len(report.Loops) > 0 {
+		fmt.Println("\n重复执行的命令:")
+		for _, loop := range report.Loops {
+			args := loop.Args
+			if runes := []rune(args); len(runes) > 100 {
+				args = string(runes[:100]) + "..."
+			}
+			fmt.Printf("  %3d×  %s %s  (%s)\n", loop.Repeats, loop.Tool, args, loop.File)
+		}
+	}
+
+	if len(report.LoadErrors) > 0 {
+		fmt.Println("\n无法读取的文件:")
+		for path, message := range report.LoadErrors {
+			fmt.Printf("  %s: %s\n", path, message)
+		}
+	}
+}
+
+// percent 计算占比
+func percent(part, total time.Duration) string {
+	if total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.0f%%", float64(part)/float64(total)*100)
+}
@@ -5,6 +5,8 @@ go 1.21
 require (
 	github.com/sashabaranov/go-openai v1.41.1
 	github.com/spf13/cobra v1.8.0
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/sys v0.4.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
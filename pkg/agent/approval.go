@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/llm"
+)
+
+// Approver 在执行工具调用前请求用户批准
+type Approver interface {
+	// Approve 返回是否执行toolCall，返回错误时按拒绝处理
+	Approve(ctx context.Context, toolCall llm.ToolCall) (bool, error)
+}
+
+// readOnlyTools 不会修改工作区的工具，ask策略下执行前不需要批准
+var readOnlyTools = map[string]bool{
+	"sequential_thinking": true,
+	"task_done":           true,
+	"ckg":                 true,
+}
+
+// SetApprover 设置工具调用的审批者，approval_policy为ask时使用
+func (ba *BaseAgent) SetApprover(approver Approver) {
+	ba.approver = approver
+}
+
+// requiresApproval 按代理的审批策略判断执行toolName前是否需要批准
+func (ba *BaseAgent) requiresApproval(toolName string) bool {
+	return ba.config != nil && ba.config.ApprovalPolicy == config.ApprovalAsk && !readOnlyTools[toolName]
+}
+
+// approveToolCall 检查工具调用是否可以执行，不能执行时返回原因
+func (ba *BaseAgent) approveToolCall(ctx context.Context, toolCall llm.ToolCall) (bool, string) {
+	if !ba.requiresApproval(toolCall.Function.Name) {
+		return true, ""
+	}
+	if ba.approver == nil {
+		return false, "tool call requires approval (approval_policy: ask) but no approver is available"
+	}
+
+	approved, err := ba.approver.Approve(ctx, toolCall)
+	if err != nil {
+		return false, fmt.Sprintf("tool call was not approved: %v", err)
+	}
+	if !approved {
+		return false, "tool call was rejected by the user"
+	}
+	return true, ""
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/tools"
+)
+
+// scriptedApprover 按顺序返回预设结果的测试审批者
+type scriptedApprover struct {
+	answers []bool
+	asked   []string
+}
+
+func (a *scriptedApprover) Approve(ctx context.Context, toolCall llm.ToolCall) (bool, error) {
+	a.asked = append(a.asked, toolCall.Function.Name)
+	answer := a.answers[0]
+	a.answers = a.answers[1:]
+	return answer, nil
+}
+
+func TestTraeAgent_ApprovalPolicy(t *testing.T) {
+	echoCall := llm.ToolCall{
+		ID:       "call_1",
+		Type:     "function",
+		Function: llm.ToolCallFunction{Name: "echo", Arguments: map[string]interface{}{"text": "hello"}},
+	}
+	thinkCall := llm.ToolCall{
+		ID:       "call_2",
+		Type:     "function",
+		Function: llm.ToolCallFunction{Name: "sequential_thinking", Arguments: map[string]interface{}{"thought": "plan", "thought_number": 1, "total_thoughts": 1, "next_thought_needed": false}},
+	}
+	newScript := func() []llm.LLMInteraction {
+		return []llm.LLMInteraction{
+			{Response: &llm.LLMMessage{Role: "assistant", ToolCalls: []llm.ToolCall{echoCall, thinkCall}}},
+			{Response: &llm.LLMMessage{Role: "assistant", Content: "任务完成"}},
+		}
+	}
+	newAskAgent := func(t *testing.T) (*TraeAgent, *echoTool) {
+		agent, tool := newReplayAgent(t, llm.NewReplayClient(newScript()), filepath.Join(t.TempDir(), "approval.jsonl"))
+		agent.config.ApprovalPolicy = config.ApprovalAsk
+		agent.AddTool(tools.NewSequentialThinkingTool())
+		return agent, tool
+	}
+
+	// 没有审批者时拒绝会修改工作区的工具，并把原因返回给LLM
+	agent, tool := newAskAgent(t)
+	execution, err := agent.Run(context.Background(), "say hello", nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tool.calls != 0 {
+		t.Errorf("Expected echo not to run without an approver, got %d calls", tool.calls)
+	}
+	if len(execution.ToolResults) != 2 {
+		t.Fatalf("Expected 2 tool results, got %d", len(execution.ToolResults))
+	}
+	denied := execution.ToolResults[0]
+	if denied.Success || !strings.Contains(denied.Result, "no approver") {
+		t.Errorf("Expected denied result explaining the missing approver, got %+v", denied)
+	}
+	// 只读工具不需要批准
+	if !execution.ToolResults[1].Success {
+		t.Errorf("Expected sequential_thinking to run without approval, got %+v", execution.ToolResults[1])
+	}
+
+	// 审批者拒绝时不执行，批准时执行
+	for _, approved := range []bool{false, true} {
+		agent, tool = newAskAgent(t)
+		approver := &scriptedApprover{answers: []bool{approved}}
+		agent.SetApprover(approver)
+		if _, err := agent.Run(context.Background(), "say hello", nil, nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if strings.Join(approver.asked, ",") != "echo" {
+			t.Errorf("Expected approval to be requested for echo only, got %v", approver.asked)
+		}
+		if expected := map[bool]int{false: 0, true: 1}[approved]; tool.calls != expected {
+			t.Errorf("Expected %d echo calls when approved=%v, got %d", expected, approved, tool.calls)
+		}
+	}
+
+	// auto策略不询问
+	agent, tool = newReplayAgent(t, llm.NewReplayClient(newScript()), filepath.Join(t.TempDir(), "auto.jsonl"))
+	agent.AddTool(tools.NewSequentialThinkingTool())
+	if _, err := agent.Run(context.Background(), "say hello", nil, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tool.calls != 1 {
+		t.Errorf("Expected echo to run with the auto policy, got %d calls", tool.calls)
+	}
+}
+
+func TestTraeAgent_SystemPromptTemplate(t *testing.T) {
+	agent, _ := newReplayAgent(t, llm.NewReplayClient(nil), filepath.Join(t.TempDir(), "prompt.jsonl"))
+	agent.config.Name = "triage"
+	agent.config.SystemPrompt = "You are {{.Agent}}.{{range .Tools}} [{{.Name}}]{{end}}"
+
+	prompt, err := agent.buildSystemPrompt()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if prompt != "You are triage. [echo]" {
+		t.Errorf("Expected rendered prompt, got %q", prompt)
+	}
+
+	// 渲染失败时任务不会开始
+	agent.config.SystemPrompt = "{{.Missing}}"
+	if _, err := agent.buildSystemPrompt(); err == nil || !strings.Contains(err.Error(), "failed to render system prompt") {
+		t.Errorf("Expected render error, got %v", err)
+	}
+}
@@ -2,12 +2,14 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"trage-agent-go/pkg/config"
 	"trage-agent-go/pkg/llm"
 	"trage-agent-go/pkg/tools"
+	"trage-agent-go/pkg/utils"
 )
 
 // AgentType 代理类型
@@ -32,6 +34,7 @@ type AgentExecution struct {
 	Success     bool                   `json:"success"`
 	Output      string                 `json:"output,omitempty"`
 	Error       string                 `json:"error,omitempty"`
+	Cancelled   bool                   `json:"cancelled,omitempty"`
 	Steps       []ExecutionStep        `json:"steps"`
 	Duration    time.Duration          `json:"duration"`
 	ToolResults []*tools.ToolResult    `json:"tool_results,omitempty"`
@@ -64,8 +67,29 @@ type Agent interface {
 	// SetTrajectoryRecorder 设置轨迹记录器
 	SetTrajectoryRecorder(recorder llm.TrajectoryRecorder)
 
+	// GetTrajectoryRecorder 获取轨迹记录器
+	GetTrajectoryRecorder() llm.TrajectoryRecorder
+
+	// SetCLIConsole 设置控制台
+	SetCLIConsole(console Console)
+
+	// SetWorkingDir 设置工具的工作目录
+	SetWorkingDir(dir string)
+
+	// SetMaxSteps 设置最大步数
+	SetMaxSteps(maxSteps int)
+
 	// GetConfig 获取配置
 	GetConfig() *config.AgentConfig
+
+	// SetApprover 设置工具调用的审批者
+	SetApprover(approver Approver)
+
+	// AddTool 添加工具，如MCP服务器提供的工具
+	AddTool(tool tools.Tool)
+
+	// GetTools 获取代理的所有工具
+	GetTools() []tools.Tool
 }
 
 // BaseAgent 基础代理实现
@@ -77,10 +101,15 @@ type BaseAgent struct {
 	tools              []tools.Tool
 	toolRegistry       *tools.ToolRegistry
 	trajectoryRecorder llm.TrajectoryRecorder
+	console            Console
+	workingDir         string
 	executionTracker   *tools.ToolExecutionTracker
 	stepCount          int
 	maxSteps           int
 	task               string // 存储当前任务内容
+	steps              []ExecutionStep
+	keepToolState      bool // 恢复会话后的第一个任务不重置工具状态
+	approver           Approver
 }
 
 // NewBaseAgent 创建基础代理
@@ -92,8 +121,10 @@ func NewBaseAgent(agentType AgentType, config *config.AgentConfig, modelConfig *
 		llmClient:        llmClient,
 		tools:            make([]tools.Tool, 0),
 		toolRegistry:     tools.NewToolRegistry(),
+		console:          printConsole{},
 		executionTracker: tools.NewToolExecutionTracker(),
 		maxSteps:         config.MaxSteps,
+		steps:            make([]ExecutionStep, 0),
 	}
 }
 
@@ -102,18 +133,76 @@ func (ba *BaseAgent) GetConfig() *config.AgentConfig {
 	return ba.config
 }
 
+// SetModel 切换模型配置和LLM客户端，新客户端沿用当前的轨迹记录器
+func (ba *BaseAgent) SetModel(modelConfig *config.ModelConfig, llmClient llm.LLMClient) {
+	ba.modelConfig = modelConfig
+	ba.llmClient = llmClient
+	if llmClient != nil && ba.trajectoryRecorder != nil {
+		llmClient.SetTrajectoryRecorder(ba.trajectoryRecorder)
+	}
+}
+
+// GetModelConfig 获取模型配置
+func (ba *BaseAgent) GetModelConfig() *config.ModelConfig {
+	return ba.modelConfig
+}
+
 // SetTrajectoryRecorder 设置轨迹记录器
 func (ba *BaseAgent) SetTrajectoryRecorder(recorder llm.TrajectoryRecorder) {
 	ba.trajectoryRecorder = recorder
 	if ba.llmClient != nil {
 		ba.llmClient.SetTrajectoryRecorder(recorder)
 	}
+	for _, tool := range ba.tools {
+		if aware, ok := tool.(tools.TrajectoryAwareTool); ok {
+			aware.SetTrajectoryRecorder(recorder)
+		}
+	}
+}
+
+// SetCLIConsole 设置控制台，为nil时只输出普通消息
+func (ba *BaseAgent) SetCLIConsole(console Console) {
+	if console == nil {
+		console = printConsole{}
+	}
+	ba.console = console
+}
+
+// GetCLIConsole 获取控制台
+func (ba *BaseAgent) GetCLIConsole() Console {
+	return ba.console
+}
+
+// SetWorkingDir 设置工具的工作目录，为空时使用进程的当前目录
+func (ba *BaseAgent) SetWorkingDir(dir string) {
+	ba.workingDir = dir
+	for _, tool := range ba.tools {
+		if aware, ok := tool.(tools.WorkspaceAwareTool); ok {
+			aware.SetWorkingDir(dir)
+		}
+	}
+}
+
+// GetWorkingDir 获取工具的工作目录
+func (ba *BaseAgent) GetWorkingDir() string {
+	return ba.workingDir
+}
+
+// GetTrajectoryRecorder 获取轨迹记录器
+func (ba *BaseAgent) GetTrajectoryRecorder() llm.TrajectoryRecorder {
+	return ba.trajectoryRecorder
 }
 
 // AddTool 添加工具
 func (ba *BaseAgent) AddTool(tool tools.Tool) {
 	ba.tools = append(ba.tools, tool)
 	ba.toolRegistry.Register(tool)
+	if aware, ok := tool.(tools.TrajectoryAwareTool); ok && ba.trajectoryRecorder != nil {
+		aware.SetTrajectoryRecorder(ba.trajectoryRecorder)
+	}
+	if aware, ok := tool.(tools.WorkspaceAwareTool); ok && ba.workingDir != "" {
+		aware.SetWorkingDir(ba.workingDir)
+	}
 }
 
 // GetTools 获取工具列表
@@ -121,6 +210,39 @@ func (ba *BaseAgent) GetTools() []tools.Tool {
 	return ba.tools
 }
 
+// SaveToolStates 导出有状态工具的状态，键为工具名称
+func (ba *BaseAgent) SaveToolStates() (map[string]json.RawMessage, error) {
+	states := make(map[string]json.RawMessage)
+	for _, tool := range ba.tools {
+		if stateful, ok := tool.(tools.StatefulTool); ok {
+			state, err := stateful.SaveState()
+			if err != nil {
+				return nil, fmt.Errorf("failed to save state of tool %s: %w", tool.GetName(), err)
+			}
+			states[tool.GetName()] = state
+		}
+	}
+	return states, nil
+}
+
+// RestoreToolStates 恢复SaveToolStates导出的工具状态，忽略未注册的工具，
+// 恢复的状态在下一个任务开始时不会被重置
+func (ba *BaseAgent) RestoreToolStates(states map[string]json.RawMessage) error {
+	for _, tool := range ba.tools {
+		state, exists := states[tool.GetName()]
+		if !exists {
+			continue
+		}
+		if stateful, ok := tool.(tools.StatefulTool); ok {
+			if err := stateful.RestoreState(state); err != nil {
+				return fmt.Errorf("failed to restore state of tool %s: %w", tool.GetName(), err)
+			}
+		}
+	}
+	ba.keepToolState = true
+	return nil
+}
+
 // GetToolRegistry 获取工具注册表
 func (ba *BaseAgent) GetToolRegistry() *tools.ToolRegistry {
 	return ba.toolRegistry
@@ -128,29 +250,53 @@ func (ba *BaseAgent) GetToolRegistry() *tools.ToolRegistry {
 
 // NewTask 创建新任务
 func (ba *BaseAgent) NewTask(task string, extraArgs map[string]string, toolNames []string) error {
+	// 指定的工具必须是代理已有的工具，否则过滤会静默地去掉它
+	for _, name := range toolNames {
+		if !ba.hasTool(name) {
+			return &AgentError{
+				Message: fmt.Sprintf("tool '%s' is not available to this agent", name),
+				Code:    400,
+			}
+		}
+	}
+
 	// 保存任务内容
 	ba.task = task
 
 	// 重置步数计数
 	ba.stepCount = 0
+	ba.steps = make([]ExecutionStep, 0)
 
-	// 如果指定了工具名称，过滤工具
-	if len(toolNames) > 0 {
-		filteredTools := make([]tools.Tool, 0)
-		for _, tool := range ba.tools {
-			for _, name := range toolNames {
-				if tool.GetName() == name {
-					filteredTools = append(filteredTools, tool)
-					break
-				}
-			}
+	// 重置有状态工具（如思考历史），刚恢复的会话保留工具状态
+	for _, tool := range ba.tools {
+		if resettable, ok := tool.(tools.ResettableTool); ok && !ba.keepToolState {
+			resettable.Reset()
+		}
+	}
+	ba.keepToolState = false
+
+	// 注册表决定发送给LLM的工具定义，指定了工具名称时只注册这些工具；
+	// 过滤只作用于本次任务，之后的任务仍可使用全部工具
+	ba.toolRegistry = tools.NewToolRegistry()
+	for _, tool := range ba.tools {
+		if len(toolNames) == 0 || containsString(toolNames, tool.GetName()) {
+			ba.toolRegistry.Register(tool)
 		}
-		ba.tools = filteredTools
 	}
 
 	return nil
 }
 
+// hasTool 检查代理是否有名为name的工具
+func (ba *BaseAgent) hasTool(name string) bool {
+	for _, tool := range ba.tools {
+		if tool.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
 // ExecuteTask 执行任务（基础实现，子类需要重写）
 func (ba *BaseAgent) ExecuteTask(ctx context.Context) (*AgentExecution, error) {
 	return nil, &AgentError{
@@ -168,6 +314,8 @@ func (ba *BaseAgent) Run(ctx context.Context, task string, extraArgs map[string]
 		return nil, err
 	}
 
+	ba.recordTaskMetadata()
+
 	// 执行任务
 	execution, err := ba.ExecuteTask(ctx)
 	if err != nil {
@@ -176,6 +324,7 @@ func (ba *BaseAgent) Run(ctx context.Context, task string, extraArgs map[string]
 
 	// 设置执行时间
 	execution.Duration = time.Since(startTime)
+	ba.recordExecution(execution)
 
 	return execution, nil
 }
@@ -184,9 +333,7 @@ func (ba *BaseAgent) Run(ctx context.Context, task string, extraArgs map[string]
 func (ba *BaseAgent) AddExecutionStep(action, input, output string, toolCall *llm.ToolCall, toolResult *tools.ToolResult) {
 	ba.stepCount++
 
-	// 这里可以添加步骤到执行历史中
-	// 具体实现取决于子类的需求
-	_ = ExecutionStep{
+	step := ExecutionStep{
 		StepNumber: ba.stepCount,
 		Action:     action,
 		Input:      input,
@@ -195,6 +342,24 @@ func (ba *BaseAgent) AddExecutionStep(action, input, output string, toolCall *ll
 		ToolResult: toolResult,
 		Timestamp:  time.Now(),
 	}
+	ba.steps = append(ba.steps, step)
+
+	if ba.trajectoryRecorder == nil {
+		return
+	}
+	record := llm.StepRecord{
+		StepNumber: step.StepNumber,
+		Action:     step.Action,
+		Input:      step.Input,
+		Output:     step.Output,
+		Timestamp:  step.Timestamp,
+	}
+	if toolCall != nil {
+		record.ToolCallID = toolCall.ID
+	}
+	if err := ba.trajectoryRecorder.RecordStep(record); err != nil {
+		ba.console.Print(fmt.Sprintf("警告: 写入轨迹失败: %v", err))
+	}
 }
 
 // CheckStepLimit 检查步数限制
@@ -218,6 +383,11 @@ func (ba *BaseAgent) GetMaxSteps() int {
 	return ba.maxSteps
 }
 
+// SetMaxSteps 设置最大步数
+func (ba *BaseAgent) SetMaxSteps(maxSteps int) {
+	ba.maxSteps = maxSteps
+}
+
 // GetTask 获取当前任务内容
 func (ba *BaseAgent) GetTask() string {
 	return ba.task
@@ -230,9 +400,104 @@ func (ba *BaseAgent) GetExecutionTracker() *tools.ToolExecutionTracker {
 
 // getExecutionSteps 获取执行步骤
 func (ba *BaseAgent) getExecutionSteps() []ExecutionStep {
-	// 这里应该返回实际的执行步骤
-	// 暂时返回空切片，后续可以实现步骤跟踪
-	return make([]ExecutionStep, 0)
+	steps := make([]ExecutionStep, len(ba.steps))
+	copy(steps, ba.steps)
+	return steps
+}
+
+// recordMessage 将消息写入轨迹
+func (ba *BaseAgent) recordMessage(message llm.LLMMessage) {
+	if ba.trajectoryRecorder == nil {
+		return
+	}
+	if err := ba.trajectoryRecorder.RecordMessage(message); err != nil {
+		ba.console.Print(fmt.Sprintf("警告: 写入轨迹失败: %v", err))
+	}
+}
+
+// recordToolCall 将工具调用写入轨迹
+func (ba *BaseAgent) recordToolCall(toolCall llm.ToolCall) {
+	if ba.trajectoryRecorder == nil {
+		return
+	}
+	if err := ba.trajectoryRecorder.RecordToolCall(toolCall); err != nil {
+		ba.console.Print(fmt.Sprintf("警告: 写入轨迹失败: %v", err))
+	}
+}
+
+// recordToolResult 将工具结果写入轨迹
+func (ba *BaseAgent) recordToolResult(toolCall llm.ToolCall, result *tools.ToolResult, duration time.Duration) {
+	if ba.trajectoryRecorder == nil || result == nil {
+		return
+	}
+	record := llm.ToolExecutionResult{
+		CallID:   result.CallID,
+		Name:     result.Name,
+		Success:  result.Success,
+		Result:   result.Result,
+		Error:    result.Error,
+		Duration: duration,
+	}
+	if err := ba.trajectoryRecorder.RecordToolResult(toolCall, record); err != nil {
+		ba.console.Print(fmt.Sprintf("警告: 写入轨迹失败: %v", err))
+	}
+}
+
+// recordError 将执行错误写入轨迹
+func (ba *BaseAgent) recordError(source, message string) {
+	if ba.trajectoryRecorder == nil {
+		return
+	}
+	if err := ba.trajectoryRecorder.RecordError(source, message); err != nil {
+		ba.console.Print(fmt.Sprintf("警告: 写入轨迹失败: %v", err))
+	}
+}
+
+// recordLakeview 将Lakeview步骤总结写入轨迹
+func (ba *BaseAgent) recordLakeview(step *LakeviewStep) {
+	if ba.trajectoryRecorder == nil {
+		return
+	}
+	record := llm.LakeviewRecord{
+		StepNumber: step.StepNumber,
+		Tags:       step.Tags,
+		Summary:    step.Summary,
+		Timestamp:  time.Now(),
+	}
+	if err := ba.trajectoryRecorder.RecordLakeview(record); err != nil {
+		ba.console.Print(fmt.Sprintf("警告: 写入轨迹失败: %v", err))
+	}
+}
+
+// recordExecution 将最终执行结果写入轨迹并保存
+func (ba *BaseAgent) recordExecution(execution *AgentExecution) {
+	if ba.trajectoryRecorder == nil || execution == nil {
+		return
+	}
+	if err := ba.trajectoryRecorder.RecordExecution(execution); err != nil {
+		ba.console.Print(fmt.Sprintf("警告: 写入轨迹失败: %v", err))
+		return
+	}
+	if err := ba.trajectoryRecorder.Save(); err != nil {
+		ba.console.Print(fmt.Sprintf("警告: 保存轨迹失败: %v", err))
+	}
+}
+
+// recordTaskMetadata 将任务信息写入轨迹元数据
+func (ba *BaseAgent) recordTaskMetadata() {
+	if ba.trajectoryRecorder == nil {
+		return
+	}
+	ba.trajectoryRecorder.AddMetadata("task", ba.task)
+	ba.trajectoryRecorder.AddMetadata("agent_type", string(ba.agentType))
+	if ba.config != nil && ba.config.Name != "" {
+		ba.trajectoryRecorder.AddMetadata("agent", ba.config.Name)
+	}
+	ba.trajectoryRecorder.AddMetadata("max_steps", ba.maxSteps)
+	if ba.modelConfig != nil {
+		ba.trajectoryRecorder.AddMetadata("model", ba.modelConfig.Model)
+		ba.trajectoryRecorder.AddMetadata("provider", ba.modelConfig.ModelProvider)
+	}
 }
 
 // TrackToolExecution 跟踪工具执行
@@ -248,14 +513,15 @@ func NewAgentFactory() *AgentFactory {
 	return &AgentFactory{}
 }
 
-// CreateAgent 创建代理
+// CreateAgent 使用agents中名为agentName的配置创建代理
 func (af *AgentFactory) CreateAgent(
 	agentType AgentType,
+	agentName string,
 	config *config.Config,
 	trajectoryFile string,
 ) (Agent, error) {
 	// 获取代理配置
-	agentConfig, err := config.GetTraeAgentConfig()
+	agentConfig, err := config.GetAgentConfig(agentName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get agent config: %w", err)
 	}
@@ -272,16 +538,104 @@ func (af *AgentFactory) CreateAgent(
 		return nil, fmt.Errorf("failed to create LLM client: %w", err)
 	}
 
+	agent, err := af.newAgent(agentType, config, agentConfig, modelConfig, llmClient, trajectoryFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// 启用Lakeview时为步骤总结单独创建客户端
+	if agentConfig.EnableLakeview {
+		if traeAgent, ok := agent.(*TraeAgent); ok {
+			lakeview, err := af.createLakeview(config, agentConfig)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create lakeview: %w", err)
+			}
+			traeAgent.SetLakeview(lakeview)
+		}
+	}
+
+	return agent, nil
+}
+
+// createLakeview 创建Lakeview，使用lakeview.model指定的模型，未指定时使用代理的模型
+func (af *AgentFactory) createLakeview(cfg *config.Config, agentConfig *config.AgentConfig) (*Lakeview, error) {
+	modelConfig, err := cfg.GetLakeviewModelConfig(agentConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lakeview model config: %w", err)
+	}
+
+	// 不设置轨迹记录器，总结调用不计入代理的LLM记录，也不影响回放
+	client, err := af.createLLMClient(modelConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lakeview LLM client: %w", err)
+	}
+
+	lakeviewConfig := cfg.Lakeview
+	return NewLakeview(client, modelConfig, &lakeviewConfig), nil
+}
+
+// CreateAgentWithClient 使用指定的LLM客户端创建代理（如回放客户端）
+func (af *AgentFactory) CreateAgentWithClient(
+	agentType AgentType,
+	agentName string,
+	config *config.Config,
+	trajectoryFile string,
+	llmClient llm.LLMClient,
+) (Agent, error) {
+	// 获取代理配置
+	agentConfig, err := config.GetAgentConfig(agentName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent config: %w", err)
+	}
+
+	// 获取模型配置
+	modelConfig, err := config.GetModelConfig(agentConfig.Model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get model config: %w", err)
+	}
+
+	return af.newAgent(agentType, config, agentConfig, modelConfig, llmClient, trajectoryFile)
+}
+
+// newAgent 根据代理类型创建代理，添加代理配置中的工具并设置轨迹记录器
+func (af *AgentFactory) newAgent(
+	agentType AgentType,
+	cfg *config.Config,
+	agentConfig *config.AgentConfig,
+	modelConfig *config.ModelConfig,
+	llmClient llm.LLMClient,
+	trajectoryFile string,
+) (Agent, error) {
 	// 根据代理类型创建具体代理
+	var agent Agent
 	switch agentType {
 	case AgentTypeTraeAgent:
-		return NewTraeAgent(agentConfig, modelConfig, llmClient), nil
+		agent = NewTraeAgent(agentConfig, modelConfig, llmClient)
 	default:
 		return nil, &AgentError{
 			Message: fmt.Sprintf("unsupported agent type: %s", agentType),
 			Code:    400,
 		}
 	}
+
+	// 按代理配置的名称创建工具，每个代理使用各自的工具实例
+	agentTools, err := tools.NewTools(agentConfig.Tools, cfg.Tools)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tools for agent '%s': %w", agentConfig.Name, err)
+	}
+	for _, tool := range agentTools {
+		agent.AddTool(tool)
+	}
+
+	// 创建轨迹记录器，未指定路径时自动生成
+	agent.SetTrajectoryRecorder(utils.NewTrajectoryRecorder(trajectoryFile))
+
+	return agent, nil
+}
+
+// CreateLLMClient 根据模型配置创建LLM客户端，用于在运行中切换模型
+func (af *AgentFactory) CreateLLMClient(modelConfig *config.ModelConfig) (llm.LLMClient, error) {
+	return af.createLLMClient(modelConfig)
 }
 
 // createLLMClient 创建LLM客户端
@@ -304,8 +658,12 @@ func (af *AgentFactory) createOpenAIClient(modelConfig *config.ModelConfig) (llm
 	if provider == nil {
 		return nil, fmt.Errorf("provider not resolved for model %s", modelConfig.Model)
 	}
+	apiKey, err := provider.ResolveAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve API key for model %s: %w", modelConfig.Model, err)
+	}
 	return llm.NewOpenAIClient(
-		provider.APIKey,
+		apiKey,
 		provider.BaseURL,
 		provider.APIVersion,
 	), nil
@@ -317,8 +675,12 @@ func (af *AgentFactory) createDoubaoClient(modelConfig *config.ModelConfig) (llm
 	if provider == nil {
 		return nil, fmt.Errorf("provider not resolved for model %s", modelConfig.Model)
 	}
+	apiKey, err := provider.ResolveAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve API key for model %s: %w", modelConfig.Model, err)
+	}
 	return llm.NewDoubaoClient(
-		provider.APIKey,
+		apiKey,
 		provider.BaseURL,
 		provider.APIVersion,
 	), nil
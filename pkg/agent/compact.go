@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"trage-agent-go/pkg/llm"
+)
+
+// compactPrompt 压缩对话历史时使用的系统提示
+const compactPrompt = `你负责压缩软件工程代理的对话历史。请用简洁的中文总结下面的对话，保留继续工作所需的信息：
+- 用户提出的任务和要求
+- 已经完成的修改（文件路径和要点）
+- 重要的发现、决定和尚未解决的问题
+- 下一步计划
+只输出总结本身。`
+
+// maxCompactToolOutput 压缩时每条工具输出保留的字符数
+const maxCompactToolOutput = 2000
+
+// CompactConversationHistory 让LLM总结对话历史，并用总结替换历史以节省上下文。
+// instructions为额外的总结要求，返回总结内容和本次调用的令牌用量
+func (ta *TraeAgent) CompactConversationHistory(ctx context.Context, instructions string) (string, llm.Usage, error) {
+	usage := llm.Usage{}
+	if len(ta.conversationHistory) == 0 {
+		return "", usage, fmt.Errorf("conversation history is empty")
+	}
+
+	systemPrompt := compactPrompt
+	if instructions != "" {
+		systemPrompt += "\n\n额外要求：" + instructions
+	}
+	messages := []llm.LLMMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: renderTranscript(ta.conversationHistory)},
+	}
+
+	llmConfig := ta.modelConfig.ToLLMModelConfig().(llm.ModelConfig)
+	response, err := ta.llmClient.Chat(ctx, messages, nil, llmConfig)
+	if err != nil {
+		return "", usage, fmt.Errorf("failed to summarize conversation history: %w", err)
+	}
+	if response.Usage != nil {
+		usage = *response.Usage
+	}
+	summary := strings.TrimSpace(response.Content)
+	if summary == "" {
+		return "", usage, fmt.Errorf("failed to summarize conversation history: empty summary")
+	}
+
+	ta.conversationHistory = []llm.LLMMessage{{
+		Role:    "user",
+		Content: "以下是之前对话的总结，请在此基础上继续工作：\n\n" + summary,
+	}}
+	return summary, usage, nil
+}
+
+// renderTranscript 将对话历史渲染为供LLM总结的文本，工具输出会被截断
+func renderTranscript(messages []llm.LLMMessage) string {
+	var sb strings.Builder
+	for _, message := range messages {
+		content := message.Content
+		if message.Role == "tool" {
+			if runes := []rune(content); len(runes) > maxCompactToolOutput {
+				content = string(runes[:maxCompactToolOutput]) + "\n...（已截断）"
+			}
+		}
+		sb.WriteString(fmt.Sprintf("[%s]\n", message.Role))
+		if content != "" {
+			sb.WriteString(content + "\n")
+		}
+		for _, toolCall := range message.ToolCalls {
+			args, _ := json.Marshal(toolCall.Function.Arguments)
+			sb.WriteString(fmt.Sprintf("调用工具 %s %s\n", toolCall.Function.Name, args))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"fmt"
+
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/tools"
+)
+
+// Console 控制台接口，代理执行过程中的所有输出都通过它显示
+type Console interface {
+	// Print 打印消息
+	Print(message string)
+
+	// PrintTaskDetails 打印任务详情
+	PrintTaskDetails(details map[string]string)
+
+	// Start 启动控制台
+	Start() error
+
+	// SetLakeview 设置Lakeview
+	SetLakeview(config *config.LakeviewConfig)
+
+	// OnLLMStart 开始第step步的LLM调用
+	OnLLMStart(step int)
+
+	// OnLLMEnd LLM调用结束，失败时response为nil
+	OnLLMEnd(step int, response *llm.LLMMessage, err error)
+
+	// OnToolStart 开始执行工具
+	OnToolStart(step int, toolCall llm.ToolCall)
+
+	// OnToolResult 工具执行结束
+	OnToolResult(step int, toolCall llm.ToolCall, result *tools.ToolResult)
+
+	// OnLakeview 显示Lakeview步骤总结
+	OnLakeview(step *LakeviewStep)
+
+	// Finish 任务结束，显示执行结果并停止控制台
+	Finish(execution *AgentExecution)
+}
+
+// printConsole 未设置控制台时使用，只输出普通消息，忽略执行过程事件
+type printConsole struct{}
+
+func (printConsole) Print(message string) {
+	fmt.Println(message)
+}
+
+func (printConsole) PrintTaskDetails(details map[string]string)                             {}
+func (printConsole) Start() error                                                           { return nil }
+func (printConsole) SetLakeview(config *config.LakeviewConfig)                              {}
+func (printConsole) OnLLMStart(step int)                                                    {}
+func (printConsole) OnLLMEnd(step int, response *llm.LLMMessage, err error)                 {}
+func (printConsole) OnToolStart(step int, toolCall llm.ToolCall)                            {}
+func (printConsole) OnToolResult(step int, toolCall llm.ToolCall, result *tools.ToolResult) {}
+func (printConsole) OnLakeview(step *LakeviewStep)                                          {}
+func (printConsole) Finish(execution *AgentExecution)                                       {}
@@ -0,0 +1,235 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/tools"
+)
+
+// lakeviewTag Lakeview步骤标签
+type lakeviewTag struct {
+	Name        string
+	Emoji       string
+	Description string
+}
+
+// lakeviewTags 已知的步骤标签，顺序即提示词中的顺序
+var lakeviewTags = []lakeviewTag{
+	{"WRITE_TEST", "☑️", "编写用于复现问题的测试或脚本"},
+	{"VERIFY_TEST", "✅", "运行测试或脚本，确认问题可以复现"},
+	{"EXAMINE_CODE", "👁️", "查看、搜索或理解代码"},
+	{"WRITE_FIX", "📝", "修改代码以修复问题或实现功能"},
+	{"VERIFY_FIX", "🔥", "运行测试或脚本，验证修改是否生效"},
+	{"REPORT", "📣", "向用户报告进展或最终结果"},
+	{"THINK", "🧠", "分析问题、制定或调整计划"},
+	{"OUTLIER", "⁉️", "不属于以上任何一类"},
+}
+
+const (
+	// lakeviewMaxContent 步骤内容中单段文本的最大字符数，控制总结调用的开销
+	lakeviewMaxContent = 1000
+	// lakeviewHistory 提示词中附带的历史总结条数
+	lakeviewHistory = 5
+)
+
+var (
+	lakeviewTagsPattern    = regexp.MustCompile(`(?s)<tags>(.*?)</tags>`)
+	lakeviewSummaryPattern = regexp.MustCompile(`(?s)<summary>(.*?)</summary>`)
+)
+
+// LakeviewStep 单个步骤的标签和简短总结
+type LakeviewStep struct {
+	StepNumber int
+	Tags       []string
+	Summary    string
+}
+
+// Labels 带图标的标签，用于显示
+func (s *LakeviewStep) Labels() []string {
+	labels := make([]string, 0, len(s.Tags))
+	for _, name := range s.Tags {
+		labels = append(labels, lakeviewEmoji(name)+" "+name)
+	}
+	return labels
+}
+
+// String 格式化为控制台显示的文本
+func (s *LakeviewStep) String() string {
+	return fmt.Sprintf("🌊 Lakeview 第 %d 步 [%s]\n%s", s.StepNumber, strings.Join(s.Labels(), ", "), s.Summary)
+}
+
+// Lakeview 每步结束后用一次低成本的模型调用为步骤打标签并生成简短总结
+type Lakeview struct {
+	client      llm.LLMClient
+	modelConfig *config.ModelConfig
+	config      *config.LakeviewConfig
+	history     []string
+}
+
+// NewLakeview 创建Lakeview，client不应设置轨迹记录器，以免总结调用混入代理的LLM记录
+func NewLakeview(client llm.LLMClient, modelConfig *config.ModelConfig, lakeviewConfig *config.LakeviewConfig) *Lakeview {
+	if lakeviewConfig == nil {
+		lakeviewConfig = &config.LakeviewConfig{}
+	}
+	return &Lakeview{
+		client:      client,
+		modelConfig: modelConfig,
+		config:      lakeviewConfig,
+		history:     make([]string, 0),
+	}
+}
+
+// GetConfig 获取Lakeview配置
+func (lv *Lakeview) GetConfig() *config.LakeviewConfig {
+	return lv.config
+}
+
+// Reset 清空历史总结，开始新任务时调用
+func (lv *Lakeview) Reset() {
+	lv.history = make([]string, 0)
+}
+
+// SummarizeStep 为一个步骤打标签并生成总结
+func (lv *Lakeview) SummarizeStep(ctx context.Context, task string, stepNumber int, content string) (*LakeviewStep, error) {
+	messages := []llm.LLMMessage{
+		{Role: "system", Content: lakeviewSystemPrompt()},
+		{Role: "user", Content: lv.buildPrompt(task, content)},
+	}
+
+	llmConfig := lv.modelConfig.ToLLMModelConfig().(llm.ModelConfig)
+	response, err := lv.client.Chat(ctx, messages, nil, llmConfig)
+	if err != nil {
+		return nil, fmt.Errorf("lakeview call failed: %w", err)
+	}
+
+	step := parseLakeviewResponse(response.Content, lv.config.MaxLines)
+	step.StepNumber = stepNumber
+	lv.history = append(lv.history, fmt.Sprintf("第 %d 步 [%s]: %s", stepNumber, strings.Join(step.Tags, ","), step.Summary))
+	return step, nil
+}
+
+// buildPrompt 构建总结请求，附带任务和最近几步的总结作为上下文
+func (lv *Lakeview) buildPrompt(task, content string) string {
+	var sb strings.Builder
+	sb.WriteString("<task>\n" + truncateForLakeview(task) + "\n</task>\n\n")
+
+	history := lv.history
+	if len(history) > lakeviewHistory {
+		history = history[len(history)-lakeviewHistory:]
+	}
+	if len(history) > 0 {
+		sb.WriteString("<previous_steps>\n" + strings.Join(history, "\n") + "\n</previous_steps>\n\n")
+	}
+
+	sb.WriteString("<current_step>\n" + content + "\n</current_step>")
+	return sb.String()
+}
+
+// lakeviewSystemPrompt Lakeview的系统提示
+func lakeviewSystemPrompt() string {
+	var sb strings.Builder
+	sb.WriteString(`你负责观察一个软件工程代理的执行过程。给定任务、之前步骤的总结和当前步骤的内容，
+请为当前步骤选择一个或多个标签，并用一两句话总结代理在这一步做了什么、发现了什么。
+
+可用标签：
+`)
+	for _, tag := range lakeviewTags {
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", tag.Name, tag.Description))
+	}
+	sb.WriteString(`
+严格按以下格式输出，不要输出其他内容：
+<tags>标签1,标签2</tags>
+<summary>总结</summary>`)
+	return sb.String()
+}
+
+// parseLakeviewResponse 解析模型输出，未知标签会被丢弃，总结按maxLines截断
+func parseLakeviewResponse(content string, maxLines int) *LakeviewStep {
+	step := &LakeviewStep{Tags: make([]string, 0)}
+
+	if match := lakeviewTagsPattern.FindStringSubmatch(content); match != nil {
+		for _, name := range strings.Split(match[1], ",") {
+			name = strings.ToUpper(strings.TrimSpace(name))
+			if lakeviewEmoji(name) != "" && !containsString(step.Tags, name) {
+				step.Tags = append(step.Tags, name)
+			}
+		}
+	}
+	if len(step.Tags) == 0 {
+		step.Tags = append(step.Tags, "OUTLIER")
+	}
+
+	summary := content
+	if match := lakeviewSummaryPattern.FindStringSubmatch(content); match != nil {
+		summary = match[1]
+	} else {
+		// 模型没有按格式输出时，去掉标签部分后整体作为总结
+		summary = lakeviewTagsPattern.ReplaceAllString(content, "")
+	}
+	step.Summary = truncateLines(strings.TrimSpace(summary), maxLines)
+	return step
+}
+
+// formatLakeviewStep 将一轮LLM响应和工具结果整理为总结的输入
+func formatLakeviewStep(response *llm.LLMMessage, results []*tools.ToolResult) string {
+	var sb strings.Builder
+	if response.Content != "" {
+		sb.WriteString("代理回复:\n" + truncateForLakeview(response.Content) + "\n")
+	}
+	for _, toolCall := range response.ToolCalls {
+		args, _ := json.Marshal(toolCall.Function.Arguments)
+		sb.WriteString(fmt.Sprintf("调用工具 %s: %s\n", toolCall.Function.Name, truncateForLakeview(string(args))))
+	}
+	for _, result := range results {
+		if result.Success {
+			sb.WriteString(fmt.Sprintf("工具 %s 成功:\n%s\n", result.Name, truncateForLakeview(result.Result)))
+		} else {
+			sb.WriteString(fmt.Sprintf("工具 %s 失败:\n%s\n", result.Name, truncateForLakeview(result.Error)))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// lakeviewEmoji 获取标签对应的图标，未知标签返回空字符串
+func lakeviewEmoji(name string) string {
+	for _, tag := range lakeviewTags {
+		if tag.Name == name {
+			return tag.Emoji
+		}
+	}
+	return ""
+}
+
+// truncateLines 只保留前maxLines行，maxLines不大于0时不截断
+func truncateLines(text string, maxLines int) string {
+	lines := strings.Split(text, "\n")
+	if maxLines <= 0 || len(lines) <= maxLines {
+		return text
+	}
+	return strings.Join(lines[:maxLines], "\n") + "\n..."
+}
+
+// truncateForLakeview 截断过长的步骤内容
+func truncateForLakeview(text string) string {
+	runes := []rune(text)
+	if len(runes) <= lakeviewMaxContent {
+		return text
+	}
+	return string(runes[:lakeviewMaxContent]) + "..."
+}
+
+// containsString 检查切片中是否包含指定字符串
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
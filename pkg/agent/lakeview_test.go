@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/utils"
+)
+
+func TestParseLakeviewResponse(t *testing.T) {
+	content := "<tags>examine_code, UNKNOWN,EXAMINE_CODE,VERIFY_FIX</tags>\n<summary>\nline1\nline2\nline3\n</summary>"
+	step := parseLakeviewResponse(content, 2)
+
+	// 未知和重复的标签被丢弃，标签不区分大小写
+	if strings.Join(step.Tags, ",") != "EXAMINE_CODE,VERIFY_FIX" {
+		t.Errorf("Expected tags EXAMINE_CODE,VERIFY_FIX, got %v", step.Tags)
+	}
+	if step.Summary != "line1\nline2\n..." {
+		t.Errorf("Expected summary truncated to 2 lines, got %q", step.Summary)
+	}
+
+	// 没有按格式输出时使用整段内容，并归为OUTLIER
+	step = parseLakeviewResponse("查看了main.go", 0)
+	if len(step.Tags) != 1 || step.Tags[0] != "OUTLIER" {
+		t.Errorf("Expected OUTLIER tag, got %v", step.Tags)
+	}
+	if step.Summary != "查看了main.go" {
+		t.Errorf("Expected raw content as summary, got %q", step.Summary)
+	}
+}
+
+func TestTraeAgent_Lakeview(t *testing.T) {
+	dir := t.TempDir()
+	toolCall := llm.ToolCall{
+		ID:       "call_1",
+		Type:     "function",
+		Function: llm.ToolCallFunction{Name: "echo", Arguments: map[string]interface{}{"text": "hello"}},
+	}
+	script := []llm.LLMInteraction{
+		{Response: &llm.LLMMessage{Role: "assistant", ToolCalls: []llm.ToolCall{toolCall}}},
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "任务完成"}},
+	}
+	summaries := []llm.LLMInteraction{
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "<tags>EXAMINE_CODE</tags><summary>运行echo\n第二行</summary>"}},
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "<tags>REPORT</tags><summary>报告完成</summary>"}},
+	}
+
+	path := filepath.Join(dir, "lakeview.jsonl")
+	agent, _ := newReplayAgent(t, llm.NewReplayClient(script), path)
+	lakeviewClient := llm.NewReplayClient(summaries)
+	modelConfig := &config.ModelConfig{Model: "cheap-model", ModelProvider: "openai"}
+	agent.SetLakeview(NewLakeview(lakeviewClient, modelConfig, &config.LakeviewConfig{MaxLines: 1}))
+
+	execution, err := agent.Run(context.Background(), "say hello", nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !execution.Success {
+		t.Fatalf("Expected success, got error: %s", execution.Error)
+	}
+	if lakeviewClient.Remaining() != 0 {
+		t.Errorf("Expected one lakeview call per step, %d left", lakeviewClient.Remaining())
+	}
+
+	recorded, err := utils.LoadTrajectory(path)
+	if err != nil {
+		t.Fatalf("Failed to load trajectory: %v", err)
+	}
+	records := recorded.GetLakeview()
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 lakeview records, got %d", len(records))
+	}
+	if records[0].StepNumber != 1 || records[0].Tags[0] != "EXAMINE_CODE" || records[0].Summary != "运行echo\n..." {
+		t.Errorf("Unexpected first record: %+v", records[0])
+	}
+	if records[1].StepNumber != 2 || records[1].Tags[0] != "REPORT" {
+		t.Errorf("Unexpected second record: %+v", records[1])
+	}
+
+	// 总结调用不应计入代理的LLM记录
+	if len(recorded.GetLLMInteractions()) != 2 {
+		t.Errorf("Expected 2 agent LLM interactions, got %d", len(recorded.GetLLMInteractions()))
+	}
+}
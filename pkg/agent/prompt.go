@@ -0,0 +1,172 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/utils"
+)
+
+// 内置的系统提示模板
+var (
+	//go:embed prompts/system_zh.tmpl
+	systemPromptZh string
+	//go:embed prompts/system_en.tmpl
+	systemPromptEn string
+)
+
+// InstructionFile 项目根目录和各级子目录中的说明文件
+const InstructionFile = "AGENTS.md"
+
+// InstructionRulesDir 各级目录中存放说明文件（*.md）的目录
+const InstructionRulesDir = ".trae/rules"
+
+// 系统提示中说明文件和Git状态的长度限制
+const (
+	maxInstructionBytes = 32 * 1024
+	maxGitStatusLines   = 50
+	gitStatusTimeout    = 5 * time.Second
+)
+
+// PromptTool 系统提示模板中的工具信息
+type PromptTool struct {
+	Name        string
+	Description string
+}
+
+// Instruction 项目中的一个说明文件
+type Instruction struct {
+	Path    string
+	Content string
+}
+
+// PromptData 渲染系统提示模板时可用的数据
+type PromptData struct {
+	Agent        string
+	Tools        []PromptTool
+	ProjectPath  string
+	OS           string
+	Date         string
+	GitStatus    string        // git status --short --branch的输出，不是git仓库时为空
+	Instructions []Instruction // 从外层目录到项目路径排列
+}
+
+// DefaultPromptTemplate 返回language对应的内置系统提示模板，未知语言使用中文
+func DefaultPromptTemplate(language string) string {
+	if language == config.LanguageEnglish {
+		return systemPromptEn
+	}
+	return systemPromptZh
+}
+
+// RenderPrompt 使用data渲染text/template格式的系统提示
+func RenderPrompt(promptTemplate string, data PromptData) (string, error) {
+	tmpl, err := template.New("system_prompt").Parse(promptTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to render system prompt: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render system prompt: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// DiscoverInstructions 从dir向上直到git仓库根目录查找说明文件：每级目录的AGENTS.md和.trae/rules/*.md。
+// 外层目录的文件在前；dir不在git仓库中时只查找dir
+func DiscoverInstructions(dir string) ([]Instruction, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve project path: %w", err)
+	}
+
+	dirs := []string{dir}
+	for current := dir; !pathExists(filepath.Join(current, ".git")); {
+		parent := filepath.Dir(current)
+		if parent == current {
+			dirs = []string{dir}
+			break
+		}
+		current = parent
+		dirs = append([]string{current}, dirs...)
+	}
+
+	instructions := make([]Instruction, 0)
+	for _, current := range dirs {
+		paths, err := filepath.Glob(filepath.Join(current, InstructionRulesDir, "*.md"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+		if path := filepath.Join(current, InstructionFile); pathExists(path) {
+			paths = append([]string{path}, paths...)
+		}
+
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read instruction file: %w", err)
+			}
+			content := strings.TrimSpace(string(data))
+			if len(content) > maxInstructionBytes {
+				content = strings.ToValidUTF8(content[:maxInstructionBytes], "") + "\n... (truncated)"
+			}
+			if content != "" {
+				instructions = append(instructions, Instruction{Path: path, Content: content})
+			}
+		}
+	}
+	return instructions, nil
+}
+
+// gitStatus 返回dir的git status --short --branch输出，不是git仓库或git不可用时返回空字符串
+func gitStatus(dir string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), gitStatusTimeout)
+	defer cancel()
+	output, err := utils.RunGit(ctx, dir, "status", "--short", "--branch")
+	if err != nil {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	if len(lines) > maxGitStatusLines {
+		lines = append(lines[:maxGitStatusLines:maxGitStatusLines], fmt.Sprintf("... (%d more lines)", len(lines)-maxGitStatusLines))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// newPromptData 收集渲染系统提示所需的项目信息
+func newPromptData(agentName, projectPath string, tools []PromptTool) (PromptData, error) {
+	instructions, err := DiscoverInstructions(projectPath)
+	if err != nil {
+		return PromptData{}, err
+	}
+	if absPath, err := filepath.Abs(projectPath); err == nil {
+		projectPath = absPath
+	}
+	return PromptData{
+		Agent:        agentName,
+		Tools:        tools,
+		ProjectPath:  projectPath,
+		OS:           runtime.GOOS,
+		Date:         time.Now().Format("2006-01-02"),
+		GitStatus:    gitStatus(projectPath),
+		Instructions: instructions,
+	}, nil
+}
+
+// pathExists 检查路径是否存在
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
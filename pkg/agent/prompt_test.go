@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/llm"
+)
+
+// writeFiles 在dir下创建文件，内容为空的路径创建为目录
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if content == "" {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+}
+
+func TestDiscoverInstructions(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"AGENTS.md":                      "outside the repository",
+		"repo/.git":                      "",
+		"repo/AGENTS.md":                 "repository rules",
+		"repo/.trae/rules/b.md":          "rule b",
+		"repo/.trae/rules/a.md":          "rule a",
+		"repo/.trae/rules/notes.txt":     "not markdown",
+		"repo/service/AGENTS.md":         "service rules",
+		"repo/service/api/.gitkeep":      "keep",
+		"plain/AGENTS.md":                "plain rules",
+		"plain/sub/.trae/rules/local.md": "local rule",
+	})
+
+	// 从子目录向上查找到仓库根目录，外层目录的文件在前
+	instructions, err := DiscoverInstructions(filepath.Join(root, "repo", "service", "api"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var actual []string
+	for _, instruction := range instructions {
+		relative, _ := filepath.Rel(root, instruction.Path)
+		actual = append(actual, filepath.ToSlash(relative)+"="+instruction.Content)
+	}
+	expected := "repo/AGENTS.md=repository rules,repo/.trae/rules/a.md=rule a,repo/.trae/rules/b.md=rule b,repo/service/AGENTS.md=service rules"
+	if strings.Join(actual, ",") != expected {
+		t.Errorf("Expected instructions %s, got %s", expected, strings.Join(actual, ","))
+	}
+
+	// 不在git仓库中时只查找起始目录
+	instructions, err = DiscoverInstructions(filepath.Join(root, "plain", "sub"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(instructions) != 1 || instructions[0].Content != "local rule" {
+		t.Errorf("Expected only the local rule, got %+v", instructions)
+	}
+}
+
+func TestTraeAgent_DefaultSystemPrompt(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"AGENTS.md": "Run go vet before finishing."})
+
+	agent, _ := newReplayAgent(t, llm.NewReplayClient(nil), filepath.Join(t.TempDir(), "prompt.jsonl"))
+	agent.SetWorkingDir(dir)
+
+	// 内置模板按语言选择，包含环境信息、工具和项目说明
+	for language, heading := range map[string]string{"": "项目说明", config.LanguageEnglish: "Project instructions"} {
+		agent.config.Language = language
+		prompt, err := agent.buildSystemPrompt()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for _, want := range []string{heading, "Run go vet before finishing.", "- echo: Echo the given text", dir, runtime.GOOS} {
+			if !strings.Contains(prompt, want) {
+				t.Errorf("Expected %q prompt to contain %q, got:\n%s", language, want, prompt)
+			}
+		}
+	}
+
+	// 代理的提示文件覆盖内置模板
+	promptFile := filepath.Join(dir, "triage.tmpl")
+	writeFiles(t, dir, map[string]string{"triage.tmpl": "{{.OS}} {{.Date}} {{len .Instructions}}"})
+	agent.config.SystemPromptFile = promptFile
+	prompt, err := agent.buildSystemPrompt()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if fields := strings.Fields(prompt); len(fields) != 3 || fields[0] != runtime.GOOS || fields[2] != "1" {
+		t.Errorf("Expected prompt rendered from the prompt file, got %q", prompt)
+	}
+
+	// 固定的系统提示不再渲染模板
+	agent.SetSystemPrompt("recorded prompt")
+	if prompt, _ := agent.buildSystemPrompt(); prompt != "recorded prompt" {
+		t.Errorf("Expected recorded prompt, got %q", prompt)
+	}
+}
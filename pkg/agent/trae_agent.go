@@ -3,12 +3,14 @@ package agent
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"trage-agent-go/pkg/config"
 	"trage-agent-go/pkg/llm"
 	"trage-agent-go/pkg/tools"
+	"trage-agent-go/pkg/utils"
 )
 
 // TraeAgent Trae代理实现
@@ -22,9 +24,10 @@ type TraeAgent struct {
 	allowMCPServers     []string
 	mcpTools            []tools.Tool
 	mcpClients          []interface{} // MCP客户端列表，用于清理
-	cliConsole          Console
+	lakeview            *Lakeview
 	allowMCPServersFlag bool
 	conversationHistory []llm.LLMMessage // 对话历史记录
+	systemPrompt        string           // 固定的系统提示，为空时每个任务渲染模板
 }
 
 // NewTraeAgent 创建TraeAgent
@@ -41,14 +44,24 @@ func NewTraeAgent(agentConfig *config.AgentConfig, modelConfig *config.ModelConf
 		allowMCPServers:     agentConfig.Tools, // 使用配置中的工具列表
 		mcpTools:            make([]tools.Tool, 0),
 		mcpClients:          make([]interface{}, 0),
-		cliConsole:          nil,
 		allowMCPServersFlag: true,
 	}
 }
 
 // SetCLIConsole 设置CLI控制台
 func (ta *TraeAgent) SetCLIConsole(console Console) {
-	ta.cliConsole = console
+	ta.BaseAgent.SetCLIConsole(console)
+	if ta.lakeview != nil {
+		ta.console.SetLakeview(ta.lakeview.GetConfig())
+	}
+}
+
+// SetLakeview 设置Lakeview，为nil时不生成步骤总结
+func (ta *TraeAgent) SetLakeview(lakeview *Lakeview) {
+	ta.lakeview = lakeview
+	if lakeview != nil {
+		ta.console.SetLakeview(lakeview.GetConfig())
+	}
 }
 
 // SetTrajectoryRecorder 设置轨迹记录器
@@ -64,10 +77,16 @@ func (ta *TraeAgent) NewTask(task string, extraArgs map[string]string, toolNames
 	}
 
 	// 将新任务添加到对话历史
-	ta.AddToConversationHistory(llm.LLMMessage{
+	taskMessage := llm.LLMMessage{
 		Role:    "user",
 		Content: task,
-	})
+	}
+	ta.AddToConversationHistory(taskMessage)
+	ta.recordMessage(taskMessage)
+
+	if ta.lakeview != nil {
+		ta.lakeview.Reset()
+	}
 
 	// TraeAgent特定的任务初始化逻辑
 	if extraArgs != nil {
@@ -105,6 +124,11 @@ func (ta *TraeAgent) GetConversationHistory() []llm.LLMMessage {
 	return ta.conversationHistory
 }
 
+// SetConversationHistory 替换对话历史，用于恢复会话
+func (ta *TraeAgent) SetConversationHistory(messages []llm.LLMMessage) {
+	ta.conversationHistory = append(make([]llm.LLMMessage, 0, len(messages)), messages...)
+}
+
 // ClearConversationHistory 清空对话历史
 func (ta *TraeAgent) ClearConversationHistory() {
 	ta.conversationHistory = make([]llm.LLMMessage, 0)
@@ -119,15 +143,19 @@ func (ta *TraeAgent) Run(ctx context.Context, task string, extraArgs map[string]
 		return nil, err
 	}
 
+	ta.recordTaskMetadata()
+
 	// 执行任务 - 调用TraeAgent的实现
 	execution, err := ta.ExecuteTask(ctx)
+	if execution != nil {
+		// 设置执行时间
+		execution.Duration = time.Since(startTime)
+		ta.recordExecution(execution)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// 设置执行时间
-	execution.Duration = time.Since(startTime)
-
 	return execution, nil
 }
 
@@ -147,7 +175,11 @@ func (ta *TraeAgent) ExecuteTask(ctx context.Context) (*AgentExecution, error) {
 	}
 
 	// 构建系统提示
-	systemPrompt := ta.buildSystemPrompt()
+	systemPrompt, err := ta.buildSystemPrompt()
+	if err != nil {
+		execution.Error = err.Error()
+		return execution, err
+	}
 
 	// 创建初始消息，包含系统提示和对话历史
 	messages := []llm.LLMMessage{
@@ -162,8 +194,20 @@ func (ta *TraeAgent) ExecuteTask(ctx context.Context) (*AgentExecution, error) {
 		messages = append(messages, ta.conversationHistory...)
 	}
 
+	// 令牌用量统计
+	usage := llm.Usage{}
+	turn := 0
+
 	// 主执行循环
 	for ta.GetStepCount() < ta.GetMaxSteps() {
+		turn++
+
+		// 任务被取消时停止
+		if err := ctx.Err(); err != nil {
+			ta.recordCancellation(execution, err)
+			break
+		}
+
 		// 检查步数限制
 		if err := ta.CheckStepLimit(); err != nil {
 			execution.Error = err.Error()
@@ -180,7 +224,13 @@ func (ta *TraeAgent) ExecuteTask(ctx context.Context) (*AgentExecution, error) {
 
 		// 调用LLM
 		llmConfig := ta.modelConfig.ToLLMModelConfig().(llm.ModelConfig)
-		response, err := ta.llmClient.Chat(messages, ta.toolRegistry.GetToolDefinitions(), llmConfig)
+		ta.console.OnLLMStart(turn)
+		response, err := ta.llmClient.Chat(ctx, messages, ta.toolRegistry.GetToolDefinitions(), llmConfig)
+		ta.console.OnLLMEnd(turn, response, err)
+		if err != nil && ctx.Err() != nil {
+			ta.recordCancellation(execution, ctx.Err())
+			break
+		}
 		if err != nil {
 			// 改进错误处理，提供更详细的错误信息
 			errorMsg := fmt.Sprintf("LLM call failed: %v", err)
@@ -192,27 +242,32 @@ func (ta *TraeAgent) ExecuteTask(ctx context.Context) (*AgentExecution, error) {
 				errorMsg += " - 达到API调用限制，请稍后重试"
 			}
 			execution.Error = errorMsg
+			ta.recordError("llm", errorMsg)
 			break
 		}
 
 		// 记录消息
 		messages = append(messages, *response)
+		ta.recordMessage(*response)
+		if response.Usage != nil {
+			usage.PromptTokens += response.Usage.PromptTokens
+			usage.CompletionTokens += response.Usage.CompletionTokens
+			usage.TotalTokens += response.Usage.TotalTokens
+		}
 
 		// 将响应添加到对话历史
 		ta.AddToConversationHistory(*response)
 
 		// 检查是否有工具调用
 		if len(response.ToolCalls) > 0 {
+			stepResults := make([]*tools.ToolResult, 0, len(response.ToolCalls))
+
 			// 执行工具调用
-			for _, toolCall := range response.ToolCalls {
+			for i, toolCall := range response.ToolCalls {
 				startTime := time.Now()
 
-				// 执行工具
-				tool, exists := ta.toolRegistry.Get(toolCall.Function.Name)
-				if !exists {
-					execution.Error = fmt.Sprintf("tool '%s' not found", toolCall.Function.Name)
-					break
-				}
+				ta.recordToolCall(toolCall)
+				ta.console.OnToolStart(turn, toolCall)
 
 				// 将工具调用的参数转换为ToolCallArguments
 				args := make(tools.ToolCallArguments)
@@ -220,27 +275,50 @@ func (ta *TraeAgent) ExecuteTask(ctx context.Context) (*AgentExecution, error) {
 					args[key] = value
 				}
 
-				toolResult, err := tool.Execute(ctx, args)
+				// 执行工具：未知的工具和被审批拒绝的调用不执行，把原因作为工具结果返回给LLM
+				var toolResult *tools.ToolResult
+				var err error
+				tool, exists := ta.toolRegistry.Get(toolCall.Function.Name)
+				if !exists {
+					available := ta.toolRegistry.ListTools()
+					sort.Strings(available)
+					message := fmt.Sprintf("tool '%s' not found, available tools: %s",
+						toolCall.Function.Name, strings.Join(available, ", "))
+					ta.recordError("tool", message)
+					toolResult = &tools.ToolResult{
+						Success: false,
+						Result:  message,
+						Error:   message,
+					}
+				} else if approved, reason := ta.approveToolCall(ctx, toolCall); approved {
+					toolResult, err = tool.Execute(ctx, args)
 
-				// 添加调试信息
-				fmt.Printf("工具执行: %s, 参数: %v, 错误: %v\n", toolCall.Function.Name, args, err)
-				if toolResult != nil {
-					fmt.Printf("工具结果: 成功=%v, 结果=%s\n", toolResult.Success, toolResult.Result)
+					// 跟踪执行
+					ta.TrackToolExecution(toolCall.Function.Name, startTime, err == nil, err)
+				} else {
+					toolResult = &tools.ToolResult{
+						Success: false,
+						Result:  reason,
+						Error:   reason,
+					}
 				}
 
-				// 跟踪执行
-				ta.TrackToolExecution(toolCall.Function.Name, startTime, err == nil, err)
-
 				if err != nil {
 					// 记录工具执行错误
+					ta.recordError("tool", fmt.Sprintf("%s: %v", toolCall.Function.Name, err))
 					toolResult = &tools.ToolResult{
 						CallID:  toolCall.ID,
 						Name:    toolCall.Function.Name,
 						Success: false,
 						Error:   err.Error(),
 					}
+				} else {
+					toolResult.CallID = toolCall.ID
+					toolResult.Name = toolCall.Function.Name
 				}
 
+				ta.console.OnToolResult(turn, toolCall, toolResult)
+
 				// 添加执行步骤
 				ta.AddExecutionStep(
 					"tool_execution",
@@ -252,6 +330,8 @@ func (ta *TraeAgent) ExecuteTask(ctx context.Context) (*AgentExecution, error) {
 
 				// 添加工具结果到执行历史
 				execution.ToolResults = append(execution.ToolResults, toolResult)
+				stepResults = append(stepResults, toolResult)
+				ta.recordToolResult(toolCall, toolResult, time.Since(startTime))
 
 				// 将工具结果添加到消息历史
 				toolMessage := llm.LLMMessage{
@@ -260,23 +340,45 @@ func (ta *TraeAgent) ExecuteTask(ctx context.Context) (*AgentExecution, error) {
 					ToolCallID: toolCall.ID,
 				}
 				messages = append(messages, toolMessage)
+				ta.recordMessage(toolMessage)
 
 				// 将工具结果添加到对话历史
 				ta.AddToConversationHistory(toolMessage)
+
+				// 任务被取消时跳过剩余的工具调用
+				if ctx.Err() != nil {
+					messages = ta.skipToolCalls(messages, response.ToolCalls[i+1:])
+					break
+				}
+			}
+
+			if err := ctx.Err(); err != nil {
+				ta.recordCancellation(execution, err)
+				break
 			}
 
+			ta.summarizeStep(ctx, turn, response, stepResults)
+
 			// 工具执行完成后，检查是否应该停止
 			if ta.shouldStopExecution(execution) {
-				execution.Success = true
-				execution.Output = "任务通过工具执行完成"
-				break
+				if !ta.patchMissing(ctx) {
+					execution.Success = true
+					execution.Output = "任务通过工具执行完成"
+					break
+				}
+				messages = append(messages, ta.mustPatchReminder())
 			}
 		} else {
+			ta.summarizeStep(ctx, turn, response, nil)
+
 			// 没有工具调用，检查是否是最终答案
 			if ta.isTaskComplete(response.Content) {
-				execution.Success = true
-				execution.Output = response.Content
-				break
+				if !ta.patchMissing(ctx) {
+					execution.Success = true
+					execution.Output = response.Content
+					break
+				}
+				messages = append(messages, ta.mustPatchReminder())
 			}
 		}
 
@@ -292,43 +394,100 @@ func (ta *TraeAgent) ExecuteTask(ctx context.Context) (*AgentExecution, error) {
 
 	// 设置执行统计
 	execution.Steps = ta.getExecutionSteps()
+	execution.Metadata["usage"] = usage
 
 	return execution, nil
 }
 
-// buildSystemPrompt 构建系统提示
-func (ta *TraeAgent) buildSystemPrompt() string {
-	prompt := `你是一个专业的软件工程代理，专门用于处理软件工程任务。
+// recordCancellation 记录任务被取消（如用户按下Ctrl-C），并写入轨迹
+func (ta *TraeAgent) recordCancellation(execution *AgentExecution, err error) {
+	execution.Error = fmt.Sprintf("task cancelled: %v", err)
+	execution.Cancelled = true
+	ta.recordError("cancel", execution.Error)
+	ta.console.Print("⏹️  任务已中断")
+}
+
+// skipToolCalls 为未执行的工具调用补充结果消息，使对话历史中的每个工具调用都有对应的结果
+func (ta *TraeAgent) skipToolCalls(messages []llm.LLMMessage, toolCalls []llm.ToolCall) []llm.LLMMessage {
+	for _, toolCall := range toolCalls {
+		toolMessage := llm.LLMMessage{
+			Role:       "tool",
+			Content:    "任务被用户中断，工具调用未执行",
+			ToolCallID: toolCall.ID,
+		}
+		messages = append(messages, toolMessage)
+		ta.recordMessage(toolMessage)
+		ta.AddToConversationHistory(toolMessage)
+	}
+	return messages
+}
+
+// summarizeStep 启用Lakeview时总结一轮执行，结果显示在控制台并写入轨迹
+func (ta *TraeAgent) summarizeStep(ctx context.Context, turn int, response *llm.LLMMessage, results []*tools.ToolResult) {
+	if ta.lakeview == nil {
+		return
+	}
 
-你的能力包括：
-- 代码分析和理解
-- 代码编辑和重构
-- 执行命令行操作
-- 结构化思考
-- 任务完成判断
+	// 总结只用于展示，失败不影响任务执行
+	step, err := ta.lakeview.SummarizeStep(ctx, ta.GetTask(), turn, formatLakeviewStep(response, results))
+	if err != nil {
+		ta.console.Print(fmt.Sprintf("警告: Lakeview总结失败: %v", err))
+		return
+	}
 
-可用工具：
-`
+	ta.console.OnLakeview(step)
+	ta.recordLakeview(step)
+}
+
+// buildSystemPrompt 渲染系统提示：设置了固定提示时直接使用，否则使用代理配置的模板或按语言选择的内置模板，
+// 模板中可以使用项目路径、操作系统、日期、工具、Git状态和项目说明文件
+func (ta *TraeAgent) buildSystemPrompt() (string, error) {
+	if ta.systemPrompt != "" {
+		return ta.systemPrompt, nil
+	}
 
-	// 添加工具描述
+	// 只列出本次任务可用的工具
+	var enabledTools []PromptTool
 	for _, tool := range ta.tools {
-		prompt += fmt.Sprintf("- %s: %s\n", tool.GetName(), tool.GetDescription())
+		if _, enabled := ta.toolRegistry.Get(tool.GetName()); enabled {
+			enabledTools = append(enabledTools, PromptTool{Name: tool.GetName(), Description: tool.GetDescription()})
+		}
+	}
+
+	var agentName, language, promptTemplate string
+	if ta.config != nil {
+		agentName, language = ta.config.Name, ta.config.Language
+		var err error
+		if promptTemplate, err = ta.config.PromptTemplate(); err != nil {
+			return "", fmt.Errorf("failed to render system prompt: %w", err)
+		}
+	}
+	if promptTemplate == "" {
+		promptTemplate = DefaultPromptTemplate(language)
 	}
 
-	prompt += `
-请按照以下步骤工作：
-1. 分析任务需求
-2. 使用适当的工具执行任务
-3. 验证结果
-4. 报告完成状态
+	data, err := newPromptData(agentName, ta.projectDir(), enabledTools)
+	if err != nil {
+		return "", fmt.Errorf("failed to render system prompt: %w", err)
+	}
+	return RenderPrompt(promptTemplate, data)
+}
 
-重要提示：
-- 当用户要求创建文件时，必须使用edit_file工具实际创建文件
-- 当用户要求执行命令时，必须使用bash工具实际执行
-- 不要只提供代码示例，要实际完成任务
-- 始终使用工具来完成任务，不要假设或猜测。`
+// SetSystemPrompt 固定使用prompt作为系统提示，不再渲染模板，用于回放录制的轨迹；为空时恢复渲染
+func (ta *TraeAgent) SetSystemPrompt(prompt string) {
+	ta.systemPrompt = prompt
+}
 
-	return prompt
+// projectDir 获取项目目录：任务的project_path、代理的工作目录或当前目录
+func (ta *TraeAgent) projectDir() string {
+	dir := ta.projectPath
+	if dir == "" {
+		dir = ta.GetWorkingDir()
+	}
+	if dir == "" {
+		dir = "."
+	}
+	return dir
 }
 
 // getCurrentTask 获取当前任务
@@ -391,6 +550,28 @@ func (ta *TraeAgent) shouldStopExecution(execution *AgentExecution) bool {
 	return false
 }
 
+// patchMissing 设置了must_patch且项目目录相对基准提交没有任何修改时返回true，
+// 无法生成补丁（如不是git仓库）也视为没有修改
+func (ta *TraeAgent) patchMissing(ctx context.Context) bool {
+	if ta.mustPatch != "true" {
+		return false
+	}
+
+	patch, err := utils.GitPatch(ctx, ta.projectDir(), ta.baseCommit)
+	return err != nil || strings.TrimSpace(string(patch)) == ""
+}
+
+// mustPatchReminder 提示代理必须修改代码后才能结束，消息会加入对话历史
+func (ta *TraeAgent) mustPatchReminder() llm.LLMMessage {
+	reminder := llm.LLMMessage{
+		Role:    "user",
+		Content: "任务要求必须生成补丁，但项目目录中还没有任何修改。请修改代码解决问题后再结束。",
+	}
+	ta.AddToConversationHistory(reminder)
+	ta.recordMessage(reminder)
+	return reminder
+}
+
 // getExecutionSteps 获取执行步骤
 func (ta *TraeAgent) getExecutionSteps() []ExecutionStep {
 	if ta.BaseAgent != nil {
@@ -412,21 +593,6 @@ func (ta *TraeAgent) cleanupMCPClients() error {
 	return nil
 }
 
-// Console 控制台接口
-type Console interface {
-	// Print 打印消息
-	Print(message string)
-
-	// PrintTaskDetails 打印任务详情
-	PrintTaskDetails(details map[string]string)
-
-	// Start 启动控制台
-	Start() error
-
-	// SetLakeview 设置Lakeview
-	SetLakeview(config *config.LakeviewConfig)
-}
-
 // ModelConfig 模型配置接口实现
 type modelConfigWrapper struct {
 	config *config.ModelConfig
@@ -0,0 +1,460 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/tools"
+	"trage-agent-go/pkg/utils"
+)
+
+// echoTool 原样返回参数的测试工具
+type echoTool struct {
+	*tools.BaseTool
+	calls int
+}
+
+func newEchoTool() *echoTool {
+	return &echoTool{
+		BaseTool: tools.NewBaseTool("echo", "Echo the given text", "", []tools.ToolParameter{
+			{Name: "text", Type: "string", Description: "Text to echo", Required: true},
+		}),
+	}
+}
+
+func (e *echoTool) Execute(ctx context.Context, args tools.ToolCallArguments) (*tools.ToolResult, error) {
+	e.calls++
+	text, _ := args["text"].(string)
+	return &tools.ToolResult{Success: true, Result: text}, nil
+}
+
+// newReplayAgent 创建使用回放客户端的代理
+func newReplayAgent(t *testing.T, client llm.LLMClient, trajectoryFile string) (*TraeAgent, *echoTool) {
+	t.Helper()
+	agentConfig := &config.AgentConfig{Model: "test_model", MaxSteps: 10}
+	modelConfig := &config.ModelConfig{Model: "test-model", ModelProvider: "openai", MaxTokens: 1024}
+
+	agent := NewTraeAgent(agentConfig, modelConfig, client)
+	agent.SetTrajectoryRecorder(utils.NewTrajectoryRecorder(trajectoryFile))
+	tool := newEchoTool()
+	agent.AddTool(tool)
+	return agent, tool
+}
+
+func TestTraeAgent_ReplayIsDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	toolCall := llm.ToolCall{
+		ID:       "call_1",
+		Type:     "function",
+		Function: llm.ToolCallFunction{Name: "echo", Arguments: map[string]interface{}{"text": "hello"}},
+	}
+	script := []llm.LLMInteraction{
+		{Response: &llm.LLMMessage{Role: "assistant", ToolCalls: []llm.ToolCall{toolCall}}},
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "任务完成"}},
+	}
+
+	// 第一次运行：按脚本返回响应，请求内容未知，允许不一致
+	firstPath := filepath.Join(dir, "first.jsonl")
+	first, tool := newReplayAgent(t, llm.NewReplayClient(script), firstPath)
+	execution, err := first.Run(context.Background(), "say hello", nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !execution.Success || tool.calls != 1 {
+		t.Fatalf("Expected success with one tool call, got success=%v calls=%d error=%s", execution.Success, tool.calls, execution.Error)
+	}
+
+	// 第二次运行：严格回放第一次的轨迹，请求必须完全一致
+	recorded, err := utils.LoadTrajectory(firstPath)
+	if err != nil {
+		t.Fatalf("Failed to load trajectory: %v", err)
+	}
+	replayClient := llm.NewReplayClient(recorded.GetLLMInteractions())
+	replayClient.SetStrict(true)
+
+	second, _ := newReplayAgent(t, replayClient, filepath.Join(dir, "second.jsonl"))
+	execution, err = second.Run(context.Background(), "say hello", nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !execution.Success {
+		t.Fatalf("Expected replay to succeed, got error: %s", execution.Error)
+	}
+	if divergences := replayClient.GetDivergences(); len(divergences) != 0 {
+		t.Errorf("Expected no divergences, got:\n%s", divergences[0].Diff)
+	}
+	if replayClient.Remaining() != 0 {
+		t.Errorf("Expected all recorded calls to be used, %d left", replayClient.Remaining())
+	}
+
+	// 任务不同时应报告差异
+	divergent := llm.NewReplayClient(recorded.GetLLMInteractions())
+	third, _ := newReplayAgent(t, divergent, filepath.Join(dir, "third.jsonl"))
+	if _, err := third.Run(context.Background(), "say goodbye", nil, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(divergent.GetDivergences()) == 0 {
+		t.Error("Expected divergence for a different task")
+	}
+}
+
+// recordingConsole 记录收到的事件的测试控制台
+type recordingConsole struct {
+	printConsole
+	events []string
+}
+
+func (c *recordingConsole) OnLLMStart(step int) {
+	c.events = append(c.events, fmt.Sprintf("llm_start:%d", step))
+}
+
+func (c *recordingConsole) OnLLMEnd(step int, response *llm.LLMMessage, err error) {
+	c.events = append(c.events, fmt.Sprintf("llm_end:%d", step))
+}
+
+func (c *recordingConsole) OnToolStart(step int, toolCall llm.ToolCall) {
+	c.events = append(c.events, "tool_start:"+toolCall.Function.Name)
+}
+
+func (c *recordingConsole) OnToolResult(step int, toolCall llm.ToolCall, result *tools.ToolResult) {
+	c.events = append(c.events, fmt.Sprintf("tool_result:%s:%v", toolCall.Function.Name, result.Success))
+}
+
+func TestTraeAgent_ConsoleEvents(t *testing.T) {
+	toolCall := llm.ToolCall{
+		ID:       "call_1",
+		Type:     "function",
+		Function: llm.ToolCallFunction{Name: "echo", Arguments: map[string]interface{}{"text": "hello"}},
+	}
+	script := []llm.LLMInteraction{
+		{Response: &llm.LLMMessage{Role: "assistant", ToolCalls: []llm.ToolCall{toolCall}}},
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "任务完成"}},
+	}
+
+	agent, _ := newReplayAgent(t, llm.NewReplayClient(script), filepath.Join(t.TempDir(), "console.jsonl"))
+	console := &recordingConsole{}
+	agent.SetCLIConsole(console)
+	if _, err := agent.Run(context.Background(), "say hello", nil, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := "llm_start:1,llm_end:1,tool_start:echo,tool_result:echo:true,llm_start:2,llm_end:2"
+	if got := strings.Join(console.events, ","); got != expected {
+		t.Errorf("Expected events %s, got %s", expected, got)
+	}
+}
+
+func TestTraeAgent_ToolNamesFilterDefinitions(t *testing.T) {
+	script := []llm.LLMInteraction{
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "任务完成"}},
+	}
+	agent, _ := newReplayAgent(t, llm.NewReplayClient(script), filepath.Join(t.TempDir(), "filter.jsonl"))
+	agent.AddTool(tools.NewTaskDoneTool())
+
+	if _, err := agent.Run(context.Background(), "say hello", nil, []string{"echo"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// 发送给LLM的工具定义应只包含允许的工具
+	definitions := agent.GetToolRegistry().GetToolDefinitions()
+	if len(definitions) != 1 || definitions[0].Function.Name != "echo" {
+		t.Errorf("Expected only echo tool definition, got %+v", definitions)
+	}
+
+	// 过滤只作用于本次任务
+	if err := agent.NewTask("say hello again", nil, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if definitions := agent.GetToolRegistry().GetToolDefinitions(); len(definitions) != 2 {
+		t.Errorf("Expected all tools to be available for the next task, got %+v", definitions)
+	}
+}
+
+func TestAgentFactory_ConfiguredTools(t *testing.T) {
+	cfg := &config.Config{
+		Agents: map[string]config.AgentConfig{
+			"triage": {Model: "test_model", MaxSteps: 10, Tools: []string{"bash", "task_done"}},
+			"broken": {Model: "test_model", MaxSteps: 10, Tools: []string{"browser"}},
+		},
+		Models: map[string]config.ModelConfig{
+			"test_model": {Model: "test-model", ModelProvider: "openai"},
+		},
+		Tools: map[string]config.ToolConfig{
+			"bash": {Timeout: 30},
+		},
+	}
+	factory := NewAgentFactory()
+	trajectoryFile := filepath.Join(t.TempDir(), "tools.jsonl")
+
+	// 代理只有配置中列出的工具，并应用工具配置
+	agent, err := factory.CreateAgentWithClient(AgentTypeTraeAgent, "triage", cfg, trajectoryFile, llm.NewReplayClient(nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var names []string
+	for _, tool := range agent.GetTools() {
+		names = append(names, tool.GetName())
+	}
+	if strings.Join(names, ",") != "bash,task_done" {
+		t.Errorf("Expected tools bash,task_done, got %v", names)
+	}
+	if bash, ok := agent.GetTools()[0].(*tools.BashTool); !ok || bash.GetTimeout().Seconds() != 30 {
+		t.Errorf("Expected bash tool with 30s timeout, got %v", agent.GetTools()[0])
+	}
+
+	// 指定代理没有的工具时任务不会开始
+	if err := agent.NewTask("say hello", nil, []string{"edit_file"}); err == nil || !strings.Contains(err.Error(), "edit_file") {
+		t.Errorf("Expected error for unavailable tool, got %v", err)
+	}
+
+	// 未知的工具名称
+	if _, err := factory.CreateAgentWithClient(AgentTypeTraeAgent, "broken", cfg, trajectoryFile, llm.NewReplayClient(nil)); err == nil || !strings.Contains(err.Error(), "unknown tool 'browser'") {
+		t.Errorf("Expected unknown tool error, got %v", err)
+	}
+}
+
+func TestTraeAgent_MustPatch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	dir := t.TempDir()
+	if _, err := utils.RunGit(context.Background(), dir, "init", "--quiet"); err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+
+	editCall := llm.ToolCall{
+		ID:       "call_1",
+		Type:     "function",
+		Function: llm.ToolCallFunction{Name: "edit_file", Arguments: map[string]interface{}{"file_path": "fix.txt", "content": "fixed\n", "backup": false}},
+	}
+	script := []llm.LLMInteraction{
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "任务完成"}},
+		{Response: &llm.LLMMessage{Role: "assistant", ToolCalls: []llm.ToolCall{editCall}}},
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "任务完成"}},
+	}
+	client := llm.NewReplayClient(script)
+	agent, _ := newReplayAgent(t, client, filepath.Join(t.TempDir(), "must_patch.jsonl"))
+	agent.AddTool(tools.NewEditTool())
+	agent.SetWorkingDir(dir)
+
+	// 第一次声称完成时还没有修改，代理应被提示继续
+	execution, err := agent.Run(context.Background(), "fix it", map[string]string{"project_path": dir, "must_patch": "true"}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !execution.Success {
+		t.Fatalf("Expected success, got error: %s", execution.Error)
+	}
+	if client.Remaining() != 0 {
+		t.Errorf("Expected all 3 responses to be used, %d left", client.Remaining())
+	}
+
+	reminded := false
+	for _, message := range agent.GetConversationHistory() {
+		if message.Role == "user" && strings.Contains(message.Content, "必须生成补丁") {
+			reminded = true
+		}
+	}
+	if !reminded {
+		t.Error("Expected a must_patch reminder in the conversation")
+	}
+}
+
+func TestTraeAgent_RestoreSession(t *testing.T) {
+	thinkCall := llm.ToolCall{
+		ID:   "call_1",
+		Type: "function",
+		Function: llm.ToolCallFunction{Name: "sequential_thinking", Arguments: map[string]interface{}{
+			"thought": "先看看代码", "thought_number": float64(1), "total_thoughts": float64(2), "next_thought_needed": true,
+		}},
+	}
+	script := []llm.LLMInteraction{
+		{Response: &llm.LLMMessage{Role: "assistant", ToolCalls: []llm.ToolCall{thinkCall}}},
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "任务完成"}},
+	}
+	first, _ := newReplayAgent(t, llm.NewReplayClient(script), filepath.Join(t.TempDir(), "first.jsonl"))
+	first.AddTool(tools.NewSequentialThinkingTool())
+	if _, err := first.Run(context.Background(), "第一个任务", nil, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	messages := first.GetConversationHistory()
+	states, err := first.SaveToolStates()
+	if err != nil {
+		t.Fatalf("Failed to save tool states: %v", err)
+	}
+	if _, exists := states["sequential_thinking"]; !exists || len(states) != 1 {
+		t.Fatalf("Expected only sequential_thinking state, got %v", states)
+	}
+
+	// 新代理恢复会话后继续工作，对话历史和思考历史都应保留
+	resumed, _ := newReplayAgent(t, llm.NewReplayClient(script[1:]), filepath.Join(t.TempDir(), "resumed.jsonl"))
+	thinking := tools.NewSequentialThinkingTool()
+	resumed.AddTool(thinking)
+	resumed.SetConversationHistory(messages)
+	if err := resumed.RestoreToolStates(states); err != nil {
+		t.Fatalf("Failed to restore tool states: %v", err)
+	}
+	if _, err := resumed.Run(context.Background(), "第二个任务", nil, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	history := resumed.GetConversationHistory()
+	if len(history) != len(messages)+2 || history[0].Content != "第一个任务" || history[len(messages)].Content != "第二个任务" {
+		t.Errorf("Expected restored history followed by the new task, got %+v", history)
+	}
+	if len(thinking.GetThoughtHistory()) != 1 {
+		t.Errorf("Expected restored thought history to survive the first task, got %d thoughts", len(thinking.GetThoughtHistory()))
+	}
+
+	// 之后的任务照常重置工具状态
+	if err := resumed.NewTask("第三个任务", nil, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(thinking.GetThoughtHistory()) != 0 {
+		t.Errorf("Expected thought history to be reset for later tasks, got %d thoughts", len(thinking.GetThoughtHistory()))
+	}
+}
+
+func TestTraeAgent_CompactConversationHistory(t *testing.T) {
+	toolCall := llm.ToolCall{
+		ID:       "call_1",
+		Type:     "function",
+		Function: llm.ToolCallFunction{Name: "echo", Arguments: map[string]interface{}{"text": "hello"}},
+	}
+	script := []llm.LLMInteraction{
+		{Response: &llm.LLMMessage{Role: "assistant", ToolCalls: []llm.ToolCall{toolCall}}},
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "任务完成"}},
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "用户让代理回显hello，已完成。", Usage: &llm.Usage{TotalTokens: 42}}},
+	}
+	client := llm.NewReplayClient(script)
+	agent, _ := newReplayAgent(t, client, filepath.Join(t.TempDir(), "compact.jsonl"))
+
+	if _, _, err := agent.CompactConversationHistory(context.Background(), ""); err == nil {
+		t.Errorf("Expected error for empty history")
+	}
+
+	if _, err := agent.Run(context.Background(), "echo hello", nil, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(agent.GetConversationHistory()) != 4 {
+		t.Fatalf("Expected 4 messages before compaction, got %d", len(agent.GetConversationHistory()))
+	}
+
+	summary, usage, err := agent.CompactConversationHistory(context.Background(), "保留文件路径")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if summary != "用户让代理回显hello，已完成。" || usage.TotalTokens != 42 {
+		t.Errorf("Unexpected summary %q with usage %+v", summary, usage)
+	}
+	history := agent.GetConversationHistory()
+	if len(history) != 1 || history[0].Role != "user" || !strings.Contains(history[0].Content, summary) {
+		t.Errorf("Expected history to be replaced by the summary, got %+v", history)
+	}
+}
+
+// cancelTool 执行时取消任务的测试工具，模拟用户按下Ctrl-C
+type cancelTool struct {
+	*tools.BaseTool
+	cancel context.CancelFunc
+}
+
+func (c *cancelTool) Execute(ctx context.Context, args tools.ToolCallArguments) (*tools.ToolResult, error) {
+	c.cancel()
+	return &tools.ToolResult{Success: false, Error: "command interrupted"}, nil
+}
+
+func TestTraeAgent_Cancel(t *testing.T) {
+	toolCalls := []llm.ToolCall{
+		{ID: "call_1", Type: "function", Function: llm.ToolCallFunction{Name: "interrupt", Arguments: map[string]interface{}{}}},
+		{ID: "call_2", Type: "function", Function: llm.ToolCallFunction{Name: "echo", Arguments: map[string]interface{}{"text": "hello"}}},
+	}
+	script := []llm.LLMInteraction{
+		{Response: &llm.LLMMessage{Role: "assistant", ToolCalls: toolCalls}},
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "任务完成"}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agent, echo := newReplayAgent(t, llm.NewReplayClient(script), "")
+	recorder := utils.NewTrajectoryRecorder(filepath.Join(t.TempDir(), "cancel.jsonl"))
+	agent.SetTrajectoryRecorder(recorder)
+	agent.AddTool(&cancelTool{BaseTool: tools.NewBaseTool("interrupt", "Cancel the task", "", nil), cancel: cancel})
+
+	execution, err := agent.Run(ctx, "say hello", nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !execution.Cancelled || execution.Success || !strings.Contains(execution.Error, "cancelled") {
+		t.Errorf("Expected cancelled execution, got %+v", execution)
+	}
+	if echo.calls != 0 {
+		t.Errorf("Expected remaining tool calls to be skipped, got %d calls", echo.calls)
+	}
+
+	// 每个工具调用都有对应的结果，下一个任务可以继续使用对话历史
+	history := agent.GetConversationHistory()
+	if len(history) != 4 || history[3].ToolCallID != "call_2" {
+		t.Fatalf("Expected task, assistant message and two tool results, got %+v", history)
+	}
+
+	errors := recorder.GetErrors()
+	if len(errors) != 1 || errors[0].Source != "cancel" {
+		t.Errorf("Expected cancellation to be recorded in trajectory, got %+v", errors)
+	}
+	if record := recorder.GetExecution(); record == nil || !record.Cancelled {
+		t.Errorf("Expected cancelled execution record, got %+v", record)
+	}
+}
+
+func TestTraeAgent_UnknownTool(t *testing.T) {
+	missingCall := llm.ToolCall{
+		ID:       "call_missing",
+		Type:     "function",
+		Function: llm.ToolCallFunction{Name: "missing", Arguments: map[string]interface{}{}},
+	}
+	echoCall := llm.ToolCall{
+		ID:       "call_echo",
+		Type:     "function",
+		Function: llm.ToolCallFunction{Name: "echo", Arguments: map[string]interface{}{"text": "hello"}},
+	}
+	script := []llm.LLMInteraction{
+		{Response: &llm.LLMMessage{Role: "assistant", ToolCalls: []llm.ToolCall{missingCall, echoCall}}},
+		{Response: &llm.LLMMessage{Role: "assistant", Content: "任务完成"}},
+	}
+	agent, tool := newReplayAgent(t, llm.NewReplayClient(script), filepath.Join(t.TempDir(), "unknown.jsonl"))
+
+	// 未知工具的调用返回错误结果，同一响应中的其他工具调用照常执行，任务继续
+	execution, err := agent.Run(context.Background(), "say hello", nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !execution.Success || execution.Error != "" {
+		t.Errorf("Expected task to continue after an unknown tool, got success=%v error=%q", execution.Success, execution.Error)
+	}
+	if tool.calls != 1 {
+		t.Errorf("Expected echo to run once, got %d", tool.calls)
+	}
+	if len(execution.ToolResults) != 2 || execution.ToolResults[0].Success ||
+		!strings.Contains(execution.ToolResults[0].Result, "tool 'missing' not found, available tools: echo") {
+		t.Fatalf("Expected error result for the unknown tool, got %+v", execution.ToolResults)
+	}
+
+	// 每个工具调用都有对应的tool消息
+	answered := make(map[string]bool)
+	for _, message := range agent.GetConversationHistory() {
+		if message.Role == "tool" {
+			answered[message.ToolCallID] = true
+		}
+	}
+	if !answered["call_missing"] || !answered["call_echo"] {
+		t.Errorf("Expected tool messages for both calls, got %v", answered)
+	}
+}
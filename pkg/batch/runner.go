@@ -0,0 +1,234 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/tools"
+)
+
+// Result 单个任务的执行结果，写入结果JSONL
+type Result struct {
+	ID             string    `json:"id"`
+	Success        bool      `json:"success"`
+	Patch          string    `json:"patch"`
+	Usage          llm.Usage `json:"usage"`
+	Steps          int       `json:"steps"`
+	Duration       float64   `json:"duration_seconds"`
+	TrajectoryPath string    `json:"trajectory_path,omitempty"`
+	Output         string    `json:"output,omitempty"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Options 批量运行选项
+type Options struct {
+	NewAgent      func(trajectoryFile string) (agent.Agent, error) // 为每个任务创建独立的代理
+	Concurrency   int                                              // 同时运行的任务数
+	TrajectoryDir string                                           // 轨迹文件目录，文件名为<id>.jsonl
+	WorkingDir    string                                           // 任务未指定working_dir时使用，为空时为当前目录
+}
+
+// Runner 按并发限制运行一批任务
+type Runner struct {
+	options  Options
+	dirLocks map[string]*sync.Mutex
+	mutex    sync.Mutex
+}
+
+// NewRunner 创建批量运行器
+func NewRunner(options Options) (*Runner, error) {
+	if options.NewAgent == nil {
+		return nil, fmt.Errorf("agent builder is required")
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.TrajectoryDir == "" {
+		options.TrajectoryDir = "trajectories"
+	}
+	if err := os.MkdirAll(options.TrajectoryDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create trajectory directory: %w", err)
+	}
+
+	return &Runner{
+		options:  options,
+		dirLocks: make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// Run 运行所有任务，每个任务结束后串行调用onResult，返回按输入顺序排列的结果。
+// ctx取消后正在运行的任务会停止，尚未开始的任务直接记为失败
+func (r *Runner) Run(ctx context.Context, tasks []Task, onResult func(Result)) []Result {
+	results := make([]Result, len(tasks))
+	slots := make(chan struct{}, r.options.Concurrency)
+	var wg sync.WaitGroup
+	var resultMutex sync.Mutex
+
+	for i := range tasks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			result := r.runTask(ctx, tasks[i])
+			resultMutex.Lock()
+			results[i] = result
+			if onResult != nil {
+				onResult(result)
+			}
+			resultMutex.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// runTask 在任务的工作目录中运行一个独立的代理并收集结果
+func (r *Runner) runTask(ctx context.Context, task Task) (result Result) {
+	startTime := time.Now()
+	result = Result{
+		ID:             task.ID,
+		TrajectoryPath: filepath.Join(r.options.TrajectoryDir, task.ID+".jsonl"),
+	}
+	defer func() {
+		result.Duration = time.Since(startTime).Seconds()
+	}()
+
+	if err := ctx.Err(); err != nil {
+		result.TrajectoryPath = ""
+		result.Error = fmt.Sprintf("task cancelled: %v", err)
+		return result
+	}
+
+	workingDir, err := r.workingDir(task)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// 同一工作目录中的任务依次运行，每个任务结束后恢复工作目录，补丁只包含任务自己的修改
+	lock := r.dirLock(workingDir)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ws, err := openWorkspace(ctx, workingDir, task.BaseCommit)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer func() {
+		if err := ws.close(context.Background()); err != nil {
+			result.Error = appendError(result.Error, fmt.Sprintf("failed to restore working directory: %v", err))
+		}
+	}()
+	workingDir = ws.dir
+
+	agentInstance, err := r.newAgent(task, result.TrajectoryPath, workingDir)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	extraArgs := map[string]string{
+		"project_path": workingDir,
+		"working_dir":  workingDir,
+	}
+	if task.BaseCommit != "" {
+		extraArgs["base_commit"] = task.BaseCommit
+	}
+	if task.MustPatch {
+		extraArgs["must_patch"] = "true"
+	}
+
+	execution, err := agentInstance.Run(ctx, task.Prompt, extraArgs, task.AllowedTools)
+	if execution != nil {
+		result.Success = execution.Success
+		result.Output = execution.Output
+		result.Error = execution.Error
+		result.Steps = len(execution.Steps)
+		if usage, ok := execution.Metadata["usage"].(llm.Usage); ok {
+			result.Usage = usage
+		}
+	}
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+	}
+
+	// 任务取消后仍然收集补丁，便于查看已做的修改
+	patch, err := ws.patch(context.Background())
+	if err != nil {
+		result.Error = appendError(result.Error, fmt.Sprintf("failed to create patch: %v", err))
+	}
+	result.Patch = patch
+	return result
+}
+
+// newAgent 创建代理并应用任务的工作目录、最大步数和工具限制
+func (r *Runner) newAgent(task Task, trajectoryFile, workingDir string) (agent.Agent, error) {
+	agentInstance, err := r.options.NewAgent(trajectoryFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create agent: %w", err)
+	}
+	agentInstance.SetWorkingDir(workingDir)
+	if task.MaxSteps > 0 {
+		agentInstance.SetMaxSteps(task.MaxSteps)
+	}
+
+	// 未知的工具名会被静默忽略，提前报错
+	if registered, ok := agentInstance.(interface{ GetToolRegistry() *tools.ToolRegistry }); ok {
+		for _, name := range task.AllowedTools {
+			if _, exists := registered.GetToolRegistry().Get(name); !exists {
+				return nil, fmt.Errorf("unknown tool %q in allowed_tools", name)
+			}
+		}
+	}
+	return agentInstance, nil
+}
+
+// workingDir 获取任务工作目录的绝对路径
+func (r *Runner) workingDir(task Task) (string, error) {
+	dir := task.WorkingDir
+	if dir == "" {
+		dir = r.options.WorkingDir
+	}
+	if dir == "" {
+		dir = "."
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+	if info, err := os.Stat(absDir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("working directory %s does not exist", absDir)
+	}
+	return absDir, nil
+}
+
+// dirLock 获取工作目录对应的锁
+func (r *Runner) dirLock(dir string) *sync.Mutex {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	lock, exists := r.dirLocks[dir]
+	if !exists {
+		lock = &sync.Mutex{}
+		r.dirLocks[dir] = lock
+	}
+	return lock
+}
+
+// appendError 合并错误信息
+func appendError(existing, message string) string {
+	if existing == "" {
+		return message
+	}
+	return existing + "; " + message
+}
@@ -0,0 +1,214 @@
+package batch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/testutil"
+	"trage-agent-go/pkg/tools"
+	"trage-agent-go/pkg/utils"
+)
+
+// newTestRunner 创建每个任务都按script回放响应的运行器
+func newTestRunner(t *testing.T, concurrency int, script []llm.LLMInteraction) (*Runner, *[]llm.LLMClient) {
+	t.Helper()
+	var mutex sync.Mutex
+	clients := make([]llm.LLMClient, 0)
+
+	runner, err := NewRunner(Options{
+		NewAgent: func(trajectoryFile string) (agent.Agent, error) {
+			client := llm.NewReplayClient(script)
+			mutex.Lock()
+			clients = append(clients, client)
+			mutex.Unlock()
+			return testutil.NewReplayAgent(client, trajectoryFile, tools.NewEditTool(), tools.NewBashTool()), nil
+		},
+		Concurrency:   concurrency,
+		TrajectoryDir: filepath.Join(t.TempDir(), "trajectories"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create runner: %v", err)
+	}
+	return runner, &clients
+}
+
+// initRepo 创建包含一次提交的git仓库
+func initRepo(t *testing.T) string {
+	t.Helper()
+	dir, _ := testutil.InitRepo(t, map[string]string{"README.md": "base\n"})
+	return dir
+}
+
+// editResponse 写入文件并带有用量的脚本响应
+func editResponse(path, content string) llm.LLMInteraction {
+	response := testutil.EditResponse(path, content)
+	response.Response.Usage = &llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	return response
+}
+
+func TestRunner_Run(t *testing.T) {
+	repoA := initRepo(t)
+	repoB := initRepo(t)
+	script := []llm.LLMInteraction{
+		editResponse("fix.txt", "fixed\n"),
+		testutil.FinalResponse("任务完成"),
+	}
+	runner, _ := newTestRunner(t, 2, script)
+
+	tasks := []Task{
+		{ID: "a", Prompt: "fix a", WorkingDir: repoA},
+		{ID: "b", Prompt: "fix b", WorkingDir: repoB, AllowedTools: []string{"edit_file"}},
+		{ID: "c", Prompt: "fix c", WorkingDir: filepath.Join(repoA, "missing")},
+		{ID: "d", Prompt: "fix d", WorkingDir: repoB, AllowedTools: []string{"unknown"}},
+	}
+
+	reported := make([]string, 0)
+	results := runner.Run(context.Background(), tasks, func(result Result) {
+		reported = append(reported, result.ID)
+	})
+	if len(results) != len(tasks) || len(reported) != len(tasks) {
+		t.Fatalf("Expected %d results, got %d (reported %d)", len(tasks), len(results), len(reported))
+	}
+
+	for i, id := range []string{"a", "b"} {
+		result := results[i]
+		if result.ID != id || !result.Success {
+			t.Errorf("Expected task %s to succeed, got %+v", id, result)
+			continue
+		}
+		if !strings.Contains(result.Patch, "+++ b/fix.txt") || !strings.Contains(result.Patch, "+fixed") {
+			t.Errorf("Expected patch for task %s to add fix.txt, got %q", id, result.Patch)
+		}
+		if result.Steps != 2 || result.Usage.TotalTokens != 15 {
+			t.Errorf("Expected 2 steps and 15 tokens for task %s, got %d and %d", id, result.Steps, result.Usage.TotalTokens)
+		}
+		if _, err := os.Stat(result.TrajectoryPath); err != nil {
+			t.Errorf("Expected trajectory for task %s: %v", id, err)
+		}
+	}
+
+	if results[2].Success || !strings.Contains(results[2].Error, "does not exist") {
+		t.Errorf("Expected missing working dir error, got %+v", results[2])
+	}
+	if results[3].Success || !strings.Contains(results[3].Error, `unknown tool "unknown"`) {
+		t.Errorf("Expected unknown tool error, got %+v", results[3])
+	}
+}
+
+func TestRunner_BaseCommitAndMaxSteps(t *testing.T) {
+	repo := initRepo(t)
+	base := testutil.Git(t, repo, "rev-parse", "HEAD")
+
+	// 在基准提交之后再提交一次，任务应从基准提交开始，补丁也相对基准提交
+	if err := os.WriteFile(filepath.Join(repo, "later.txt"), []byte("later\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	testutil.Git(t, repo, "add", ".")
+	testutil.Git(t, repo, "commit", "--quiet", "-m", "later")
+
+	script := []llm.LLMInteraction{
+		editResponse("fix.txt", "fixed\n"),
+		editResponse("fix.txt", "fixed again\n"),
+		testutil.FinalResponse("任务完成"),
+	}
+	runner, clients := newTestRunner(t, 1, script)
+
+	results := runner.Run(context.Background(), []Task{
+		{ID: "limited", Prompt: "fix", WorkingDir: repo, BaseCommit: base, MaxSteps: 1},
+	}, nil)
+
+	result := results[0]
+	if result.Success {
+		t.Errorf("Expected task to fail after reaching max steps, got %+v", result)
+	}
+	if remaining := (*clients)[0].(*llm.ReplayClient).Remaining(); remaining != 2 {
+		t.Errorf("Expected a single LLM call, %d of 3 responses left", remaining)
+	}
+	if !strings.Contains(result.Patch, "+fixed") || strings.Contains(result.Patch, "later.txt") {
+		t.Errorf("Expected patch relative to base commit, got %q", result.Patch)
+	}
+
+	// 任务在临时worktree中运行，用户的检出保持在原分支上，也没有留下修改和worktree
+	if _, err := utils.RunGit(context.Background(), repo, "symbolic-ref", "--quiet", "HEAD"); err != nil {
+		t.Errorf("Expected repository to stay on its branch, got detached HEAD: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "fix.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected task edits not to leak into the repository")
+	}
+	if worktrees, _ := utils.RunGit(context.Background(), repo, "worktree", "list"); strings.Count(string(worktrees), "\n") != 1 {
+		t.Errorf("Expected temporary worktree to be removed, got:\n%s", worktrees)
+	}
+}
+
+func TestRunner_SharedWorkingDir(t *testing.T) {
+	repo := initRepo(t)
+	// 用户未提交的修改不应出现在任务的补丁中，也不应被任务恢复掉
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("local change\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	scripts := map[string][]llm.LLMInteraction{
+		"a": {testutil.EditResponse("a.txt", "from a\n"), testutil.FinalResponse("任务完成")},
+		"b": {testutil.EditResponse("b.txt", "from b\n"), testutil.FinalResponse("任务完成")},
+	}
+	runner, err := NewRunner(Options{
+		NewAgent: func(trajectoryFile string) (agent.Agent, error) {
+			client := llm.NewReplayClient(scripts[testutil.TaskID(trajectoryFile)])
+			return testutil.NewReplayAgent(client, trajectoryFile, tools.NewEditTool()), nil
+		},
+		Concurrency:   2,
+		TrajectoryDir: filepath.Join(t.TempDir(), "trajectories"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create runner: %v", err)
+	}
+
+	results := runner.Run(context.Background(), []Task{
+		{ID: "a", Prompt: "add a", WorkingDir: repo},
+		{ID: "b", Prompt: "add b", WorkingDir: repo},
+	}, nil)
+
+	for _, tc := range []struct {
+		result     Result
+		own, other string
+	}{
+		{results[0], "a.txt", "b.txt"},
+		{results[1], "b.txt", "a.txt"},
+	} {
+		if !tc.result.Success || tc.result.Error != "" {
+			t.Errorf("Expected task %s to succeed, got %+v", tc.result.ID, tc.result)
+		}
+		if !strings.Contains(tc.result.Patch, "+++ b/"+tc.own) {
+			t.Errorf("Expected patch of task %s to add %s, got %q", tc.result.ID, tc.own, tc.result.Patch)
+		}
+		if strings.Contains(tc.result.Patch, tc.other) || strings.Contains(tc.result.Patch, "README.md") {
+			t.Errorf("Expected patch of task %s to contain only its own edit, got %q", tc.result.ID, tc.result.Patch)
+		}
+		if _, err := os.Stat(filepath.Join(repo, tc.own)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed after task %s", tc.own, tc.result.ID)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, "README.md")); string(data) != "local change\n" {
+		t.Errorf("Expected uncommitted change to be kept, got %q", data)
+	}
+}
+
+func TestRunner_Cancelled(t *testing.T) {
+	runner, clients := newTestRunner(t, 1, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := runner.Run(ctx, []Task{{ID: "a", Prompt: "fix"}}, nil)
+	if results[0].Success || !strings.Contains(results[0].Error, "cancelled") {
+		t.Errorf("Expected cancelled result, got %+v", results[0])
+	}
+	if len(*clients) != 0 {
+		t.Errorf("Expected no agent to be created for a cancelled batch")
+	}
+}
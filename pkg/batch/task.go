@@ -0,0 +1,78 @@
+package batch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Task 批量任务文件中的一个任务，每行一个JSON对象
+type Task struct {
+	ID           string   `json:"id"`
+	Prompt       string   `json:"prompt"`
+	WorkingDir   string   `json:"working_dir,omitempty"`   // 为空时使用当前目录
+	BaseCommit   string   `json:"base_commit,omitempty"`   // 运行前检出，补丁相对该提交生成
+	AllowedTools []string `json:"allowed_tools,omitempty"` // 为空时使用全部工具
+	MaxSteps     int      `json:"max_steps,omitempty"`     // 为0时使用配置中的值
+	MustPatch    bool     `json:"must_patch,omitempty"`    // 没有修改代码时不允许代理结束
+}
+
+// LoadTasks 从JSONL文件读取任务，空行会被跳过
+func LoadTasks(path string) ([]Task, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open task file: %w", err)
+	}
+	defer file.Close()
+
+	tasks := make([]Task, 0)
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var task Task
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&task); err != nil {
+			return nil, fmt.Errorf("line %d: invalid task: %w", lineNumber, err)
+		}
+		if err := task.Validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if previous, exists := seen[task.ID]; exists {
+			return nil, fmt.Errorf("line %d: duplicate task id %q (first defined on line %d)", lineNumber, task.ID, previous)
+		}
+		seen[task.ID] = lineNumber
+		tasks = append(tasks, task)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read task file: %w", err)
+	}
+	return tasks, nil
+}
+
+// Validate 检查任务字段，ID会用作轨迹文件名
+func (t *Task) Validate() error {
+	if strings.TrimSpace(t.ID) == "" {
+		return fmt.Errorf("task id is required")
+	}
+	if strings.ContainsAny(t.ID, `/\`) || t.ID == "." || t.ID == ".." {
+		return fmt.Errorf("task id %q must be usable as a file name", t.ID)
+	}
+	if strings.TrimSpace(t.Prompt) == "" {
+		return fmt.Errorf("task %s: prompt is required", t.ID)
+	}
+	if t.MaxSteps < 0 {
+		return fmt.Errorf("task %s: max_steps must not be negative", t.ID)
+	}
+	return nil
+}
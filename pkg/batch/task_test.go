@@ -0,0 +1,65 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTaskFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tasks.jsonl")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write task file: %v", err)
+	}
+	return path
+}
+
+func TestLoadTasks(t *testing.T) {
+	path := writeTaskFile(t, `{"id": "a", "prompt": "fix a", "working_dir": "repo", "base_commit": "abc", "allowed_tools": ["bash"], "max_steps": 5}
+
+{"id": "b", "prompt": "fix b"}
+`)
+
+	tasks, err := LoadTasks(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("Expected 2 tasks, got %d", len(tasks))
+	}
+
+	first := tasks[0]
+	if first.ID != "a" || first.WorkingDir != "repo" || first.BaseCommit != "abc" || first.MaxSteps != 5 {
+		t.Errorf("Unexpected first task: %+v", first)
+	}
+	if len(first.AllowedTools) != 1 || first.AllowedTools[0] != "bash" {
+		t.Errorf("Expected allowed tools [bash], got %v", first.AllowedTools)
+	}
+}
+
+func TestLoadTasks_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"invalid json", `{"id": "a"`, "line 1"},
+		{"unknown field", `{"id": "a", "prompt": "x", "steps": 3}`, "unknown field"},
+		{"missing id", `{"prompt": "x"}`, "task id is required"},
+		{"missing prompt", `{"id": "a"}`, "prompt is required"},
+		{"path in id", `{"id": "../a", "prompt": "x"}`, "file name"},
+		{"negative steps", `{"id": "a", "prompt": "x", "max_steps": -1}`, "max_steps"},
+		{"duplicate id", "{\"id\": \"a\", \"prompt\": \"x\"}\n{\"id\": \"a\", \"prompt\": \"y\"}", "line 2: duplicate task id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadTasks(writeTaskFile(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"trage-agent-go/pkg/utils"
+)
+
+// workspace 任务实际运行的目录。任务结束后恢复任务开始前的状态，
+// 使同一仓库中的任务互不影响，也不改变用户检出的分支
+type workspace struct {
+	dir      string // 代理运行的目录
+	root     string // dir所在git仓库或worktree的根目录
+	base     string // 生成补丁的基准提交或快照树，为空时不是git仓库，不生成补丁
+	worktree bool   // root是为基准提交创建的临时worktree
+	repo     string // 创建worktree的仓库目录
+}
+
+// openWorkspace 准备任务的运行目录。dir的HEAD就是baseCommit或未指定baseCommit时，
+// 在dir中运行并记录快照，补丁只包含任务自己的修改；否则在baseCommit的临时worktree中运行
+func openWorkspace(ctx context.Context, dir, baseCommit string) (*workspace, error) {
+	root, err := utils.RunGit(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		if baseCommit != "" {
+			return nil, fmt.Errorf("base_commit requires a git repository: %w", err)
+		}
+		return &workspace{dir: dir}, nil
+	}
+
+	if baseCommit != "" {
+		base, err := revParse(ctx, dir, baseCommit+"^{commit}")
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve base commit: %w", err)
+		}
+		if head, _ := revParse(ctx, dir, "HEAD"); head != base {
+			return openWorktree(ctx, dir, base)
+		}
+	}
+
+	w := &workspace{dir: dir, root: strings.TrimSpace(string(root))}
+	if w.base, err = utils.GitSnapshot(ctx, w.root); err != nil {
+		return nil, fmt.Errorf("failed to snapshot working directory: %w", err)
+	}
+	return w, nil
+}
+
+// openWorktree 在临时目录中创建检出commit的分离worktree，dir是仓库的子目录时代理在worktree的对应子目录中运行
+func openWorktree(ctx context.Context, dir, commit string) (*workspace, error) {
+	prefix, err := utils.RunGit(ctx, dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	path, err := os.MkdirTemp("", "trage-batch-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	if _, err := utils.RunGit(ctx, dir, "worktree", "add", "--detach", "--force", path, commit); err != nil {
+		os.RemoveAll(path)
+		return nil, fmt.Errorf("failed to create worktree: %w", err)
+	}
+	return &workspace{
+		dir:      filepath.Join(path, filepath.FromSlash(strings.TrimSpace(string(prefix)))),
+		root:     path,
+		base:     commit,
+		worktree: true,
+		repo:     dir,
+	}, nil
+}
+
+// patch 生成任务修改的补丁，不是git仓库时返回空
+func (w *workspace) patch(ctx context.Context) (string, error) {
+	if w.base == "" {
+		return "", nil
+	}
+	patch, err := utils.GitPatch(ctx, w.root, w.base)
+	return string(patch), err
+}
+
+// close 删除临时worktree，或把原目录恢复为任务开始前的快照
+func (w *workspace) close(ctx context.Context) error {
+	switch {
+	case w.worktree:
+		_, err := utils.RunGit(ctx, w.repo, "worktree", "remove", "--force", w.root)
+		os.RemoveAll(w.root)
+		utils.RunGit(ctx, w.repo, "worktree", "prune")
+		return err
+	case w.base != "":
+		return utils.GitRestoreSnapshot(ctx, w.root, w.base)
+	default:
+		return nil
+	}
+}
+
+// revParse 解析git对象名
+func revParse(ctx context.Context, dir, name string) (string, error) {
+	output, err := utils.RunGit(ctx, dir, "rev-parse", "--verify", "--quiet", name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,374 @@
+package ckg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketFiles   = []byte("files")
+	bucketSymbols = map[SymbolKind][]byte{
+		SymbolKindFunction:    []byte("functions"),
+		SymbolKindClass:       []byte("classes"),
+		SymbolKindClassMethod: []byte("class_methods"),
+	}
+	bucketMeta = []byte("meta")
+)
+
+// DefaultMaxFileSize 超过该大小的文件不建立索引
+const DefaultMaxFileSize = 1 << 20
+
+// skipDirs 索引时跳过的目录
+var skipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"__pycache__":  true,
+	"dist":         true,
+	"build":        true,
+	"venv":         true,
+}
+
+// Index 代码知识图谱索引，基于bbolt持久化
+type Index struct {
+	codebase    string
+	dbPath      string
+	db          *bolt.DB
+	maxFileSize int64
+}
+
+// DefaultCacheDir 获取默认的索引缓存目录（~/.trae/ckg）
+func DefaultCacheDir() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".trae", "ckg")
+	}
+	return filepath.Join(os.TempDir(), ".trae", "ckg")
+}
+
+// IndexPath 获取代码库对应的索引文件路径
+func IndexPath(cacheDir, codebase string) string {
+	sum := sha256.Sum256([]byte(codebase))
+	name := fmt.Sprintf("%s_%s.db", filepath.Base(codebase), hex.EncodeToString(sum[:])[:16])
+	return filepath.Join(cacheDir, name)
+}
+
+// OpenIndex 打开（或创建）代码库的索引
+func OpenIndex(codebase, cacheDir string) (*Index, error) {
+	absPath, err := filepath.Abs(codebase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve codebase path: %w", err)
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat codebase: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("codebase path '%s' is not a directory", absPath)
+	}
+
+	if cacheDir == "" {
+		cacheDir = DefaultCacheDir()
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	dbPath := IndexPath(cacheDir, absPath)
+	db, err := bolt.Open(dbPath, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketFiles, bucketMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		for _, name := range bucketSymbols {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(bucketMeta).Put([]byte("codebase"), []byte(absPath))
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize index database: %w", err)
+	}
+
+	return &Index{
+		codebase:    absPath,
+		dbPath:      dbPath,
+		db:          db,
+		maxFileSize: DefaultMaxFileSize,
+	}, nil
+}
+
+// Close 关闭索引
+func (idx *Index) Close() error {
+	return idx.db.Close()
+}
+
+// GetCodebase 获取代码库路径
+func (idx *Index) GetCodebase() string {
+	return idx.codebase
+}
+
+// GetDBPath 获取索引文件路径
+func (idx *Index) GetDBPath() string {
+	return idx.dbPath
+}
+
+// Update 增量更新索引，只为大小或修改时间变化的文件计算哈希，只重新解析内容哈希发生变化的文件
+func (idx *Index) Update() (*UpdateStats, error) {
+	stats := &UpdateStats{}
+	seen := make(map[string]bool)
+
+	err := filepath.WalkDir(idx.codebase, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 无法访问的路径直接跳过
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != idx.codebase && (strings.HasPrefix(name, ".") || skipDirs[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		lang, ok := languageForExt(filepath.Ext(name))
+		if !ok || strings.HasSuffix(name, ".d.ts") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > idx.maxFileSize {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(idx.codebase, path)
+		if err != nil {
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+		seen[relPath] = true
+		stats.Scanned++
+
+		existing, err := idx.getFileRecord(relPath)
+		if err != nil {
+			return err
+		}
+		// 大小和修改时间都未变化的文件不再读取和计算哈希
+		modTime := info.ModTime().UnixNano()
+		if existing != nil && existing.Size == info.Size() && existing.ModTime == modTime {
+			stats.Unchanged++
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			stats.Failed++
+			return nil
+		}
+		sum := sha256.Sum256(content)
+		record := &FileRecord{Path: relPath, Hash: hex.EncodeToString(sum[:]), Size: info.Size(), ModTime: modTime}
+
+		if existing != nil && existing.Hash == record.Hash {
+			// 内容未变化，只更新记录的大小和修改时间
+			stats.Unchanged++
+			record.Symbols = existing.Symbols
+			return idx.putFileRecord(record, existing)
+		}
+
+		symbols, err := ParseSource(relPath, lang, content)
+		if err != nil {
+			// 解析失败时仍然记录哈希，避免每次重复解析
+			stats.Failed++
+			symbols = nil
+		} else {
+			stats.Indexed++
+		}
+		record.Symbols = symbols
+
+		return idx.putFileRecord(record, existing)
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to walk codebase: %w", err)
+	}
+
+	// 清理已删除的文件
+	removed, err := idx.removeMissing(seen)
+	if err != nil {
+		return stats, err
+	}
+	stats.Removed = removed
+
+	return stats, nil
+}
+
+// getFileRecord 读取文件索引记录
+func (idx *Index) getFileRecord(relPath string) (*FileRecord, error) {
+	var record *FileRecord
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketFiles).Get([]byte(relPath))
+		if data == nil {
+			return nil
+		}
+		record = &FileRecord{}
+		return json.Unmarshal(data, record)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file record '%s': %w", relPath, err)
+	}
+	return record, nil
+}
+
+// putFileRecord 写入文件记录并替换其旧的符号
+func (idx *Index) putFileRecord(record, previous *FileRecord) error {
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		if previous != nil {
+			if err := deleteSymbols(tx, previous); err != nil {
+				return err
+			}
+		}
+
+		// 同一文件中的同名符号（如重载、同名方法）合并存储
+		grouped := make(map[SymbolKind]map[string][]Symbol)
+		for _, symbol := range record.Symbols {
+			if grouped[symbol.Kind] == nil {
+				grouped[symbol.Kind] = make(map[string][]Symbol)
+			}
+			grouped[symbol.Kind][symbol.Name] = append(grouped[symbol.Kind][symbol.Name], symbol)
+		}
+		for kind, byName := range grouped {
+			bucket := tx.Bucket(bucketSymbols[kind])
+			for name, symbols := range byName {
+				data, err := json.Marshal(symbols)
+				if err != nil {
+					return err
+				}
+				if err := bucket.Put(symbolKey(name, record.Path), data); err != nil {
+					return err
+				}
+			}
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketFiles).Put([]byte(record.Path), data)
+	})
+}
+
+// removeMissing 删除磁盘上已不存在的文件的索引
+func (idx *Index) removeMissing(seen map[string]bool) (int, error) {
+	removed := 0
+	err := idx.db.Update(func(tx *bolt.Tx) error {
+		files := tx.Bucket(bucketFiles)
+		stale := make([]*FileRecord, 0)
+		err := files.ForEach(func(k, v []byte) error {
+			if seen[string(k)] {
+				return nil
+			}
+			record := &FileRecord{}
+			if err := json.Unmarshal(v, record); err != nil {
+				record = &FileRecord{Path: string(k)}
+			}
+			stale = append(stale, record)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, record := range stale {
+			if err := deleteSymbols(tx, record); err != nil {
+				return err
+			}
+			if err := files.Delete([]byte(record.Path)); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove stale records: %w", err)
+	}
+	return removed, nil
+}
+
+// deleteSymbols 删除文件记录对应的所有符号
+func deleteSymbols(tx *bolt.Tx, record *FileRecord) error {
+	for _, symbol := range record.Symbols {
+		if err := tx.Bucket(bucketSymbols[symbol.Kind]).Delete(symbolKey(symbol.Name, record.Path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// symbolKey 构建符号键：名称\x00文件路径
+func symbolKey(name, relPath string) []byte {
+	return []byte(name + "\x00" + relPath)
+}
+
+// Search 按名称精确查找指定类型的符号
+func (idx *Index) Search(kind SymbolKind, name string) ([]Symbol, error) {
+	bucketName, ok := bucketSymbols[kind]
+	if !ok {
+		return nil, fmt.Errorf("unsupported symbol kind: %s", kind)
+	}
+
+	results := make([]Symbol, 0)
+	prefix := []byte(name + "\x00")
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketName).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var symbols []Symbol
+			if err := json.Unmarshal(v, &symbols); err != nil {
+				return err
+			}
+			results = append(results, symbols...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search index: %w", err)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].FilePath != results[j].FilePath {
+			return results[i].FilePath < results[j].FilePath
+		}
+		return results[i].StartLine < results[j].StartLine
+	})
+	return results, nil
+}
+
+// SearchFunction 查找函数
+func (idx *Index) SearchFunction(name string) ([]Symbol, error) {
+	return idx.Search(SymbolKindFunction, name)
+}
+
+// SearchClass 查找类（Go中为struct/interface类型）
+func (idx *Index) SearchClass(name string) ([]Symbol, error) {
+	return idx.Search(SymbolKindClass, name)
+}
+
+// SearchClassMethod 查找类方法
+func (idx *Index) SearchClassMethod(name string) ([]Symbol, error) {
+	return idx.Search(SymbolKindClassMethod, name)
+}
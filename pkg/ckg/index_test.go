@@ -0,0 +1,304 @@
+package ckg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const goSource = `package sample
+
+// Greeter 问候器
+type Greeter struct {
+	Name string
+}
+
+// Greet 问候
+func (g *Greeter) Greet() string {
+	return "hello " + g.Name
+}
+
+func NewGreeter(name string) *Greeter {
+	return &Greeter{Name: name}
+}
+`
+
+const pySource = `class Greeter:
+    def __init__(self, name):
+        self.name = name
+
+    def greet(self):
+        return "hello " + self.name
+
+
+def new_greeter(name):
+    return Greeter(name)
+`
+
+const tsSource = `export class Greeter {
+  constructor(private name: string) {}
+
+  greet(): string {
+    return "hello {" + this.name;
+  }
+}
+
+export function newGreeter(name: string): Greeter {
+  return new Greeter(name);
+}
+
+const shout = (s: string) => {
+  return s.toUpperCase();
+};
+`
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		lang     Language
+		content  string
+		expected map[SymbolKind][]string
+	}{
+		{
+			name:    "go",
+			lang:    LanguageGo,
+			content: goSource,
+			expected: map[SymbolKind][]string{
+				SymbolKindClass:       {"Greeter"},
+				SymbolKindClassMethod: {"Greet"},
+				SymbolKindFunction:    {"NewGreeter"},
+			},
+		},
+		{
+			name:    "python",
+			lang:    LanguagePython,
+			content: pySource,
+			expected: map[SymbolKind][]string{
+				SymbolKindClass:       {"Greeter"},
+				SymbolKindClassMethod: {"__init__", "greet"},
+				SymbolKindFunction:    {"new_greeter"},
+			},
+		},
+		{
+			name:    "typescript",
+			lang:    LanguageTypeScript,
+			content: tsSource,
+			expected: map[SymbolKind][]string{
+				SymbolKindClass:       {"Greeter"},
+				SymbolKindClassMethod: {"constructor", "greet"},
+				SymbolKindFunction:    {"newGreeter", "shout"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbols, err := ParseSource("sample", tt.lang, []byte(tt.content))
+			if err != nil {
+				t.Fatalf("Failed to parse source: %v", err)
+			}
+
+			got := make(map[SymbolKind][]string)
+			for _, symbol := range symbols {
+				got[symbol.Kind] = append(got[symbol.Kind], symbol.Name)
+			}
+
+			for kind, names := range tt.expected {
+				if len(got[kind]) != len(names) {
+					t.Fatalf("Expected %s %v, got %v", kind, names, got[kind])
+				}
+				for i, name := range names {
+					if got[kind][i] != name {
+						t.Errorf("Expected %s %s, got %s", kind, name, got[kind][i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseSource_MethodParentAndLines(t *testing.T) {
+	symbols, err := ParseSource("sample.py", LanguagePython, []byte(pySource))
+	if err != nil {
+		t.Fatalf("Failed to parse source: %v", err)
+	}
+
+	for _, symbol := range symbols {
+		if symbol.Name == "greet" {
+			if symbol.ParentClass != "Greeter" {
+				t.Errorf("Expected parent class 'Greeter', got '%s'", symbol.ParentClass)
+			}
+			if symbol.StartLine != 5 || symbol.EndLine != 6 {
+				t.Errorf("Expected lines 5-6, got %d-%d", symbol.StartLine, symbol.EndLine)
+			}
+			return
+		}
+	}
+	t.Fatal("Expected greet method to be found")
+}
+
+func TestIndex_IncrementalUpdate(t *testing.T) {
+	codebase := t.TempDir()
+	cacheDir := t.TempDir()
+
+	writeFile(t, codebase, "greeter.go", goSource)
+	writeFile(t, codebase, "greeter.py", pySource)
+
+	index, err := OpenIndex(codebase, cacheDir)
+	if err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	defer index.Close()
+
+	stats, err := index.Update()
+	if err != nil {
+		t.Fatalf("Failed to update index: %v", err)
+	}
+	if stats.Indexed != 2 {
+		t.Errorf("Expected 2 indexed files, got %d", stats.Indexed)
+	}
+
+	classes, err := index.SearchClass("Greeter")
+	if err != nil {
+		t.Fatalf("Failed to search class: %v", err)
+	}
+	if len(classes) != 2 {
+		t.Errorf("Expected 2 classes, got %d", len(classes))
+	}
+
+	// 未修改的文件不应重新解析
+	stats, err = index.Update()
+	if err != nil {
+		t.Fatalf("Failed to update index: %v", err)
+	}
+	if stats.Unchanged != 2 || stats.Indexed != 0 {
+		t.Errorf("Expected 2 unchanged files, got %+v", stats)
+	}
+
+	// 修改文件后旧符号应被替换
+	writeFile(t, codebase, "greeter.py", "def renamed():\n    pass\n")
+	if err := os.Remove(filepath.Join(codebase, "greeter.go")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	stats, err = index.Update()
+	if err != nil {
+		t.Fatalf("Failed to update index: %v", err)
+	}
+	if stats.Indexed != 1 || stats.Removed != 1 {
+		t.Errorf("Expected 1 indexed and 1 removed file, got %+v", stats)
+	}
+
+	classes, _ = index.SearchClass("Greeter")
+	if len(classes) != 0 {
+		t.Errorf("Expected stale classes to be removed, got %d", len(classes))
+	}
+
+	functions, _ := index.SearchFunction("renamed")
+	if len(functions) != 1 {
+		t.Errorf("Expected 1 function, got %d", len(functions))
+	}
+}
+
+func TestIndex_SkipsFilesWithUnchangedStat(t *testing.T) {
+	codebase := t.TempDir()
+	cacheDir := t.TempDir()
+	writeFile(t, codebase, "greeter.go", goSource)
+	path := filepath.Join(codebase, "greeter.go")
+
+	index, err := OpenIndex(codebase, cacheDir)
+	if err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	defer index.Close()
+	if _, err := index.Update(); err != nil {
+		t.Fatalf("Failed to update index: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+
+	// 大小和修改时间不变时不读取文件，即使内容已被替换
+	writeFile(t, codebase, "greeter.go", strings.Replace(goSource, "NewGreeter", "OldGreeter", 1))
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Failed to restore mtime: %v", err)
+	}
+	stats, err := index.Update()
+	if err != nil {
+		t.Fatalf("Failed to update index: %v", err)
+	}
+	if stats.Unchanged != 1 || stats.Indexed != 0 {
+		t.Errorf("Expected file with unchanged stat to be skipped, got %+v", stats)
+	}
+
+	// 修改时间变化后重新计算哈希并解析
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Failed to update mtime: %v", err)
+	}
+	stats, err = index.Update()
+	if err != nil {
+		t.Fatalf("Failed to update index: %v", err)
+	}
+	if stats.Indexed != 1 {
+		t.Errorf("Expected changed file to be reindexed, got %+v", stats)
+	}
+	if functions, _ := index.SearchFunction("OldGreeter"); len(functions) != 1 {
+		t.Errorf("Expected reindexed function, got %d", len(functions))
+	}
+
+	// 只有修改时间变化、内容相同的文件不重新解析
+	later = later.Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Failed to update mtime: %v", err)
+	}
+	stats, err = index.Update()
+	if err != nil {
+		t.Fatalf("Failed to update index: %v", err)
+	}
+	if stats.Unchanged != 1 || stats.Indexed != 0 {
+		t.Errorf("Expected touched file to stay unchanged, got %+v", stats)
+	}
+	if functions, _ := index.SearchFunction("OldGreeter"); len(functions) != 1 {
+		t.Errorf("Expected symbols to survive a touch, got %d", len(functions))
+	}
+}
+
+func TestIndex_Persistence(t *testing.T) {
+	codebase := t.TempDir()
+	cacheDir := t.TempDir()
+	writeFile(t, codebase, "greeter.go", goSource)
+
+	index, err := OpenIndex(codebase, cacheDir)
+	if err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	if _, err := index.Update(); err != nil {
+		t.Fatalf("Failed to update index: %v", err)
+	}
+	index.Close()
+
+	index, err = OpenIndex(codebase, cacheDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen index: %v", err)
+	}
+	defer index.Close()
+
+	methods, err := index.SearchClassMethod("Greet")
+	if err != nil {
+		t.Fatalf("Failed to search class method: %v", err)
+	}
+	if len(methods) != 1 || methods[0].ParentClass != "Greeter" {
+		t.Errorf("Expected persisted method Greeter.Greet, got %+v", methods)
+	}
+}
@@ -0,0 +1,367 @@
+package ckg
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// ParseSource 解析源码并提取符号
+func ParseSource(filePath string, lang Language, content []byte) ([]Symbol, error) {
+	switch lang {
+	case LanguageGo:
+		return parseGo(filePath, content)
+	case LanguagePython:
+		return parsePython(filePath, content), nil
+	case LanguageJavaScript, LanguageTypeScript:
+		return parseJS(filePath, lang, content), nil
+	default:
+		return nil, nil
+	}
+}
+
+// parseGo 使用go/ast解析Go源码
+func parseGo(filePath string, content []byte) ([]Symbol, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filePath, content, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make([]Symbol, 0)
+	body := func(node ast.Node) (string, int, int) {
+		start := fset.Position(node.Pos())
+		end := fset.Position(node.End())
+		return string(content[start.Offset:end.Offset]), start.Line, end.Line
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			text, startLine, endLine := body(d)
+			// 包含文档注释，便于检索时直接查看
+			if d.Doc != nil {
+				docStart := fset.Position(d.Doc.Pos())
+				text = string(content[docStart.Offset:fset.Position(d.End()).Offset])
+				startLine = docStart.Line
+			}
+
+			symbol := Symbol{
+				Kind:      SymbolKindFunction,
+				Name:      d.Name.Name,
+				FilePath:  filePath,
+				StartLine: startLine,
+				EndLine:   endLine,
+				Body:      text,
+				Language:  LanguageGo,
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				symbol.Kind = SymbolKindClassMethod
+				symbol.ParentClass = receiverTypeName(d.Recv.List[0].Type)
+			}
+			symbols = append(symbols, symbol)
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				switch ts.Type.(type) {
+				case *ast.StructType, *ast.InterfaceType:
+				default:
+					continue
+				}
+
+				var node ast.Node = ts
+				if len(d.Specs) == 1 {
+					node = d
+				}
+				text, startLine, endLine := body(node)
+				symbols = append(symbols, Symbol{
+					Kind:      SymbolKindClass,
+					Name:      ts.Name.Name,
+					FilePath:  filePath,
+					StartLine: startLine,
+					EndLine:   endLine,
+					Body:      text,
+					Language:  LanguageGo,
+				})
+			}
+		}
+	}
+
+	return symbols, nil
+}
+
+// receiverTypeName 获取方法接收者的类型名
+func receiverTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexListExpr:
+		return receiverTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	default:
+		return ""
+	}
+}
+
+var (
+	pyDefPattern   = regexp.MustCompile(`^(\s*)(?:async\s+)?def\s+([A-Za-z_][A-Za-z0-9_]*)\s*\(`)
+	pyClassPattern = regexp.MustCompile(`^(\s*)class\s+([A-Za-z_][A-Za-z0-9_]*)\s*[\(:]`)
+)
+
+// pyScope Python作用域
+type pyScope struct {
+	indent int
+	name   string
+	class  bool
+}
+
+// parsePython 基于缩进解析Python源码
+func parsePython(filePath string, content []byte) []Symbol {
+	lines := strings.Split(string(content), "\n")
+	symbols := make([]Symbol, 0)
+	scopes := make([]pyScope, 0)
+
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := indentWidth(line)
+
+		// 弹出已结束的作用域
+		for len(scopes) > 0 && indent <= scopes[len(scopes)-1].indent {
+			scopes = scopes[:len(scopes)-1]
+		}
+
+		if m := pyClassPattern.FindStringSubmatch(line); m != nil {
+			end := pythonBlockEnd(lines, i, indent)
+			symbols = append(symbols, Symbol{
+				Kind:      SymbolKindClass,
+				Name:      m[2],
+				FilePath:  filePath,
+				StartLine: i + 1,
+				EndLine:   end + 1,
+				Body:      strings.Join(lines[i:end+1], "\n"),
+				Language:  LanguagePython,
+			})
+			scopes = append(scopes, pyScope{indent: indent, name: m[2], class: true})
+			continue
+		}
+
+		if m := pyDefPattern.FindStringSubmatch(line); m != nil {
+			end := pythonBlockEnd(lines, i, indent)
+			symbol := Symbol{
+				Kind:      SymbolKindFunction,
+				Name:      m[2],
+				FilePath:  filePath,
+				StartLine: i + 1,
+				EndLine:   end + 1,
+				Body:      strings.Join(lines[i:end+1], "\n"),
+				Language:  LanguagePython,
+			}
+			if len(scopes) > 0 && scopes[len(scopes)-1].class {
+				symbol.Kind = SymbolKindClassMethod
+				symbol.ParentClass = scopes[len(scopes)-1].name
+			}
+			symbols = append(symbols, symbol)
+			scopes = append(scopes, pyScope{indent: indent, name: m[2]})
+		}
+	}
+
+	return symbols
+}
+
+// pythonBlockEnd 查找Python代码块的最后一行（下标）
+func pythonBlockEnd(lines []string, start, indent int) int {
+	end := start
+	for j := start + 1; j < len(lines); j++ {
+		trimmed := strings.TrimSpace(lines[j])
+		if trimmed == "" {
+			continue
+		}
+		if indentWidth(lines[j]) <= indent {
+			break
+		}
+		end = j
+	}
+	return end
+}
+
+// indentWidth 计算缩进宽度，制表符按4个空格计
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+var (
+	jsFunctionPattern = regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][A-Za-z0-9_$]*)\s*[<(]`)
+	jsArrowPattern    = regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][A-Za-z0-9_$]*)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|[A-Za-z_$][A-Za-z0-9_$]*\s*=>)`)
+	jsClassPattern    = regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][A-Za-z0-9_$]*)`)
+	jsMethodPattern   = regexp.MustCompile(`^\s*(?:(?:public|private|protected|static|async|readonly|override|abstract|get|set)\s+)*\*?\s*([A-Za-z_$#][A-Za-z0-9_$]*)\s*(?:<[^>]*>)?\s*\([^;]*$`)
+)
+
+// jsKeywords 不能作为方法名的关键字
+var jsKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true,
+	"return": true, "function": true, "new": true, "typeof": true, "await": true,
+}
+
+// parseJS 基于正则和括号匹配解析JavaScript/TypeScript源码
+func parseJS(filePath string, lang Language, content []byte) []Symbol {
+	lines := strings.Split(string(content), "\n")
+	symbols := make([]Symbol, 0)
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if m := jsClassPattern.FindStringSubmatch(line); m != nil {
+			end := braceBlockEnd(lines, i)
+			symbols = append(symbols, Symbol{
+				Kind:      SymbolKindClass,
+				Name:      m[1],
+				FilePath:  filePath,
+				StartLine: i + 1,
+				EndLine:   end + 1,
+				Body:      strings.Join(lines[i:end+1], "\n"),
+				Language:  lang,
+			})
+			symbols = append(symbols, parseJSClassMethods(filePath, lang, lines, i, end, m[1])...)
+			i = end
+			continue
+		}
+
+		name := ""
+		if m := jsFunctionPattern.FindStringSubmatch(line); m != nil {
+			name = m[1]
+		} else if m := jsArrowPattern.FindStringSubmatch(line); m != nil {
+			name = m[1]
+		}
+		if name == "" {
+			continue
+		}
+
+		end := braceBlockEnd(lines, i)
+		symbols = append(symbols, Symbol{
+			Kind:      SymbolKindFunction,
+			Name:      name,
+			FilePath:  filePath,
+			StartLine: i + 1,
+			EndLine:   end + 1,
+			Body:      strings.Join(lines[i:end+1], "\n"),
+			Language:  lang,
+		})
+		i = end
+	}
+
+	return symbols
+}
+
+// parseJSClassMethods 解析类体中的方法
+func parseJSClassMethods(filePath string, lang Language, lines []string, start, end int, className string) []Symbol {
+	symbols := make([]Symbol, 0)
+	depth := 0
+
+	for i := start; i <= end; i++ {
+		// 仅在类体的第一层查找方法
+		if depth == 1 {
+			if m := jsMethodPattern.FindStringSubmatch(lines[i]); m != nil && !jsKeywords[m[1]] {
+				methodEnd := braceBlockEnd(lines, i)
+				if methodEnd > end {
+					methodEnd = end
+				}
+				symbols = append(symbols, Symbol{
+					Kind:        SymbolKindClassMethod,
+					Name:        m[1],
+					FilePath:    filePath,
+					StartLine:   i + 1,
+					EndLine:     methodEnd + 1,
+					Body:        strings.Join(lines[i:methodEnd+1], "\n"),
+					ParentClass: className,
+					Language:    lang,
+				})
+				i = methodEnd
+				continue
+			}
+		}
+		depth += braceDelta(lines[i])
+	}
+
+	return symbols
+}
+
+// braceBlockEnd 从起始行开始匹配花括号，返回代码块结束行（下标）
+func braceBlockEnd(lines []string, start int) int {
+	depth := 0
+	opened := false
+	for i := start; i < len(lines); i++ {
+		delta := braceDelta(lines[i])
+		if strings.Contains(stripJSStrings(lines[i]), "{") {
+			opened = true
+		}
+		depth += delta
+		if opened && depth <= 0 {
+			return i
+		}
+		// 没有花括号的单行表达式
+		if !opened && strings.HasSuffix(strings.TrimSpace(lines[i]), ";") {
+			return i
+		}
+	}
+	return len(lines) - 1
+}
+
+// braceDelta 计算一行中花括号的净增量
+func braceDelta(line string) int {
+	line = stripJSStrings(line)
+	return strings.Count(line, "{") - strings.Count(line, "}")
+}
+
+// stripJSStrings 去除字符串字面量和行注释，避免干扰括号匹配
+func stripJSStrings(line string) string {
+	var sb strings.Builder
+	var quote rune
+	escaped := false
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if quote != 0 {
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+			continue
+		}
+		if r == '/' && i+1 < len(runes) && runes[i+1] == '/' {
+			break
+		}
+		if r == '"' || r == '\'' || r == '`' {
+			quote = r
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,66 @@
+package ckg
+
+// SymbolKind 符号类型
+type SymbolKind string
+
+const (
+	SymbolKindFunction    SymbolKind = "function"
+	SymbolKindClass       SymbolKind = "class"
+	SymbolKindClassMethod SymbolKind = "class_method"
+)
+
+// Language 源码语言
+type Language string
+
+const (
+	LanguageGo         Language = "go"
+	LanguagePython     Language = "python"
+	LanguageJavaScript Language = "javascript"
+	LanguageTypeScript Language = "typescript"
+)
+
+// Symbol 代码符号（函数、类或类方法）
+type Symbol struct {
+	Kind        SymbolKind `json:"kind"`
+	Name        string     `json:"name"`
+	FilePath    string     `json:"file_path"`
+	StartLine   int        `json:"start_line"`
+	EndLine     int        `json:"end_line"`
+	Body        string     `json:"body"`
+	ParentClass string     `json:"parent_class,omitempty"`
+	Language    Language   `json:"language"`
+}
+
+// FileRecord 文件索引记录
+type FileRecord struct {
+	Path    string   `json:"path"`
+	Hash    string   `json:"hash"`
+	Size    int64    `json:"size,omitempty"`
+	ModTime int64    `json:"mod_time,omitempty"`
+	Symbols []Symbol `json:"symbols"`
+}
+
+// UpdateStats 索引更新统计
+type UpdateStats struct {
+	Scanned   int `json:"scanned"`
+	Indexed   int `json:"indexed"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+	Failed    int `json:"failed"`
+}
+
+// languageForExt 根据扩展名获取语言
+func languageForExt(ext string) (Language, bool) {
+	switch ext {
+	case ".go":
+		return LanguageGo, true
+	case ".py":
+		return LanguagePython, true
+	case ".js", ".jsx", ".mjs", ".cjs":
+		return LanguageJavaScript, true
+	case ".ts", ".tsx":
+		return LanguageTypeScript, true
+	default:
+		return "", false
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -24,6 +25,10 @@ type ModelProvider struct {
 	Provider   string `yaml:"provider" json:"provider"`
 	BaseURL    string `yaml:"base_url,omitempty" json:"base_url,omitempty"`
 	APIVersion string `yaml:"api_version,omitempty" json:"api_version,omitempty"`
+
+	// 没有api_key时从文件读取或通过命令获取密钥，在创建客户端时才解析
+	APIKeyFile    string `yaml:"api_key_file,omitempty" json:"api_key_file,omitempty"`
+	APIKeyCommand string `yaml:"api_key_command,omitempty" json:"api_key_command,omitempty"`
 }
 
 // ModelConfig 模型配置
@@ -81,69 +86,88 @@ func (m *modelConfigAdapter) GetAPIVersion() string {
 	return ""
 }
 
-// ResolveConfigValues 解析配置值，支持命令行和环境变量覆盖
+// ResolveConfigValues 解析模型的提供商、API密钥和基础URL，优先级：命令行 > 环境变量 > 配置文件。
+// 参数为nil或空字符串表示命令行未指定；返回被覆盖的api_key和base_url的来源
 func (m *ModelConfig) ResolveConfigValues(
 	modelProviders map[string]ModelProvider,
 	provider *string,
 	model *string,
 	modelBaseURL *string,
 	apiKey *string,
-) error {
-	if model != nil {
+) (map[string]string, error) {
+	if model != nil && *model != "" {
 		m.Model = *model
 	}
 
-	if provider != nil {
+	if provider != nil && *provider != "" {
 		m.ModelProvider = *provider
 	}
 
-	// 解析提供商信息
-	if m.ModelProvider != "" {
-		if mp, exists := modelProviders[m.ModelProvider]; exists {
-			m.ResolvedProvider = &mp
-		} else {
-			// 创建新的提供商配置
-			newProvider := ModelProvider{
-				Provider: m.ModelProvider,
-			}
-			if apiKey != nil {
-				newProvider.APIKey = *apiKey
-			}
-			if modelBaseURL != nil {
-				newProvider.BaseURL = *modelBaseURL
-			}
-			m.ResolvedProvider = &newProvider
+	// 解析提供商信息，未在配置中定义的提供商只能使用命令行和环境变量中的密钥
+	origins := make(map[string]string)
+	if m.ModelProvider == "" {
+		return origins, nil
+	}
+	resolved := ModelProvider{Provider: m.ModelProvider}
+	if mp, exists := modelProviders[m.ModelProvider]; exists {
+		resolved = mp
+		if resolved.Provider == "" {
+			resolved.Provider = m.ModelProvider
 		}
 	}
 
 	// 从环境变量解析API密钥和基础URL
-	if m.ResolvedProvider != nil {
-		envVarAPIKey := strings.ToUpper(m.ResolvedProvider.Provider) + "_API_KEY"
-		envVarBaseURL := strings.ToUpper(m.ResolvedProvider.Provider) + "_BASE_URL"
+	envPrefix := providerEnvPrefix(resolved.Provider)
+	resolved.APIKey, origins["api_key"] = resolveConfigValue(apiKey, resolved.APIKey, envPrefix+"_API_KEY")
+	resolved.BaseURL, origins["base_url"] = resolveConfigValue(modelBaseURL, resolved.BaseURL, envPrefix+"_BASE_URL")
+	m.ResolvedProvider = &resolved
 
-		if resolvedAPIKey := resolveConfigValue(apiKey, m.ResolvedProvider.APIKey, envVarAPIKey); resolvedAPIKey != "" {
-			m.ResolvedProvider.APIKey = resolvedAPIKey
-		}
+	return origins, nil
+}
 
-		if resolvedBaseURL := resolveConfigValue(modelBaseURL, m.ResolvedProvider.BaseURL, envVarBaseURL); resolvedBaseURL != "" {
-			m.ResolvedProvider.BaseURL = resolvedBaseURL
-		}
-	}
+// 工具调用的审批策略
+const (
+	ApprovalAuto = "auto" // 直接执行所有工具调用
+	ApprovalAsk  = "ask"  // 执行可能修改工作区的工具调用前询问用户
+)
 
-	return nil
-}
+// 内置系统提示的语言
+const (
+	LanguageChinese = "zh"
+	LanguageEnglish = "en"
+)
 
 // AgentConfig 代理配置
 type AgentConfig struct {
-	EnableLakeview bool     `yaml:"enable_lakeview" json:"enable_lakeview"`
-	Model          string   `yaml:"model" json:"model"`
-	MaxSteps       int      `yaml:"max_steps" json:"max_steps"`
-	Tools          []string `yaml:"tools" json:"tools"`
+	EnableLakeview   bool     `yaml:"enable_lakeview" json:"enable_lakeview"`
+	Model            string   `yaml:"model" json:"model"`
+	MaxSteps         int      `yaml:"max_steps" json:"max_steps"`
+	Tools            []string `yaml:"tools" json:"tools"`                                               // 代理使用的工具名称，为空时使用默认工具
+	SystemPrompt     string   `yaml:"system_prompt,omitempty" json:"system_prompt,omitempty"`           // text/template格式的系统提示，为空时使用内置提示
+	SystemPromptFile string   `yaml:"system_prompt_file,omitempty" json:"system_prompt_file,omitempty"` // 包含系统提示模板的文件，相对路径相对于设置它的配置文件
+	Language         string   `yaml:"language,omitempty" json:"language,omitempty"`                     // 内置系统提示的语言，zh或en，为空时为zh
+	ApprovalPolicy   string   `yaml:"approval_policy,omitempty" json:"approval_policy,omitempty"`       // auto或ask，为空时为auto
+
+	// 代理在agents中的名称，由GetAgentConfig设置
+	Name string `yaml:"-" json:"-"`
+}
+
+// PromptTemplate 返回代理的系统提示模板，来自system_prompt或system_prompt_file，都没有设置时返回空字符串
+func (a *AgentConfig) PromptTemplate() (string, error) {
+	if a.SystemPrompt != "" || a.SystemPromptFile == "" {
+		return a.SystemPrompt, nil
+	}
+	data, err := os.ReadFile(a.SystemPromptFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read system prompt file: %w", err)
+	}
+	return string(data), nil
 }
 
 // LakeviewConfig Lakeview配置
 type LakeviewConfig struct {
-	MaxLines int `yaml:"max_lines" json:"max_lines"`
+	Model    string `yaml:"model,omitempty" json:"model,omitempty"` // 引用models中的配置名称，为空时使用代理的模型
+	MaxLines int    `yaml:"max_lines" json:"max_lines"`
 }
 
 // MCPServerConfig MCP服务器配置
@@ -152,6 +176,12 @@ type MCPServerConfig struct {
 	Args    []string `yaml:"args" json:"args"`
 }
 
+// ToolConfig 单个工具的配置，按工具名称放在tools下，对所有使用该工具的代理生效
+type ToolConfig struct {
+	Timeout         int      `yaml:"timeout,omitempty" json:"timeout,omitempty"`                   // 执行超时（秒），0表示使用工具的默认值
+	AllowedCommands []string `yaml:"allowed_commands,omitempty" json:"allowed_commands,omitempty"` // bash允许执行的命令，为空时不限制
+}
+
 // Config 主配置结构
 type Config struct {
 	Agents          map[string]AgentConfig     `yaml:"agents" json:"agents"`
@@ -160,42 +190,45 @@ type Config struct {
 	Lakeview        LakeviewConfig             `yaml:"lakeview" json:"lakeview"`
 	MCPServers      map[string]MCPServerConfig `yaml:"mcp_servers,omitempty" json:"mcp_servers,omitempty"`
 	AllowMCPServers []string                   `yaml:"allow_mcp_servers,omitempty" json:"allow_mcp_servers,omitempty"`
+	Tools           map[string]ToolConfig      `yaml:"tools,omitempty" json:"tools,omitempty"`
+
+	// 每个配置项的来源和在配置文件中的位置，键为以.分隔的YAML路径
+	origins   map[string]string
+	positions map[string]Position
+	// 加载时发现的未知字段和已废弃字段
+	warnings []Problem
+	// 加载的配置层，按优先级从低到高排列
+	layers []Layer
 }
 
-// LoadConfig 加载配置文件
+// LoadConfig 加载单个配置文件，不查找系统、用户和项目配置层
 func LoadConfig(configFile string) (*Config, error) {
-	// 读取配置文件
-	data, err := os.ReadFile(configFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
-
-	// 解析环境变量占位符
-	configContent := string(data)
-	configContent = os.ExpandEnv(configContent)
+	return LoadLayers([]Layer{{Name: LayerFile, Path: configFile}})
+}
 
-	// 使用YAML库解析配置
-	var config Config
-	if err := yaml.Unmarshal([]byte(configContent), &config); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+// GetAgentConfig 获取agents中名为name的代理配置
+func (c *Config) GetAgentConfig(name string) (*AgentConfig, error) {
+	agentConfig, exists := c.Agents[name]
+	if !exists {
+		return nil, &ConfigError{Message: fmt.Sprintf("agent '%s' not found, available agents: %s", name, strings.Join(c.AgentNames(), ", "))}
 	}
+	agentConfig.Name = name
+	return &agentConfig, nil
+}
 
-	fmt.Println("config", config)
-
-	// 解析模型配置中的提供商信息
-	if err := config.resolveModelProviders(); err != nil {
-		return nil, fmt.Errorf("failed to resolve model providers: %w", err)
+// AgentNames 返回所有代理的名称，按名称排序
+func (c *Config) AgentNames() []string {
+	names := make([]string, 0, len(c.Agents))
+	for name := range c.Agents {
+		names = append(names, name)
 	}
-
-	return &config, nil
+	sort.Strings(names)
+	return names
 }
 
 // GetTraeAgentConfig 获取TraeAgent配置
 func (c *Config) GetTraeAgentConfig() (*AgentConfig, error) {
-	if agentConfig, exists := c.Agents["trae_agent"]; exists {
-		return &agentConfig, nil
-	}
-	return nil, &ConfigError{Message: "trae_agent configuration not found"}
+	return c.GetAgentConfig(DefaultAgentName)
 }
 
 // GetModelConfig 获取模型配置
@@ -206,6 +239,15 @@ func (c *Config) GetModelConfig(modelName string) (*ModelConfig, error) {
 	return nil, &ConfigError{Message: fmt.Sprintf("model configuration '%s' not found", modelName)}
 }
 
+// GetLakeviewModelConfig 获取Lakeview使用的模型配置，未指定时使用代理的模型
+func (c *Config) GetLakeviewModelConfig(agentConfig *AgentConfig) (*ModelConfig, error) {
+	modelName := c.Lakeview.Model
+	if modelName == "" {
+		modelName = agentConfig.Model
+	}
+	return c.GetModelConfig(modelName)
+}
+
 // GetModelProvider 获取模型提供商配置
 func (c *Config) GetModelProvider(providerName string) (*ModelProvider, error) {
 	if providerConfig, exists := c.ModelProviders[providerName]; exists {
@@ -214,17 +256,17 @@ func (c *Config) GetModelProvider(providerName string) (*ModelProvider, error) {
 	return nil, &ConfigError{Message: fmt.Sprintf("model provider '%s' not found", providerName)}
 }
 
-// resolveConfigValue 解析配置值，优先级：命令行 > 环境变量 > 配置文件
-func resolveConfigValue(cliValue *string, configValue string, envVar string) string {
+// resolveConfigValue 解析配置值，优先级：命令行 > 环境变量 > 配置文件，同时返回值的来源（来自配置文件时为空）
+func resolveConfigValue(cliValue *string, configValue string, envVar string) (string, string) {
 	if cliValue != nil && *cliValue != "" {
-		return *cliValue
+		return *cliValue, OriginCommandLine
 	}
 
 	if envValue := os.Getenv(envVar); envValue != "" {
-		return envValue
+		return envValue, envOrigin(envVar)
 	}
 
-	return configValue
+	return configValue, ""
 }
 
 // SaveConfig 保存配置到文件
@@ -237,60 +279,6 @@ func (c *Config) SaveConfig(filename string) error {
 	return os.WriteFile(filename, data, 0644)
 }
 
-// Validate 验证配置
-func (c *Config) Validate() error {
-	// 验证必需的配置项
-	if len(c.Agents) == 0 {
-		return &ConfigError{Message: "at least one agent must be configured"}
-	}
-
-	if len(c.ModelProviders) == 0 {
-		return &ConfigError{Message: "at least one model provider must be configured"}
-	}
-
-	if len(c.Models) == 0 {
-		return &ConfigError{Message: "at least one model must be configured"}
-	}
-
-	// 验证每个代理配置
-	for agentName, agentConfig := range c.Agents {
-		if agentConfig.Model == "" {
-			return &ConfigError{Message: fmt.Sprintf("agent '%s' must specify a model", agentName)}
-		}
-
-		if _, exists := c.Models[agentConfig.Model]; !exists {
-			return &ConfigError{Message: fmt.Sprintf("agent '%s' references undefined model '%s'", agentName, agentConfig.Model)}
-		}
-	}
-
-	// 验证每个模型配置
-	for modelName, modelConfig := range c.Models {
-		if modelConfig.Model == "" {
-			return &ConfigError{Message: fmt.Sprintf("model '%s' must specify a model name", modelName)}
-		}
-
-		if modelConfig.ModelProvider == "" {
-			return &ConfigError{Message: fmt.Sprintf("model '%s' must specify a provider", modelName)}
-		}
-
-		if _, exists := c.ModelProviders[modelConfig.ModelProvider]; !exists {
-			return &ConfigError{Message: fmt.Sprintf("model '%s' references undefined provider '%s'", modelName, modelConfig.ModelProvider)}
-		}
-
-		// 检查API密钥
-		provider := c.ModelProviders[modelConfig.ModelProvider]
-		if provider.APIKey == "" {
-			// 检查环境变量
-			envVar := strings.ToUpper(provider.Provider) + "_API_KEY"
-			if os.Getenv(envVar) == "" {
-				return &ConfigError{Message: fmt.Sprintf("model '%s' has no API key configured and no environment variable '%s' found", modelName, envVar)}
-			}
-		}
-	}
-
-	return nil
-}
-
 // GetEnv 获取环境变量值
 func (c *Config) GetEnv(key string) string {
 	return os.Getenv(key)
@@ -324,8 +312,8 @@ func (c *Config) GetEnvBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
-// resolveModelProviders 解析模型配置中的提供商信息
-func (c *Config) resolveModelProviders() error {
+// resolveModelProviders 解析模型配置中的提供商信息，未定义的提供商由Validate报告
+func (c *Config) resolveModelProviders() {
 	for modelName, modelConfig := range c.Models {
 		if modelConfig.ModelProvider != "" {
 			if provider, exists := c.ModelProviders[modelConfig.ModelProvider]; exists {
@@ -333,10 +321,7 @@ func (c *Config) resolveModelProviders() error {
 				updatedConfig := modelConfig
 				updatedConfig.ResolvedProvider = &provider
 				c.Models[modelName] = updatedConfig
-			} else {
-				return &ConfigError{Message: fmt.Sprintf("model '%s' references undefined provider '%s'", modelName, modelConfig.ModelProvider)}
 			}
 		}
 	}
-	return nil
 }
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -130,7 +132,8 @@ func TestConfig_Validate(t *testing.T) {
 	config := &Config{
 		Agents: map[string]AgentConfig{
 			"test_agent": {
-				Model: "test_model",
+				Model:    "test_model",
+				MaxSteps: 10,
 			},
 		},
 		ModelProviders: map[string]ModelProvider{
@@ -168,7 +171,8 @@ func TestConfig_Validate(t *testing.T) {
 	// 恢复配置
 	config.Agents = map[string]AgentConfig{
 		"test_agent": {
-			Model: "test_model",
+			Model:    "test_model",
+			MaxSteps: 10,
 		},
 	}
 
@@ -186,6 +190,13 @@ func TestConfig_Validate(t *testing.T) {
 		},
 	}
 
+	// 测试Lakeview引用不存在的模型
+	config.Lakeview.Model = "missing_model"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected validation error for undefined lakeview model")
+	}
+	config.Lakeview.Model = ""
+
 	// 测试缺少模型
 	config.Models = nil
 	if err := config.Validate(); err == nil {
@@ -219,6 +230,29 @@ func TestConfig_GetTraeAgentConfig(t *testing.T) {
 	}
 }
 
+func TestConfig_GetAgentConfig(t *testing.T) {
+	config := &Config{
+		Agents: map[string]AgentConfig{
+			"trae_agent": {Model: "test_model"},
+			"triage":     {Model: "small_model", ApprovalPolicy: ApprovalAsk},
+		},
+	}
+
+	agentConfig, err := config.GetAgentConfig("triage")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if agentConfig.Name != "triage" || agentConfig.Model != "small_model" || agentConfig.ApprovalPolicy != ApprovalAsk {
+		t.Errorf("Expected triage agent config, got %+v", agentConfig)
+	}
+
+	// 未知代理的错误列出可用的代理
+	_, err = config.GetAgentConfig("fixer")
+	if err == nil || !strings.Contains(err.Error(), "available agents: trae_agent, triage") {
+		t.Errorf("Expected error listing available agents, got %v", err)
+	}
+}
+
 func TestConfig_GetModelConfig(t *testing.T) {
 	config := &Config{
 		Models: map[string]ModelConfig{
@@ -243,3 +277,102 @@ func TestConfig_GetModelConfig(t *testing.T) {
 		t.Error("Expected error for nonexistent model")
 	}
 }
+
+func TestConfig_GetLakeviewModelConfig(t *testing.T) {
+	config := &Config{
+		Models: map[string]ModelConfig{
+			"agent_model":    {Model: "gpt-4o"},
+			"lakeview_model": {Model: "gpt-4o-mini"},
+		},
+	}
+	agentConfig := &AgentConfig{Model: "agent_model"}
+
+	// 未指定时使用代理的模型
+	modelConfig, err := config.GetLakeviewModelConfig(agentConfig)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if modelConfig.Model != "gpt-4o" {
+		t.Errorf("Expected fallback model 'gpt-4o', got '%s'", modelConfig.Model)
+	}
+
+	config.Lakeview.Model = "lakeview_model"
+	modelConfig, err = config.GetLakeviewModelConfig(agentConfig)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if modelConfig.Model != "gpt-4o-mini" {
+		t.Errorf("Expected lakeview model 'gpt-4o-mini', got '%s'", modelConfig.Model)
+	}
+}
+
+func TestConfig_ApplyOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trae_config.yaml")
+	content := `
+agents:
+  trae_agent:
+    model: agent_model
+    max_steps: 100
+model_providers:
+  openai:
+    provider: openai
+    base_url: https://file.example.com
+models:
+  agent_model:
+    model_provider: openai
+    model: gpt-4o
+  other_model:
+    model_provider: openai
+    model: gpt-4o-mini
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	t.Setenv("OPENAI_API_KEY", "env-key")
+	t.Setenv(EnvModel, "env-model")
+	t.Setenv(EnvMaxSteps, "50")
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	// 命令行优先于环境变量，环境变量优先于配置文件
+	if err := config.ApplyOverrides("trae_agent", Overrides{MaxSteps: 20, APIKey: "flag-key"}); err != nil {
+		t.Fatalf("Failed to apply overrides: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected overridden config to be valid, got %v", err)
+	}
+
+	if steps := config.Agents["trae_agent"].MaxSteps; steps != 20 {
+		t.Errorf("Expected max_steps from command line, got %d", steps)
+	}
+	agentModel := config.Models["agent_model"]
+	if agentModel.Model != "env-model" || agentModel.ResolvedProvider.APIKey != "flag-key" {
+		t.Errorf("Expected model from env and key from command line, got %s / %s", agentModel.Model, agentModel.ResolvedProvider.APIKey)
+	}
+	// 命令行和TRAE_*只作用于代理的模型，提供商环境变量作用于所有模型
+	otherModel := config.Models["other_model"]
+	if otherModel.Model != "gpt-4o-mini" || otherModel.ResolvedProvider.APIKey != "env-key" {
+		t.Errorf("Expected other model to keep its name and use the env key, got %s / %s", otherModel.Model, otherModel.ResolvedProvider.APIKey)
+	}
+
+	origins := map[string]string{
+		"agents.trae_agent.max_steps":  OriginCommandLine,
+		"models.agent_model.model":     "env " + EnvModel,
+		"models.agent_model.api_key":   OriginCommandLine,
+		"models.agent_model.base_url":  "file " + path,
+		"models.other_model.api_key":   "env OPENAI_API_KEY",
+		"models.other_model.max_steps": OriginDefault,
+	}
+	for key, expected := range origins {
+		if origin := config.Origin(key); origin != expected {
+			t.Errorf("Expected origin of %s to be %q, got %q", key, expected, origin)
+		}
+	}
+
+	t.Setenv(EnvMaxSteps, "many")
+	if err := config.ApplyOverrides("trae_agent", Overrides{}); err == nil {
+		t.Error("Expected error for invalid max steps environment variable")
+	}
+}
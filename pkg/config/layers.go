@@ -0,0 +1,355 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 配置层的名称，按优先级从低到高排列
+const (
+	LayerSystem  = "system"
+	LayerUser    = "user"
+	LayerProject = "project"
+	LayerFile    = "file"
+)
+
+// SystemConfigPath 系统级配置文件路径
+const SystemConfigPath = "/etc/trage/config.yaml"
+
+// ProjectConfigFile 项目级配置文件相对仓库根目录的路径，可以提交到仓库中供团队共享
+const ProjectConfigFile = ".trae/config.yaml"
+
+// Layer 一个配置文件层
+type Layer struct {
+	Name string
+	Path string
+}
+
+// origin 来自该层的配置项的来源描述，如project /repo/.trae/config.yaml
+func (l Layer) origin() string {
+	return l.Name + " " + l.Path
+}
+
+// UserConfigPath 用户级配置文件路径：$XDG_CONFIG_HOME/trage/config.yaml，默认~/.config/trage/config.yaml
+func UserConfigPath() string {
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return filepath.Join(configHome, "trage", "config.yaml")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "trage", "config.yaml")
+}
+
+// ProjectConfigPath 返回dir所在仓库的.trae/config.yaml路径。仓库根目录是从dir向上第一个包含.git的目录，
+// 不在仓库中时使用dir本身；用户主目录下的.trae保存的是会话等数据，不作为项目配置
+func ProjectConfigPath(dir string) string {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	root := absDir
+	for current := absDir; ; {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			root = current
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+
+	if home, err := os.UserHomeDir(); err == nil && filepath.Clean(home) == root {
+		return ""
+	}
+	return filepath.Join(root, ProjectConfigFile)
+}
+
+// DiscoverLayers 返回存在的配置层，按优先级从低到高排列：系统、用户、dir所在仓库的项目配置、configFile。
+// required为true表示configFile是明确指定的，不存在时返回错误；否则不存在时跳过。没有找到任何配置文件时返回错误
+func DiscoverLayers(dir, configFile string, required bool) ([]Layer, error) {
+	candidates := []Layer{
+		{Name: LayerSystem, Path: SystemConfigPath},
+		{Name: LayerUser, Path: UserConfigPath()},
+		{Name: LayerProject, Path: ProjectConfigPath(dir)},
+	}
+
+	var layers []Layer
+	seen := make(map[string]bool)
+	add := func(layer Layer) {
+		absPath, err := filepath.Abs(layer.Path)
+		if err != nil || seen[absPath] {
+			return
+		}
+		seen[absPath] = true
+		layers = append(layers, layer)
+	}
+
+	for _, layer := range candidates {
+		if layer.Path == "" {
+			continue
+		}
+		if info, err := os.Stat(layer.Path); err == nil && !info.IsDir() {
+			add(layer)
+		}
+	}
+
+	if configFile != "" {
+		if _, err := os.Stat(configFile); err == nil {
+			add(Layer{Name: LayerFile, Path: configFile})
+		} else if required || !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	if len(layers) == 0 {
+		searched := []string{}
+		for _, layer := range candidates {
+			if layer.Path != "" {
+				searched = append(searched, layer.Path)
+			}
+		}
+		if configFile != "" {
+			searched = append(searched, configFile)
+		}
+		return nil, &ConfigError{Message: fmt.Sprintf("no config file found (searched %s)", strings.Join(searched, ", "))}
+	}
+	return layers, nil
+}
+
+// LoadLayers 依次加载配置层并深度合并：映射按键合并，标量和列表由后面的层整体替换。
+// 每个配置项的来源记录为最后设置它的层
+func LoadLayers(layers []Layer) (*Config, error) {
+	var config Config
+	var merged *yaml.Node
+	var problems []Problem
+	for _, layer := range layers {
+		root, err := readLayer(layer)
+		if err != nil {
+			return nil, err
+		}
+		if root == nil {
+			continue
+		}
+		// 类型错误在合并前按文件检查并汇总，未知字段和已废弃字段只作为警告
+		for _, problem := range checkSchema(root, layer.Path) {
+			if problem.Warning {
+				config.warnings = append(config.warnings, problem)
+			} else {
+				problems = append(problems, problem)
+			}
+		}
+		config.recordOrigins(root, "", layer)
+		merged = mergeNodes(merged, root)
+	}
+	if len(problems) > 0 {
+		return nil, newValidationError(problems)
+	}
+
+	if merged != nil {
+		if err := merged.Decode(&config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+		}
+	}
+	if problems := config.checkProjectLayer(); len(problems) > 0 {
+		return nil, newValidationError(problems)
+	}
+	config.layers = layers
+
+	// 解析模型配置中的提供商信息
+	config.resolveModelProviders()
+	config.resolvePromptFiles()
+
+	return &config, nil
+}
+
+// resolvePromptFiles 将代理的system_prompt_file解析为绝对路径，相对路径相对于设置它的配置文件所在的目录
+func (c *Config) resolvePromptFiles() {
+	for name, agentConfig := range c.Agents {
+		path := expandHome(agentConfig.SystemPromptFile)
+		if path == "" || filepath.IsAbs(path) {
+			agentConfig.SystemPromptFile = path
+		} else if position, exists := c.positions["agents."+name+".system_prompt_file"]; exists {
+			agentConfig.SystemPromptFile = filepath.Join(filepath.Dir(position.File), path)
+		}
+		c.Agents[name] = agentConfig
+	}
+}
+
+// projectRestrictedFields 项目配置中不允许设置的提供商字段。项目配置随仓库提交并自动加载，
+// 否则仓库可以执行任意命令（api_key_command）或把用户的API密钥发送到仓库指定的地址（base_url）
+var projectRestrictedFields = map[string]bool{
+	"api_key":         true,
+	"api_key_file":    true,
+	"api_key_command": true,
+	"base_url":        true,
+}
+
+// checkProjectLayer 按记录的来源检查生效的配置项中来自项目配置、会削弱用户安全设置的项：
+// 提供商的密钥和地址、代理的审批策略、工具配置（如bash允许的命令），以及指向仓库外的系统提示文件
+func (c *Config) checkProjectLayer() []Problem {
+	var problems []Problem
+	report := func(path, message string) {
+		problems = append(problems, Problem{Position: c.position(path), Path: path, Message: message})
+	}
+	for path, origin := range c.origins {
+		if !strings.HasPrefix(origin, LayerProject+" ") {
+			continue
+		}
+		parts := strings.Split(path, ".")
+		switch {
+		case len(parts) == 3 && parts[0] == "model_providers" && projectRestrictedFields[parts[2]]:
+			report(path, fmt.Sprintf("%s cannot be set in the project config %s, move it to the user config %s", parts[2], ProjectConfigFile, UserConfigPath()))
+		case len(parts) == 3 && parts[0] == "agents" && parts[2] == "approval_policy":
+			report(path, fmt.Sprintf("approval_policy cannot be set in the project config %s, set it in the user config %s", ProjectConfigFile, UserConfigPath()))
+		case parts[0] == "tools":
+			report(path, fmt.Sprintf("tool settings cannot be set in the project config %s, set them in the user config %s", ProjectConfigFile, UserConfigPath()))
+		case len(parts) == 3 && parts[0] == "agents" && parts[2] == "system_prompt_file":
+			if file := c.Agents[parts[1]].SystemPromptFile; !isProjectRelativePath(file) {
+				report(path, fmt.Sprintf("system_prompt_file in the project config %s must be a relative path inside the repository, got '%s'", ProjectConfigFile, file))
+			}
+		}
+	}
+	return problems
+}
+
+// isProjectRelativePath 检查路径是否是不离开当前目录的相对路径
+func isProjectRelativePath(path string) bool {
+	if path == "" {
+		return true
+	}
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "~") || filepath.VolumeName(path) != "" {
+		return false
+	}
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// readLayer 检查配置层的权限后读取并解析环境变量占位符，返回文档的根节点，空文件返回nil。
+// 项目配置由仓库控制，不解析环境变量占位符，避免把环境变量中的密钥带入系统提示等配置项
+func readLayer(layer Layer) (*yaml.Node, error) {
+	data, err := os.ReadFile(layer.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	info, err := os.Stat(layer.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := checkSecretPermissions(layer.Path, info, data); err != nil {
+		return nil, err
+	}
+
+	content := string(data)
+	if layer.Name != LayerProject {
+		content = os.ExpandEnv(content)
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &document); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config %s: %w", layer.Path, err)
+	}
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil, nil
+	}
+	return document.Content[0], nil
+}
+
+// mergeNodes 将src合并到dst：两者都是映射时按键递归合并，否则src替换dst
+func mergeNodes(dst, src *yaml.Node) *yaml.Node {
+	if dst == nil || dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return src
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		if index := mappingIndex(dst, key.Value); index >= 0 {
+			dst.Content[index+1] = mergeNodes(dst.Content[index+1], value)
+		} else {
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+	return dst
+}
+
+// mappingIndex 返回映射节点中键key的位置，不存在时返回-1
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Warnings 返回加载配置时发现的未知字段和已废弃字段
+func (c *Config) Warnings() []Problem {
+	return c.warnings
+}
+
+// Layers 返回加载的配置层，按优先级从低到高排列
+func (c *Config) Layers() []Layer {
+	return c.layers
+}
+
+// Setting 一个生效的配置项
+type Setting struct {
+	Path   string // 以.分隔的YAML路径
+	Value  string
+	Origin string
+}
+
+// Settings 返回所有生效的配置项及其来源，列表值格式化为[a, b]，API密钥被隐藏
+func (c *Config) Settings() ([]Setting, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	var settings []Setting
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				if path != "" {
+					key = path + "." + key
+				}
+				walk(node.Content[i+1], key)
+			}
+		case yaml.SequenceNode:
+			values := make([]string, 0, len(node.Content))
+			for _, child := range node.Content {
+				values = append(values, child.Value)
+			}
+			settings = append(settings, Setting{Path: path, Value: "[" + strings.Join(values, ", ") + "]", Origin: c.Origin(path)})
+		default:
+			value := node.Value
+			if strings.HasSuffix(path, ".api_key") {
+				value = RedactSecret(value)
+			}
+			settings = append(settings, Setting{Path: path, Value: value, Origin: c.Origin(path)})
+		}
+	}
+	walk(&document, "")
+	return settings, nil
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultAgentName 默认使用的代理配置名称
+const DefaultAgentName = "trae_agent"
+
+// 覆盖代理配置的环境变量，优先级低于命令行参数、高于配置文件
+const (
+	EnvAgent    = "TRAE_AGENT"
+	EnvProvider = "TRAE_PROVIDER"
+	EnvModel    = "TRAE_MODEL"
+	EnvMaxSteps = "TRAE_MAX_STEPS"
+)
+
+// 配置值的来源
+const (
+	OriginDefault     = "default"
+	OriginCommandLine = "command line"
+)
+
+// Overrides 命令行指定的覆盖值，零值表示未指定
+type Overrides struct {
+	Provider     string
+	Model        string
+	ModelBaseURL string
+	APIKey       string
+	MaxSteps     int
+}
+
+// ApplyOverrides 将命令行参数和环境变量应用到配置，优先级：命令行 > 环境变量 > 配置文件。
+// provider、model、max_steps以及--api-key、--model-base-url只作用于agentName代理及其模型，
+// <PROVIDER>_API_KEY和<PROVIDER>_BASE_URL环境变量作用于所有模型
+func (c *Config) ApplyOverrides(agentName string, overrides Overrides) error {
+	agentConfig, exists := c.Agents[agentName]
+	if !exists {
+		if overrides.Provider != "" || overrides.Model != "" || overrides.MaxSteps > 0 {
+			return &ConfigError{Message: fmt.Sprintf("agent '%s' not found", agentName)}
+		}
+		// 没有该代理时只解析各模型的密钥和地址
+		return c.resolveModels("", overrides)
+	}
+	agentPath := "agents." + agentName
+
+	if overrides.MaxSteps > 0 {
+		agentConfig.MaxSteps = overrides.MaxSteps
+		c.SetOrigin(agentPath+".max_steps", OriginCommandLine)
+	} else if value := os.Getenv(EnvMaxSteps); value != "" {
+		maxSteps, err := strconv.Atoi(value)
+		if err != nil || maxSteps <= 0 {
+			return &ConfigError{Message: fmt.Sprintf("invalid %s value '%s': must be a positive integer", EnvMaxSteps, value)}
+		}
+		agentConfig.MaxSteps = maxSteps
+		c.SetOrigin(agentPath+".max_steps", envOrigin(EnvMaxSteps))
+	}
+	c.Agents[agentName] = agentConfig
+
+	return c.resolveModels(agentConfig.Model, overrides)
+}
+
+// resolveModels 解析所有模型的提供商、密钥和地址，命令行参数和TRAE_*环境变量只作用于agentModel
+func (c *Config) resolveModels(agentModel string, overrides Overrides) error {
+	for name, modelConfig := range c.Models {
+		modelPath := "models." + name
+		var provider, model, baseURL, apiKey *string
+		if name == agentModel {
+			provider = c.overrideValue(modelPath+".model_provider", overrides.Provider, EnvProvider)
+			model = c.overrideValue(modelPath+".model", overrides.Model, EnvModel)
+			baseURL = &overrides.ModelBaseURL
+			apiKey = &overrides.APIKey
+		}
+
+		origins, err := modelConfig.ResolveConfigValues(c.ModelProviders, provider, model, baseURL, apiKey)
+		if err != nil {
+			return fmt.Errorf("failed to resolve model '%s': %w", name, err)
+		}
+		c.Models[name] = modelConfig
+
+		// 没有被覆盖的密钥和地址来自提供商配置，密钥也可能来自api_key_file或api_key_command
+		providerPath := "model_providers." + modelConfig.ModelProvider
+		for _, field := range []string{"api_key", "base_url"} {
+			origin := origins[field]
+			if origin == "" {
+				origin = c.Origin(providerPath + "." + field)
+			}
+			if field == "api_key" && origin == OriginDefault {
+				for _, secretField := range []string{"api_key_file", "api_key_command"} {
+					if secretOrigin := c.Origin(providerPath + "." + secretField); secretOrigin != OriginDefault {
+						origin = secretOrigin + " (" + secretField + ")"
+						break
+					}
+				}
+			}
+			c.SetOrigin(modelPath+"."+field, origin)
+		}
+	}
+	return nil
+}
+
+// overrideValue 返回命令行或环境变量中的覆盖值并记录来源，都未指定时返回nil
+func (c *Config) overrideValue(path, cliValue, envVar string) *string {
+	if cliValue != "" {
+		c.SetOrigin(path, OriginCommandLine)
+		return &cliValue
+	}
+	if value := os.Getenv(envVar); value != "" {
+		c.SetOrigin(path, envOrigin(envVar))
+		return &value
+	}
+	return nil
+}
+
+// Origin 返回配置项的来源，path是以.分隔的YAML路径（如models.trae_agent_model.model），
+// 没有在任何地方设置的配置项返回default
+func (c *Config) Origin(path string) string {
+	if origin, exists := c.origins[path]; exists {
+		return origin
+	}
+	return OriginDefault
+}
+
+// SetOrigin 记录配置项的来源
+func (c *Config) SetOrigin(path, origin string) {
+	if c.origins == nil {
+		c.origins = make(map[string]string)
+	}
+	c.origins[path] = origin
+}
+
+// recordOrigins 将YAML文档中每个叶子配置项（包括列表）的来源记录为layer，并记录其在文件中的位置
+func (c *Config) recordOrigins(node *yaml.Node, path string, layer Layer) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			c.recordOrigins(child, path, layer)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := joinPath(path, key.Value)
+			// 标量定位到值，映射和列表定位到键
+			at := value
+			if value.Kind == yaml.MappingNode || value.Kind == yaml.SequenceNode {
+				at = key
+			}
+			c.setPosition(keyPath, Position{File: layer.Path, Line: at.Line, Column: at.Column})
+			c.recordOrigins(value, keyPath, layer)
+		}
+	default:
+		if path != "" {
+			c.SetOrigin(path, layer.origin())
+		}
+	}
+}
+
+// setPosition 记录配置项在配置文件中的位置
+func (c *Config) setPosition(path string, position Position) {
+	if c.positions == nil {
+		c.positions = make(map[string]Position)
+	}
+	c.positions[path] = position
+}
+
+// envOrigin 来自环境变量的来源描述
+func envOrigin(envVar string) string {
+	return "env " + envVar
+}
+
+// providerEnvPrefix 提供商环境变量的前缀，如openai对应OPENAI
+func providerEnvPrefix(provider string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(provider))
+}
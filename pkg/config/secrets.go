@@ -0,0 +1,148 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// apiKeyCommandTimeout api_key_command的最长执行时间
+const apiKeyCommandTimeout = 30 * time.Second
+
+// redactedSecret 隐藏后的密钥
+const redactedSecret = "****"
+
+// RedactSecret 隐藏密钥，只保留足够长的密钥的最后4个字符用于辨认，空字符串保持不变
+func RedactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) < 16 {
+		return redactedSecret
+	}
+	return redactedSecret + secret[len(secret)-4:]
+}
+
+// HasAPIKey 是否配置了API密钥，包括尚未读取的api_key_file和api_key_command
+func (p *ModelProvider) HasAPIKey() bool {
+	return p.APIKey != "" || p.APIKeyFile != "" || p.APIKeyCommand != ""
+}
+
+// ResolveAPIKey 返回API密钥，优先级：api_key（包括命令行和环境变量的覆盖）> api_key_file > api_key_command。
+// 文件和命令只在创建客户端时才读取和执行，结果会被缓存
+func (p *ModelProvider) ResolveAPIKey() (string, error) {
+	if p.APIKey != "" {
+		return p.APIKey, nil
+	}
+
+	var key string
+	switch {
+	case p.APIKeyFile != "":
+		data, err := os.ReadFile(expandHome(p.APIKeyFile))
+		if err != nil {
+			return "", fmt.Errorf("failed to read api_key_file: %w", err)
+		}
+		key = strings.TrimSpace(string(data))
+	case p.APIKeyCommand != "":
+		output, err := runSecretCommand(p.APIKeyCommand)
+		if err != nil {
+			return "", fmt.Errorf("api_key_command failed: %w", err)
+		}
+		key = strings.TrimSpace(output)
+	default:
+		return "", nil
+	}
+
+	if key == "" {
+		return "", &ConfigError{Message: fmt.Sprintf("provider '%s' resolved an empty API key", p.Provider)}
+	}
+	p.APIKey = key
+	return key, nil
+}
+
+// runSecretCommand 通过shell执行命令并返回标准输出，失败时错误中包含标准错误
+func runSecretCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiKeyCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%w: %s", err, message)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// expandHome 将路径开头的~展开为用户主目录
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// Format 格式化时隐藏API密钥，避免密钥通过fmt出现在日志中
+func (p ModelProvider) Format(f fmt.State, verb rune) {
+	type plain ModelProvider
+	redacted := plain(p)
+	redacted.APIKey = RedactSecret(p.APIKey)
+	fmt.Fprintf(f, fmt.FormatString(f, verb), redacted)
+}
+
+// String 返回隐藏了API密钥的描述
+func (p ModelProvider) String() string {
+	return fmt.Sprintf("%v", p)
+}
+
+// String 返回隐藏了API密钥的描述
+func (c *Config) String() string {
+	return fmt.Sprintf("%+v", *c)
+}
+
+// checkSecretPermissions 拒绝其他用户可读且直接包含API密钥的配置文件，
+// 通过${VAR}引用环境变量的密钥不受限制
+func checkSecretPermissions(path string, info os.FileInfo, data []byte) error {
+	if runtime.GOOS == "windows" || info.Mode().Perm()&0004 == 0 {
+		return nil
+	}
+
+	var document struct {
+		ModelProviders map[string]struct {
+			APIKey string `yaml:"api_key"`
+		} `yaml:"model_providers"`
+	}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		// 语法错误在之后解析时报告
+		return nil
+	}
+	for name, provider := range document.ModelProviders {
+		if provider.APIKey != "" && !strings.Contains(provider.APIKey, "$") {
+			return &ConfigError{Message: fmt.Sprintf(
+				"config file %s is readable by other users and contains a raw API key (model_providers.%s.api_key): run 'chmod 600 %s', or use ${ENV_VAR}, api_key_file or api_key_command",
+				path, name, path)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestModelProvider_ResolveAPIKey(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	os.WriteFile(keyFile, []byte("file-key\n"), 0600)
+
+	type testCase struct {
+		name     string
+		provider ModelProvider
+		expected string
+		wantErr  bool
+	}
+	tests := []testCase{
+		{"api_key优先", ModelProvider{APIKey: "raw-key", APIKeyFile: keyFile}, "raw-key", false},
+		{"从文件读取", ModelProvider{APIKeyFile: keyFile, APIKeyCommand: "echo command-key"}, "file-key", false},
+		{"文件不存在", ModelProvider{APIKeyFile: filepath.Join(dir, "missing")}, "", true},
+		{"未配置密钥", ModelProvider{}, "", false},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests,
+			testCase{"执行命令", ModelProvider{APIKeyCommand: "echo '  command-key  '"}, "command-key", false},
+			testCase{"命令失败", ModelProvider{APIKeyCommand: "echo denied >&2; exit 1"}, "", true},
+			testCase{"命令输出为空", ModelProvider{APIKeyCommand: "true"}, "", true},
+		)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := tt.provider.ResolveAPIKey()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if key != tt.expected {
+				t.Errorf("Expected key %q, got %q", tt.expected, key)
+			}
+			// 解析的密钥被缓存
+			if !tt.wantErr && tt.provider.APIKey != tt.expected {
+				t.Errorf("Expected resolved key to be cached, got %q", tt.provider.APIKey)
+			}
+		})
+	}
+}
+
+func TestModelProvider_Format(t *testing.T) {
+	provider := ModelProvider{APIKey: "sk-1234567890abcdef", Provider: "openai"}
+	modelConfig := ModelConfig{Model: "gpt-4o", ResolvedProvider: &provider}
+	config := &Config{
+		ModelProviders: map[string]ModelProvider{"openai": provider},
+		Models:         map[string]ModelConfig{"model": modelConfig},
+	}
+
+	// 各种格式化方式都不能输出密钥
+	outputs := []string{
+		fmt.Sprint(provider),
+		fmt.Sprintf("%+v", provider),
+		fmt.Sprintf("%#v", provider),
+		fmt.Sprintf("%v", &provider),
+		fmt.Sprintf("%+v", modelConfig),
+		fmt.Sprint(config),
+		config.String(),
+	}
+	for _, output := range outputs {
+		if strings.Contains(output, "sk-1234567890") {
+			t.Errorf("Expected API key to be redacted, got %s", output)
+		}
+	}
+	if !strings.Contains(fmt.Sprintf("%+v", provider), "APIKey:****cdef") {
+		t.Errorf("Expected redacted key with last 4 characters, got %+v", provider)
+	}
+
+	if redacted := RedactSecret("short"); redacted != "****" {
+		t.Errorf("Expected short secret to be fully redacted, got %s", redacted)
+	}
+}
+
+func TestLoadConfig_WorldReadableSecrets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not supported on windows")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "trae_config.yaml")
+	raw := "model_providers:\n  openai:\n    provider: openai\n    api_key: sk-raw-key\n"
+
+	// 其他用户可读且包含明文密钥时拒绝加载
+	os.WriteFile(path, []byte(raw), 0644)
+	os.Chmod(path, 0644)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "chmod 600") {
+		t.Errorf("Expected world-readable config with raw key to be refused, got %v", err)
+	}
+
+	// 只有自己可读时允许
+	os.Chmod(path, 0600)
+	if _, err := LoadConfig(path); err != nil {
+		t.Errorf("Expected private config to load, got %v", err)
+	}
+
+	// 引用环境变量或使用api_key_file时允许
+	t.Setenv("TEST_OPENAI_KEY", "sk-env-key")
+	os.WriteFile(path, []byte("model_providers:\n  openai:\n    provider: openai\n    api_key: ${TEST_OPENAI_KEY}\n  other:\n    api_key_file: ~/key\n"), 0644)
+	os.Chmod(path, 0644)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Expected config referencing env vars to load, got %v", err)
+	}
+	if key := config.ModelProviders["openai"].APIKey; key != "sk-env-key" {
+		t.Errorf("Expected key from env var, got %s", RedactSecret(key))
+	}
+}
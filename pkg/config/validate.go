@@ -0,0 +1,411 @@
+package config
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// jsonSchema 配置文件的JSON Schema
+//
+//go:embed schema.json
+var jsonSchema []byte
+
+// JSONSchema 返回配置文件的JSON Schema，可用于编辑器补全和校验
+func JSONSchema() []byte {
+	return jsonSchema
+}
+
+// Position 配置项在配置文件中的位置
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String 返回file:line:column格式的位置，未知位置返回空字符串
+func (p Position) String() string {
+	if p.File == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Problem 配置中的一个错误或警告
+type Problem struct {
+	Position
+	Path    string // 以.分隔的YAML路径，为空表示整个配置
+	Message string
+	Warning bool // 警告不影响配置的加载和验证
+}
+
+// String 返回带位置和路径的问题描述
+func (p Problem) String() string {
+	var parts []string
+	if location := p.Position.String(); location != "" {
+		parts = append(parts, location)
+	}
+	if p.Path != "" {
+		parts = append(parts, p.Path)
+	}
+	parts = append(parts, p.Message)
+	return strings.Join(parts, ": ")
+}
+
+// ValidationError 配置中的所有错误
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].String()
+	}
+	lines := []string{fmt.Sprintf("%d configuration problems:", len(e.Problems))}
+	for _, problem := range e.Problems {
+		lines = append(lines, "  "+problem.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// newValidationError 按文件位置排序问题后创建验证错误，没有位置的问题排在最后
+func newValidationError(problems []Problem) *ValidationError {
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if (a.File == "") != (b.File == "") {
+			return a.File != ""
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Path < b.Path
+	})
+	return &ValidationError{Problems: problems}
+}
+
+// 数值配置项的取值范围
+const (
+	minTemperature = 0.0
+	maxTemperature = 2.0
+	minTopP        = 0.0
+	maxTopP        = 1.0
+)
+
+// deprecatedFields 已废弃的顶层字段及替代方式
+var deprecatedFields = map[string]string{
+	"default_provider": "use agents.<name>.model and models.<name>.model_provider instead",
+	"max_steps":        "use agents.<name>.max_steps instead",
+	"enable_lakeview":  "use agents.<name>.enable_lakeview instead",
+	"lakeview_config":  "use lakeview instead",
+}
+
+// Validate 验证配置并汇总所有问题，配置项来自配置文件时标注文件、行和列
+func (c *Config) Validate() error {
+	v := &validator{config: c}
+
+	// 验证必需的配置项
+	if len(c.Agents) == 0 {
+		v.report("agents", "at least one agent must be configured")
+	}
+	if len(c.ModelProviders) == 0 {
+		v.report("model_providers", "at least one model provider must be configured")
+	}
+	if len(c.Models) == 0 {
+		v.report("models", "at least one model must be configured")
+	}
+
+	// 验证每个代理配置
+	for agentName, agentConfig := range c.Agents {
+		agentPath := "agents." + agentName
+		if agentConfig.Model == "" {
+			v.report(agentPath+".model", "agent '%s' must specify a model", agentName)
+		} else if _, exists := c.Models[agentConfig.Model]; !exists {
+			v.report(agentPath+".model", "agent '%s' references undefined model '%s'", agentName, agentConfig.Model)
+		}
+		if agentConfig.MaxSteps < 1 {
+			v.report(agentPath+".max_steps", "must be at least 1, got %d", agentConfig.MaxSteps)
+		}
+		switch agentConfig.ApprovalPolicy {
+		case "", ApprovalAuto, ApprovalAsk:
+		default:
+			v.report(agentPath+".approval_policy", "must be '%s' or '%s', got '%s'", ApprovalAuto, ApprovalAsk, agentConfig.ApprovalPolicy)
+		}
+		switch agentConfig.Language {
+		case "", LanguageChinese, LanguageEnglish:
+		default:
+			v.report(agentPath+".language", "must be '%s' or '%s', got '%s'", LanguageChinese, LanguageEnglish, agentConfig.Language)
+		}
+		v.validatePrompt(agentPath, agentConfig)
+	}
+
+	if c.Lakeview.Model != "" {
+		if _, exists := c.Models[c.Lakeview.Model]; !exists {
+			v.report("lakeview.model", "lakeview references undefined model '%s'", c.Lakeview.Model)
+		}
+	}
+	if c.Lakeview.MaxLines < 0 {
+		v.report("lakeview.max_lines", "must not be negative, got %d", c.Lakeview.MaxLines)
+	}
+
+	// 验证每个模型配置
+	for modelName, modelConfig := range c.Models {
+		v.validateModel(modelName, modelConfig)
+	}
+
+	// 验证工具配置
+	for toolName, toolConfig := range c.Tools {
+		if toolConfig.Timeout < 0 {
+			v.report("tools."+toolName+".timeout", "must not be negative, got %d", toolConfig.Timeout)
+		}
+	}
+
+	return v.err()
+}
+
+// validatePrompt 检查代理的系统提示模板能否读取和解析
+func (v *validator) validatePrompt(agentPath string, agentConfig AgentConfig) {
+	promptPath := agentPath + ".system_prompt"
+	if agentConfig.SystemPromptFile != "" {
+		if agentConfig.SystemPrompt != "" {
+			v.report(promptPath, "system_prompt and system_prompt_file cannot both be set")
+			return
+		}
+		promptPath += "_file"
+	}
+
+	prompt, err := agentConfig.PromptTemplate()
+	if err != nil {
+		v.report(promptPath, "%v", err)
+		return
+	}
+	if prompt != "" {
+		if _, err := template.New(promptPath).Parse(prompt); err != nil {
+			v.report(promptPath, "invalid template: %v", err)
+		}
+	}
+}
+
+// validator 收集验证过程中发现的问题
+type validator struct {
+	config   *Config
+	problems []Problem
+}
+
+// report 记录path处的错误
+func (v *validator) report(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{
+		Position: v.config.position(path),
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// err 没有问题时返回nil，否则返回汇总所有问题的ValidationError
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return newValidationError(v.problems)
+}
+
+// validateModel 验证模型的必需字段、提供商、API密钥和参数范围
+func (v *validator) validateModel(modelName string, modelConfig ModelConfig) {
+	modelPath := "models." + modelName
+	if modelConfig.Model == "" {
+		v.report(modelPath+".model", "model '%s' must specify a model name", modelName)
+	}
+
+	if modelConfig.ModelProvider == "" {
+		v.report(modelPath+".model_provider", "model '%s' must specify a provider", modelName)
+	} else {
+		// 已解析的提供商包含命令行和环境变量的覆盖，可能是配置中没有定义的提供商
+		provider := modelConfig.ResolvedProvider
+		if provider == nil || provider.Provider == "" {
+			if mp, exists := v.config.ModelProviders[modelConfig.ModelProvider]; exists {
+				provider = &mp
+			}
+		}
+		if provider == nil {
+			v.report(modelPath+".model_provider", "model '%s' references undefined provider '%s'", modelName, modelConfig.ModelProvider)
+		} else if !provider.HasAPIKey() {
+			// 检查API密钥，api_key_file和api_key_command在创建客户端时才解析
+			envVar := providerEnvPrefix(provider.Provider) + "_API_KEY"
+			if os.Getenv(envVar) == "" {
+				v.report(modelPath, "model '%s' has no API key configured and no environment variable '%s' found", modelName, envVar)
+			}
+		}
+	}
+
+	// 0表示使用提供商的默认值
+	if modelConfig.MaxTokens < 0 {
+		v.report(modelPath+".max_tokens", "must not be negative, got %d", modelConfig.MaxTokens)
+	}
+	if modelConfig.Temperature < minTemperature || modelConfig.Temperature > maxTemperature {
+		v.report(modelPath+".temperature", "must be between %g and %g, got %g", minTemperature, maxTemperature, modelConfig.Temperature)
+	}
+	if modelConfig.TopP < minTopP || modelConfig.TopP > maxTopP {
+		v.report(modelPath+".top_p", "must be between %g and %g, got %g", minTopP, maxTopP, modelConfig.TopP)
+	}
+	if modelConfig.TopK < 0 {
+		v.report(modelPath+".top_k", "must not be negative, got %d", modelConfig.TopK)
+	}
+	if modelConfig.MaxRetries < 0 {
+		v.report(modelPath+".max_retries", "must not be negative, got %d", modelConfig.MaxRetries)
+	}
+}
+
+// position 返回配置项在配置文件中的位置，配置项本身没有出现在文件中时使用最近的上级配置项的位置
+func (c *Config) position(path string) Position {
+	for path != "" {
+		if position, exists := c.positions[path]; exists {
+			return position
+		}
+		index := strings.LastIndex(path, ".")
+		if index < 0 {
+			break
+		}
+		path = path[:index]
+	}
+	return Position{}
+}
+
+// checkSchema 按Config的结构检查配置文件：类型不匹配是错误，未知字段和已废弃字段是警告
+func checkSchema(root *yaml.Node, file string) []Problem {
+	var problems []Problem
+	checkNode(root, reflect.TypeOf(Config{}), "", file, &problems)
+	return problems
+}
+
+// checkNode 检查node是否能解析为typ类型
+func checkNode(node *yaml.Node, typ reflect.Type, path, file string, problems *[]Problem) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	if node.Tag == "!!null" {
+		return
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	add := func(at *yaml.Node, path, message string, warning bool) {
+		*problems = append(*problems, Problem{
+			Position: Position{File: file, Line: at.Line, Column: at.Column},
+			Path:     path,
+			Message:  message,
+			Warning:  warning,
+		})
+	}
+	expect := func(kind yaml.Kind, description string) bool {
+		if node.Kind == kind {
+			return true
+		}
+		add(node, path, fmt.Sprintf("expected %s, got %s", description, describeNode(node)), false)
+		return false
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		if !expect(yaml.MappingNode, "a mapping") {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				continue
+			}
+			keyPath := joinPath(path, key.Value)
+			if replacement, deprecated := deprecatedFields[key.Value]; deprecated && path == "" {
+				add(key, keyPath, "deprecated field, "+replacement, true)
+				continue
+			}
+			field, exists := yamlField(typ, key.Value)
+			if !exists {
+				add(key, keyPath, "unknown field", true)
+				continue
+			}
+			checkNode(value, field.Type, keyPath, file, problems)
+		}
+	case reflect.Map:
+		if !expect(yaml.MappingNode, "a mapping") {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			checkNode(node.Content[i+1], typ.Elem(), joinPath(path, node.Content[i].Value), file, problems)
+		}
+	case reflect.Slice:
+		if !expect(yaml.SequenceNode, "a list") {
+			return
+		}
+		for i, child := range node.Content {
+			checkNode(child, typ.Elem(), fmt.Sprintf("%s[%d]", path, i), file, problems)
+		}
+	case reflect.String:
+		expect(yaml.ScalarNode, "a string")
+	case reflect.Bool:
+		if expect(yaml.ScalarNode, "a boolean") && node.Tag != "!!bool" {
+			add(node, path, fmt.Sprintf("expected a boolean, got %s", describeNode(node)), false)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if expect(yaml.ScalarNode, "an integer") && node.Tag != "!!int" {
+			add(node, path, fmt.Sprintf("expected an integer, got %s", describeNode(node)), false)
+		}
+	case reflect.Float32, reflect.Float64:
+		if expect(yaml.ScalarNode, "a number") && node.Tag != "!!int" && node.Tag != "!!float" {
+			add(node, path, fmt.Sprintf("expected a number, got %s", describeNode(node)), false)
+		}
+	}
+}
+
+// yamlField 按YAML键名查找结构体字段
+func yamlField(typ reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if name == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// describeNode 描述节点的值，用于错误信息
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
+
+// joinPath 拼接YAML路径
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
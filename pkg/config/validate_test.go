@@ -0,0 +1,282 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_SchemaProblems(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trae_config.yaml")
+	content := `agents:
+  trae_agent:
+    model: agent_model
+    max_steps: many
+    tool: [bash]
+max_steps: 10
+models:
+  agent_model:
+    model: gpt-4o
+    temperature: hot
+    stop_sequences: END
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	// 所有类型错误一起报告，并标注行和列
+	_, err := LoadConfig(path)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	expected := []string{
+		path + ":4:16: agents.trae_agent.max_steps: expected an integer, got \"many\"",
+		path + ":10:18: models.agent_model.temperature: expected a number, got \"hot\"",
+		path + ":11:21: models.agent_model.stop_sequences: expected a list, got \"END\"",
+	}
+	var actual []string
+	for _, problem := range validationErr.Problems {
+		actual = append(actual, problem.String())
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected problems:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	// 修正类型错误后，未知字段和已废弃字段只作为警告
+	content = strings.NewReplacer("max_steps: many", "max_steps: 10", "temperature: hot", "temperature: 0.5", "stop_sequences: END", "stop_sequences: [END]").Replace(content)
+	os.WriteFile(path, []byte(content), 0600)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Expected config to load, got %v", err)
+	}
+	warnings := config.Warnings()
+	if len(warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %v", warnings)
+	}
+	if warnings[0].Path != "agents.trae_agent.tool" || warnings[0].Line != 5 || !strings.Contains(warnings[0].Message, "unknown field") {
+		t.Errorf("Expected unknown field warning at line 5, got %s", warnings[0])
+	}
+	if warnings[1].Path != "max_steps" || !strings.Contains(warnings[1].Message, "deprecated") {
+		t.Errorf("Expected deprecated field warning, got %s", warnings[1])
+	}
+}
+
+func TestConfig_ValidateRanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trae_config.yaml")
+	content := `agents:
+  trae_agent:
+    model: agent_model
+    max_steps: 0
+model_providers:
+  openai:
+    provider: openai
+    api_key: test-key
+models:
+  agent_model:
+    model: gpt-4o
+    model_provider: openai
+    max_tokens: -1
+    temperature: 3
+    top_p: 1.5
+  broken_model:
+    model: gpt-4o
+    model_provider: missing
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	err = config.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	// 问题按文件中的位置排序
+	expected := []string{
+		path + ":4:16: agents.trae_agent.max_steps: must be at least 1, got 0",
+		path + ":13:17: models.agent_model.max_tokens: must not be negative, got -1",
+		path + ":14:18: models.agent_model.temperature: must be between 0 and 2, got 3",
+		path + ":15:12: models.agent_model.top_p: must be between 0 and 1, got 1.5",
+		path + ":18:21: models.broken_model.model_provider: model 'broken_model' references undefined provider 'missing'",
+	}
+	var actual []string
+	for _, problem := range validationErr.Problems {
+		actual = append(actual, problem.String())
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected problems:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	// 没有出现在文件中的配置项使用最近的上级配置项的位置
+	config.Agents["other_agent"] = AgentConfig{Model: "agent_model"}
+	err = config.Validate()
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	first := validationErr.Problems[0]
+	if first.String() != path+":1:1: agents.other_agent.max_steps: must be at least 1, got 0" {
+		t.Errorf("Expected problem at the agents key, got %s", first)
+	}
+}
+
+func TestConfig_ValidateAgents(t *testing.T) {
+	config := &Config{
+		Agents: map[string]AgentConfig{
+			"trae_agent": {Model: "agent_model", MaxSteps: 10},
+			"triage":     {Model: "agent_model", MaxSteps: 10, ApprovalPolicy: "sometimes"},
+			"fixer":      {Model: "agent_model", MaxSteps: 10, ApprovalPolicy: ApprovalAsk, SystemPrompt: "You are {{.Agent"},
+		},
+		ModelProviders: map[string]ModelProvider{
+			"openai": {Provider: "openai", APIKey: "test-key"},
+		},
+		Models: map[string]ModelConfig{
+			"agent_model": {Model: "gpt-4o", ModelProvider: "openai"},
+		},
+	}
+
+	err := config.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	if len(validationErr.Problems) != 2 {
+		t.Fatalf("Expected 2 problems, got %v", validationErr.Problems)
+	}
+	for _, problem := range validationErr.Problems {
+		switch problem.Path {
+		case "agents.triage.approval_policy":
+			if !strings.Contains(problem.Message, "got 'sometimes'") {
+				t.Errorf("Unexpected approval_policy problem: %s", problem)
+			}
+		case "agents.fixer.system_prompt":
+			if !strings.Contains(problem.Message, "invalid template") {
+				t.Errorf("Unexpected system_prompt problem: %s", problem)
+			}
+		default:
+			t.Errorf("Unexpected problem: %s", problem)
+		}
+	}
+}
+
+func TestLoadConfig_SystemPromptFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "prompts"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "prompts", "triage.tmpl"), []byte("You are {{.Agent}}."), 0644)
+	os.WriteFile(filepath.Join(dir, "prompts", "broken.tmpl"), []byte("{{.Agent"), 0644)
+	path := filepath.Join(dir, "trae_config.yaml")
+	content := `agents:
+  triage:
+    model: agent_model
+    max_steps: 10
+    system_prompt_file: prompts/triage.tmpl
+    language: en
+  broken:
+    model: agent_model
+    max_steps: 10
+    system_prompt_file: prompts/broken.tmpl
+    language: fr
+  both:
+    model: agent_model
+    max_steps: 10
+    system_prompt: inline
+    system_prompt_file: prompts/triage.tmpl
+model_providers:
+  openai:
+    provider: openai
+    api_key: test-key
+models:
+  agent_model:
+    model: gpt-4o
+    model_provider: openai
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	// 相对路径相对于配置文件，而不是当前目录
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	triage, _ := config.GetAgentConfig("triage")
+	if prompt, err := triage.PromptTemplate(); err != nil || prompt != "You are {{.Agent}}." {
+		t.Errorf("Expected prompt template from file, got %q, %v", prompt, err)
+	}
+
+	err = config.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	expected := []string{
+		path + ":10:25: agents.broken.system_prompt_file: invalid template: template: agents.broken.system_prompt_file:1: unclosed action",
+		path + ":11:15: agents.broken.language: must be 'zh' or 'en', got 'fr'",
+		path + ":15:20: agents.both.system_prompt: system_prompt and system_prompt_file cannot both be set",
+	}
+	var actual []string
+	for _, problem := range validationErr.Problems {
+		actual = append(actual, problem.String())
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected problems:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	var schema struct {
+		Properties  map[string]json.RawMessage `json:"properties"`
+		Definitions map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(JSONSchema(), &schema); err != nil {
+		t.Fatalf("Failed to parse JSON schema: %v", err)
+	}
+
+	// Schema中的字段必须和配置结构体保持一致
+	types := map[string]reflect.Type{
+		"":               reflect.TypeOf(Config{}),
+		"agent":          reflect.TypeOf(AgentConfig{}),
+		"model_provider": reflect.TypeOf(ModelProvider{}),
+		"model":          reflect.TypeOf(ModelConfig{}),
+		"lakeview":       reflect.TypeOf(LakeviewConfig{}),
+		"mcp_server":     reflect.TypeOf(MCPServerConfig{}),
+		"tool":           reflect.TypeOf(ToolConfig{}),
+	}
+	for name, typ := range types {
+		properties := schema.Properties
+		if name != "" {
+			properties = schema.Definitions[name].Properties
+		}
+		var schemaFields []string
+		for field := range properties {
+			schemaFields = append(schemaFields, field)
+		}
+		sort.Strings(schemaFields)
+
+		var structFields []string
+		for i := 0; i < typ.NumField(); i++ {
+			tag := strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0]
+			if typ.Field(i).PkgPath == "" && tag != "-" {
+				structFields = append(structFields, tag)
+			}
+		}
+		sort.Strings(structFields)
+
+		if !reflect.DeepEqual(schemaFields, structFields) {
+			t.Errorf("Schema %q fields %v do not match struct fields %v", name, schemaFields, structFields)
+		}
+	}
+}
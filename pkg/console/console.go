@@ -0,0 +1,120 @@
+package console
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/textwidth"
+)
+
+// Type 控制台类型
+type Type string
+
+const (
+	// TypeSimple 逐行输出的纯文本控制台
+	TypeSimple Type = "simple"
+	// TypeRich 实时刷新步骤面板的终端控制台
+	TypeRich Type = "rich"
+)
+
+const (
+	// previewLines 工具结果预览的最大行数
+	previewLines = 5
+	// contentLines LLM回复显示的最大行数
+	contentLines = 8
+)
+
+// NewConsole 根据类型创建控制台，输出不是终端时rich会退化为simple
+func NewConsole(consoleType string, out io.Writer) (agent.Console, error) {
+	switch Type(consoleType) {
+	case TypeSimple, "":
+		return NewSimpleConsole(out), nil
+	case TypeRich:
+		if file, ok := out.(*os.File); ok && !isTerminal(file) {
+			return NewSimpleConsole(out), nil
+		}
+		return NewRichConsole(out), nil
+	default:
+		return nil, fmt.Errorf("unsupported console type: %s (expected simple or rich)", consoleType)
+	}
+}
+
+// isTerminal 检查文件是否为终端
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// summarizeToolCall 用一行描述工具调用的主要参数
+func summarizeToolCall(toolCall llm.ToolCall) string {
+	args := toolCall.Function.Arguments
+	for _, key := range []string{"command", "file_path", "path", "identifier", "thought", "summary"} {
+		if value, ok := args[key].(string); ok && value != "" {
+			return strings.Join(strings.Fields(value), " ")
+		}
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(args)
+	return string(data)
+}
+
+// preview 取文本的前maxLines行，超出部分用一行说明代替
+func preview(text string, maxLines int) []string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) <= maxLines {
+		return lines
+	}
+	return append(lines[:maxLines:maxLines], fmt.Sprintf("... (还有 %d 行)", len(lines)-maxLines))
+}
+
+// sortedKeys 返回按字母排序的键，保证输出稳定
+func sortedKeys(details map[string]string) []string {
+	keys := make([]string, 0, len(details))
+	for key := range details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// executionTokens 获取执行结果中记录的令牌用量
+func executionTokens(execution *agent.AgentExecution) (llm.Usage, bool) {
+	if execution == nil || execution.Metadata == nil {
+		return llm.Usage{}, false
+	}
+	usage, ok := execution.Metadata["usage"].(llm.Usage)
+	return usage, ok
+}
+
+// truncateWidth 按显示宽度截断文本
+func truncateWidth(text string, width int) string {
+	if textwidth.String(text) <= width {
+		return text
+	}
+	var sb strings.Builder
+	used := 0
+	for _, r := range text {
+		w := textwidth.Rune(r)
+		if used+w > width-1 {
+			break
+		}
+		sb.WriteRune(r)
+		used += w
+	}
+	return sb.String() + "…"
+}
@@ -0,0 +1,108 @@
+package console
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/textwidth"
+	"trage-agent-go/pkg/tools"
+)
+
+// runEvents 按代理执行一步的顺序发送事件
+func runEvents(c agent.Console) {
+	toolCall := llm.ToolCall{
+		ID:       "call_1",
+		Function: llm.ToolCallFunction{Name: "bash", Arguments: map[string]interface{}{"command": "go test ./..."}},
+	}
+	c.Start()
+	c.OnLLMStart(1)
+	c.OnLLMEnd(1, &llm.LLMMessage{Content: "运行测试", Usage: &llm.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}}, nil)
+	c.OnToolStart(1, toolCall)
+	c.OnToolResult(1, toolCall, &tools.ToolResult{Success: true, Result: "ok  pkg/a\nok  pkg/b"})
+	c.OnLakeview(&agent.LakeviewStep{StepNumber: 1, Tags: []string{"VERIFY_FIX"}, Summary: "测试全部通过"})
+	c.Print("警告: 示例")
+	c.OnLLMStart(2)
+	c.OnLLMEnd(2, nil, errors.New("rate limit"))
+	c.Finish(&agent.AgentExecution{
+		Success:  false,
+		Error:    "LLM call failed",
+		Duration: time.Second,
+		Metadata: map[string]interface{}{"usage": llm.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}},
+	})
+}
+
+func TestSimpleConsole(t *testing.T) {
+	var out bytes.Buffer
+	runEvents(NewSimpleConsole(&out))
+	text := out.String()
+
+	for _, want := range []string{"第 1 步", "🤖 运行测试", "累计 120", "🔧 bash go test ./...", "│ ok  pkg/b",
+		"🔥 VERIFY_FIX", "测试全部通过", "警告: 示例", "LLM调用失败: rate limit", "任务执行失败", "令牌: 120"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "\033[") {
+		t.Error("Expected simple console output without escape sequences")
+	}
+}
+
+func TestRichConsole(t *testing.T) {
+	var out bytes.Buffer
+	console := NewRichConsole(&out)
+	console.width = 40
+	runEvents(console)
+	text := out.String()
+
+	for _, want := range []string{"第 1 步", "令牌 120", "bash", "ok  pkg/b", "VERIFY_FIX", "测试全部通过",
+		"警告: 示例", "rate limit", "任务执行失败"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, text)
+		}
+	}
+	// 面板更新时应上移光标重绘
+	if !strings.Contains(text, "\033[J") {
+		t.Error("Expected panel redraw escape sequences")
+	}
+	// 每行都不超过终端宽度
+	for _, line := range strings.Split(stripANSI(text), "\n") {
+		if width := textwidth.String(line); width > 40 {
+			t.Errorf("Expected line width <= 40, got %d: %q", width, line)
+		}
+	}
+}
+
+func TestNewConsole(t *testing.T) {
+	var out bytes.Buffer
+	if c, err := NewConsole("simple", &out); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	} else if _, ok := c.(*SimpleConsole); !ok {
+		t.Errorf("Expected SimpleConsole, got %T", c)
+	}
+	if c, err := NewConsole("rich", &out); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	} else if _, ok := c.(*RichConsole); !ok {
+		t.Errorf("Expected RichConsole, got %T", c)
+	}
+	if _, err := NewConsole("fancy", &out); err == nil {
+		t.Error("Expected error for unknown console type")
+	}
+}
+
+func TestTruncateWidth(t *testing.T) {
+	if got := truncateWidth("abcdef", 4); got != "abc…" {
+		t.Errorf("Expected 'abc…', got %q", got)
+	}
+	// 中文按两列计算
+	if got := truncateWidth("中文测试", 5); got != "中文…" {
+		t.Errorf("Expected '中文…', got %q", got)
+	}
+	if got := truncateWidth("short", 10); got != "short" {
+		t.Errorf("Expected unchanged text, got %q", got)
+	}
+}
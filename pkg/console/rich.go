@@ -0,0 +1,395 @@
+package console
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/textwidth"
+	"trage-agent-go/pkg/tools"
+)
+
+// ANSI转义序列
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiDim    = "\033[2m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiBlue   = "\033[34m"
+	ansiCyan   = "\033[36m"
+)
+
+const (
+	// defaultWidth 无法获取终端宽度时使用的宽度
+	defaultWidth = 100
+	// spinnerInterval 加载动画的刷新间隔
+	spinnerInterval = 100 * time.Millisecond
+)
+
+// spinnerFrames 加载动画的帧
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// richTool 面板中的一次工具调用
+type richTool struct {
+	call   llm.ToolCall
+	result *tools.ToolResult
+}
+
+// richStep 当前正在刷新的步骤面板
+type richStep struct {
+	number   int
+	started  time.Time
+	thinking bool
+	content  string
+	err      error
+	tools    []*richTool
+	lakeview *agent.LakeviewStep
+}
+
+// active 检查步骤中是否还有进行中的操作
+func (s *richStep) active() bool {
+	if s.thinking {
+		return true
+	}
+	for _, tool := range s.tools {
+		if tool.result == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// RichConsole 终端控制台，每一步显示为一个实时刷新的面板，
+// LLM调用和工具执行期间显示加载动画
+type RichConsole struct {
+	out      io.Writer
+	width    int
+	lakeview *config.LakeviewConfig
+	usage    llm.Usage
+	current  *richStep
+	rendered int // 当前面板在屏幕上占用的行数
+	frame    int
+	stop     chan struct{}
+	done     chan struct{}
+	mutex    sync.Mutex
+}
+
+// NewRichConsole 创建终端控制台，宽度取自COLUMNS环境变量
+func NewRichConsole(out io.Writer) *RichConsole {
+	width := defaultWidth
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 20 {
+		width = columns
+	}
+	return &RichConsole{out: out, width: width}
+}
+
+// Print 打印消息，消息显示在当前面板上方
+func (rc *RichConsole) Print(message string) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.clearLocked()
+	fmt.Fprintln(rc.out, message)
+	rc.drawLocked()
+}
+
+// PrintTaskDetails 以面板形式打印任务详情
+func (rc *RichConsole) PrintTaskDetails(details map[string]string) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.clearLocked()
+	lines := []string{rc.header("📋 任务详情", "")}
+	for _, key := range sortedKeys(details) {
+		value := strings.Join(strings.Fields(details[key]), " ")
+		lines = append(lines, rc.line(ansiBold+key+ansiReset+": ", value, ""))
+	}
+	lines = append(lines, rc.footer())
+	fmt.Fprintln(rc.out, strings.Join(lines, "\n"))
+	rc.drawLocked()
+}
+
+// Start 启动控制台，开始刷新加载动画
+func (rc *RichConsole) Start() error {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.usage = llm.Usage{}
+	if rc.stop != nil {
+		return nil
+	}
+
+	rc.stop = make(chan struct{})
+	rc.done = make(chan struct{})
+	go rc.animate(rc.stop, rc.done)
+	return nil
+}
+
+// animate 定时刷新进行中的面板
+func (rc *RichConsole) animate(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			rc.mutex.Lock()
+			if rc.current != nil && rc.current.active() {
+				rc.frame++
+				rc.redrawLocked()
+			}
+			rc.mutex.Unlock()
+		}
+	}
+}
+
+// SetLakeview 设置Lakeview
+func (rc *RichConsole) SetLakeview(config *config.LakeviewConfig) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.lakeview = config
+}
+
+// OnLLMStart 开始新步骤的面板
+func (rc *RichConsole) OnLLMStart(step int) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	// 上一步的面板保留在屏幕上，不再刷新
+	rc.rendered = 0
+	rc.current = &richStep{number: step, started: time.Now(), thinking: true}
+	rc.drawLocked()
+}
+
+// OnLLMEnd 更新面板中的LLM回复和令牌计数
+func (rc *RichConsole) OnLLMEnd(step int, response *llm.LLMMessage, err error) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	current := rc.stepLocked(step)
+	current.thinking = false
+	current.err = err
+	if response != nil {
+		current.content = strings.TrimSpace(response.Content)
+		if response.Usage != nil {
+			rc.usage.PromptTokens += response.Usage.PromptTokens
+			rc.usage.CompletionTokens += response.Usage.CompletionTokens
+			rc.usage.TotalTokens += response.Usage.TotalTokens
+		}
+	}
+	rc.redrawLocked()
+}
+
+// OnToolStart 在面板中添加进行中的工具调用
+func (rc *RichConsole) OnToolStart(step int, toolCall llm.ToolCall) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	current := rc.stepLocked(step)
+	current.tools = append(current.tools, &richTool{call: toolCall})
+	rc.redrawLocked()
+}
+
+// OnToolResult 在面板中显示工具结果预览
+func (rc *RichConsole) OnToolResult(step int, toolCall llm.ToolCall, result *tools.ToolResult) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	current := rc.stepLocked(step)
+	for _, tool := range current.tools {
+		if tool.call.ID == toolCall.ID && tool.result == nil {
+			tool.result = result
+			rc.redrawLocked()
+			return
+		}
+	}
+	current.tools = append(current.tools, &richTool{call: toolCall, result: result})
+	rc.redrawLocked()
+}
+
+// OnLakeview 在对应步骤的面板中显示Lakeview总结
+func (rc *RichConsole) OnLakeview(step *agent.LakeviewStep) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	if rc.current != nil && rc.current.number == step.StepNumber {
+		rc.current.lakeview = step
+		rc.redrawLocked()
+		return
+	}
+	// 面板已结束时单独输出
+	rc.clearLocked()
+	fmt.Fprintln(rc.out, ansiBlue+step.String()+ansiReset)
+	rc.drawLocked()
+}
+
+// Finish 停止刷新并显示执行结果
+func (rc *RichConsole) Finish(execution *agent.AgentExecution) {
+	rc.mutex.Lock()
+	stop, done := rc.stop, rc.done
+	rc.stop, rc.done = nil, nil
+	rc.mutex.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.redrawLocked()
+	rc.current = nil
+	rc.rendered = 0
+	if execution == nil {
+		return
+	}
+
+	lines := make([]string, 0)
+	if execution.Success {
+		lines = append(lines, rc.header(ansiGreen+"✅ 任务执行成功"+ansiReset, ""))
+		for _, line := range preview(execution.Output, contentLines) {
+			lines = append(lines, rc.line("", line, ""))
+		}
+	} else {
+		lines = append(lines, rc.header(ansiRed+"❌ 任务执行失败"+ansiReset, ""))
+		for _, line := range preview(execution.Error, contentLines) {
+			lines = append(lines, rc.line("", line, ansiRed))
+		}
+	}
+	stats := fmt.Sprintf("耗时 %s · %d 步", execution.Duration.Round(time.Millisecond), len(execution.Steps))
+	if usage, ok := executionTokens(execution); ok {
+		stats += fmt.Sprintf(" · 令牌 %d（输入 %d / 输出 %d）", usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens)
+	}
+	lines = append(lines, rc.line("", stats, ansiDim), rc.footer())
+	fmt.Fprintln(rc.out, strings.Join(lines, "\n"))
+}
+
+// stepLocked 获取指定步骤的面板，事件先于OnLLMStart到达时新建（调用方需持有锁）
+func (rc *RichConsole) stepLocked(step int) *richStep {
+	if rc.current == nil || rc.current.number != step {
+		rc.rendered = 0
+		rc.current = &richStep{number: step, started: time.Now()}
+	}
+	return rc.current
+}
+
+// redrawLocked 清除并重新绘制当前面板（调用方需持有锁）
+func (rc *RichConsole) redrawLocked() {
+	rc.clearLocked()
+	rc.drawLocked()
+}
+
+// clearLocked 清除屏幕上的当前面板（调用方需持有锁）
+func (rc *RichConsole) clearLocked() {
+	if rc.rendered > 0 {
+		fmt.Fprintf(rc.out, "\033[%dA\033[J", rc.rendered)
+		rc.rendered = 0
+	}
+}
+
+// drawLocked 绘制当前面板并记录占用的行数（调用方需持有锁）
+func (rc *RichConsole) drawLocked() {
+	if rc.current == nil {
+		return
+	}
+	lines := rc.renderStep(rc.current)
+	fmt.Fprintln(rc.out, strings.Join(lines, "\n"))
+	rc.rendered = len(lines)
+}
+
+// renderStep 渲染步骤面板，每行都不超过终端宽度，保证重绘时行数准确
+func (rc *RichConsole) renderStep(step *richStep) []string {
+	spinner := spinnerFrames[rc.frame%len(spinnerFrames)]
+	lines := []string{rc.header(fmt.Sprintf("第 %d 步", step.number), fmt.Sprintf("令牌 %d", rc.usage.TotalTokens))}
+
+	switch {
+	case step.thinking:
+		elapsed := time.Since(step.started).Round(time.Second)
+		lines = append(lines, rc.line(ansiYellow+spinner+ansiReset+" ", fmt.Sprintf("思考中... %s", elapsed), ansiDim))
+	case step.err != nil:
+		lines = append(lines, rc.line("❌ ", "LLM调用失败: "+step.err.Error(), ansiRed))
+	case step.content != "":
+		for i, line := range preview(step.content, contentLines) {
+			prefix := "   "
+			if i == 0 {
+				prefix = "🤖 "
+			}
+			lines = append(lines, rc.line(prefix, line, ""))
+		}
+	}
+
+	for _, tool := range step.tools {
+		title := ansiBold + tool.call.Function.Name + ansiReset + " "
+		switch {
+		case tool.result == nil:
+			lines = append(lines, rc.line(ansiYellow+spinner+ansiReset+" "+title, summarizeToolCall(tool.call), ""))
+		case tool.result.Success:
+			lines = append(lines, rc.line(ansiGreen+"✓"+ansiReset+" "+title, summarizeToolCall(tool.call), ""))
+			for _, line := range preview(tool.result.Result, previewLines) {
+				lines = append(lines, rc.line("  ", line, ansiDim))
+			}
+		default:
+			lines = append(lines, rc.line(ansiRed+"✗"+ansiReset+" "+title, summarizeToolCall(tool.call), ""))
+			for _, line := range preview(tool.result.Error, previewLines) {
+				lines = append(lines, rc.line("  ", line, ansiRed))
+			}
+		}
+	}
+
+	if step.lakeview != nil {
+		lines = append(lines, rc.line("🌊 ", "["+strings.Join(step.lakeview.Labels(), ", ")+"]", ansiBlue))
+		for _, line := range strings.Split(step.lakeview.Summary, "\n") {
+			lines = append(lines, rc.line("   ", line, ansiBlue))
+		}
+	}
+
+	return append(lines, rc.footer())
+}
+
+// header 渲染面板标题行
+func (rc *RichConsole) header(title, right string) string {
+	text := ansiCyan + "╭─ " + ansiReset + ansiBold + title + ansiReset
+	if right != "" {
+		text += ansiDim + " · " + right + ansiReset
+	}
+	return text
+}
+
+// line 渲染面板内容行，prefix可以包含转义序列，text按剩余宽度截断后使用color着色
+func (rc *RichConsole) line(prefix, text, color string) string {
+	available := rc.width - 2 - textwidth.String(stripANSI(prefix))
+	if available < 1 {
+		available = 1
+	}
+	text = truncateWidth(strings.ReplaceAll(text, "\t", "    "), available)
+	if color != "" {
+		text = color + text + ansiReset
+	}
+	return ansiCyan + "│ " + ansiReset + prefix + text
+}
+
+// footer 渲染面板底部
+func (rc *RichConsole) footer() string {
+	return ansiCyan + "╰─" + ansiReset
+}
+
+// stripANSI 去掉文本中的ANSI转义序列
+func stripANSI(text string) string {
+	var sb strings.Builder
+	inEscape := false
+	for _, r := range text {
+		switch {
+		case inEscape:
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+				inEscape = false
+			}
+		case r == '\033':
+			inEscape = true
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
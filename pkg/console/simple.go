@@ -0,0 +1,144 @@
+package console
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/tools"
+)
+
+// SimpleConsole 逐行输出的纯文本控制台，适合日志和非交互环境
+type SimpleConsole struct {
+	out      io.Writer
+	lakeview *config.LakeviewConfig
+	usage    llm.Usage
+	mutex    sync.Mutex
+}
+
+// NewSimpleConsole 创建纯文本控制台
+func NewSimpleConsole(out io.Writer) *SimpleConsole {
+	return &SimpleConsole{out: out}
+}
+
+// Print 打印消息
+func (sc *SimpleConsole) Print(message string) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	fmt.Fprintln(sc.out, message)
+}
+
+// PrintTaskDetails 打印任务详情
+func (sc *SimpleConsole) PrintTaskDetails(details map[string]string) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	fmt.Fprintln(sc.out, "📋 任务详情:")
+	for _, key := range sortedKeys(details) {
+		fmt.Fprintf(sc.out, "  %s: %s\n", key, details[key])
+	}
+}
+
+// Start 启动控制台
+func (sc *SimpleConsole) Start() error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	sc.usage = llm.Usage{}
+	return nil
+}
+
+// SetLakeview 设置Lakeview
+func (sc *SimpleConsole) SetLakeview(config *config.LakeviewConfig) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	sc.lakeview = config
+}
+
+// OnLLMStart 开始LLM调用
+func (sc *SimpleConsole) OnLLMStart(step int) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	fmt.Fprintf(sc.out, "\n── 第 %d 步 ──\n", step)
+}
+
+// OnLLMEnd LLM调用结束
+func (sc *SimpleConsole) OnLLMEnd(step int, response *llm.LLMMessage, err error) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	if err != nil {
+		fmt.Fprintf(sc.out, "❌ LLM调用失败: %v\n", err)
+		return
+	}
+	if response.Usage != nil {
+		sc.usage.PromptTokens += response.Usage.PromptTokens
+		sc.usage.CompletionTokens += response.Usage.CompletionTokens
+		sc.usage.TotalTokens += response.Usage.TotalTokens
+	}
+	if content := strings.TrimSpace(response.Content); content != "" {
+		fmt.Fprintf(sc.out, "🤖 %s\n", content)
+	}
+	if response.Usage != nil {
+		fmt.Fprintf(sc.out, "   令牌: 本步 %d，累计 %d\n", response.Usage.TotalTokens, sc.usage.TotalTokens)
+	}
+}
+
+// OnToolStart 开始执行工具
+func (sc *SimpleConsole) OnToolStart(step int, toolCall llm.ToolCall) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	fmt.Fprintf(sc.out, "🔧 %s %s\n", toolCall.Function.Name, summarizeToolCall(toolCall))
+}
+
+// OnToolResult 工具执行结束
+func (sc *SimpleConsole) OnToolResult(step int, toolCall llm.ToolCall, result *tools.ToolResult) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	if result.Success {
+		fmt.Fprintln(sc.out, "   ✅ 成功")
+		for _, line := range preview(result.Result, previewLines) {
+			fmt.Fprintf(sc.out, "   │ %s\n", line)
+		}
+		return
+	}
+	fmt.Fprintln(sc.out, "   ❌ 失败")
+	for _, line := range preview(result.Error, previewLines) {
+		fmt.Fprintf(sc.out, "   │ %s\n", line)
+	}
+}
+
+// OnLakeview 显示Lakeview步骤总结
+func (sc *SimpleConsole) OnLakeview(step *agent.LakeviewStep) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	fmt.Fprintln(sc.out, step.String())
+}
+
+// Finish 显示执行结果
+func (sc *SimpleConsole) Finish(execution *agent.AgentExecution) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	if execution == nil {
+		return
+	}
+
+	fmt.Fprintln(sc.out)
+	if execution.Success {
+		fmt.Fprintln(sc.out, "✅ 任务执行成功！")
+		if execution.Output != "" {
+			fmt.Fprintf(sc.out, "输出: %s\n", execution.Output)
+		}
+	} else {
+		fmt.Fprintln(sc.out, "❌ 任务执行失败！")
+		if execution.Error != "" {
+			fmt.Fprintf(sc.out, "错误: %s\n", execution.Error)
+		}
+	}
+	fmt.Fprintf(sc.out, "执行时间: %v\n", execution.Duration)
+	fmt.Fprintf(sc.out, "执行步数: %d\n", len(execution.Steps))
+	if usage, ok := executionTokens(execution); ok {
+		fmt.Fprintf(sc.out, "令牌: %d（输入 %d / 输出 %d）\n", usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens)
+	}
+}
@@ -0,0 +1,90 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"trage-agent-go/pkg/batch"
+)
+
+// Instance 评测数据集中的一个实例
+type Instance struct {
+	InstanceID       string   `json:"instance_id"`
+	Repo             string   `json:"repo"` // 本地git仓库路径，相对路径相对数据集文件所在目录
+	BaseCommit       string   `json:"base_commit"`
+	ProblemStatement string   `json:"problem_statement"`
+	TestPatch        string   `json:"test_patch,omitempty"`   // 评测前应用的测试补丁，代理看不到
+	FailToPass       []string `json:"fail_to_pass"`           // 修复前失败、修复后应通过的测试命令
+	PassToPass       []string `json:"pass_to_pass,omitempty"` // 修复前后都应通过的测试命令
+}
+
+// LoadDataset 读取数据集，支持JSONL和JSON数组两种格式
+func LoadDataset(path string) ([]Instance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+
+	instances := make([]Instance, 0)
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&instances); err != nil {
+			return nil, fmt.Errorf("invalid dataset: %w", err)
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		for {
+			var instance Instance
+			if err := decoder.Decode(&instance); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("instance %d: invalid instance: %w", len(instances)+1, err)
+			}
+			instances = append(instances, instance)
+		}
+	}
+
+	baseDir := filepath.Dir(path)
+	seen := make(map[string]bool)
+	for i := range instances {
+		instance := &instances[i]
+		if err := instance.Validate(); err != nil {
+			return nil, fmt.Errorf("instance %d: %w", i+1, err)
+		}
+		if seen[instance.InstanceID] {
+			return nil, fmt.Errorf("instance %d: duplicate instance id %q", i+1, instance.InstanceID)
+		}
+		seen[instance.InstanceID] = true
+
+		if !filepath.IsAbs(instance.Repo) {
+			instance.Repo = filepath.Join(baseDir, instance.Repo)
+		}
+	}
+	return instances, nil
+}
+
+// Validate 检查实例字段，实例ID会用作文件名
+func (i *Instance) Validate() error {
+	task := batch.Task{ID: i.InstanceID, Prompt: i.ProblemStatement}
+	if err := task.Validate(); err != nil {
+		return err
+	}
+	if strings.TrimSpace(i.Repo) == "" {
+		return fmt.Errorf("instance %s: repo is required", i.InstanceID)
+	}
+	if strings.TrimSpace(i.BaseCommit) == "" {
+		return fmt.Errorf("instance %s: base_commit is required", i.InstanceID)
+	}
+	if len(i.FailToPass) == 0 {
+		return fmt.Errorf("instance %s: at least one fail_to_pass test is required", i.InstanceID)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package eval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/testutil"
+	"trage-agent-go/pkg/tools"
+	"trage-agent-go/pkg/utils"
+)
+
+func TestLoadDataset(t *testing.T) {
+	dir := t.TempDir()
+	instance := `{"instance_id": "a", "repo": "repo", "base_commit": "abc", "problem_statement": "fix", "fail_to_pass": ["true"]}`
+
+	jsonl := filepath.Join(dir, "dataset.jsonl")
+	os.WriteFile(jsonl, []byte(instance+"\n\n"+strings.Replace(instance, `"a"`, `"b"`, 1)+"\n"), 0644)
+	instances, err := LoadDataset(jsonl)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(instances) != 2 || instances[1].InstanceID != "b" {
+		t.Fatalf("Expected 2 instances, got %+v", instances)
+	}
+	// 相对路径相对数据集文件所在目录
+	if instances[0].Repo != filepath.Join(dir, "repo") {
+		t.Errorf("Expected repo to be resolved against dataset dir, got %s", instances[0].Repo)
+	}
+
+	array := filepath.Join(dir, "dataset.json")
+	os.WriteFile(array, []byte("["+instance+"]"), 0644)
+	instances, err = LoadDataset(array)
+	if err != nil || len(instances) != 1 {
+		t.Fatalf("Expected 1 instance from JSON array, got %d (%v)", len(instances), err)
+	}
+
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{`{"instance_id": "a", "repo": "r", "base_commit": "c", "problem_statement": "p"}`, "fail_to_pass"},
+		{`{"instance_id": "a", "repo": "r", "problem_statement": "p", "fail_to_pass": ["true"]}`, "base_commit"},
+		{`{"instance_id": "a/b", "repo": "r", "base_commit": "c", "problem_statement": "p", "fail_to_pass": ["true"]}`, "file name"},
+		{instance + "\n" + instance, "duplicate instance id"},
+		{`{"instance_id": "a", "tests": []}`, "unknown field"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "invalid.jsonl")
+		os.WriteFile(path, []byte(tt.content), 0644)
+		if _, err := LoadDataset(path); err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+		}
+	}
+}
+
+// newEvalRepo 创建status.txt内容为broken的仓库，返回仓库路径和基准提交
+func newEvalRepo(t *testing.T) (string, string) {
+	t.Helper()
+	return testutil.InitRepo(t, map[string]string{"status.txt": "broken\n", "README.md": "readme\n"})
+}
+
+// editStatus 修改status.txt的脚本响应
+func editStatus(content string) []llm.LLMInteraction {
+	return []llm.LLMInteraction{testutil.EditResponse("status.txt", content), testutil.FinalResponse("任务完成")}
+}
+
+func TestHarness_Run(t *testing.T) {
+	repo, base := newEvalRepo(t)
+	scripts := map[string][]llm.LLMInteraction{
+		"fixed": editStatus("fixed\n"),
+		"wrong": editStatus("still broken\n"),
+		// 没有修改就声称完成，must_patch提示后脚本耗尽
+		"empty": {testutil.FinalResponse("任务完成")},
+	}
+
+	scratch := t.TempDir()
+	harness, err := NewHarness(Options{
+		NewAgent: func(trajectoryFile string) (agent.Agent, error) {
+			client := llm.NewReplayClient(scripts[testutil.TaskID(trajectoryFile)])
+			return testutil.NewReplayAgent(client, trajectoryFile, tools.NewEditTool()), nil
+		},
+		ScratchDir:    scratch,
+		TrajectoryDir: filepath.Join(t.TempDir(), "trajectories"),
+		Concurrency:   3,
+		TestTimeout:   time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create harness: %v", err)
+	}
+
+	// 测试脚本只在评测时通过test_patch加入
+	testPatch := "diff --git a/check.sh b/check.sh\nnew file mode 100644\n--- /dev/null\n+++ b/check.sh\n@@ -0,0 +1 @@\n+grep -q fixed status.txt\n"
+	instances := make([]Instance, 0)
+	for _, id := range []string{"fixed", "wrong", "empty"} {
+		instances = append(instances, Instance{
+			InstanceID:       id,
+			Repo:             repo,
+			BaseCommit:       base,
+			ProblemStatement: "status.txt should say fixed",
+			TestPatch:        testPatch,
+			FailToPass:       []string{"sh check.sh"},
+			PassToPass:       []string{"test -f README.md"},
+		})
+	}
+
+	results := harness.Run(context.Background(), instances, nil)
+
+	fixed := results[0]
+	if !fixed.Resolved || !fixed.PatchApplied || !fixed.FailToPass[0].Passed || !fixed.PassToPass[0].Passed {
+		t.Errorf("Expected fixed instance to be resolved, got %+v", fixed)
+	}
+	if strings.Contains(fixed.Patch, "check.sh") {
+		t.Errorf("Expected model patch not to contain the test patch, got %q", fixed.Patch)
+	}
+
+	wrong := results[1]
+	if wrong.Resolved || !wrong.PatchApplied || wrong.FailToPass[0].Passed || wrong.FailToPass[0].ExitCode != 1 {
+		t.Errorf("Expected wrong instance to fail its fail_to_pass test, got %+v", wrong)
+	}
+
+	empty := results[2]
+	if empty.Resolved || empty.Patch != "" || !strings.Contains(empty.Error, "empty patch") {
+		t.Errorf("Expected empty patch result, got %+v", empty)
+	}
+
+	summary := Summarize(results)
+	if summary.Total != 3 || summary.Resolved != 1 || summary.EmptyPatches != 1 || summary.ApplyFailures != 0 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if len(summary.ResolvedIDs) != 1 || summary.ResolvedIDs[0] != "fixed" {
+		t.Errorf("Expected resolved ids [fixed], got %v", summary.ResolvedIDs)
+	}
+
+	// 原仓库不受影响，worktree已清理
+	content, _ := os.ReadFile(filepath.Join(repo, "status.txt"))
+	if string(content) != "broken\n" {
+		t.Errorf("Expected original repo to be untouched, got %q", content)
+	}
+	worktrees, _ := utils.RunGit(context.Background(), repo, "worktree", "list")
+	if lines := strings.Count(strings.TrimSpace(string(worktrees)), "\n") + 1; lines != 1 {
+		t.Errorf("Expected worktrees to be removed, got:\n%s", worktrees)
+	}
+}
+
+func TestRunTest_Timeout(t *testing.T) {
+	result := runTest(context.Background(), t.TempDir(), "sleep 5", 100*time.Millisecond)
+	if result.Passed || result.ExitCode != -1 || !strings.Contains(result.Output, "timed out") {
+		t.Errorf("Expected timed out result, got %+v", result)
+	}
+}
@@ -0,0 +1,317 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/batch"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/utils"
+)
+
+// maxTestOutput 测试结果中保留的输出字符数（取末尾）
+const maxTestOutput = 2000
+
+// TestResult 单条测试命令的结果
+type TestResult struct {
+	Command  string  `json:"command"`
+	Passed   bool    `json:"passed"`
+	ExitCode int     `json:"exit_code"`
+	Duration float64 `json:"duration_seconds"`
+	Output   string  `json:"output,omitempty"`
+}
+
+// Result 单个实例的评测结果
+type Result struct {
+	InstanceID     string       `json:"instance_id"`
+	Resolved       bool         `json:"resolved"`
+	AgentSuccess   bool         `json:"agent_success"`
+	PatchApplied   bool         `json:"patch_applied"`
+	Patch          string       `json:"patch"`
+	FailToPass     []TestResult `json:"fail_to_pass"`
+	PassToPass     []TestResult `json:"pass_to_pass"`
+	Usage          llm.Usage    `json:"usage"`
+	Steps          int          `json:"steps"`
+	Duration       float64      `json:"duration_seconds"`
+	TrajectoryPath string       `json:"trajectory_path,omitempty"`
+	Error          string       `json:"error,omitempty"`
+}
+
+// Options 评测选项
+type Options struct {
+	NewAgent      func(trajectoryFile string) (agent.Agent, error) // 为每个实例创建独立的代理
+	ScratchDir    string                                           // 存放临时worktree的目录
+	TrajectoryDir string                                           // 轨迹文件目录，文件名为<instance_id>.jsonl
+	Concurrency   int                                              // 同时评测的实例数
+	MaxSteps      int                                              // 代理的最大步数，为0时使用配置中的值
+	TestTimeout   time.Duration                                    // 单条测试命令的超时时间
+	KeepWorktrees bool                                             // 保留worktree便于排查
+}
+
+// Harness SWE-bench风格的评测：在基准提交的临时worktree中运行代理，
+// 再把补丁应用到另一个干净的worktree中运行测试
+type Harness struct {
+	options Options
+	runner  *batch.Runner
+	// git不支持在同一仓库中并发添加或删除worktree
+	gitMutex sync.Mutex
+}
+
+// NewHarness 创建评测
+func NewHarness(options Options) (*Harness, error) {
+	if options.ScratchDir == "" {
+		options.ScratchDir = "eval_workspaces"
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.TestTimeout <= 0 {
+		options.TestTimeout = 10 * time.Minute
+	}
+
+	scratchDir, err := filepath.Abs(options.ScratchDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve scratch directory: %w", err)
+	}
+	if err := os.MkdirAll(scratchDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	options.ScratchDir = scratchDir
+
+	runner, err := batch.NewRunner(batch.Options{
+		NewAgent:      options.NewAgent,
+		Concurrency:   1,
+		TrajectoryDir: options.TrajectoryDir,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Harness{options: options, runner: runner}, nil
+}
+
+// Run 评测所有实例，每个实例结束后串行调用onResult，返回按输入顺序排列的结果
+func (h *Harness) Run(ctx context.Context, instances []Instance, onResult func(Result)) []Result {
+	results := make([]Result, len(instances))
+	slots := make(chan struct{}, h.options.Concurrency)
+	var wg sync.WaitGroup
+	var resultMutex sync.Mutex
+
+	for i := range instances {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			result := h.evaluate(ctx, instances[i])
+			resultMutex.Lock()
+			results[i] = result
+			if onResult != nil {
+				onResult(result)
+			}
+			resultMutex.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// evaluate 评测单个实例
+func (h *Harness) evaluate(ctx context.Context, instance Instance) Result {
+	startTime := time.Now()
+	result := Result{
+		InstanceID: instance.InstanceID,
+		FailToPass: make([]TestResult, 0),
+		PassToPass: make([]TestResult, 0),
+	}
+	defer func() {
+		result.Duration = time.Since(startTime).Seconds()
+	}()
+
+	if err := ctx.Err(); err != nil {
+		result.Error = fmt.Sprintf("evaluation cancelled: %v", err)
+		return result
+	}
+
+	instanceDir := filepath.Join(h.options.ScratchDir, instance.InstanceID)
+	agentDir := filepath.Join(instanceDir, "agent")
+	evalDir := filepath.Join(instanceDir, "eval")
+
+	// 在基准提交的worktree中运行代理，必须生成补丁
+	if err := h.addWorktree(instance.Repo, agentDir, instance.BaseCommit); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if !h.options.KeepWorktrees {
+		defer h.removeWorktree(instance.Repo, agentDir)
+	}
+
+	agentResult := h.runner.Run(ctx, []batch.Task{{
+		ID:         instance.InstanceID,
+		Prompt:     buildPrompt(instance),
+		WorkingDir: agentDir,
+		BaseCommit: instance.BaseCommit,
+		MaxSteps:   h.options.MaxSteps,
+		MustPatch:  true,
+	}}, nil)[0]
+	result.AgentSuccess = agentResult.Success
+	result.Patch = agentResult.Patch
+	result.Usage = agentResult.Usage
+	result.Steps = agentResult.Steps
+	result.TrajectoryPath = agentResult.TrajectoryPath
+	result.Error = agentResult.Error
+
+	if err := ctx.Err(); err != nil {
+		result.Error = appendError(result.Error, fmt.Sprintf("evaluation cancelled: %v", err))
+		return result
+	}
+	if strings.TrimSpace(result.Patch) == "" {
+		result.Error = appendError(result.Error, "agent produced an empty patch")
+		return result
+	}
+
+	// 在干净的worktree中应用补丁并运行测试，避免代理留下的状态影响结果
+	if err := h.addWorktree(instance.Repo, evalDir, instance.BaseCommit); err != nil {
+		result.Error = appendError(result.Error, err.Error())
+		return result
+	}
+	if !h.options.KeepWorktrees {
+		defer h.removeWorktree(instance.Repo, evalDir)
+	}
+
+	if err := applyPatch(ctx, evalDir, filepath.Join(instanceDir, "model.patch"), result.Patch); err != nil {
+		result.Error = appendError(result.Error, fmt.Sprintf("failed to apply patch: %v", err))
+		return result
+	}
+	result.PatchApplied = true
+
+	if instance.TestPatch != "" {
+		if err := applyPatch(ctx, evalDir, filepath.Join(instanceDir, "test.patch"), instance.TestPatch); err != nil {
+			result.Error = appendError(result.Error, fmt.Sprintf("failed to apply test patch: %v", err))
+			return result
+		}
+	}
+
+	result.FailToPass = h.runTests(ctx, evalDir, instance.FailToPass)
+	result.PassToPass = h.runTests(ctx, evalDir, instance.PassToPass)
+	result.Resolved = allPassed(result.FailToPass) && allPassed(result.PassToPass)
+	return result
+}
+
+// addWorktree 在path创建检出commit的分离worktree，已存在时先删除
+func (h *Harness) addWorktree(repo, path, commit string) error {
+	h.removeWorktree(repo, path)
+
+	h.gitMutex.Lock()
+	defer h.gitMutex.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	if _, err := utils.RunGit(context.Background(), repo, "worktree", "add", "--detach", "--force", path, commit); err != nil {
+		return fmt.Errorf("failed to create worktree: %w", err)
+	}
+	return nil
+}
+
+// removeWorktree 删除worktree，忽略不存在的情况
+func (h *Harness) removeWorktree(repo, path string) {
+	h.gitMutex.Lock()
+	defer h.gitMutex.Unlock()
+	utils.RunGit(context.Background(), repo, "worktree", "remove", "--force", path)
+	os.RemoveAll(path)
+	utils.RunGit(context.Background(), repo, "worktree", "prune")
+}
+
+// runTests 依次运行测试命令
+func (h *Harness) runTests(ctx context.Context, dir string, commands []string) []TestResult {
+	results := make([]TestResult, 0, len(commands))
+	for _, command := range commands {
+		results = append(results, runTest(ctx, dir, command, h.options.TestTimeout))
+	}
+	return results
+}
+
+// runTest 在dir中通过shell运行一条测试命令，退出码为0视为通过
+func runTest(ctx context.Context, dir, command string, timeout time.Duration) TestResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	// 超时后shell被终止，但它启动的子进程可能仍占用输出管道
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+
+	result := TestResult{
+		Command:  command,
+		Passed:   err == nil,
+		Duration: time.Since(startTime).Seconds(),
+		Output:   tail(string(output), maxTestOutput),
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.ExitCode = -1
+		result.Output += fmt.Sprintf("\n[timed out after %s]", timeout)
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		result.ExitCode = -1
+		result.Output += "\n" + err.Error()
+	}
+	return result
+}
+
+// applyPatch 将补丁写入patchFile后应用到dir
+func applyPatch(ctx context.Context, dir, patchFile, patch string) error {
+	if err := os.WriteFile(patchFile, []byte(patch), 0644); err != nil {
+		return fmt.Errorf("failed to write patch: %w", err)
+	}
+	_, err := utils.RunGit(ctx, dir, "apply", "--whitespace=nowarn", patchFile)
+	return err
+}
+
+// buildPrompt 构建代理的任务描述
+func buildPrompt(instance Instance) string {
+	return fmt.Sprintf(`请修改当前仓库中的代码来解决下面描述的问题。修改应尽量小，并且不需要修改或新增测试。
+
+[问题描述]
+%s`, instance.ProblemStatement)
+}
+
+// allPassed 检查测试是否全部通过
+func allPassed(results []TestResult) bool {
+	for _, result := range results {
+		if !result.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// tail 只保留文本末尾的maxChars个字符
+func tail(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return "..." + string(runes[len(runes)-maxChars:])
+}
+
+// appendError 合并错误信息
+func appendError(existing, message string) string {
+	if existing == "" {
+		return message
+	}
+	return existing + "; " + message
+}
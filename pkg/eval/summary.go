@@ -0,0 +1,65 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Summary 评测汇总，用于比较不同模型或提示词
+type Summary struct {
+	Model         string   `json:"model,omitempty"`
+	Dataset       string   `json:"dataset,omitempty"`
+	Total         int      `json:"total"`
+	Resolved      int      `json:"resolved"`
+	ResolveRate   float64  `json:"resolve_rate"`
+	EmptyPatches  int      `json:"empty_patches"`
+	ApplyFailures int      `json:"apply_failures"`
+	TotalTokens   int      `json:"total_tokens"`
+	Duration      float64  `json:"duration_seconds"`
+	ResolvedIDs   []string `json:"resolved_ids"`
+}
+
+// Summarize 汇总评测结果
+func Summarize(results []Result) Summary {
+	summary := Summary{
+		Total:       len(results),
+		ResolvedIDs: make([]string, 0),
+	}
+
+	for _, result := range results {
+		if result.Resolved {
+			summary.Resolved++
+			summary.ResolvedIDs = append(summary.ResolvedIDs, result.InstanceID)
+		}
+		if strings.TrimSpace(result.Patch) == "" {
+			summary.EmptyPatches++
+		} else if !result.PatchApplied {
+			summary.ApplyFailures++
+		}
+		summary.TotalTokens += result.Usage.TotalTokens
+		summary.Duration += result.Duration
+	}
+
+	if summary.Total > 0 {
+		summary.ResolveRate = float64(summary.Resolved) / float64(summary.Total)
+	}
+	return summary
+}
+
+// String 格式化为控制台显示的文本
+func (s Summary) String() string {
+	var sb strings.Builder
+	if s.Model != "" {
+		sb.WriteString(fmt.Sprintf("模型:         %s\n", s.Model))
+	}
+	if s.Dataset != "" {
+		sb.WriteString(fmt.Sprintf("数据集:       %s\n", s.Dataset))
+	}
+	sb.WriteString(fmt.Sprintf("实例数:       %d\n", s.Total))
+	sb.WriteString(fmt.Sprintf("已解决:       %d (%.1f%%)\n", s.Resolved, s.ResolveRate*100))
+	sb.WriteString(fmt.Sprintf("空补丁:       %d\n", s.EmptyPatches))
+	sb.WriteString(fmt.Sprintf("补丁应用失败: %d\n", s.ApplyFailures))
+	sb.WriteString(fmt.Sprintf("总Token数:    %d\n", s.TotalTokens))
+	sb.WriteString(fmt.Sprintf("总耗时:       %.1fs", s.Duration))
+	return sb.String()
+}
@@ -0,0 +1,70 @@
+package lineedit
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Completer 根据当前行和光标位置（按rune计）返回补全候选，start为被替换部分的起始位置
+type Completer func(line []rune, pos int) (start int, candidates []string)
+
+// CurrentWord 返回光标前的单词及其起始位置，单词以空白分隔
+func CurrentWord(line []rune, pos int) (string, int) {
+	start := pos
+	for start > 0 && !unicode.IsSpace(line[start-1]) {
+		start--
+	}
+	return string(line[start:pos]), start
+}
+
+// CompletePath 补全文件路径，目录以/结尾，隐藏文件只在前缀以.开头时出现
+func CompletePath(prefix string) []string {
+	dir, base := filepath.Split(prefix)
+	searchDir := dir
+	if searchDir == "" {
+		searchDir = "."
+	} else if strings.HasPrefix(searchDir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			searchDir = filepath.Join(home, searchDir[2:])
+		}
+	}
+
+	entries, err := os.ReadDir(searchDir)
+	if err != nil {
+		return nil
+	}
+	candidates := make([]string, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		candidate := dir + name
+		if entry.IsDir() {
+			candidate += "/"
+		}
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// commonPrefix 返回所有候选的最长公共前缀
+func commonPrefix(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	prefix := []rune(candidates[0])
+	for _, candidate := range candidates[1:] {
+		runes := []rune(candidate)
+		n := 0
+		for n < len(prefix) && n < len(runes) && prefix[n] == runes[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
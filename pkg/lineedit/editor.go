@@ -0,0 +1,582 @@
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"trage-agent-go/pkg/textwidth"
+)
+
+// ErrInterrupted 用户在输入时按下了Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// defaultWidth 无法获取终端宽度时使用的宽度
+const defaultWidth = 80
+
+// pasteEnd 括号粘贴模式中粘贴内容的结束标记（ESC之后的部分）
+const pasteEnd = "[201~"
+
+// Editor 终端行编辑器，支持光标移动、历史、Ctrl-R搜索、Tab补全和多行粘贴。
+// 输入不是终端时（如管道）退化为逐行读取
+type Editor struct {
+	in        *os.File
+	out       io.Writer
+	reader    *bufio.Reader
+	history   *History
+	completer Completer
+	cursorRow int // 上次绘制后光标位于提示符下方第几行
+}
+
+// lineState 正在编辑的一行
+type lineState struct {
+	prompt       string
+	buf          []rune
+	pos          int
+	historyIndex int    // 当前显示的历史记录，等于历史条数时表示正在编辑的新输入
+	saved        string // 浏览历史前正在编辑的内容
+}
+
+// NewEditor 创建行编辑器，history为nil时不记录历史
+func NewEditor(in *os.File, out io.Writer, history *History) *Editor {
+	if history == nil {
+		history, _ = LoadHistory("", 0)
+	}
+	return &Editor{
+		in:      in,
+		out:     out,
+		reader:  bufio.NewReader(in),
+		history: history,
+	}
+}
+
+// SetCompleter 设置Tab补全函数
+func (e *Editor) SetCompleter(completer Completer) {
+	e.completer = completer
+}
+
+// History 获取输入历史
+func (e *Editor) History() *History {
+	return e.history
+}
+
+// ReadInput 读取一条完整的输入并写入历史：行尾的\表示续行；以"""开始的输入持续到以"""结尾的行，
+// 两侧的"""会被去掉。续行使用continuation作为提示符
+func (e *Editor) ReadInput(prompt, continuation string) (string, error) {
+	line, err := e.ReadLine(prompt)
+	if err != nil {
+		return "", err
+	}
+
+	var input string
+	if body, found := strings.CutPrefix(strings.TrimSpace(line), `"""`); found {
+		lines := make([]string, 0)
+		for {
+			if content, closed := strings.CutSuffix(body, `"""`); closed {
+				lines = append(lines, content)
+				break
+			}
+			lines = append(lines, body)
+			if body, err = e.ReadLine(continuation); err != nil {
+				return "", err
+			}
+		}
+		input = strings.Trim(strings.Join(lines, "\n"), "\n")
+	} else {
+		lines := make([]string, 0)
+		for {
+			content, continued := strings.CutSuffix(line, `\`)
+			lines = append(lines, content)
+			if !continued {
+				break
+			}
+			if line, err = e.ReadLine(continuation); err != nil {
+				return "", err
+			}
+		}
+		input = strings.Join(lines, "\n")
+	}
+
+	// 补全时追加的空格不写入历史
+	if err := e.history.Add(strings.TrimRight(input, " \t")); err != nil {
+		fmt.Fprintf(e.out, "warning: %v\n", err)
+	}
+	return input, nil
+}
+
+// ReadLine 读取一行输入，不写入历史。Ctrl-C返回ErrInterrupted，空行上的Ctrl-D返回io.EOF；
+// 粘贴的多行文本作为一行返回，其中保留换行符
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	fd := int(e.in.Fd())
+	if !isTerminal(fd) {
+		return e.readPlainLine(prompt)
+	}
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return e.readPlainLine(prompt)
+	}
+	defer restore()
+
+	// 开启括号粘贴模式，粘贴的换行不会被当作回车
+	io.WriteString(e.out, "\x1b[?2004h")
+	defer io.WriteString(e.out, "\x1b[?2004l")
+
+	s := &lineState{prompt: prompt, buf: make([]rune, 0), historyIndex: e.history.Len()}
+	e.cursorRow = 0
+	e.refresh(s.prompt, s.buf, s.pos)
+
+	lastTab := false
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		tab := false
+		switch r {
+		case '\r', '\n':
+			e.finish(s)
+			return string(s.buf), nil
+		case ctrl('C'):
+			e.finish(s)
+			return "", ErrInterrupted
+		case ctrl('D'):
+			if len(s.buf) == 0 {
+				e.finish(s)
+				return "", io.EOF
+			}
+			s.deleteForward()
+		case ctrl('A'):
+			s.pos = 0
+		case ctrl('E'):
+			s.pos = len(s.buf)
+		case ctrl('B'):
+			s.moveBy(-1)
+		case ctrl('F'):
+			s.moveBy(1)
+		case ctrl('H'), 0x7F:
+			s.deleteBackward()
+		case ctrl('K'):
+			s.buf = s.buf[:s.pos]
+		case ctrl('U'):
+			s.buf = append([]rune(nil), s.buf[s.pos:]...)
+			s.pos = 0
+		case ctrl('W'):
+			s.deleteWordBackward()
+		case ctrl('L'):
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+			e.cursorRow = 0
+		case ctrl('P'):
+			e.moveHistory(s, -1)
+		case ctrl('N'):
+			e.moveHistory(s, 1)
+		case ctrl('R'):
+			if submit := e.search(s); submit {
+				e.finish(s)
+				return string(s.buf), nil
+			}
+		case '\t':
+			e.complete(s, lastTab)
+			tab = true
+		case 0x1B:
+			e.handleEscape(s)
+		default:
+			if r >= 0x20 {
+				s.insert(r)
+			}
+		}
+		lastTab = tab
+		e.refresh(s.prompt, s.buf, s.pos)
+	}
+}
+
+// readPlainLine 非终端输入时逐行读取，不限制行的长度
+func (e *Editor) readPlainLine(prompt string) (string, error) {
+	io.WriteString(e.out, prompt)
+	line, err := e.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ReadKey 显示prompt后读取一个按键，不回显也不需要回车。Ctrl-C返回ErrInterrupted，Ctrl-D返回io.EOF；
+// 输入不是终端时读取一行并返回其第一个字符，空行返回回车
+func (e *Editor) ReadKey(prompt string) (rune, error) {
+	fd := int(e.in.Fd())
+	if !isTerminal(fd) {
+		return e.readPlainKey(prompt)
+	}
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return e.readPlainKey(prompt)
+	}
+	defer restore()
+
+	io.WriteString(e.out, prompt)
+	r, _, err := e.reader.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch r {
+	case ctrl('C'):
+		return 0, ErrInterrupted
+	case ctrl('D'):
+		return 0, io.EOF
+	case 0x1B:
+		// 丢弃方向键等转义序列的剩余部分
+		if e.reader.Buffered() > 0 {
+			e.readEscape()
+		}
+	}
+	return r, nil
+}
+
+// readPlainKey 非终端输入时读取一行，返回其第一个字符，空行返回回车
+func (e *Editor) readPlainKey(prompt string) (rune, error) {
+	line, err := e.readPlainLine(prompt)
+	if err != nil {
+		return 0, err
+	}
+	if line == "" {
+		return '\r', nil
+	}
+	r, _ := utf8.DecodeRuneInString(line)
+	return r, nil
+}
+
+// handleEscape 处理方向键、Home/End、Delete、Alt组合键和括号粘贴等转义序列
+func (e *Editor) handleEscape(s *lineState) {
+	sequence := e.readEscape()
+	switch sequence {
+	case "[A", "OA":
+		e.moveHistory(s, -1)
+	case "[B", "OB":
+		e.moveHistory(s, 1)
+	case "[C", "OC":
+		s.moveBy(1)
+	case "[D", "OD":
+		s.moveBy(-1)
+	case "[H", "OH", "[1~", "[7~":
+		s.pos = 0
+	case "[F", "OF", "[4~", "[8~":
+		s.pos = len(s.buf)
+	case "[3~":
+		s.deleteForward()
+	case "[1;5C", "[1;3C", "f":
+		s.moveWord(1)
+	case "[1;5D", "[1;3D", "b":
+		s.moveWord(-1)
+	case "\x7F":
+		s.deleteWordBackward()
+	case "[200~":
+		e.readPaste(s)
+	}
+}
+
+// readEscape 读取ESC之后的转义序列
+func (e *Editor) readEscape() string {
+	first, _, err := e.reader.ReadRune()
+	if err != nil {
+		return ""
+	}
+	switch first {
+	case '[':
+		// CSI序列：参数字节之后是0x40-0x7E之间的结束字节
+		var sb strings.Builder
+		sb.WriteRune(first)
+		for {
+			r, _, err := e.reader.ReadRune()
+			if err != nil {
+				return sb.String()
+			}
+			sb.WriteRune(r)
+			if r >= 0x40 && r <= 0x7E {
+				return sb.String()
+			}
+		}
+	case 'O':
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return ""
+		}
+		return "O" + string(r)
+	default:
+		return string(first)
+	}
+}
+
+// readPaste 读取括号粘贴的内容，换行按原样插入
+func (e *Editor) readPaste(s *lineState) {
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return
+		}
+		switch r {
+		case 0x1B:
+			if sequence := e.readEscape(); sequence == pasteEnd {
+				return
+			}
+		case '\r':
+			// \r\n只插入一个换行
+			if next, err := e.reader.Peek(1); err == nil && next[0] == '\n' {
+				continue
+			}
+			s.insert('\n')
+		case '\t':
+			s.insert(' ')
+		default:
+			if r >= 0x20 || r == '\n' {
+				s.insert(r)
+			}
+		}
+	}
+}
+
+// moveHistory 在历史记录中前后移动，delta为-1时显示更早的记录
+func (e *Editor) moveHistory(s *lineState, delta int) {
+	index := s.historyIndex + delta
+	if index < 0 || index > e.history.Len() {
+		return
+	}
+	if s.historyIndex == e.history.Len() {
+		s.saved = string(s.buf)
+	}
+	s.historyIndex = index
+	if index == e.history.Len() {
+		s.buf = []rune(s.saved)
+	} else {
+		s.buf = []rune(e.history.Get(index))
+	}
+	s.pos = len(s.buf)
+}
+
+// search Ctrl-R反向搜索历史。回车时返回true表示直接提交匹配的记录，
+// Ctrl-G或Ctrl-C放弃搜索，其他控制键接受匹配的记录继续编辑
+func (e *Editor) search(s *lineState) bool {
+	original, originalPos := s.buf, s.pos
+	query := make([]rune, 0)
+	match := -1
+	failed := false
+
+	for {
+		text, pos := []rune(nil), 0
+		if match >= 0 {
+			entry := e.history.Get(match)
+			text = []rune(entry)
+			pos = utf8.RuneCountInString(entry[:strings.Index(entry, string(query))])
+		}
+		label := "(reverse-i-search)"
+		if failed {
+			label = "(failed reverse-i-search)"
+		}
+		e.refresh(fmt.Sprintf("%s`%s': ", label, string(query)), text, pos)
+
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return false
+		}
+		switch r {
+		case ctrl('R'):
+			if match > 0 {
+				if previous := e.history.Search(string(query), match); previous >= 0 {
+					match = previous
+					continue
+				}
+			}
+			failed = true
+		case ctrl('H'), 0x7F:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+			match = e.history.Search(string(query), e.history.Len())
+			failed = match < 0 && len(query) > 0
+		case ctrl('G'), ctrl('C'):
+			s.buf, s.pos = original, originalPos
+			return false
+		case '\r', '\n':
+			if match >= 0 {
+				s.buf, s.pos, s.historyIndex = text, len(text), match
+			}
+			return true
+		default:
+			if r >= 0x20 {
+				query = append(query, r)
+				from := e.history.Len()
+				if match >= 0 {
+					from = match + 1
+				}
+				if next := e.history.Search(string(query), from); next >= 0 {
+					match, failed = next, false
+				} else {
+					failed = true
+				}
+				continue
+			}
+			// 其他控制键：接受匹配的记录并退出搜索，转义序列一并丢弃
+			if r == 0x1B {
+				e.readEscape()
+			}
+			if match >= 0 {
+				s.buf, s.pos, s.historyIndex = text, pos, match
+			}
+			return false
+		}
+	}
+}
+
+// complete Tab补全：唯一候选时直接补全，多个候选时补全公共前缀，连续第二次按Tab时列出候选
+func (e *Editor) complete(s *lineState, list bool) {
+	if e.completer == nil {
+		return
+	}
+	start, candidates := e.completer(s.buf, s.pos)
+	if len(candidates) == 0 || start < 0 || start > s.pos {
+		return
+	}
+
+	current := string(s.buf[start:s.pos])
+	replacement := commonPrefix(candidates)
+	if len(candidates) == 1 && !strings.HasSuffix(replacement, "/") {
+		replacement += " "
+	}
+	if replacement != current && strings.HasPrefix(replacement, current) {
+		tail := append([]rune(replacement), s.buf[s.pos:]...)
+		s.buf = append(s.buf[:start:start], tail...)
+		s.pos = start + utf8.RuneCountInString(replacement)
+		return
+	}
+
+	if list && len(candidates) > 1 {
+		e.refresh(s.prompt, s.buf, len(s.buf))
+		io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+		e.cursorRow = 0
+	}
+}
+
+// finish 将光标移到输入末尾并换行
+func (e *Editor) finish(s *lineState) {
+	e.refresh(s.prompt, s.buf, len(s.buf))
+	io.WriteString(e.out, "\r\n")
+	e.cursorRow = 0
+}
+
+// refresh 重新绘制提示符和输入内容，并把光标移到pos处
+func (e *Editor) refresh(prompt string, buf []rune, pos int) {
+	width := terminalWidth(int(e.in.Fd()))
+	if width <= 0 {
+		width = defaultWidth
+	}
+
+	var sb strings.Builder
+	// 回到提示符所在行并清除之后的内容
+	if e.cursorRow > 0 {
+		sb.WriteString(fmt.Sprintf("\x1b[%dA", e.cursorRow))
+	}
+	sb.WriteString("\r\x1b[J")
+	sb.WriteString(prompt)
+	sb.WriteString(strings.ReplaceAll(string(buf), "\n", "\r\n"))
+
+	promptRow, promptCol := layout([]rune(prompt), 0, 0, width)
+	endRow, endCol := layout(buf, promptRow, promptCol, width)
+	// 恰好写满一行时终端不会自动换行，手动换行以便定位光标
+	if endCol >= width {
+		sb.WriteString("\r\n")
+		endRow, endCol = endRow+1, 0
+	}
+
+	cursorRow, cursorCol := layout(buf[:pos], promptRow, promptCol, width)
+	if cursorCol >= width || (pos < len(buf) && buf[pos] != '\n' && cursorCol+textwidth.Rune(buf[pos]) > width) {
+		cursorRow, cursorCol = cursorRow+1, 0
+	}
+
+	if endRow > cursorRow {
+		sb.WriteString(fmt.Sprintf("\x1b[%dA", endRow-cursorRow))
+	}
+	sb.WriteString("\r")
+	if cursorCol > 0 {
+		sb.WriteString(fmt.Sprintf("\x1b[%dC", cursorCol))
+	}
+	e.cursorRow = cursorRow
+	io.WriteString(e.out, sb.String())
+}
+
+// insert 在光标处插入字符
+func (s *lineState) insert(r rune) {
+	s.buf = append(s.buf, 0)
+	copy(s.buf[s.pos+1:], s.buf[s.pos:])
+	s.buf[s.pos] = r
+	s.pos++
+}
+
+// moveBy 左右移动光标
+func (s *lineState) moveBy(delta int) {
+	s.pos += delta
+	if s.pos < 0 {
+		s.pos = 0
+	}
+	if s.pos > len(s.buf) {
+		s.pos = len(s.buf)
+	}
+}
+
+// moveWord 按单词移动光标，delta为-1时向左
+func (s *lineState) moveWord(delta int) {
+	if delta < 0 {
+		s.pos = s.wordStart()
+		return
+	}
+	for s.pos < len(s.buf) && unicode.IsSpace(s.buf[s.pos]) {
+		s.pos++
+	}
+	for s.pos < len(s.buf) && !unicode.IsSpace(s.buf[s.pos]) {
+		s.pos++
+	}
+}
+
+// wordStart 光标前一个单词的起始位置
+func (s *lineState) wordStart() int {
+	start := s.pos
+	for start > 0 && unicode.IsSpace(s.buf[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(s.buf[start-1]) {
+		start--
+	}
+	return start
+}
+
+// deleteBackward 删除光标前的字符
+func (s *lineState) deleteBackward() {
+	if s.pos == 0 {
+		return
+	}
+	s.buf = append(s.buf[:s.pos-1], s.buf[s.pos:]...)
+	s.pos--
+}
+
+// deleteForward 删除光标处的字符
+func (s *lineState) deleteForward() {
+	if s.pos >= len(s.buf) {
+		return
+	}
+	s.buf = append(s.buf[:s.pos], s.buf[s.pos+1:]...)
+}
+
+// deleteWordBackward 删除光标前的单词
+func (s *lineState) deleteWordBackward() {
+	start := s.wordStart()
+	s.buf = append(s.buf[:start], s.buf[s.pos:]...)
+	s.pos = start
+}
+
+// ctrl 返回Ctrl组合键对应的字符
+func ctrl(r rune) rune {
+	return r & 0x1F
+}
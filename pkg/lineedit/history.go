@@ -0,0 +1,155 @@
+package lineedit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultHistorySize 默认保留的历史条数
+const DefaultHistorySize = 1000
+
+// History 输入历史，每条记录以JSON字符串的形式占一行，多行输入也能完整保存
+type History struct {
+	path    string
+	max     int
+	entries []string
+}
+
+// DefaultHistoryPath 获取默认的历史文件路径（~/.trae/history）
+func DefaultHistoryPath() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".trae", "history")
+	}
+	return filepath.Join(os.TempDir(), ".trae", "history")
+}
+
+// LoadHistory 读取历史文件，path为空时只在内存中保存，文件不存在时返回空历史
+func LoadHistory(path string, max int) (*History, error) {
+	if max <= 0 {
+		max = DefaultHistorySize
+	}
+	history := &History{path: path, max: max, entries: make([]string, 0)}
+	if path == "" {
+		return history, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry string
+		// 跳过损坏的行，不影响其他记录
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil && entry != "" {
+			history.entries = append(history.entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	history.trim()
+	return history, nil
+}
+
+// Add 添加一条记录并追加到历史文件，空白输入和与上一条相同的输入会被忽略
+func (h *History) Add(entry string) error {
+	if strings.TrimSpace(entry) == "" {
+		return nil
+	}
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return nil
+	}
+	h.entries = append(h.entries, entry)
+	overflow := len(h.entries) > h.max
+	h.trim()
+
+	if h.path == "" {
+		return nil
+	}
+	// 超出上限时重写文件，否则只追加一行
+	if overflow {
+		return h.rewrite()
+	}
+	return h.append(entry)
+}
+
+// Entries 按从旧到新的顺序返回所有记录
+func (h *History) Entries() []string {
+	return append([]string(nil), h.entries...)
+}
+
+// Len 返回记录条数
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// Get 返回第index条记录
+func (h *History) Get(index int) string {
+	return h.entries[index]
+}
+
+// Search 从before之前（不含）向旧的方向查找包含query的记录，返回下标，找不到时返回-1
+func (h *History) Search(query string, before int) int {
+	if before > len(h.entries) {
+		before = len(h.entries)
+	}
+	for i := before - 1; i >= 0; i-- {
+		if strings.Contains(h.entries[i], query) {
+			return i
+		}
+	}
+	return -1
+}
+
+// trim 只保留最近的max条记录
+func (h *History) trim() {
+	if len(h.entries) > h.max {
+		h.entries = append([]string(nil), h.entries[len(h.entries)-h.max:]...)
+	}
+}
+
+// append 向历史文件追加一条记录
+func (h *History) append(entry string) error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+	data, _ := json.Marshal(entry)
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
+
+// rewrite 用当前记录重写历史文件
+func (h *History) rewrite() error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	var sb strings.Builder
+	for _, entry := range h.entries {
+		data, _ := json.Marshal(entry)
+		sb.Write(data)
+		sb.WriteByte('\n')
+	}
+	if err := os.WriteFile(h.path, []byte(sb.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package lineedit
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	history, err := LoadHistory(path, 3)
+	if err != nil {
+		t.Fatalf("Failed to load history: %v", err)
+	}
+	for _, entry := range []string{"first", "  ", "second", "second", "multi\nline", "fourth"} {
+		if err := history.Add(entry); err != nil {
+			t.Fatalf("Failed to add entry: %v", err)
+		}
+	}
+
+	// 空白和连续重复的输入被忽略，超出上限时丢弃最旧的记录
+	expected := []string{"second", "multi\nline", "fourth"}
+	if !reflect.DeepEqual(history.Entries(), expected) {
+		t.Errorf("Expected entries %q, got %q", expected, history.Entries())
+	}
+
+	// 损坏的行被跳过
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	file.WriteString("not json\n")
+	file.Close()
+
+	reloaded, err := LoadHistory(path, 3)
+	if err != nil {
+		t.Fatalf("Failed to reload history: %v", err)
+	}
+	if !reflect.DeepEqual(reloaded.Entries(), expected) {
+		t.Errorf("Expected reloaded entries %q, got %q", expected, reloaded.Entries())
+	}
+
+	if index := reloaded.Search("line", reloaded.Len()); index != 1 {
+		t.Errorf("Expected search to find index 1, got %d", index)
+	}
+	if index := reloaded.Search("fourth", 2); index != -1 {
+		t.Errorf("Expected search before index 2 to fail, got %d", index)
+	}
+}
+
+func TestCompletePath(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "main.go"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "setup.py"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, ".secret"), nil, 0644)
+
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{dir + "/s", []string{dir + "/setup.py", dir + "/src/"}},
+		{dir + "/src/", []string{dir + "/src/main.go"}},
+		{dir + "/.s", []string{dir + "/.secret"}},
+		{dir + "/missing/", nil},
+	}
+	for _, tt := range tests {
+		candidates := CompletePath(tt.prefix)
+		if len(candidates) != len(tt.expected) || (len(candidates) > 0 && !reflect.DeepEqual(candidates, tt.expected)) {
+			t.Errorf("CompletePath(%q): expected %q, got %q", tt.prefix, tt.expected, candidates)
+		}
+	}
+
+	if prefix := commonPrefix([]string{dir + "/setup.py", dir + "/src/"}); prefix != dir+"/s" {
+		t.Errorf("Expected common prefix %q, got %q", dir+"/s", prefix)
+	}
+}
+
+func TestCurrentWord(t *testing.T) {
+	line := []rune("读取 @src/ma")
+	word, start := CurrentWord(line, len(line))
+	if word != "@src/ma" || start != 3 {
+		t.Errorf("Expected word @src/ma at 3, got %q at %d", word, start)
+	}
+	if word, start := CurrentWord(line, 3); word != "" || start != 3 {
+		t.Errorf("Expected empty word at 3, got %q at %d", word, start)
+	}
+}
+
+func TestExpandFileReferences(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "binary.bin"), []byte{0x7F, 0x00, 0x01}, 0644)
+
+	expanded, references, err := ExpandFileReferences("看看 @main.go，还有@someone 和 @main.go", dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(references, []string{"main.go"}) {
+		t.Errorf("Expected references [main.go], got %v", references)
+	}
+	if !strings.HasPrefix(expanded, "看看 main.go，还有@someone 和 main.go") {
+		t.Errorf("Expected @ to be removed from file references only, got %q", expanded)
+	}
+	if strings.Count(expanded, `<file path="main.go">`) != 1 || !strings.Contains(expanded, "package main\n") {
+		t.Errorf("Expected file content to be appended once, got %q", expanded)
+	}
+
+	if _, _, err := ExpandFileReferences("@binary.bin", dir); err == nil {
+		t.Error("Expected error for binary file")
+	}
+
+	unchanged, references, err := ExpandFileReferences("email me@example.com", dir)
+	if err != nil || unchanged != "email me@example.com" || len(references) != 0 {
+		t.Errorf("Expected input without references to be unchanged, got %q %v %v", unchanged, references, err)
+	}
+}
+
+// newPipeEditor 创建从管道读取input的编辑器
+func newPipeEditor(t *testing.T, input string) *Editor {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	t.Cleanup(func() { reader.Close() })
+	go func() {
+		writer.WriteString(input)
+		writer.Close()
+	}()
+	return NewEditor(reader, io.Discard, nil)
+}
+
+func TestEditor_ReadInput(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	editor := newPipeEditor(t, "first line \\\nsecond\r\n\"\"\"\nblock\n  indented\n\"\"\"\n\"\"\"inline\"\"\"\n"+long+"\nlast")
+
+	expected := []string{"first line \nsecond", "block\n  indented", "inline", long, "last"}
+	for _, want := range expected {
+		input, err := editor.ReadInput("> ", "... ")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if input != want {
+			t.Errorf("Expected input %q, got %q", truncate(want), truncate(input))
+		}
+	}
+	if _, err := editor.ReadInput("> ", "... "); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+	if editor.History().Len() != len(expected) {
+		t.Errorf("Expected %d history entries, got %d", len(expected), editor.History().Len())
+	}
+}
+
+func TestEditor_ReadKey(t *testing.T) {
+	// 非终端输入时每行只取第一个字符，空行按回车处理
+	editor := newPipeEditor(t, "yes\n\nä\n")
+	for _, want := range []rune{'y', '\r', 'ä'} {
+		key, err := editor.ReadKey("? ")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if key != want {
+			t.Errorf("Expected key %q, got %q", want, key)
+		}
+	}
+	if _, err := editor.ReadKey("? "); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestEditor_Refresh(t *testing.T) {
+	var output bytes.Buffer
+	editor := newPipeEditor(t, "")
+	editor.out = &output
+
+	// 非终端宽度为80，第二行的光标位于提示符下方1行
+	editor.refresh("> ", []rune("hello\nworld"), 8)
+	if editor.cursorRow != 1 {
+		t.Errorf("Expected cursor row 1, got %d", editor.cursorRow)
+	}
+	if !strings.Contains(output.String(), "> hello\r\nworld") {
+		t.Errorf("Expected newlines to be written as CRLF, got %q", output.String())
+	}
+
+	output.Reset()
+	editor.refresh("> ", nil, 0)
+	if !strings.HasPrefix(output.String(), "\x1b[1A\r\x1b[J> ") {
+		t.Errorf("Expected refresh to move back to the prompt row, got %q", output.String())
+	}
+}
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		text     string
+		width    int
+		row, col int
+	}{
+		{"hello", 80, 0, 5},
+		{"你好", 80, 0, 4},
+		{"abc", 3, 0, 3},
+		{"abcd", 3, 1, 1},
+		{"ab你", 3, 1, 2},
+		{"a\nb", 80, 1, 1},
+	}
+	for _, tt := range tests {
+		row, col := layout([]rune(tt.text), 0, 0, tt.width)
+		if row != tt.row || col != tt.col {
+			t.Errorf("layout(%q, %d): expected (%d, %d), got (%d, %d)", tt.text, tt.width, tt.row, tt.col, row, col)
+		}
+	}
+}
+
+// truncate 截断过长的文本便于输出
+func truncate(text string) string {
+	if len(text) > 40 {
+		return text[:40] + "..."
+	}
+	return text
+}
@@ -0,0 +1,106 @@
+package lineedit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxReferenceSize 通过@引用内联的单个文件的最大字节数
+const MaxReferenceSize = 256 * 1024
+
+// referencePattern 位于开头或空白之后的@path，中文标点视为路径的结束
+var referencePattern = regexp.MustCompile(`(^|\s)@([^\s，。；：！？、）]+)`)
+
+// ExpandFileReferences 将输入中的@path替换为path，并在末尾附上这些文件的内容，返回展开后的输入和引用的文件。
+// 只有指向普通文件的@path才会被展开（如@someone保持不变），路径末尾的标点会被忽略，
+// 相对路径相对baseDir解析，同一文件只附加一次
+func ExpandFileReferences(input, baseDir string) (string, []string, error) {
+	matches := referencePattern.FindAllStringSubmatchIndex(input, -1)
+	if len(matches) == 0 {
+		return input, nil, nil
+	}
+
+	var text strings.Builder
+	var contents strings.Builder
+	references := make([]string, 0)
+	seen := make(map[string]bool)
+	last := 0
+	for _, match := range matches {
+		atIndex, pathStart, pathEnd := match[3], match[4], match[5]
+		name, path := resolveReference(input[pathStart:pathEnd], baseDir)
+		if name == "" {
+			continue
+		}
+
+		// 去掉@，保留路径和之后的标点
+		text.WriteString(input[last:atIndex])
+		last = atIndex + 1
+
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		data, err := readReference(path, name)
+		if err != nil {
+			return "", nil, err
+		}
+		references = append(references, name)
+		contents.WriteString(fmt.Sprintf("\n\n<file path=%q>\n%s", name, data))
+		if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+			contents.WriteString("\n")
+		}
+		contents.WriteString("</file>")
+	}
+	if last == 0 {
+		return input, nil, nil
+	}
+
+	text.WriteString(input[last:])
+	return text.String() + contents.String(), references, nil
+}
+
+// trailingPunctuation 引用末尾可以忽略的标点
+const trailingPunctuation = ".,;:!?)]}'\"，。；：！？）"
+
+// resolveReference 返回引用的文件名（去掉末尾标点后）和实际路径，不是普通文件时返回空
+func resolveReference(reference, baseDir string) (string, string) {
+	for candidate := reference; candidate != ""; {
+		path := candidate
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return candidate, path
+		}
+		r, size := utf8.DecodeLastRuneInString(candidate)
+		if !strings.ContainsRune(trailingPunctuation, r) {
+			break
+		}
+		candidate = candidate[:len(candidate)-size]
+	}
+	return "", ""
+}
+
+// readReference 读取被引用的文件，拒绝过大的文件和二进制文件
+func readReference(path, name string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if info.Size() > MaxReferenceSize {
+		return nil, fmt.Errorf("file %s is too large to inline (%d bytes, max %d)", name, info.Size(), MaxReferenceSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, fmt.Errorf("file %s looks like a binary file and cannot be inlined", name)
+	}
+	return data, nil
+}
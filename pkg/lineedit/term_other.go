@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package lineedit
+
+import "errors"
+
+// isTerminal 不支持的平台上始终按非终端处理，逐行读取输入
+func isTerminal(fd int) bool {
+	return false
+}
+
+// makeRaw 不支持的平台上无法切换原始模式
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+// terminalWidth 不支持的平台上返回0
+func terminalWidth(fd int) int {
+	return 0
+}
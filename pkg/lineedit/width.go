@@ -0,0 +1,20 @@
+package lineedit
+
+import "trage-agent-go/pkg/textwidth"
+
+// layout 计算从(row, col)开始依次输出runes后的位置，width为终端宽度，\n另起一行。
+// 恰好写满一行时返回(row, width)，由调用方决定是否换行
+func layout(runes []rune, row, col, width int) (int, int) {
+	for _, r := range runes {
+		if r == '\n' {
+			row, col = row+1, 0
+			continue
+		}
+		w := textwidth.Rune(r)
+		if col+w > width {
+			row, col = row+1, 0
+		}
+		col += w
+	}
+	return row, col
+}
@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
@@ -260,7 +261,7 @@ func NewCachedLLMClient(client LLMClient, cache LLMCache) *CachedLLMClient {
 }
 
 // Chat 实现LLMClient接口，带缓存
-func (clc *CachedLLMClient) Chat(messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
+func (clc *CachedLLMClient) Chat(ctx context.Context, messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
 	// 生成缓存键
 	cacheKey := clc.generateCacheKey(messages, tools, config)
 
@@ -270,7 +271,7 @@ func (clc *CachedLLMClient) Chat(messages []LLMMessage, tools []Tool, config Mod
 	}
 
 	// 缓存未命中，调用实际客户端
-	response, err := clc.client.Chat(messages, tools, config)
+	response, err := clc.client.Chat(ctx, messages, tools, config)
 	if err != nil {
 		return nil, err
 	}
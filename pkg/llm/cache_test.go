@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -205,13 +206,13 @@ func TestCachedLLMClient(t *testing.T) {
 	config := &MockModelConfig{}
 
 	// 第一次调用，应该缓存未命中
-	response1, err := cachedClient.Chat(messages, tools, config)
+	response1, err := cachedClient.Chat(context.Background(), messages, tools, config)
 	if err != nil {
 		t.Errorf("Expected no error, got: %v", err)
 	}
 
 	// 第二次调用，应该缓存命中
-	response2, err := cachedClient.Chat(messages, tools, config)
+	response2, err := cachedClient.Chat(context.Background(), messages, tools, config)
 	if err != nil {
 		t.Errorf("Expected no error, got: %v", err)
 	}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/sashabaranov/go-openai"
 )
@@ -41,7 +42,7 @@ func NewDoubaoClient(apiKey, baseURL, apiVersion string) *DoubaoClient {
 }
 
 // Chat 实现豆包聊天接口
-func (dc *DoubaoClient) Chat(messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
+func (dc *DoubaoClient) Chat(ctx context.Context, messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
 	// 转换消息格式
 	openAIMessages := dc.convertMessages(messages)
 
@@ -60,13 +61,19 @@ func (dc *DoubaoClient) Chat(messages []LLMMessage, tools []Tool, config ModelCo
 	}
 
 	// 调用豆包API
-	resp, err := dc.client.CreateChatCompletion(context.Background(), req)
+	interaction := NewLLMInteraction(dc.GetProvider(), messages, tools, config)
+	resp, err := dc.client.CreateChatCompletion(ctx, req)
+	interaction.Latency = time.Since(interaction.Timestamp)
 	if err != nil {
+		interaction.Error = err.Error()
+		dc.recordInteraction(ctx, interaction)
 		return nil, fmt.Errorf("doubao API call failed: %s", err.Error())
 	}
 
 	// 检查是否有选择
 	if len(resp.Choices) == 0 {
+		interaction.Error = "no choices in doubao response"
+		dc.recordInteraction(ctx, interaction)
 		return nil, fmt.Errorf("no choices in doubao response")
 	}
 
@@ -76,6 +83,11 @@ func (dc *DoubaoClient) Chat(messages []LLMMessage, tools []Tool, config ModelCo
 	response := &LLMMessage{
 		Role:    choice.Message.Role,
 		Content: choice.Message.Content,
+		Usage: &Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
 	}
 
 	// 如果有工具调用，转换工具调用
@@ -90,8 +102,8 @@ func (dc *DoubaoClient) Chat(messages []LLMMessage, tools []Tool, config ModelCo
 				// 尝试解析JSON字符串为map
 				if err := json.Unmarshal([]byte(cleanArgs), &arguments); err != nil {
 					// 如果解析失败，尝试手动解析关键参数
-					fmt.Printf("Warning: failed to parse tool call arguments: %v, attempting manual parsing\n", err)
-					arguments = dc.manualParseArguments(cleanArgs)
+					Warnf(ctx, "failed to parse doubao tool call arguments: %v, attempting manual parsing", err)
+					arguments = dc.manualParseArguments(ctx, cleanArgs)
 				}
 			} else {
 				arguments = make(map[string]interface{})
@@ -108,6 +120,11 @@ func (dc *DoubaoClient) Chat(messages []LLMMessage, tools []Tool, config ModelCo
 		}
 	}
 
+	interaction.Response = response
+	interaction.Usage = response.Usage
+	interaction.FinishReason = string(choice.FinishReason)
+	dc.recordInteraction(ctx, interaction)
+
 	return response, nil
 }
 
@@ -137,7 +154,7 @@ func (dc *DoubaoClient) convertMessages(messages []LLMMessage) []openai.ChatComp
 }
 
 // manualParseArguments 手动解析工具调用参数
-func (dc *DoubaoClient) manualParseArguments(argsStr string) map[string]interface{} {
+func (dc *DoubaoClient) manualParseArguments(ctx context.Context, argsStr string) map[string]interface{} {
 	arguments := make(map[string]interface{})
 
 	// 尝试提取常见的参数模式
@@ -191,7 +208,7 @@ func (dc *DoubaoClient) manualParseArguments(argsStr string) map[string]interfac
 
 	// 如果没有找到任何参数，返回空map
 	if len(arguments) == 0 {
-		fmt.Printf("Warning: manual parsing failed, no valid arguments found in: %s\n", argsStr)
+		Warnf(ctx, "manual parsing failed, no valid arguments found in: %s", argsStr)
 	}
 
 	return arguments
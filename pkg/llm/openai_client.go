@@ -38,7 +38,7 @@ func NewOpenAIClient(apiKey, baseURL, apiVersion string) *OpenAIClient {
 }
 
 // Chat 实现OpenAI聊天接口
-func (oac *OpenAIClient) Chat(messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
+func (oac *OpenAIClient) Chat(ctx context.Context, messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
 	// 转换消息格式
 	openaiMessages := oac.convertMessages(messages)
 
@@ -57,16 +57,22 @@ func (oac *OpenAIClient) Chat(messages []LLMMessage, tools []Tool, config ModelC
 	}
 
 	// 发送请求
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
 	defer cancel()
 
+	interaction := NewLLMInteraction(oac.GetProvider(), messages, tools, config)
 	resp, err := oac.client.CreateChatCompletion(ctx, req)
+	interaction.Latency = time.Since(interaction.Timestamp)
 	if err != nil {
+		interaction.Error = err.Error()
+		oac.recordInteraction(ctx, interaction)
 		return nil, fmt.Errorf("failed to create chat completion: %w", err)
 	}
 
 	// 检查是否有选择
 	if len(resp.Choices) == 0 {
+		interaction.Error = "no choices in openai response"
+		oac.recordInteraction(ctx, interaction)
 		return nil, fmt.Errorf("no choices in openai response")
 	}
 
@@ -76,6 +82,11 @@ func (oac *OpenAIClient) Chat(messages []LLMMessage, tools []Tool, config ModelC
 	response := &LLMMessage{
 		Role:    choice.Message.Role,
 		Content: choice.Message.Content,
+		Usage: &Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
 	}
 
 	// 如果有工具调用，转换工具调用
@@ -93,6 +104,11 @@ func (oac *OpenAIClient) Chat(messages []LLMMessage, tools []Tool, config ModelC
 		}
 	}
 
+	interaction.Response = response
+	interaction.Usage = response.Usage
+	interaction.FinishReason = string(choice.FinishReason)
+	oac.recordInteraction(ctx, interaction)
+
 	return response, nil
 }
 
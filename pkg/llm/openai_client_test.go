@@ -1,6 +1,12 @@
 package llm
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	openai "github.com/sashabaranov/go-openai"
@@ -295,3 +301,38 @@ func TestOpenAIClient_EdgeCases(t *testing.T) {
 		t.Errorf("Expected 0 converted tools for empty input, got %d", len(convertedTools))
 	}
 }
+
+// failingRecorder 写入LLM调用总是失败的轨迹记录器
+type failingRecorder struct {
+	TrajectoryRecorder
+}
+
+func (r *failingRecorder) RecordLLMInteraction(interaction LLMInteraction) error {
+	return errors.New("disk full")
+}
+
+func TestOpenAIClient_RecordFailureDoesNotFailChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices": [{"index": 0, "message": {"role": "assistant", "content": "hello"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4}}`)
+	}))
+	defer server.Close()
+
+	var warnings []string
+	ctx := WithWarningHandler(context.Background(), func(message string) { warnings = append(warnings, message) })
+
+	// 轨迹写入失败只输出警告，仍然返回模型的响应
+	client := NewOpenAIClient("test_key", server.URL, "")
+	client.SetTrajectoryRecorder(&failingRecorder{})
+	response, err := client.Chat(ctx, []LLMMessage{{Role: "user", Content: "hi"}}, nil, &MockModelConfig{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.Content != "hello" || response.Usage.TotalTokens != 4 {
+		t.Errorf("Expected model response, got %+v", response)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "disk full") {
+		t.Errorf("Expected a warning about the recording failure, got %v", warnings)
+	}
+}
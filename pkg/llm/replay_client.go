@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"trage-agent-go/pkg/textdiff"
+)
+
+var (
+	// ErrReplayExhausted 录制的LLM调用已全部回放
+	ErrReplayExhausted = errors.New("replay exhausted")
+	// ErrReplayDiverged 严格模式下请求与录制不一致
+	ErrReplayDiverged = errors.New("replay diverged from recorded request")
+)
+
+// ReplayDivergence 回放时实际请求与录制请求的差异
+type ReplayDivergence struct {
+	Index int    `json:"index"`
+	Diff  string `json:"diff"`
+}
+
+// ReplayClient 按顺序返回轨迹中录制的响应的LLM客户端，不访问网络
+type ReplayClient struct {
+	*BaseLLMClient
+	interactions []LLMInteraction
+	next         int
+	strict       bool
+	divergences  []ReplayDivergence
+	mutex        sync.Mutex
+}
+
+// NewReplayClient 根据录制的LLM调用创建回放客户端
+func NewReplayClient(interactions []LLMInteraction) *ReplayClient {
+	return &ReplayClient{
+		BaseLLMClient: NewBaseLLMClient("", "", "", "replay"),
+		interactions:  append([]LLMInteraction(nil), interactions...),
+	}
+}
+
+// SetStrict 设置严格模式，开启后请求与录制不一致时直接返回错误
+func (rc *ReplayClient) SetStrict(strict bool) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.strict = strict
+}
+
+// Chat 返回下一条录制的响应
+func (rc *ReplayClient) Chat(ctx context.Context, messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if rc.next >= len(rc.interactions) {
+		return nil, fmt.Errorf("%w: trajectory has only %d recorded LLM calls", ErrReplayExhausted, len(rc.interactions))
+	}
+	index := rc.next
+	recorded := rc.interactions[index]
+	rc.next++
+
+	interaction := NewLLMInteraction(rc.GetProvider(), messages, tools, config)
+
+	// 比较请求内容，记录差异
+	if diff := DiffReplayRequest(recorded, *interaction); diff != "" {
+		rc.divergences = append(rc.divergences, ReplayDivergence{Index: index, Diff: diff})
+		if rc.strict {
+			interaction.Error = ErrReplayDiverged.Error()
+			rc.recordInteraction(ctx, interaction)
+			return nil, fmt.Errorf("%w at LLM call %d:\n%s", ErrReplayDiverged, index+1, diff)
+		}
+	}
+
+	// 录制时失败的调用按原样重现
+	if recorded.Error != "" || recorded.Response == nil {
+		message := recorded.Error
+		if message == "" {
+			message = "recorded LLM call has no response"
+		}
+		interaction.Error = message
+		rc.recordInteraction(ctx, interaction)
+		return nil, fmt.Errorf("replayed LLM call failed: %s", message)
+	}
+
+	response := cloneMessage(*recorded.Response)
+	interaction.Response = &response
+	interaction.Usage = recorded.Usage
+	interaction.FinishReason = recorded.FinishReason
+	interaction.Latency = time.Since(interaction.Timestamp)
+	rc.recordInteraction(ctx, interaction)
+
+	return &response, nil
+}
+
+// SupportsToolCalling 回放客户端支持工具调用
+func (rc *ReplayClient) SupportsToolCalling() bool {
+	return true
+}
+
+// GetDivergences 获取回放过程中发现的差异
+func (rc *ReplayClient) GetDivergences() []ReplayDivergence {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	return append([]ReplayDivergence(nil), rc.divergences...)
+}
+
+// Remaining 获取尚未回放的录制调用数
+func (rc *ReplayClient) Remaining() int {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	return len(rc.interactions) - rc.next
+}
+
+// cloneMessage 深拷贝消息，避免调用方修改录制数据
+func cloneMessage(message LLMMessage) LLMMessage {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return message
+	}
+	var clone LLMMessage
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return message
+	}
+	return clone
+}
+
+// DiffReplayRequest 比较录制请求与实际请求，一致时返回空字符串
+func DiffReplayRequest(recorded, actual LLMInteraction) string {
+	return textdiff.Unified(renderRequest(recorded), renderRequest(actual), "recorded", "replayed", 2)
+}
+
+// renderRequest 将请求展开为便于逐行比较的文本
+func renderRequest(interaction LLMInteraction) []string {
+	lines := make([]string, 0)
+
+	// 工具定义来自map，顺序不固定，排序后比较
+	tools := append([]string(nil), interaction.Tools...)
+	sort.Strings(tools)
+	lines = append(lines, "tools: "+strings.Join(tools, ", "))
+
+	for i, message := range interaction.InputMessages {
+		header := fmt.Sprintf("[%d] %s", i, message.Role)
+		if message.ToolCallID != "" {
+			header += " (" + message.ToolCallID + ")"
+		}
+		lines = append(lines, header)
+		for _, line := range strings.Split(message.Content, "\n") {
+			lines = append(lines, "    "+line)
+		}
+		for _, toolCall := range message.ToolCalls {
+			args, _ := json.Marshal(toolCall.Function.Arguments)
+			lines = append(lines, fmt.Sprintf("    -> %s %s (%s)", toolCall.Function.Name, args, toolCall.ID))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newRecordedInteraction 构造一条录制的LLM调用
+func newRecordedInteraction(input []LLMMessage, response *LLMMessage) LLMInteraction {
+	return LLMInteraction{
+		Provider:      "openai",
+		InputMessages: input,
+		Tools:         []string{"bash", "edit_file"},
+		Response:      response,
+		Usage:         &Usage{TotalTokens: 10},
+	}
+}
+
+func TestReplayClient_ServesInOrder(t *testing.T) {
+	first := []LLMMessage{{Role: "system", Content: "sys"}, {Role: "user", Content: "list files"}}
+	toolCall := ToolCall{ID: "call_1", Type: "function", Function: ToolCallFunction{Name: "bash", Arguments: map[string]interface{}{"command": "ls"}}}
+	second := append(append([]LLMMessage(nil), first...),
+		LLMMessage{Role: "assistant", ToolCalls: []ToolCall{toolCall}},
+		LLMMessage{Role: "tool", Content: "a.txt", ToolCallID: "call_1"},
+	)
+
+	client := NewReplayClient([]LLMInteraction{
+		newRecordedInteraction(first, &LLMMessage{Role: "assistant", ToolCalls: []ToolCall{toolCall}}),
+		newRecordedInteraction(second, &LLMMessage{Role: "assistant", Content: "done"}),
+	})
+	// 工具顺序不同不算差异
+	tools := []Tool{{Function: ToolFunction{Name: "edit_file"}}, {Function: ToolFunction{Name: "bash"}}}
+
+	response, err := client.Chat(context.Background(), first, tools, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(response.ToolCalls) != 1 || response.ToolCalls[0].Function.Name != "bash" {
+		t.Errorf("Expected recorded tool call, got %+v", response)
+	}
+
+	response, err = client.Chat(context.Background(), second, tools, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.Content != "done" {
+		t.Errorf("Expected 'done', got %q", response.Content)
+	}
+	if divergences := client.GetDivergences(); len(divergences) != 0 {
+		t.Errorf("Expected no divergences, got %v", divergences)
+	}
+
+	if _, err := client.Chat(context.Background(), second, tools, nil); !errors.Is(err, ErrReplayExhausted) {
+		t.Errorf("Expected ErrReplayExhausted, got %v", err)
+	}
+}
+
+func TestReplayClient_Divergence(t *testing.T) {
+	recorded := []LLMMessage{{Role: "system", Content: "sys"}, {Role: "user", Content: "list files"}}
+	actual := []LLMMessage{{Role: "system", Content: "sys"}, {Role: "user", Content: "list all files"}}
+	interactions := []LLMInteraction{newRecordedInteraction(recorded, &LLMMessage{Role: "assistant", Content: "ok"})}
+
+	// 默认模式下报告差异但继续回放
+	client := NewReplayClient(interactions)
+	if _, err := client.Chat(context.Background(), actual, []Tool{{Function: ToolFunction{Name: "bash"}}, {Function: ToolFunction{Name: "edit_file"}}}, nil); err != nil {
+		t.Fatalf("Expected lenient replay to continue, got %v", err)
+	}
+	divergences := client.GetDivergences()
+	if len(divergences) != 1 {
+		t.Fatalf("Expected 1 divergence, got %d", len(divergences))
+	}
+	diff := divergences[0].Diff
+	if !strings.Contains(diff, "-    list files") || !strings.Contains(diff, "+    list all files") {
+		t.Errorf("Expected diff to show changed line, got:\n%s", diff)
+	}
+
+	// 严格模式下直接返回错误
+	strict := NewReplayClient(interactions)
+	strict.SetStrict(true)
+	if _, err := strict.Chat(context.Background(), actual, nil, nil); !errors.Is(err, ErrReplayDiverged) {
+		t.Errorf("Expected ErrReplayDiverged, got %v", err)
+	}
+}
+
+func TestReplayClient_RecordedError(t *testing.T) {
+	interaction := newRecordedInteraction(nil, nil)
+	interaction.Error = "rate limit"
+	client := NewReplayClient([]LLMInteraction{interaction})
+
+	if _, err := client.Chat(context.Background(), nil, []Tool{{Function: ToolFunction{Name: "bash"}}, {Function: ToolFunction{Name: "edit_file"}}}, nil); err == nil || !strings.Contains(err.Error(), "rate limit") {
+		t.Errorf("Expected recorded error to be reproduced, got %v", err)
+	}
+}
@@ -25,33 +25,32 @@ func NewRetryableLLMClient(client LLMClient, retryConfig *RetryConfig) *Retryabl
 	}
 }
 
-// Chat 实现LLMClient接口，带重试机制
-func (rlc *RetryableLLMClient) Chat(messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
+// Chat 实现LLMClient接口，带重试机制，ctx被取消时不再重试
+func (rlc *RetryableLLMClient) Chat(ctx context.Context, messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
 	var lastErr error
 	var response *LLMMessage
 
 	for attempt := 0; attempt <= rlc.retryConfig.MaxRetries; attempt++ {
-		// 创建带超时的上下文
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-
 		// 尝试调用
-		response, lastErr = rlc.client.Chat(messages, tools, config)
+		response, lastErr = rlc.client.Chat(ctx, messages, tools, config)
 
 		// 如果没有错误，直接返回
 		if lastErr == nil {
-			cancel()
 			return response, nil
 		}
 
+		// 调用被取消时直接返回
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("context cancelled: %w", lastErr)
+		}
+
 		// 检查是否是可重试的错误
 		if !IsRetryableError(lastErr) {
-			cancel()
 			return nil, fmt.Errorf("non-retryable error: %w", lastErr)
 		}
 
 		// 如果是最后一次尝试，返回错误
 		if attempt == rlc.retryConfig.MaxRetries {
-			cancel()
 			return nil, fmt.Errorf("max retries exceeded, last error: %w", lastErr)
 		}
 
@@ -60,17 +59,15 @@ func (rlc *RetryableLLMClient) Chat(messages []LLMMessage, tools []Tool, config
 
 		// 记录重试信息
 		if rlc.client.GetProvider() != "" {
-			fmt.Printf("Retrying %s API call in %v (attempt %d/%d): %v\n",
+			Warnf(ctx, "retrying %s API call in %v (attempt %d/%d): %v",
 				rlc.client.GetProvider(), delay, attempt+1, rlc.retryConfig.MaxRetries+1, lastErr)
 		}
 
 		// 等待延迟时间
 		select {
 		case <-ctx.Done():
-			cancel()
 			return nil, fmt.Errorf("context cancelled during retry: %w", ctx.Err())
 		case <-time.After(delay):
-			cancel()
 		}
 	}
 
@@ -1,7 +1,9 @@
 package llm
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -25,7 +27,7 @@ func NewMockLLMClient(failCount int) *MockLLMClient {
 }
 
 // Chat 实现LLMClient接口
-func (m *MockLLMClient) Chat(messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
+func (m *MockLLMClient) Chat(ctx context.Context, messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
 	m.lastAttempts++
 
 	if m.shouldFail && m.lastAttempts <= m.failCount {
@@ -69,14 +71,22 @@ func TestRetryableLLMClient_SuccessAfterRetries(t *testing.T) {
 
 	retryableClient := NewRetryableLLMClient(mockClient, retryConfig)
 
+	// 重试信息作为警告输出，不写到标准输出
+	var warnings []string
+	ctx := WithWarningHandler(context.Background(), func(message string) { warnings = append(warnings, message) })
+
 	messages := []LLMMessage{{Role: "user", Content: "test"}}
 	tools := []Tool{}
 	config := &MockModelConfig{}
 
 	start := time.Now()
-	response, err := retryableClient.Chat(messages, tools, config)
+	response, err := retryableClient.Chat(ctx, messages, tools, config)
 	duration := time.Since(start)
 
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "retrying mock API call") {
+		t.Errorf("Expected 2 retry warnings, got %v", warnings)
+	}
+
 	if err != nil {
 		t.Errorf("Expected no error, got: %v", err)
 	}
@@ -116,7 +126,7 @@ func TestRetryableLLMClient_MaxRetriesExceeded(t *testing.T) {
 	tools := []Tool{}
 	config := &MockModelConfig{}
 
-	response, err := retryableClient.Chat(messages, tools, config)
+	response, err := retryableClient.Chat(context.Background(), messages, tools, config)
 
 	if err == nil {
 		t.Fatal("Expected error, got nil")
@@ -158,7 +168,7 @@ func TestRetryableLLMClient_NonRetryableError(t *testing.T) {
 	tools := []Tool{}
 	config := &MockModelConfig{}
 
-	response, err := retryableClient.Chat(messages, tools, config)
+	response, err := retryableClient.Chat(context.Background(), messages, tools, config)
 
 	if err == nil {
 		t.Fatal("Expected error, got nil")
@@ -180,7 +190,7 @@ type SpecialMockLLMClient struct {
 	attempts int
 }
 
-func (sm *SpecialMockLLMClient) Chat(messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
+func (sm *SpecialMockLLMClient) Chat(ctx context.Context, messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
 	sm.attempts++
 	// 返回一个特殊的错误，这个错误在types.go中被标记为不可重试
 	return nil, &Error{
@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -13,6 +14,7 @@ type LLMMessage struct {
 	Name       string                 `json:"name,omitempty"`
 	ToolCalls  []ToolCall             `json:"tool_calls,omitempty"`
 	ToolCallID string                 `json:"tool_call_id,omitempty"`
+	Usage      *Usage                 `json:"usage,omitempty"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
 }
 
@@ -57,6 +59,73 @@ type Usage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+// LLMInteraction 一次LLM调用的完整记录
+type LLMInteraction struct {
+	Timestamp     time.Time              `json:"timestamp"`
+	Provider      string                 `json:"provider"`
+	Model         string                 `json:"model"`
+	Params        map[string]interface{} `json:"params,omitempty"`
+	InputMessages []LLMMessage           `json:"input_messages"`
+	Tools         []string               `json:"tools,omitempty"`
+	Response      *LLMMessage            `json:"response,omitempty"`
+	Usage         *Usage                 `json:"usage,omitempty"`
+	FinishReason  string                 `json:"finish_reason,omitempty"`
+	Latency       time.Duration          `json:"latency"`
+	Error         string                 `json:"error,omitempty"`
+}
+
+// ToolExecutionResult 工具执行结果（用于轨迹记录）
+type ToolExecutionResult struct {
+	CallID   string        `json:"call_id"`
+	Name     string        `json:"name"`
+	Success  bool          `json:"success"`
+	Result   string        `json:"result,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// StepRecord 代理执行步骤记录
+type StepRecord struct {
+	StepNumber int       `json:"step_number"`
+	Action     string    `json:"action"`
+	Input      string    `json:"input,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	ToolCallID string    `json:"tool_call_id,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// LakeviewRecord Lakeview对单个步骤的标签和简短总结
+type LakeviewRecord struct {
+	StepNumber int       `json:"step_number"`
+	Tags       []string  `json:"tags,omitempty"`
+	Summary    string    `json:"summary"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// NewLLMInteraction 根据请求参数创建LLM调用记录
+func NewLLMInteraction(provider string, messages []LLMMessage, tools []Tool, config ModelConfig) *LLMInteraction {
+	interaction := &LLMInteraction{
+		Timestamp:     time.Now(),
+		Provider:      provider,
+		InputMessages: append([]LLMMessage(nil), messages...),
+	}
+	if config != nil {
+		interaction.Model = config.GetModel()
+		interaction.Params = map[string]interface{}{
+			"max_tokens":            config.GetMaxTokens(),
+			"temperature":           config.GetTemperature(),
+			"top_p":                 config.GetTopP(),
+			"top_k":                 config.GetTopK(),
+			"parallel_tool_calls":   config.GetParallelToolCalls(),
+			"supports_tool_calling": config.GetSupportsToolCalling(),
+		}
+	}
+	for _, tool := range tools {
+		interaction.Tools = append(interaction.Tools, tool.Function.Name)
+	}
+	return interaction
+}
+
 // ToolParameter 工具参数结构
 type ToolParameter struct {
 	Name        string      `json:"name"`
@@ -82,8 +151,8 @@ type ToolFunction struct {
 
 // LLMClient LLM客户端接口
 type LLMClient interface {
-	// Chat 发送聊天消息
-	Chat(messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error)
+	// Chat 发送聊天消息，ctx被取消时请求会被中止
+	Chat(ctx context.Context, messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error)
 
 	// SetTrajectoryRecorder 设置轨迹记录器
 	SetTrajectoryRecorder(recorder TrajectoryRecorder)
@@ -119,7 +188,25 @@ type TrajectoryRecorder interface {
 	RecordToolCall(toolCall ToolCall) error
 
 	// RecordToolResult 记录工具结果
-	RecordToolResult(toolCall ToolCall, result interface{}) error
+	RecordToolResult(toolCall ToolCall, result ToolExecutionResult) error
+
+	// RecordStep 记录代理执行步骤
+	RecordStep(step StepRecord) error
+
+	// RecordError 记录执行过程中的错误
+	RecordError(source string, message string) error
+
+	// RecordLakeview 记录Lakeview的步骤总结
+	RecordLakeview(record LakeviewRecord) error
+
+	// RecordLLMInteraction 记录一次LLM调用（请求、响应、用量和耗时）
+	RecordLLMInteraction(interaction LLMInteraction) error
+
+	// RecordExecution 记录代理的最终执行结果
+	RecordExecution(execution interface{}) error
+
+	// AddMetadata 添加元数据
+	AddMetadata(key string, value interface{})
 
 	// Save 保存轨迹
 	Save() error
@@ -138,7 +225,7 @@ type BaseLLMClient struct {
 }
 
 // Chat 实现LLMClient接口
-func (b *BaseLLMClient) Chat(messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
+func (b *BaseLLMClient) Chat(ctx context.Context, messages []LLMMessage, tools []Tool, config ModelConfig) (*LLMMessage, error) {
 	// 简单的测试实现，返回一个工具调用
 	// 在实际应用中，这里应该调用真正的LLM API
 
@@ -250,13 +337,28 @@ func (b *BaseLLMClient) RecordToolCall(toolCall ToolCall) error {
 }
 
 // RecordToolResult 记录工具结果
-func (b *BaseLLMClient) RecordToolResult(toolCall ToolCall, result interface{}) error {
+func (b *BaseLLMClient) RecordToolResult(toolCall ToolCall, result ToolExecutionResult) error {
 	if b.Recorder != nil {
 		return b.Recorder.RecordToolResult(toolCall, result)
 	}
 	return nil
 }
 
+// RecordLLMInteraction 记录LLM调用
+func (b *BaseLLMClient) RecordLLMInteraction(interaction *LLMInteraction) error {
+	if b.Recorder != nil && interaction != nil {
+		return b.Recorder.RecordLLMInteraction(*interaction)
+	}
+	return nil
+}
+
+// recordInteraction 记录LLM调用，轨迹写入失败只输出警告，不影响模型调用的结果
+func (b *BaseLLMClient) recordInteraction(ctx context.Context, interaction *LLMInteraction) {
+	if err := b.RecordLLMInteraction(interaction); err != nil {
+		Warnf(ctx, "failed to record %s llm interaction: %v", b.Provider, err)
+	}
+}
+
 // RetryConfig 重试配置
 type RetryConfig struct {
 	MaxRetries  int
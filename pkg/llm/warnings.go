@@ -0,0 +1,28 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// warningHandlerKey ctx中警告处理函数的键
+type warningHandlerKey struct{}
+
+// WithWarningHandler 返回携带警告处理函数的ctx。使用该ctx的模型调用和工具中不影响结果的警告
+// （如重试、轨迹写入失败、工具参数解析失败）交给handler，而不是写入标准错误的日志，
+// 使交互式控制台和服务器能把警告输出到各自的界面和任务事件中
+func WithWarningHandler(ctx context.Context, handler func(message string)) context.Context {
+	return context.WithValue(ctx, warningHandlerKey{}, handler)
+}
+
+// Warnf 输出不影响结果的警告：ctx中设置了处理函数时交给它，否则写入标准错误的日志。
+// 工具等使用同一ctx的组件也通过它输出警告
+func Warnf(ctx context.Context, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if handler, ok := ctx.Value(warningHandlerKey{}).(func(message string)); ok && handler != nil {
+		handler(message)
+		return
+	}
+	log.Printf("WARN: %s", message)
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/tools"
+)
+
+// maxEventText 事件中单段文本的最大字符数，完整内容在轨迹中
+const maxEventText = 4000
+
+// taskConsole 将代理的执行过程转换为任务事件
+type taskConsole struct {
+	task    *Task
+	metrics *serverMetrics
+}
+
+func (c *taskConsole) Print(message string) {
+	c.task.publish(EventMessage, map[string]interface{}{"text": message})
+}
+
+func (c *taskConsole) PrintTaskDetails(details map[string]string) {}
+
+func (c *taskConsole) Start() error { return nil }
+
+func (c *taskConsole) SetLakeview(config *config.LakeviewConfig) {}
+
+func (c *taskConsole) OnLLMStart(step int) {
+	c.task.update(func(info *TaskInfo) { info.Steps = step })
+	c.metrics.llmCalls.Increment()
+	c.task.publish(EventLLMStart, map[string]interface{}{"step": step})
+}
+
+func (c *taskConsole) OnLLMEnd(step int, response *llm.LLMMessage, err error) {
+	data := map[string]interface{}{"step": step}
+	if err != nil {
+		data["error"] = err.Error()
+		c.task.publish(EventLLMEnd, data)
+		return
+	}
+
+	data["content"] = truncate(response.Content)
+	toolNames := make([]string, 0, len(response.ToolCalls))
+	for _, toolCall := range response.ToolCalls {
+		toolNames = append(toolNames, toolCall.Function.Name)
+	}
+	data["tool_calls"] = toolNames
+	if response.Usage != nil {
+		data["usage"] = response.Usage
+		c.metrics.tokens.Add(int64(response.Usage.TotalTokens))
+		c.task.update(func(info *TaskInfo) {
+			info.Usage.PromptTokens += response.Usage.PromptTokens
+			info.Usage.CompletionTokens += response.Usage.CompletionTokens
+			info.Usage.TotalTokens += response.Usage.TotalTokens
+		})
+	}
+	c.task.publish(EventLLMEnd, data)
+}
+
+func (c *taskConsole) OnToolStart(step int, toolCall llm.ToolCall) {
+	c.metrics.toolCalls.Increment()
+	c.task.publish(EventToolStart, map[string]interface{}{
+		"step":      step,
+		"call_id":   toolCall.ID,
+		"name":      toolCall.Function.Name,
+		"arguments": toolCall.Function.Arguments,
+	})
+}
+
+func (c *taskConsole) OnToolResult(step int, toolCall llm.ToolCall, result *tools.ToolResult) {
+	c.task.publish(EventToolResult, map[string]interface{}{
+		"step":    step,
+		"call_id": toolCall.ID,
+		"name":    toolCall.Function.Name,
+		"success": result.Success,
+		"result":  truncate(result.Result),
+		"error":   truncate(result.Error),
+	})
+}
+
+func (c *taskConsole) OnLakeview(step *agent.LakeviewStep) {
+	c.task.publish(EventLakeview, map[string]interface{}{
+		"step":    step.StepNumber,
+		"tags":    step.Tags,
+		"summary": step.Summary,
+	})
+}
+
+func (c *taskConsole) Finish(execution *agent.AgentExecution) {}
+
+// truncate 截断过长的事件文本
+func truncate(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxEventText {
+		return text
+	}
+	return string(runes[:maxEventText]) + "..."
+}
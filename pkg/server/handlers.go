@@ -0,0 +1,324 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"trage-agent-go/pkg/utils"
+)
+
+// maxRequestBody 提交任务请求体的最大字节数
+const maxRequestBody = 1 << 20
+
+// sseKeepAlive SSE连接空闲时发送注释行的间隔，防止被代理断开
+var sseKeepAlive = 15 * time.Second
+
+// Handler 返回服务器的HTTP处理器
+//
+//	POST   /api/tasks                  提交任务
+//	GET    /api/tasks                  列出任务
+//	GET    /api/tasks/{id}             查询任务状态
+//	DELETE /api/tasks/{id}             取消任务
+//	POST   /api/tasks/{id}/cancel      取消任务
+//	GET    /api/tasks/{id}/events      以SSE推送步骤事件
+//	GET    /api/tasks/{id}/trajectory  下载轨迹，可用?format=指定导出格式
+//	GET    /api/tasks/{id}/patch       下载工作目录的补丁
+//	GET    /healthz                    健康检查
+//	GET    /metrics                    Prometheus指标
+//
+// 设置了Token时，除/healthz外的接口都需要Bearer令牌
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/metrics", s.requireToken(s.handleMetrics))
+	mux.HandleFunc("/api/tasks", s.requireToken(s.handleTasks))
+	mux.HandleFunc("/api/tasks/", s.requireToken(s.handleTask))
+	return mux
+}
+
+// requireToken 检查请求的Bearer令牌，未设置Token时不检查
+func (s *Server) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	if s.options.Token == "" {
+		return next
+	}
+	expected := []byte("Bearer " + s.options.Token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="trage"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleHealth 健康检查
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":        "ok",
+		"tasks_running": s.metrics.running.GetValue(),
+		"tasks_queued":  s.metrics.queued.GetValue(),
+	})
+}
+
+// handleMetrics 导出Prometheus指标
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, s.metrics.collector.ExportPrometheus())
+}
+
+// handleTasks 提交或列出任务
+func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tasks := s.ListTasks()
+		infos := make([]TaskInfo, 0, len(tasks))
+		for _, task := range tasks {
+			infos = append(infos, task.Info())
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"tasks": infos})
+	case http.MethodPost:
+		var req TaskRequest
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+
+		task, err := s.Submit(req)
+		switch {
+		case errors.Is(err, ErrEmptyTask), errors.Is(err, ErrInvalidRequest):
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		case errors.Is(err, ErrServerClosed):
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		info := task.Info()
+		w.Header().Set("Location", "/api/tasks/"+info.ID)
+		writeJSON(w, http.StatusAccepted, info)
+	default:
+		allowMethods(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleTask 处理单个任务的请求
+func (s *Server) handleTask(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/tasks/"), "/"), "/")
+	if len(parts) > 2 || parts[0] == "" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	task, exists := s.GetTask(parts[0])
+	if !exists {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("task %s not found", parts[0]))
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch action {
+	case "":
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, task.Info())
+		case http.MethodDelete:
+			s.cancelTask(w, task)
+		default:
+			allowMethods(w, r, http.MethodGet, http.MethodDelete)
+		}
+	case "cancel":
+		if allowMethods(w, r, http.MethodPost) {
+			s.cancelTask(w, task)
+		}
+	case "events":
+		if allowMethods(w, r, http.MethodGet) {
+			s.streamEvents(w, r, task)
+		}
+	case "trajectory":
+		if allowMethods(w, r, http.MethodGet) {
+			s.serveTrajectory(w, r, task)
+		}
+	case "patch":
+		if allowMethods(w, r, http.MethodGet) {
+			s.servePatch(w, r, task)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// cancelTask 取消任务，已结束的任务返回409
+func (s *Server) cancelTask(w http.ResponseWriter, task *Task) {
+	if !task.Cancel() {
+		writeError(w, http.StatusConflict, fmt.Sprintf("task %s already finished", task.Info().ID))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, task.Info())
+}
+
+// streamEvents 以SSE推送任务事件，支持Last-Event-ID断点续传，任务结束后关闭连接
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, task *Task) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	after := 0
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		if id, err := strconv.Atoi(lastID); err == nil {
+			after = id
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		events, changed, done := task.eventsSince(after)
+		for _, event := range events {
+			if err := writeSSE(w, event); err != nil {
+				return
+			}
+			after = event.ID
+		}
+		flusher.Flush()
+		if done {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeSSE 写入一条SSE事件
+func writeSSE(w http.ResponseWriter, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// serveTrajectory 返回轨迹文件，指定format时先导出为对应格式
+func (s *Server) serveTrajectory(w http.ResponseWriter, r *http.Request, task *Task) {
+	path := task.Info().TrajectoryPath
+	if _, err := os.Stat(path); err != nil {
+		writeError(w, http.StatusNotFound, "trajectory is not available yet")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" || format == "jsonl" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		http.ServeFile(w, r, path)
+		return
+	}
+
+	recorder, err := utils.LoadTrajectory(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load trajectory: %v", err))
+		return
+	}
+
+	exportDir, err := os.MkdirTemp("", "trage-trajectory-")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer os.RemoveAll(exportDir)
+
+	exportPath := filepath.Join(exportDir, "trajectory."+format)
+	if err := recorder.ExportToFormat(format, exportPath); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to export trajectory: %v (supported: jsonl, %s)",
+			err, strings.Join(utils.ExportFormats(), ", ")))
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", task.Info().ID+"."+format))
+	http.ServeFile(w, r, exportPath)
+}
+
+// servePatch 返回工作目录相对基准提交的补丁
+func (s *Server) servePatch(w http.ResponseWriter, r *http.Request, task *Task) {
+	info := task.Info()
+	if _, err := os.Stat(info.Workspace); err != nil {
+		writeError(w, http.StatusNotFound, "workspace is not available yet")
+		return
+	}
+
+	patch, err := utils.GitPatch(r.Context(), info.Workspace, info.BaseCommit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to create patch: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(patch)
+}
+
+// allowMethods 检查请求方法，不允许时返回405
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+	return false
+}
+
+// writeJSON 写入JSON响应
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+// writeError 写入JSON格式的错误响应
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
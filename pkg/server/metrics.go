@@ -0,0 +1,48 @@
+package server
+
+import (
+	"trage-agent-go/pkg/utils"
+)
+
+// serverMetrics 服务器暴露在/metrics的指标
+type serverMetrics struct {
+	collector *utils.MetricsCollector
+
+	submitted *utils.Counter
+	succeeded *utils.Counter
+	failed    *utils.Counter
+	cancelled *utils.Counter
+	llmCalls  *utils.Counter
+	toolCalls *utils.Counter
+	tokens    *utils.Counter
+	queued    *utils.Gauge
+	running   *utils.Gauge
+	duration  *utils.Histogram
+}
+
+// newServerMetrics 创建并注册服务器指标
+func newServerMetrics() *serverMetrics {
+	m := &serverMetrics{
+		collector: utils.NewMetricsCollector(),
+		submitted: utils.NewCounter("trage_tasks_submitted_total", "Total number of submitted tasks"),
+		succeeded: utils.NewCounter("trage_tasks_succeeded_total", "Total number of succeeded tasks"),
+		failed:    utils.NewCounter("trage_tasks_failed_total", "Total number of failed tasks"),
+		cancelled: utils.NewCounter("trage_tasks_cancelled_total", "Total number of cancelled tasks"),
+		llmCalls:  utils.NewCounter("trage_llm_calls_total", "Total number of LLM calls made by tasks"),
+		toolCalls: utils.NewCounter("trage_tool_calls_total", "Total number of tool calls made by tasks"),
+		tokens:    utils.NewCounter("trage_tokens_total", "Total number of tokens used by tasks"),
+		queued:    utils.NewGauge("trage_tasks_queued", "Number of tasks waiting for a free slot"),
+		running:   utils.NewGauge("trage_tasks_running", "Number of tasks currently running"),
+		duration: utils.NewHistogram("trage_task_duration_seconds", "Task run duration in seconds",
+			[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1800}),
+	}
+
+	for _, metric := range []utils.Metric{
+		m.submitted, m.succeeded, m.failed, m.cancelled,
+		m.llmCalls, m.toolCalls, m.tokens,
+		m.queued, m.running, m.duration,
+	} {
+		m.collector.RegisterMetric(metric)
+	}
+	return m
+}
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/llm"
+)
+
+var (
+	// ErrEmptyTask 任务描述为空
+	ErrEmptyTask = errors.New("task must not be empty")
+	// ErrServerClosed 服务器已关闭，不再接受新任务
+	ErrServerClosed = errors.New("server is shutting down")
+	// ErrInvalidRequest 请求中的仓库或基准提交不合法
+	ErrInvalidRequest = errors.New("invalid task request")
+)
+
+// AgentBuilder 为每个任务创建独立的代理实例，代理需要把轨迹写到trajectoryFile
+type AgentBuilder func(trajectoryFile string) (agent.Agent, error)
+
+// Options 服务器选项
+type Options struct {
+	NewAgent      AgentBuilder
+	WorkspaceRoot string // 任务工作目录和轨迹的根目录
+	MaxConcurrent int    // 同时运行的任务数，超出的任务排队等待
+	// AllowLocalRepos 允许克隆file://和服务器本地路径的仓库，默认只允许远程仓库
+	AllowLocalRepos bool
+	// Token 不为空时，除/healthz外的请求都需要携带Authorization: Bearer <Token>
+	Token string
+	// Retention 已结束的任务保留的时长，超过后删除任务和它的目录；为0时不删除
+	Retention time.Duration
+}
+
+// Server 通过REST API提交和管理代理任务
+type Server struct {
+	options Options
+	metrics *serverMetrics
+	slots   chan struct{}
+	tasks   map[string]*Task
+	order   []string
+	closed  bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+	mutex   sync.Mutex
+}
+
+// NewServer 创建服务器
+func NewServer(options Options) (*Server, error) {
+	if options.NewAgent == nil {
+		return nil, fmt.Errorf("agent builder is required")
+	}
+	if options.WorkspaceRoot == "" {
+		options.WorkspaceRoot = "workspaces"
+	}
+	if options.MaxConcurrent <= 0 {
+		options.MaxConcurrent = 4
+	}
+
+	root, err := filepath.Abs(options.WorkspaceRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace root: %w", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create workspace root: %w", err)
+	}
+	options.WorkspaceRoot = root
+
+	s := &Server{
+		options: options,
+		metrics: newServerMetrics(),
+		slots:   make(chan struct{}, options.MaxConcurrent),
+		tasks:   make(map[string]*Task),
+		order:   make([]string, 0),
+		done:    make(chan struct{}),
+	}
+	if options.Retention > 0 {
+		go s.pruneLoop()
+	}
+	return s, nil
+}
+
+// Submit 提交任务，任务在后台排队执行
+func (s *Server) Submit(req TaskRequest) (*Task, error) {
+	if strings.TrimSpace(req.Task) == "" {
+		return nil, ErrEmptyTask
+	}
+	if err := validateTaskRequest(req, s.options.AllowLocalRepos); err != nil {
+		return nil, err
+	}
+
+	id, err := newTaskID()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(s.options.WorkspaceRoot, id)
+	task := newTask(id, req, dir, filepath.Join(dir, "workspace"), filepath.Join(dir, "trajectory.jsonl"))
+
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil, ErrServerClosed
+	}
+	s.tasks[id] = task
+	s.order = append(s.order, id)
+	s.wg.Add(1)
+	s.mutex.Unlock()
+
+	s.metrics.submitted.Increment()
+	s.metrics.queued.Add(1)
+	task.publish(EventStatus, map[string]interface{}{"status": StatusQueued})
+
+	go s.run(task, req)
+	return task, nil
+}
+
+// GetTask 按ID获取任务
+func (s *Server) GetTask(id string) (*Task, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	task, exists := s.tasks[id]
+	return task, exists
+}
+
+// ListTasks 按提交顺序列出任务
+func (s *Server) ListTasks() []*Task {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tasks := make([]*Task, 0, len(s.order))
+	for _, id := range s.order {
+		tasks = append(tasks, s.tasks[id])
+	}
+	return tasks
+}
+
+// Prune 删除在before之前结束的任务和它们的目录，返回删除的任务数
+func (s *Server) Prune(before time.Time) int {
+	s.mutex.Lock()
+	expired := make([]*Task, 0)
+	order := make([]string, 0, len(s.order))
+	for _, id := range s.order {
+		task := s.tasks[id]
+		if info := task.Info(); info.FinishedAt != nil && info.FinishedAt.Before(before) {
+			expired = append(expired, task)
+			delete(s.tasks, id)
+			continue
+		}
+		order = append(order, id)
+	}
+	s.order = order
+	s.mutex.Unlock()
+
+	for _, task := range expired {
+		os.RemoveAll(task.dir)
+	}
+	return len(expired)
+}
+
+// pruneLoop 定期删除超过保留时长的任务，直到服务器关闭
+func (s *Server) pruneLoop() {
+	interval := s.options.Retention / 10
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Prune(time.Now().Add(-s.options.Retention))
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Shutdown 停止接受新任务，取消所有未结束的任务并等待它们退出
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	if !s.closed {
+		close(s.done)
+	}
+	s.closed = true
+	for _, task := range s.tasks {
+		task.Cancel()
+	}
+	s.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run 等待空闲槽位后执行任务
+func (s *Server) run(task *Task, req TaskRequest) {
+	defer s.wg.Done()
+	defer task.cancel()
+
+	select {
+	case s.slots <- struct{}{}:
+	case <-task.ctx.Done():
+		s.metrics.queued.Subtract(1)
+		s.finish(task, nil, task.ctx.Err())
+		return
+	}
+	defer func() { <-s.slots }()
+
+	s.metrics.queued.Subtract(1)
+	s.metrics.running.Add(1)
+	task.setStatus(StatusRunning)
+
+	start := time.Now()
+	execution, err := s.execute(task, req)
+	s.metrics.duration.Observe(time.Since(start).Seconds())
+	s.metrics.running.Subtract(1)
+
+	s.finish(task, execution, err)
+}
+
+// execute 准备工作目录，创建独立的代理并运行任务
+func (s *Server) execute(task *Task, req TaskRequest) (*agent.AgentExecution, error) {
+	info := task.Info()
+	if err := os.MkdirAll(task.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create task directory: %w", err)
+	}
+	if err := prepareWorkspace(task.ctx, info.Workspace, req); err != nil {
+		return nil, fmt.Errorf("failed to prepare workspace: %w", err)
+	}
+
+	agentInstance, err := s.options.NewAgent(info.TrajectoryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create agent: %w", err)
+	}
+	agentInstance.SetWorkingDir(info.Workspace)
+	agentInstance.SetCLIConsole(&taskConsole{task: task, metrics: s.metrics})
+
+	extraArgs := map[string]string{
+		"project_path": info.Workspace,
+		"working_dir":  info.Workspace,
+	}
+	if req.BaseCommit != "" {
+		extraArgs["base_commit"] = req.BaseCommit
+	}
+	// 模型调用的警告作为任务事件发布，不写入服务器的日志
+	ctx := llm.WithWarningHandler(task.ctx, func(message string) {
+		task.publish(EventWarning, map[string]interface{}{"text": message})
+	})
+	return agentInstance.Run(ctx, req.Task, extraArgs, nil)
+}
+
+// finish 记录任务结果并发布结束事件
+func (s *Server) finish(task *Task, execution *agent.AgentExecution, err error) {
+	var status TaskStatus
+	switch {
+	case task.ctx.Err() != nil:
+		status = StatusCancelled
+		s.metrics.cancelled.Increment()
+	case err != nil || execution == nil || !execution.Success:
+		status = StatusFailed
+		s.metrics.failed.Increment()
+	default:
+		status = StatusSucceeded
+		s.metrics.succeeded.Increment()
+	}
+
+	task.update(func(info *TaskInfo) {
+		if execution != nil {
+			info.Output = execution.Output
+			info.Error = execution.Error
+		}
+		if err != nil {
+			info.Error = err.Error()
+		}
+	})
+	task.setStatus(status)
+	task.publish(EventDone, task.Info())
+}
+
+// newTaskID 生成随机的任务ID
+func newTaskID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate task id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
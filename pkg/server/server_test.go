@@ -0,0 +1,439 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/testutil"
+	"trage-agent-go/pkg/tools"
+)
+
+// blockingTool 一直阻塞到上下文取消的测试工具
+type blockingTool struct {
+	*tools.BaseTool
+}
+
+func newBlockingTool() *blockingTool {
+	return &blockingTool{BaseTool: tools.NewBaseTool("wait", "Wait until cancelled", "", nil)}
+}
+
+func (b *blockingTool) Execute(ctx context.Context, args tools.ToolCallArguments) (*tools.ToolResult, error) {
+	<-ctx.Done()
+	return &tools.ToolResult{Success: false, Error: ctx.Err().Error()}, nil
+}
+
+// newTestServer 创建使用回放客户端的服务器，每个任务按script返回响应
+func newTestServer(t *testing.T, script []llm.LLMInteraction) *httptest.Server {
+	t.Helper()
+	ts, _ := startTestServer(t, script, Options{})
+	return ts
+}
+
+// startTestServer 使用options创建服务器，NewAgent、WorkspaceRoot和MaxConcurrent由测试设置
+func startTestServer(t *testing.T, script []llm.LLMInteraction, options Options) (*httptest.Server, *Server) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	if options.NewAgent == nil {
+		options.NewAgent = func(trajectoryFile string) (agent.Agent, error) {
+			return testutil.NewReplayAgent(llm.NewReplayClient(script), trajectoryFile, tools.NewEditTool(), newBlockingTool()), nil
+		}
+	}
+	options.WorkspaceRoot = t.TempDir()
+	options.MaxConcurrent = 2
+	apiServer, err := NewServer(options)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	ts := httptest.NewServer(apiServer.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		apiServer.Shutdown(ctx)
+	})
+	return ts, apiServer
+}
+
+// submitTask 提交任务并返回任务信息
+func submitTask(t *testing.T, ts *httptest.Server, body string) TaskInfo {
+	t.Helper()
+	resp, err := http.Post(ts.URL+"/api/tasks", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		data, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Expected status 202, got %d: %s", resp.StatusCode, data)
+	}
+
+	var info TaskInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode task: %v", err)
+	}
+	return info
+}
+
+// sseEvent 解析后的SSE事件
+type sseEvent struct {
+	id        string
+	eventType string
+	data      string
+}
+
+// readEvents 读取SSE流，直到服务器关闭连接或stop返回true
+func readEvents(t *testing.T, ts *httptest.Server, id, lastEventID string, stop func(sseEvent) bool) []sseEvent {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/tasks/"+id+"/events", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %s", ct)
+	}
+
+	events := make([]sseEvent, 0)
+	current := sseEvent{}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			current.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			current.eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && current.eventType != "":
+			events = append(events, current)
+			if stop != nil && stop(current) {
+				return events
+			}
+			current = sseEvent{}
+		}
+	}
+	return events
+}
+
+// getTask 查询任务状态
+func getTask(t *testing.T, ts *httptest.Server, id string) TaskInfo {
+	t.Helper()
+	resp, err := http.Get(ts.URL + "/api/tasks/" + id)
+	if err != nil {
+		t.Fatalf("Failed to get task: %v", err)
+	}
+	defer resp.Body.Close()
+	var info TaskInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode task: %v", err)
+	}
+	return info
+}
+
+// getBody 发送GET请求并返回状态码和响应体
+func getBody(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Request to %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func eventTypes(events []sseEvent) string {
+	types := make([]string, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.eventType)
+	}
+	return strings.Join(types, ",")
+}
+
+func TestServer_TaskLifecycle(t *testing.T) {
+	ts := newTestServer(t, []llm.LLMInteraction{
+		testutil.EditResponse("hello.txt", "hello\n"),
+		testutil.FinalResponse("任务完成"),
+	})
+
+	info := submitTask(t, ts, `{"task": "create hello.txt"}`)
+	if info.ID == "" || info.Status != StatusQueued {
+		t.Fatalf("Expected queued task with id, got %+v", info)
+	}
+
+	// 任务结束后事件流应自动关闭
+	events := readEvents(t, ts, info.ID, "", nil)
+	expected := "status,status,llm_start,llm_end,tool_start,tool_result,llm_start,llm_end,status,done"
+	if got := eventTypes(events); got != expected {
+		t.Fatalf("Expected events %s, got %s", expected, got)
+	}
+
+	info = getTask(t, ts, info.ID)
+	if info.Status != StatusSucceeded {
+		t.Fatalf("Expected succeeded task, got %s: %s", info.Status, info.Error)
+	}
+	if info.Steps != 2 || info.StartedAt == nil || info.FinishedAt == nil {
+		t.Errorf("Expected 2 steps with timestamps, got %+v", info)
+	}
+
+	// 使用Last-Event-ID续传时只返回之后的事件
+	resumed := readEvents(t, ts, info.ID, events[len(events)-3].id, nil)
+	if got := eventTypes(resumed); got != "status,done" {
+		t.Errorf("Expected resumed events status,done, got %s", got)
+	}
+
+	// 文件应写入任务自己的工作目录，并出现在补丁中
+	status, patch := getBody(t, ts.URL+"/api/tasks/"+info.ID+"/patch")
+	if status != http.StatusOK || !strings.Contains(patch, "+++ b/hello.txt") || !strings.Contains(patch, "+hello") {
+		t.Errorf("Expected patch adding hello.txt, got %d: %s", status, patch)
+	}
+
+	status, trajectory := getBody(t, ts.URL+"/api/tasks/"+info.ID+"/trajectory")
+	if status != http.StatusOK || !strings.Contains(trajectory, "create hello.txt") {
+		t.Errorf("Expected raw trajectory with task, got %d: %s", status, trajectory)
+	}
+	status, exported := getBody(t, ts.URL+"/api/tasks/"+info.ID+"/trajectory?format=md")
+	if status != http.StatusOK || !strings.Contains(exported, "create hello.txt") {
+		t.Errorf("Expected markdown trajectory, got %d: %s", status, exported)
+	}
+	if status, _ := getBody(t, ts.URL+"/api/tasks/"+info.ID+"/trajectory?format=pdf"); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unsupported format, got %d", status)
+	}
+
+	status, metrics := getBody(t, ts.URL+"/metrics")
+	if status != http.StatusOK {
+		t.Fatalf("Expected metrics, got %d", status)
+	}
+	for _, line := range []string{
+		"trage_tasks_submitted_total 1",
+		"trage_tasks_succeeded_total 1",
+		"trage_tool_calls_total 1",
+		"trage_tasks_running 0",
+		`trage_task_duration_seconds_bucket{le="+Inf"} 1`,
+	} {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, metrics)
+		}
+	}
+}
+
+func TestServer_CancelTask(t *testing.T) {
+	ts := newTestServer(t, []llm.LLMInteraction{
+		testutil.ToolCallResponse("wait", map[string]interface{}{}),
+		testutil.FinalResponse("不应执行到这里"),
+	})
+
+	info := submitTask(t, ts, `{"task": "wait forever"}`)
+	readEvents(t, ts, info.ID, "", func(event sseEvent) bool {
+		return event.eventType == EventToolStart
+	})
+
+	resp, err := http.Post(ts.URL+"/api/tasks/"+info.ID+"/cancel", "application/json", nil)
+	if err != nil {
+		t.Fatalf("Failed to cancel task: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", resp.StatusCode)
+	}
+
+	events := readEvents(t, ts, info.ID, "", nil)
+	if last := events[len(events)-1]; last.eventType != EventDone {
+		t.Fatalf("Expected stream to end with done, got %s", last.eventType)
+	}
+
+	info = getTask(t, ts, info.ID)
+	if info.Status != StatusCancelled {
+		t.Errorf("Expected cancelled task, got %s", info.Status)
+	}
+
+	// 已结束的任务不能再取消
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/api/tasks/"+info.ID, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to cancel task: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", resp.StatusCode)
+	}
+}
+
+// flakyClient 第一次调用返回可重试错误的LLM客户端
+type flakyClient struct {
+	llm.LLMClient
+	calls int
+}
+
+func (c *flakyClient) Chat(ctx context.Context, messages []llm.LLMMessage, tools []llm.Tool, config llm.ModelConfig) (*llm.LLMMessage, error) {
+	c.calls++
+	if c.calls == 1 {
+		return nil, errors.New("connection reset by peer")
+	}
+	return c.LLMClient.Chat(ctx, messages, tools, config)
+}
+
+func TestServer_WarningEvents(t *testing.T) {
+	retryConfig := &llm.RetryConfig{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, BackoffRate: 1}
+	ts, _ := startTestServer(t, nil, Options{
+		NewAgent: func(trajectoryFile string) (agent.Agent, error) {
+			client := &flakyClient{LLMClient: llm.NewReplayClient([]llm.LLMInteraction{testutil.FinalResponse("任务完成")})}
+			return testutil.NewReplayAgent(llm.NewRetryableLLMClient(client, retryConfig), trajectoryFile), nil
+		},
+	})
+
+	// 重试警告作为任务事件发布
+	info := submitTask(t, ts, `{"task": "say hello"}`)
+	events := readEvents(t, ts, info.ID, "", nil)
+	found := false
+	for _, event := range events {
+		if event.eventType == EventWarning && strings.Contains(event.data, "retrying replay API call") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a retry warning event, got %s", eventTypes(events))
+	}
+	if info = getTask(t, ts, info.ID); info.Status != StatusSucceeded {
+		t.Errorf("Expected succeeded task, got %s: %s", info.Status, info.Error)
+	}
+}
+
+func TestServer_RequestValidation(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	resp, err := http.Post(ts.URL+"/api/tasks", "application/json", strings.NewReader(`{"task": "  "}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for empty task, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/tasks", "application/json", strings.NewReader(`{"task": "x", "unknown": 1}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown field, got %d", resp.StatusCode)
+	}
+
+	// 默认不允许克隆本地仓库
+	resp, err = http.Post(ts.URL+"/api/tasks", "application/json", strings.NewReader(`{"task": "x", "repo": "file:///etc"}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for local repo, got %d", resp.StatusCode)
+	}
+
+	if status, _ := getBody(t, ts.URL+"/api/tasks/missing"); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown task, got %d", status)
+	}
+
+	resp, err = http.Post(ts.URL+"/healthz", "application/json", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", resp.StatusCode)
+	}
+
+	status, body := getBody(t, ts.URL+"/healthz")
+	if status != http.StatusOK || !strings.Contains(body, `"status": "ok"`) {
+		t.Errorf("Expected healthy response, got %d: %s", status, body)
+	}
+}
+
+func TestServer_BearerToken(t *testing.T) {
+	ts, _ := startTestServer(t, nil, Options{Token: "secret"})
+
+	// 健康检查不需要令牌
+	if status, _ := getBody(t, ts.URL+"/healthz"); status != http.StatusOK {
+		t.Errorf("Expected healthz without token, got %d", status)
+	}
+
+	for _, header := range []string{"", "Bearer wrong", "secret"} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/tasks", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for authorization %q, got %d", header, resp.StatusCode)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 with token, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_Prune(t *testing.T) {
+	ts, apiServer := startTestServer(t, []llm.LLMInteraction{
+		testutil.FinalResponse("任务完成"),
+	}, Options{})
+
+	info := submitTask(t, ts, `{"task": "finish quickly"}`)
+	readEvents(t, ts, info.ID, "", nil)
+	task, _ := apiServer.GetTask(info.ID)
+	if _, err := os.Stat(task.dir); err != nil {
+		t.Fatalf("Expected task directory to exist, got %v", err)
+	}
+
+	// 结束时间晚于before的任务保留
+	if removed := apiServer.Prune(time.Now().Add(-time.Hour)); removed != 0 {
+		t.Errorf("Expected no task to be pruned, got %d", removed)
+	}
+
+	if removed := apiServer.Prune(time.Now().Add(time.Second)); removed != 1 {
+		t.Errorf("Expected 1 pruned task, got %d", removed)
+	}
+	if _, exists := apiServer.GetTask(info.ID); exists || len(apiServer.ListTasks()) != 0 {
+		t.Errorf("Expected task to be removed")
+	}
+	if _, err := os.Stat(task.dir); !os.IsNotExist(err) {
+		t.Errorf("Expected task directory to be removed, got %v", err)
+	}
+	if status, _ := getBody(t, ts.URL+"/api/tasks/"+info.ID); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for pruned task, got %d", status)
+	}
+}
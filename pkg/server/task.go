@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"trage-agent-go/pkg/llm"
+)
+
+// TaskStatus 任务状态
+type TaskStatus string
+
+const (
+	StatusQueued    TaskStatus = "queued"
+	StatusRunning   TaskStatus = "running"
+	StatusSucceeded TaskStatus = "succeeded"
+	StatusFailed    TaskStatus = "failed"
+	StatusCancelled TaskStatus = "cancelled"
+)
+
+// Finished 检查任务是否已结束
+func (s TaskStatus) Finished() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCancelled
+}
+
+// 事件类型
+const (
+	EventStatus     = "status"
+	EventMessage    = "message"
+	EventWarning    = "warning"
+	EventLLMStart   = "llm_start"
+	EventLLMEnd     = "llm_end"
+	EventToolStart  = "tool_start"
+	EventToolResult = "tool_result"
+	EventLakeview   = "lakeview"
+	EventDone       = "done"
+)
+
+// TaskRequest 提交任务的请求
+type TaskRequest struct {
+	Task       string `json:"task"`
+	Repo       string `json:"repo,omitempty"`        // 克隆到工作目录的git仓库，为空时使用空目录
+	BaseCommit string `json:"base_commit,omitempty"` // 克隆后检出的提交
+}
+
+// TaskInfo 任务状态快照
+type TaskInfo struct {
+	ID             string     `json:"id"`
+	Task           string     `json:"task"`
+	Repo           string     `json:"repo,omitempty"`
+	BaseCommit     string     `json:"base_commit,omitempty"`
+	Status         TaskStatus `json:"status"`
+	CreatedAt      time.Time  `json:"created_at"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	Workspace      string     `json:"workspace"`
+	TrajectoryPath string     `json:"trajectory_path"`
+	Steps          int        `json:"steps"`
+	Usage          llm.Usage  `json:"usage"`
+	Output         string     `json:"output,omitempty"`
+	Error          string     `json:"error,omitempty"`
+}
+
+// Event 任务执行过程中的事件，通过SSE推送
+type Event struct {
+	ID   int         `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Task 服务器中运行的任务，每个任务有独立的代理实例和工作目录
+type Task struct {
+	info    TaskInfo
+	dir     string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	events  []Event
+	changed chan struct{}
+	mutex   sync.Mutex
+}
+
+// newTask 创建排队中的任务
+func newTask(id string, req TaskRequest, dir, workspace, trajectoryPath string) *Task {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Task{
+		info: TaskInfo{
+			ID:             id,
+			Task:           req.Task,
+			Repo:           req.Repo,
+			BaseCommit:     req.BaseCommit,
+			Status:         StatusQueued,
+			CreatedAt:      time.Now(),
+			Workspace:      workspace,
+			TrajectoryPath: trajectoryPath,
+		},
+		dir:     dir,
+		ctx:     ctx,
+		cancel:  cancel,
+		events:  make([]Event, 0),
+		changed: make(chan struct{}),
+	}
+}
+
+// Info 获取任务状态快照
+func (t *Task) Info() TaskInfo {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.info
+}
+
+// Cancel 取消任务，已结束的任务返回false
+func (t *Task) Cancel() bool {
+	t.mutex.Lock()
+	finished := t.info.Status.Finished()
+	t.mutex.Unlock()
+	if finished {
+		return false
+	}
+	t.cancel()
+	return true
+}
+
+// update 修改任务状态
+func (t *Task) update(fn func(info *TaskInfo)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	fn(&t.info)
+}
+
+// setStatus 修改任务状态并发布状态事件
+func (t *Task) setStatus(status TaskStatus) {
+	now := time.Now()
+	t.update(func(info *TaskInfo) {
+		info.Status = status
+		switch {
+		case status == StatusRunning:
+			info.StartedAt = &now
+		case status.Finished():
+			info.FinishedAt = &now
+		}
+	})
+	t.publish(EventStatus, map[string]interface{}{"status": status})
+}
+
+// publish 追加事件并唤醒等待中的订阅者
+func (t *Task) publish(eventType string, data interface{}) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.events = append(t.events, Event{
+		ID:   len(t.events) + 1,
+		Type: eventType,
+		Time: time.Now(),
+		Data: data,
+	})
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// eventsSince 获取ID大于after的事件；done表示任务已结束且没有更多事件
+func (t *Task) eventsSince(after int) (events []Event, changed <-chan struct{}, done bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if after < 0 {
+		after = 0
+	}
+	if after < len(t.events) {
+		events = append(events, t.events[after:]...)
+	}
+	finished := t.info.Status.Finished() && len(t.events) > 0 && t.events[len(t.events)-1].Type == EventDone
+	return events, t.changed, finished && after+len(events) >= len(t.events)
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"trage-agent-go/pkg/utils"
+)
+
+// remoteRepoSchemes 默认允许克隆的仓库URL协议
+var remoteRepoSchemes = []string{"https://", "ssh://"}
+
+// validateTaskRequest 检查请求中的仓库和基准提交。以-开头的值会被git当作选项；
+// 默认只允许https、ssh和user@host:path形式的远程仓库，allowLocalRepos为true时还允许file://和本地路径
+func validateTaskRequest(req TaskRequest, allowLocalRepos bool) error {
+	if strings.HasPrefix(req.BaseCommit, "-") {
+		return fmt.Errorf("%w: invalid base_commit %q", ErrInvalidRequest, req.BaseCommit)
+	}
+	if req.Repo == "" {
+		return nil
+	}
+	if strings.HasPrefix(req.Repo, "-") {
+		return fmt.Errorf("%w: invalid repo %q", ErrInvalidRequest, req.Repo)
+	}
+	if isRemoteRepo(req.Repo) {
+		return nil
+	}
+	if allowLocalRepos && (strings.HasPrefix(req.Repo, "file://") || !strings.Contains(req.Repo, "://") && !strings.Contains(req.Repo, "::")) {
+		return nil
+	}
+	return fmt.Errorf("%w: repo %q is not allowed, use an https://, ssh:// or user@host:path URL",
+		ErrInvalidRequest, req.Repo)
+}
+
+// isRemoteRepo 检查repo是否是允许的远程仓库URL
+func isRemoteRepo(repo string) bool {
+	for _, scheme := range remoteRepoSchemes {
+		if strings.HasPrefix(repo, scheme) && len(repo) > len(scheme) {
+			return true
+		}
+	}
+
+	// scp形式的user@host:path，冒号前不能有斜杠，也不能是transport::address
+	host, path, found := strings.Cut(repo, ":")
+	if !found || path == "" || strings.HasPrefix(path, ":") || strings.Contains(host, "/") {
+		return false
+	}
+	user, hostname, found := strings.Cut(host, "@")
+	return found && user != "" && hostname != ""
+}
+
+// prepareWorkspace 准备任务的工作目录：指定仓库时克隆并检出基准提交，
+// 否则初始化一个空的git仓库，以便之后生成补丁
+func prepareWorkspace(ctx context.Context, workspace string, req TaskRequest) error {
+	if req.Repo == "" {
+		if err := os.MkdirAll(workspace, 0755); err != nil {
+			return fmt.Errorf("failed to create workspace: %w", err)
+		}
+		if _, err := utils.RunGit(ctx, workspace, "init", "--quiet"); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if _, err := utils.RunGit(ctx, filepath.Dir(workspace), "clone", "--quiet", "--", req.Repo, workspace); err != nil {
+		return err
+	}
+	if req.BaseCommit != "" {
+		// 末尾的--使基准提交只能被解析为提交，不会被当作路径
+		if _, err := utils.RunGit(ctx, workspace, "checkout", "--quiet", "--detach", req.BaseCommit, "--"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"trage-agent-go/pkg/testutil"
+)
+
+func TestValidateTaskRequest(t *testing.T) {
+	cases := []struct {
+		repo       string
+		baseCommit string
+		allowLocal bool
+		valid      bool
+	}{
+		{repo: "", valid: true},
+		{repo: "https://github.com/org/repo.git", baseCommit: "abc123", valid: true},
+		{repo: "ssh://git@example.com/org/repo.git", valid: true},
+		{repo: "git@github.com:org/repo.git", valid: true},
+		// 以-开头的值会被git当作选项
+		{repo: "--upload-pack=touch /tmp/x", valid: false},
+		{repo: "https://github.com/org/repo.git", baseCommit: "--orphan=x", valid: false},
+		{repo: "", baseCommit: "-b", valid: false},
+		// 默认不允许本地仓库和其他传输方式
+		{repo: "file:///etc", valid: false},
+		{repo: "/srv/repo", valid: false},
+		{repo: "../repo", valid: false},
+		{repo: "ext::sh -c touch% /tmp/x", valid: false},
+		{repo: "http://example.com/repo.git", valid: false},
+		{repo: "host:repo", valid: false},
+		// 显式允许时可以使用本地仓库，但仍不允许其他传输方式
+		{repo: "file:///srv/repo", allowLocal: true, valid: true},
+		{repo: "/srv/repo", allowLocal: true, valid: true},
+		{repo: "ext::sh -c touch% /tmp/x", allowLocal: true, valid: false},
+		{repo: "-/srv/repo", allowLocal: true, valid: false},
+	}
+
+	for _, tc := range cases {
+		err := validateTaskRequest(TaskRequest{Task: "x", Repo: tc.repo, BaseCommit: tc.baseCommit}, tc.allowLocal)
+		if tc.valid && err != nil {
+			t.Errorf("Expected repo %q base %q to be valid, got %v", tc.repo, tc.baseCommit, err)
+		}
+		if !tc.valid && !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Expected repo %q base %q to be rejected, got %v", tc.repo, tc.baseCommit, err)
+		}
+	}
+}
+
+func TestPrepareWorkspace_CloneAndCheckout(t *testing.T) {
+	// 创建有两个提交的源仓库
+	repo, base := testutil.InitRepo(t, map[string]string{"file.txt": "first\n"})
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("second\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	testutil.Git(t, repo, "commit", "--quiet", "-am", "second")
+
+	workspace := filepath.Join(t.TempDir(), "workspace")
+	if err := prepareWorkspace(context.Background(), workspace, TaskRequest{Task: "x", Repo: repo, BaseCommit: base}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(workspace, "file.txt"))
+	if err != nil || string(data) != "first\n" {
+		t.Errorf("Expected base commit content, got %q (%v)", data, err)
+	}
+}
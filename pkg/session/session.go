@@ -0,0 +1,63 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"trage-agent-go/pkg/llm"
+)
+
+// Session 保存在磁盘上的交互会话，恢复后可以在原有对话的基础上继续工作
+type Session struct {
+	ID         string                     `json:"id"`
+	Name       string                     `json:"name,omitempty"`
+	CreatedAt  time.Time                  `json:"created_at"`
+	UpdatedAt  time.Time                  `json:"updated_at"`
+	WorkingDir string                     `json:"working_dir"`
+	Model      string                     `json:"model,omitempty"`
+	Provider   string                     `json:"provider,omitempty"`
+	Tasks      []string                   `json:"tasks"`                // 按顺序执行过的任务
+	Messages   []llm.LLMMessage           `json:"messages"`             // 对话历史，不含系统提示
+	ToolState  map[string]json.RawMessage `json:"tool_state,omitempty"` // 有状态工具的状态，键为工具名称
+	Usage      llm.Usage                  `json:"usage"`                // 累计令牌用量
+}
+
+// New 创建新会话，name可以为空
+func New(name string) (*Session, error) {
+	buf := make([]byte, 3)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %w", err)
+	}
+	now := time.Now()
+	return &Session{
+		// 以时间开头，按ID排序即按创建时间排序
+		ID:        now.Format("20060102-150405") + "-" + hex.EncodeToString(buf),
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Tasks:     make([]string, 0),
+		Messages:  make([]llm.LLMMessage, 0),
+		ToolState: make(map[string]json.RawMessage),
+	}, nil
+}
+
+// AddUsage 累加令牌用量
+func (s *Session) AddUsage(usage llm.Usage) {
+	s.Usage.PromptTokens += usage.PromptTokens
+	s.Usage.CompletionTokens += usage.CompletionTokens
+	s.Usage.TotalTokens += usage.TotalTokens
+}
+
+// Title 会话的显示名称，没有名称时使用最后一个任务
+func (s *Session) Title() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	if len(s.Tasks) > 0 {
+		return s.Tasks[len(s.Tasks)-1]
+	}
+	return ""
+}
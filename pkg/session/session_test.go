@@ -0,0 +1,159 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"trage-agent-go/pkg/llm"
+)
+
+func TestStore_SaveLoad(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "sessions"))
+
+	sess, err := New("bugfix")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	sess.WorkingDir = "/tmp/project"
+	sess.Tasks = append(sess.Tasks, "修复bug")
+	sess.Messages = append(sess.Messages,
+		llm.LLMMessage{Role: "user", Content: "修复bug"},
+		llm.LLMMessage{Role: "assistant", ToolCalls: []llm.ToolCall{{ID: "call_1", Type: "function", Function: llm.ToolCallFunction{Name: "bash", Arguments: map[string]interface{}{"command": "ls"}}}}},
+		llm.LLMMessage{Role: "tool", Content: "main.go", ToolCallID: "call_1"},
+	)
+	sess.ToolState["sequential_thinking"] = json.RawMessage(`{"thought_history":[]}`)
+	sess.AddUsage(llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15})
+	sess.AddUsage(llm.Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2})
+
+	if err := store.Save(sess); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+
+	// 按ID和名称都能加载
+	for _, ref := range []string{sess.ID, "bugfix"} {
+		loaded, err := store.Load(ref)
+		if err != nil {
+			t.Fatalf("Failed to load session %q: %v", ref, err)
+		}
+		if loaded.ID != sess.ID || loaded.WorkingDir != "/tmp/project" || len(loaded.Messages) != 3 {
+			t.Errorf("Unexpected session loaded by %q: %+v", ref, loaded)
+		}
+		if loaded.Messages[1].ToolCalls[0].Function.Name != "bash" || loaded.Messages[2].ToolCallID != "call_1" {
+			t.Errorf("Expected tool calls to survive round trip, got %+v", loaded.Messages)
+		}
+		if loaded.Usage.TotalTokens != 17 {
+			t.Errorf("Expected 17 total tokens, got %d", loaded.Usage.TotalTokens)
+		}
+		var state bytes.Buffer
+		json.Compact(&state, loaded.ToolState["sequential_thinking"])
+		if state.String() != `{"thought_history":[]}` {
+			t.Errorf("Expected tool state to survive round trip, got %s", state.String())
+		}
+	}
+
+	if _, err := store.Load("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := store.Load("../escape"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for path-like reference, got %v", err)
+	}
+
+	// 临时文件不应残留
+	entries, _ := os.ReadDir(store.Dir())
+	if len(entries) != 1 {
+		t.Errorf("Expected only the session file, got %d entries", len(entries))
+	}
+}
+
+func TestStore_ListLatestDelete(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	if _, err := store.Latest(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for empty store, got %v", err)
+	}
+
+	ids := make([]string, 0)
+	for _, task := range []string{"first", "second", "third"} {
+		sess, err := New("")
+		if err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		sess.Tasks = append(sess.Tasks, task)
+		if err := store.Save(sess); err != nil {
+			t.Fatalf("Failed to save session: %v", err)
+		}
+		ids = append(ids, sess.ID)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 再次保存第一个会话，使其成为最近更新的会话
+	first, _ := store.Load(ids[0])
+	if err := store.Save(first); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+
+	sessions, warnings, err := store.List()
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("Failed to list sessions: %v %v", err, warnings)
+	}
+	if len(sessions) != 3 || sessions[0].ID != ids[0] || sessions[1].ID != ids[2] {
+		t.Errorf("Expected sessions ordered by update time, got %v", sessions)
+	}
+	if sessions[1].Title() != "third" {
+		t.Errorf("Expected title from last task, got %q", sessions[1].Title())
+	}
+
+	latest, err := store.Latest()
+	if err != nil || latest.ID != ids[0] {
+		t.Errorf("Expected latest session %s, got %v (%v)", ids[0], latest, err)
+	}
+
+	if _, err := store.Delete(ids[1]); err != nil {
+		t.Fatalf("Failed to delete session: %v", err)
+	}
+	if _, err := store.Load(ids[1]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected deleted session to be gone, got %v", err)
+	}
+	if _, err := store.Delete(ids[1]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when deleting twice, got %v", err)
+	}
+}
+
+func TestStore_ListSkipsCorruptFiles(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	sess, err := New("good")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if err := store.Save(sess); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	// 损坏的会话文件不应导致列表和加载失败
+	if err := os.WriteFile(filepath.Join(store.Dir(), "broken.json"), []byte("{not json"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	sessions, warnings, err := store.List()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != sess.ID {
+		t.Errorf("Expected only the valid session, got %v", sessions)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("Expected one warning for the corrupt file, got %v", warnings)
+	}
+
+	if loaded, err := store.Load("good"); err != nil || loaded.ID != sess.ID {
+		t.Errorf("Expected to load session by name, got %v (%v)", loaded, err)
+	}
+	if latest, err := store.Latest(); err != nil || latest.ID != sess.ID {
+		t.Errorf("Expected latest session %s, got %v (%v)", sess.ID, latest, err)
+	}
+}
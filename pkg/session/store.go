@@ -0,0 +1,176 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 会话不存在
+var ErrNotFound = errors.New("session not found")
+
+// Store 会话存储，每个会话保存为目录下的<id>.json
+type Store struct {
+	dir string
+}
+
+// DefaultDir 获取默认的会话目录（~/.trae/sessions）
+func DefaultDir() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".trae", "sessions")
+	}
+	return filepath.Join(os.TempDir(), ".trae", "sessions")
+}
+
+// NewStore 创建会话存储，dir为空时使用默认目录
+func NewStore(dir string) *Store {
+	if dir == "" {
+		dir = DefaultDir()
+	}
+	return &Store{dir: dir}
+}
+
+// Dir 获取会话目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save 保存会话并更新修改时间，先写临时文件再重命名，避免中断时留下不完整的文件
+func (s *Store) Save(session *Session) error {
+	if !validID(session.ID) {
+		return fmt.Errorf("invalid session id %q", session.ID)
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	session.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(s.dir, session.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create session file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := os.Rename(tmpFile.Name(), s.path(session.ID)); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// Load 按ID或名称加载会话，多个会话同名时返回最近更新的一个
+func (s *Store) Load(ref string) (*Session, error) {
+	if validID(ref) {
+		session, err := s.read(s.path(ref))
+		if err == nil {
+			return session, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	sessions, _, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		if session.Name == ref {
+			return session, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+}
+
+// Latest 加载最近更新的会话
+func (s *Store) Latest() (*Session, error) {
+	sessions, _, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, ErrNotFound
+	}
+	return sessions[0], nil
+}
+
+// List 列出所有会话，按更新时间从新到旧排序。无法读取或内容损坏的会话文件会被跳过，
+// 对应的错误作为警告返回，不影响其他会话
+func (s *Store) List() ([]*Session, []error, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return make([]*Session, 0), nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read session directory: %w", err)
+	}
+
+	sessions := make([]*Session, 0, len(entries))
+	var warnings []error
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		session, err := s.read(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			warnings = append(warnings, err)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, warnings, nil
+}
+
+// Delete 按ID或名称删除会话
+func (s *Store) Delete(ref string) (*Session, error) {
+	session, err := s.Load(ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(s.path(session.ID)); err != nil {
+		return nil, fmt.Errorf("failed to delete session: %w", err)
+	}
+	return session, nil
+}
+
+// read 读取会话文件
+func (s *Store) read(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("invalid session file %s: %w", filepath.Base(path), err)
+	}
+	return &session, nil
+}
+
+// path 会话文件路径
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// validID 检查ID能否安全地用作文件名
+func validID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\`)
+}
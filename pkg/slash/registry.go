@@ -0,0 +1,129 @@
+package slash
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Command 交互模式中以/开头的命令
+type Command struct {
+	Name        string   // 命令名称，不含/
+	Aliases     []string // 别名，不含/
+	Usage       string   // 参数说明，如"<name>"
+	Description string
+	Run         func(args string) error // args为命令名之后的原始文本（已去除首尾空白）
+}
+
+// Registry 斜杠命令注册表
+type Registry struct {
+	commands map[string]*Command
+	aliases  map[string]string
+}
+
+// NewRegistry 创建斜杠命令注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		commands: make(map[string]*Command),
+		aliases:  make(map[string]string),
+	}
+}
+
+// Register 注册命令，名称或别名与已有命令冲突时返回错误
+func (r *Registry) Register(command *Command) error {
+	if command.Name == "" || strings.ContainsAny(command.Name, " \t/") {
+		return fmt.Errorf("invalid command name %q", command.Name)
+	}
+	if command.Run == nil {
+		return fmt.Errorf("command /%s has no handler", command.Name)
+	}
+	for _, name := range append([]string{command.Name}, command.Aliases...) {
+		if _, exists := r.Lookup(name); exists {
+			return fmt.Errorf("command /%s is already registered", name)
+		}
+	}
+
+	r.commands[command.Name] = command
+	for _, alias := range command.Aliases {
+		r.aliases[alias] = command.Name
+	}
+	return nil
+}
+
+// Lookup 按名称或别名查找命令
+func (r *Registry) Lookup(name string) (*Command, bool) {
+	if target, exists := r.aliases[name]; exists {
+		name = target
+	}
+	command, exists := r.commands[name]
+	return command, exists
+}
+
+// Commands 按名称排序返回所有命令
+func (r *Registry) Commands() []*Command {
+	commands := make([]*Command, 0, len(r.commands))
+	for _, command := range r.commands {
+		commands = append(commands, command)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+// Complete 返回以prefix开头的命令名（含/），prefix可以带或不带/
+func (r *Registry) Complete(prefix string) []string {
+	prefix = strings.TrimPrefix(prefix, "/")
+	matches := make([]string, 0)
+	for _, command := range r.Commands() {
+		if strings.HasPrefix(command.Name, prefix) {
+			matches = append(matches, "/"+command.Name)
+		}
+	}
+	return matches
+}
+
+// Execute 执行输入中的斜杠命令。输入不以/开头时handled为false
+func (r *Registry) Execute(input string) (handled bool, err error) {
+	name, args, ok := Parse(input)
+	if !ok {
+		return false, nil
+	}
+	command, exists := r.Lookup(name)
+	if !exists {
+		return true, fmt.Errorf("unknown command /%s, type /help to list commands", name)
+	}
+	return true, command.Run(args)
+}
+
+// Parse 将"/name args"拆分为命令名和参数，输入不是斜杠命令时ok为false
+func Parse(input string) (name, args string, ok bool) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "/") || len(input) == 1 {
+		return "", "", false
+	}
+	rest := input[1:]
+	name = rest
+	if i := strings.IndexFunc(rest, unicode.IsSpace); i >= 0 {
+		name, args = rest[:i], rest[i:]
+	}
+	// 以"//"开头或命令名中含有路径分隔符时视为普通输入（如"/tmp/a.txt"）
+	if name == "" || strings.Contains(name, "/") {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(args), true
+}
+
+// Help 生成命令帮助文本
+func (r *Registry) Help() string {
+	var sb strings.Builder
+	for _, command := range r.Commands() {
+		usage := "/" + command.Name
+		if command.Usage != "" {
+			usage += " " + command.Usage
+		}
+		sb.WriteString(fmt.Sprintf("  %-36s %s\n", usage, command.Description))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,132 @@
+package slash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+		args  string
+		ok    bool
+	}{
+		{"/help", "help", "", true},
+		{"  /model gpt-4o  ", "model", "gpt-4o", true},
+		{"/tools disable bash", "tools", "disable bash", true},
+		{"/fix\n多行\n参数", "fix", "多行\n参数", true},
+		{"fix the bug", "", "", false},
+		{"/", "", "", false},
+		{"/tmp/a.txt 有问题", "", "", false},
+	}
+	for _, tt := range tests {
+		name, args, ok := Parse(tt.input)
+		if name != tt.name || args != tt.args || ok != tt.ok {
+			t.Errorf("Parse(%q) = (%q, %q, %v), expected (%q, %q, %v)", tt.input, name, args, ok, tt.name, tt.args, tt.ok)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	var received string
+	if err := registry.Register(&Command{Name: "model", Description: "切换模型", Run: func(args string) error {
+		received = args
+		return nil
+	}}); err != nil {
+		t.Fatalf("Failed to register command: %v", err)
+	}
+	if err := registry.Register(&Command{Name: "exit", Aliases: []string{"quit"}, Run: func(string) error { return nil }}); err != nil {
+		t.Fatalf("Failed to register command: %v", err)
+	}
+
+	// 名称或别名冲突、无效名称都应报错
+	for _, command := range []*Command{
+		{Name: "model", Run: func(string) error { return nil }},
+		{Name: "bye", Aliases: []string{"quit"}, Run: func(string) error { return nil }},
+		{Name: "a/b", Run: func(string) error { return nil }},
+		{Name: "noop"},
+	} {
+		if err := registry.Register(command); err == nil {
+			t.Errorf("Expected error registering %+v", command)
+		}
+	}
+
+	handled, err := registry.Execute("/model gpt-4o")
+	if !handled || err != nil || received != "gpt-4o" {
+		t.Errorf("Expected /model to run with gpt-4o, got handled=%v err=%v args=%q", handled, err, received)
+	}
+	if command, ok := registry.Lookup("quit"); !ok || command.Name != "exit" {
+		t.Errorf("Expected alias quit to resolve to exit")
+	}
+	if handled, err := registry.Execute("/unknown"); !handled || err == nil || !strings.Contains(err.Error(), "/help") {
+		t.Errorf("Expected unknown command error, got handled=%v err=%v", handled, err)
+	}
+	if handled, _ := registry.Execute("plain task"); handled {
+		t.Errorf("Expected plain input not to be handled")
+	}
+
+	if matches := registry.Complete("/mo"); len(matches) != 1 || matches[0] != "/model" {
+		t.Errorf("Expected completion /model, got %v", matches)
+	}
+	if matches := registry.Complete(""); len(matches) != 2 || matches[0] != "/exit" {
+		t.Errorf("Expected all commands sorted, got %v", matches)
+	}
+	if help := registry.Help(); !strings.Contains(help, "/model") || !strings.Contains(help, "切换模型") {
+		t.Errorf("Expected help to list /model, got %q", help)
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "review.md"), []byte("---\ndescription: 审查代码\nusage: <path>\n---\n请审查 $ARGUMENTS 中的代码。\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "test.md"), []byte("# 为最近的修改补充测试\n\n运行 go test ./...\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644)
+
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(templates) != 2 || templates[0].Name != "review" || templates[1].Name != "test" {
+		t.Fatalf("Expected review and test templates, got %+v", templates)
+	}
+
+	review := templates[0]
+	if review.Description != "审查代码" || review.Usage != "<path>" {
+		t.Errorf("Expected front matter to be parsed, got %+v", review)
+	}
+	if prompt := review.Expand("pkg/agent"); prompt != "请审查 pkg/agent 中的代码。" {
+		t.Errorf("Unexpected expansion %q", prompt)
+	}
+
+	test := templates[1]
+	if test.Description != "为最近的修改补充测试" {
+		t.Errorf("Expected description from first line, got %q", test.Description)
+	}
+	if prompt := test.Expand("只测试pkg/slash"); !strings.HasSuffix(prompt, "\n\n只测试pkg/slash") {
+		t.Errorf("Expected arguments to be appended, got %q", prompt)
+	}
+
+	var prompt string
+	command := review.Command(func(p string) error {
+		prompt = p
+		return nil
+	})
+	if err := command.Run("main.go"); err != nil || prompt != "请审查 main.go 中的代码。" {
+		t.Errorf("Expected template command to run expanded prompt, got %q (%v)", prompt, err)
+	}
+
+	// 目录不存在时返回空列表
+	if templates, err := LoadTemplates(filepath.Join(dir, "missing")); err != nil || len(templates) != 0 {
+		t.Errorf("Expected no templates for missing directory, got %v (%v)", templates, err)
+	}
+
+	for _, content := range []string{"---\ndescription: x\n", "---\ndescription: x\n---\n", "---\n: [\n---\nbody"} {
+		if _, err := ParseTemplate("bad", []byte(content)); err == nil {
+			t.Errorf("Expected error for template %q", content)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package slash
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateDir 项目中存放自定义命令的目录，相对项目根目录
+const TemplateDir = ".trae/commands"
+
+// argumentsPlaceholder 模板中替换为命令参数的占位符
+const argumentsPlaceholder = "$ARGUMENTS"
+
+// Template 以Markdown编写的自定义命令，文件名（不含.md）即命令名，
+// 执行时把展开后的内容作为任务交给代理
+type Template struct {
+	Name        string
+	Description string
+	Usage       string
+	Body        string
+	Path        string
+}
+
+// templateFrontMatter 模板开头可选的YAML元数据
+type templateFrontMatter struct {
+	Description string `yaml:"description"`
+	Usage       string `yaml:"usage"`
+}
+
+// LoadTemplates 读取dir下的*.md模板，按名称排序，目录不存在时返回空列表
+func LoadTemplates(dir string) ([]Template, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return make([]Template, 0), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command directory: %w", err)
+	}
+
+	templates := make([]Template, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read command template: %w", err)
+		}
+		template, err := ParseTemplate(strings.TrimSuffix(entry.Name(), ".md"), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		template.Path = path
+		templates = append(templates, *template)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+// ParseTemplate 解析模板内容。开头可以有"---"包围的YAML元数据（description、usage），
+// 没有description时使用正文的第一行
+func ParseTemplate(name string, data []byte) (*Template, error) {
+	template := &Template{Name: name}
+	body := string(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")))
+
+	if rest, found := strings.CutPrefix(body, "---\n"); found {
+		header, content, closed := strings.Cut(rest, "\n---")
+		if !closed {
+			return nil, fmt.Errorf("unterminated front matter")
+		}
+		var meta templateFrontMatter
+		if err := yaml.Unmarshal([]byte(header), &meta); err != nil {
+			return nil, fmt.Errorf("invalid front matter: %w", err)
+		}
+		template.Description = meta.Description
+		template.Usage = meta.Usage
+		body = strings.TrimPrefix(content, "\n")
+	}
+
+	template.Body = strings.TrimSpace(body)
+	if template.Body == "" {
+		return nil, fmt.Errorf("command template is empty")
+	}
+	if template.Description == "" {
+		firstLine, _, _ := strings.Cut(template.Body, "\n")
+		template.Description = strings.TrimSpace(strings.TrimLeft(firstLine, "# "))
+	}
+	return template, nil
+}
+
+// Expand 用参数替换模板中的$ARGUMENTS；模板没有占位符时参数追加在末尾
+func (t *Template) Expand(args string) string {
+	if strings.Contains(t.Body, argumentsPlaceholder) {
+		return strings.ReplaceAll(t.Body, argumentsPlaceholder, args)
+	}
+	if args == "" {
+		return t.Body
+	}
+	return t.Body + "\n\n" + args
+}
+
+// Command 将模板转换为斜杠命令，执行时把展开后的提示交给run
+func (t *Template) Command(run func(prompt string) error) *Command {
+	return &Command{
+		Name:        t.Name,
+		Usage:       t.Usage,
+		Description: t.Description + "（" + filepath.ToSlash(filepath.Join(TemplateDir, t.Name+".md")) + "）",
+		Run: func(args string) error {
+			return run(t.Expand(args))
+		},
+	}
+}
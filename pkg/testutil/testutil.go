@@ -0,0 +1,91 @@
+// Package testutil 测试中共用的代理、脚本响应和git仓库辅助函数
+package testutil
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"trage-agent-go/pkg/agent"
+	"trage-agent-go/pkg/config"
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/tools"
+	"trage-agent-go/pkg/utils"
+)
+
+// NewReplayAgent 创建使用client的TraeAgent，轨迹写到trajectoryFile，并添加agentTools
+func NewReplayAgent(client llm.LLMClient, trajectoryFile string, agentTools ...tools.Tool) *agent.TraeAgent {
+	agentConfig := &config.AgentConfig{Model: "test_model", MaxSteps: 10}
+	modelConfig := &config.ModelConfig{Model: "test-model", ModelProvider: "openai", MaxTokens: 1024}
+	ag := agent.NewTraeAgent(agentConfig, modelConfig, client)
+	ag.SetTrajectoryRecorder(utils.NewTrajectoryRecorder(trajectoryFile))
+	for _, tool := range agentTools {
+		ag.AddTool(tool)
+	}
+	return ag
+}
+
+// TaskID 从<id>.jsonl形式的轨迹文件名中取出任务ID，用于按任务选择脚本
+func TaskID(trajectoryFile string) string {
+	return strings.TrimSuffix(filepath.Base(trajectoryFile), ".jsonl")
+}
+
+// ToolCallResponse 调用单个工具的脚本响应
+func ToolCallResponse(name string, arguments map[string]interface{}) llm.LLMInteraction {
+	return llm.LLMInteraction{Response: &llm.LLMMessage{
+		Role: "assistant",
+		ToolCalls: []llm.ToolCall{{
+			ID:       "call_" + name,
+			Type:     "function",
+			Function: llm.ToolCallFunction{Name: name, Arguments: arguments},
+		}},
+	}}
+}
+
+// EditResponse 用edit_file把content写入path的脚本响应
+func EditResponse(path, content string) llm.LLMInteraction {
+	return ToolCallResponse("edit_file", map[string]interface{}{"file_path": path, "content": content, "backup": false})
+}
+
+// FinalResponse 不调用工具、结束任务的脚本响应
+func FinalResponse(content string) llm.LLMInteraction {
+	return llm.LLMInteraction{Response: &llm.LLMMessage{Role: "assistant", Content: content}}
+}
+
+// InitRepo 创建包含files的git仓库并提交一次，返回仓库路径和提交哈希；git不可用时跳过测试
+func InitRepo(t *testing.T, files map[string]string) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	Git(t, dir, "init", "--quiet")
+	Git(t, dir, "config", "user.email", "test@example.com")
+	Git(t, dir, "config", "user.name", "test")
+	Git(t, dir, "add", "--all")
+	Git(t, dir, "commit", "--quiet", "--allow-empty", "-m", "base")
+	return dir, Git(t, dir, "rev-parse", "HEAD")
+}
+
+// Git 在dir中执行git命令并返回去掉首尾空白的输出，失败时结束测试
+func Git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	output, err := utils.RunGit(context.Background(), dir, args...)
+	if err != nil {
+		t.Fatalf("git %v failed: %v", args, err)
+	}
+	return strings.TrimSpace(string(output))
+}
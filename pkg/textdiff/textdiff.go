@@ -0,0 +1,134 @@
+package textdiff
+
+import "strings"
+
+// OpKind 差异操作类型
+type OpKind byte
+
+const (
+	// OpEqual 两侧相同的行
+	OpEqual OpKind = ' '
+	// OpDelete 仅在旧内容中存在的行
+	OpDelete OpKind = '-'
+	// OpInsert 仅在新内容中存在的行
+	OpInsert OpKind = '+'
+)
+
+// Op 一行差异
+type Op struct {
+	Kind OpKind
+	Text string
+}
+
+// Lines 基于最长公共子序列计算逐行差异
+func Lines(a, b []string) []Op {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]Op, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, Op{OpEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, Op{OpDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, Op{OpInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, Op{OpDelete, a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, Op{OpInsert, b[j]})
+	}
+	return ops
+}
+
+// Hunks 只保留变化行及其前后context行，被省略的部分用nil分隔
+func Hunks(ops []Op, context int) [][]Op {
+	keep := make([]bool, len(ops))
+	for k, op := range ops {
+		if op.Kind == OpEqual {
+			continue
+		}
+		for c := k - context; c <= k+context; c++ {
+			if c >= 0 && c < len(ops) {
+				keep[c] = true
+			}
+		}
+	}
+
+	hunks := make([][]Op, 0)
+	var current []Op
+	for k, op := range ops {
+		if !keep[k] {
+			if current != nil {
+				hunks = append(hunks, current)
+				current = nil
+			}
+			continue
+		}
+		current = append(current, op)
+	}
+	if current != nil {
+		hunks = append(hunks, current)
+	}
+	return hunks
+}
+
+// Unified 生成带上下文的文本差异，两侧相同时返回空字符串
+func Unified(a, b []string, fromName, toName string, context int) string {
+	ops := Lines(a, b)
+	changed := false
+	for _, op := range ops {
+		if op.Kind != OpEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("--- " + fromName + "\n+++ " + toName + "\n")
+	for i, hunk := range Hunks(ops, context) {
+		if i > 0 {
+			sb.WriteString("@@\n")
+		}
+		for _, op := range hunk {
+			sb.WriteByte(byte(op.Kind))
+			sb.WriteString(op.Text)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// SplitLines 按行切分文本，忽略末尾换行
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,38 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	ops := Lines([]string{"a", "b", "c"}, []string{"a", "x", "c", "d"})
+	var got []string
+	for _, op := range ops {
+		got = append(got, string(op.Kind)+op.Text)
+	}
+	expected := []string{" a", "-b", "+x", " c", "+d"}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	if diff := Unified([]string{"same"}, []string{"same"}, "a", "b", 2); diff != "" {
+		t.Errorf("Expected empty diff for identical input, got %q", diff)
+	}
+
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	b := []string{"1", "two", "3", "4", "5", "6", "7", "8", "nine", "10"}
+	diff := Unified(a, b, "old", "new", 1)
+	if !strings.HasPrefix(diff, "--- old\n+++ new\n") {
+		t.Errorf("Expected header, got %q", diff)
+	}
+	// 两处变化相距较远，应分成两段
+	if strings.Count(diff, "@@\n") != 1 {
+		t.Errorf("Expected two hunks, got:\n%s", diff)
+	}
+	if strings.Contains(diff, " 5\n") {
+		t.Errorf("Expected unchanged lines outside context to be omitted, got:\n%s", diff)
+	}
+}
@@ -0,0 +1,56 @@
+package textwidth
+
+import "unicode"
+
+// TabWidth 制表符按固定的列数计算
+const TabWidth = 4
+
+// wideRanges 终端中占两列的字符范围（中日韩文字、全角符号、emoji等）
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE30, 0xFE4F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x1F300, 0x1F64F},
+	{0x1F680, 0x1F6FF},
+	{0x1F900, 0x1FAFF},
+	{0x20000, 0x3FFFD},
+}
+
+// Rune 字符在终端中占的列数：控制字符、组合符号、变体选择符和零宽字符为0，宽字符为2
+func Rune(r rune) int {
+	switch {
+	case r == '\t':
+		return TabWidth
+	case r < 0x20 || (r >= 0x7F && r < 0xA0):
+		return 0
+	case r == 0x200B || r == 0x200C || r == 0x200D || r == 0xFEFF:
+		return 0
+	case r >= 0xFE00 && r <= 0xFE0F:
+		return 0
+	case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r):
+		return 0
+	}
+	for _, wide := range wideRanges {
+		if r >= wide.lo && r <= wide.hi {
+			return 2
+		}
+	}
+	return 1
+}
+
+// String 文本在终端中的显示宽度
+func String(text string) int {
+	width := 0
+	for _, r := range text {
+		width += Rune(r)
+	}
+	return width
+}
@@ -0,0 +1,36 @@
+package textwidth
+
+import "testing"
+
+func TestRune(t *testing.T) {
+	tests := []struct {
+		r     rune
+		width int
+	}{
+		{'a', 1},
+		{'中', 2},
+		{'，', 2},
+		{'한', 2},
+		{'😀', 2},
+		{'🚀', 2},
+		{'🧪', 2},
+		{'\t', TabWidth},
+		{'\n', 0},
+		{0x7F, 0},
+		{0x0301, 0}, // 组合重音符
+		{0xFE0F, 0}, // 变体选择符
+		{0x200D, 0}, // 零宽连接符
+		{'→', 1},
+	}
+	for _, tt := range tests {
+		if width := Rune(tt.r); width != tt.width {
+			t.Errorf("Rune(%U): expected %d, got %d", tt.r, tt.width, width)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	if width := String("ab中文e\u0301"); width != 7 {
+		t.Errorf("Expected width 7, got %d", width)
+	}
+}
@@ -82,6 +82,18 @@ type Tool interface {
 	ValidateArgs(args ToolCallArguments) error
 }
 
+// TrajectoryAwareTool 需要向轨迹写入记录的工具
+type TrajectoryAwareTool interface {
+	// SetTrajectoryRecorder 设置轨迹记录器
+	SetTrajectoryRecorder(recorder llm.TrajectoryRecorder)
+}
+
+// ResettableTool 在每个任务开始时需要重置状态的工具
+type ResettableTool interface {
+	// Reset 重置工具状态
+	Reset()
+}
+
 // BaseTool 基础工具实现
 type BaseTool struct {
 	name          string
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 )
@@ -13,7 +14,9 @@ import (
 // BashTool Bash工具实现
 type BashTool struct {
 	*BaseTool
-	timeout time.Duration
+	timeout         time.Duration
+	workingDir      string
+	allowedCommands map[string]bool // 允许执行的命令，为空时不限制
 }
 
 // NewBashTool 创建Bash工具
@@ -28,7 +31,7 @@ func NewBashTool() *BashTool {
 		{
 			Name:        "timeout",
 			Type:        "integer",
-			Description: "命令超时时间（秒），默认120秒",
+			Description: "命令超时时间（秒），不能超过配置的超时时间（默认120秒）",
 			Required:    false,
 		},
 	}
@@ -44,6 +47,61 @@ func NewBashTool() *BashTool {
 	}
 }
 
+// SetWorkingDir 设置命令的执行目录
+func (bt *BashTool) SetWorkingDir(dir string) {
+	bt.workingDir = dir
+}
+
+// SetAllowedCommands 限制可以执行的命令，为空时不限制。
+// 只检查管道和命令列表中每条命令的第一个词，用于约束代理而不是安全沙箱
+func (bt *BashTool) SetAllowedCommands(commands []string) {
+	if len(commands) == 0 {
+		bt.allowedCommands = nil
+		return
+	}
+	bt.allowedCommands = make(map[string]bool, len(commands))
+	for _, command := range commands {
+		bt.allowedCommands[command] = true
+	}
+}
+
+// checkAllowed 检查command中的每条命令是否都在允许列表中
+func (bt *BashTool) checkAllowed(command string) error {
+	if bt.allowedCommands == nil {
+		return nil
+	}
+	for _, name := range commandNames(command) {
+		if !bt.allowedCommands[name] {
+			allowed := make([]string, 0, len(bt.allowedCommands))
+			for allowedCommand := range bt.allowedCommands {
+				allowed = append(allowed, allowedCommand)
+			}
+			sort.Strings(allowed)
+			return fmt.Errorf("command '%s' is not allowed, allowed commands: %s", name, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// commandSeparators 把分隔管道、命令列表和子shell的符号替换为换行，重定向中的&不是分隔符
+var commandSeparators = strings.NewReplacer(">&", ">", "&>", ">", "<&", "<", "&&", "\n", "||", "\n", ";", "\n", "|", "\n", "&", "\n", "$(", "\n", "`", "\n", "(", "\n", ")", "\n")
+
+// commandNames 返回command中每条命令的名称，跳过开头的环境变量赋值。
+// 名称按原样返回，不去掉路径，/tmp/echo这样的命令不会被当作echo
+func commandNames(command string) []string {
+	names := make([]string, 0)
+	for _, segment := range strings.Split(commandSeparators.Replace(command), "\n") {
+		for _, word := range strings.Fields(segment) {
+			if strings.Contains(word, "=") && !strings.HasPrefix(word, "=") {
+				continue
+			}
+			names = append(names, word)
+			break
+		}
+	}
+	return names
+}
+
 // Execute 执行Bash命令
 func (bt *BashTool) Execute(ctx context.Context, args ToolCallArguments) (*ToolResult, error) {
 	// 验证参数
@@ -59,15 +117,25 @@ func (bt *BashTool) Execute(ctx context.Context, args ToolCallArguments) (*ToolR
 		}
 	}
 
-	// 检查超时参数
-	if timeoutStr, exists := args["timeout"]; exists {
-		if timeout, ok := timeoutStr.(float64); ok {
-			bt.timeout = time.Duration(timeout) * time.Second
+	// 检查命令是否允许执行
+	if err := bt.checkAllowed(command); err != nil {
+		return &ToolResult{
+			Success: false,
+			Result:  err.Error(),
+			Error:   err.Error(),
+		}, nil
+	}
+
+	// 检查超时参数，只作用于本次执行，且不能超过配置的超时时间
+	timeout := bt.timeout
+	if timeoutArg, exists := args["timeout"]; exists {
+		if seconds, ok := timeoutArg.(float64); ok && seconds > 0 && time.Duration(seconds*float64(time.Second)) < timeout {
+			timeout = time.Duration(seconds * float64(time.Second))
 		}
 	}
 
 	// 创建带超时的上下文
-	ctx, cancel := context.WithTimeout(ctx, bt.timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	// 根据操作系统选择shell
@@ -88,19 +156,30 @@ func (bt *BashTool) Execute(ctx context.Context, args ToolCallArguments) (*ToolR
 	// 创建命令
 	cmd := exec.CommandContext(ctx, shell, shellArgs...)
 	
-	// 设置工作目录为当前目录
-	if wd, err := os.Getwd(); err == nil {
+	// 设置工作目录，未指定时使用当前目录
+	if bt.workingDir != "" {
+		cmd.Dir = bt.workingDir
+	} else if wd, err := os.Getwd(); err == nil {
 		cmd.Dir = wd
 	}
 
-	// 执行命令
-	output, err := cmd.CombinedOutput()
+	// 执行命令，超时或被取消时终止整个进程组
+	output, err := runCommand(cmd)
 	
 	// 检查是否超时
 	if ctx.Err() == context.DeadlineExceeded {
 		return &ToolResult{
 			Success: false,
-			Error:   fmt.Sprintf("command timed out after %v", bt.timeout),
+			Error:   fmt.Sprintf("command timed out after %v", timeout),
+		}, nil
+	}
+
+	// 检查是否被取消（如用户按下Ctrl-C）
+	if ctx.Err() == context.Canceled {
+		return &ToolResult{
+			Success: false,
+			Result:  strings.TrimSpace(string(output)),
+			Error:   "command interrupted",
 		}, nil
 	}
 
@@ -2,7 +2,9 @@ package tools
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewBashTool(t *testing.T) {
@@ -98,3 +100,51 @@ func TestBashTool_ValidateArgs(t *testing.T) {
 		t.Errorf("Expected error for missing required args")
 	}
 }
+
+func TestBashTool_AllowedCommands(t *testing.T) {
+	tool := NewBashTool()
+	tool.SetAllowedCommands([]string{"echo", "tr"})
+	ctx := context.Background()
+
+	// 管道中的每条命令都在允许列表中
+	result, err := tool.Execute(ctx, ToolCallArguments{"command": "LANG=C echo hello 2>&1 | tr a-z A-Z"})
+	if err != nil || !result.Success || result.Result != "HELLO" {
+		t.Fatalf("Expected allowed pipeline to run, got %+v, %v", result, err)
+	}
+
+	// 命令列表和子shell中的命令也要检查
+	// 带路径的命令按原样比较，不能用同名的其他程序绕过
+	for _, command := range []string{"echo ok && rm -rf x", "echo $(cat /etc/passwd)", "/bin/rm x", "/tmp/echo hello", "./echo hello"} {
+		result, err := tool.Execute(ctx, ToolCallArguments{"command": command})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.Success || !strings.Contains(result.Error, "is not allowed") {
+			t.Errorf("Expected %q to be rejected, got %+v", command, result)
+		}
+	}
+}
+
+func TestBashTool_TimeoutArgument(t *testing.T) {
+	tool := NewBashTool()
+	tool.SetTimeout(5 * time.Second)
+
+	// 参数中的超时只作用于本次执行
+	if _, err := tool.Execute(context.Background(), ToolCallArguments{"command": "true", "timeout": float64(1)}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tool.GetTimeout() != 5*time.Second {
+		t.Errorf("Expected timeout to stay 5s, got %v", tool.GetTimeout())
+	}
+
+	// 参数中的超时不能超过配置的超时时间
+	tool.SetTimeout(200 * time.Millisecond)
+	start := time.Now()
+	result, err := tool.Execute(context.Background(), ToolCallArguments{"command": "sleep 5", "timeout": float64(10)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Success || !strings.Contains(result.Error, "timed out after 200ms") || time.Since(start) > 3*time.Second {
+		t.Errorf("Expected the configured timeout to cap the argument, got %+v after %v", result, time.Since(start))
+	}
+}
@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"trage-agent-go/pkg/ckg"
+)
+
+// ckgMaxResponseLen 单次查询返回内容的最大长度
+const ckgMaxResponseLen = 16000
+
+// CKGTool 代码知识图谱工具，用于检索函数、类和类方法
+type CKGTool struct {
+	*BaseTool
+	cacheDir   string
+	workingDir string
+	mutex      sync.Mutex // 同一进程中重复打开索引文件会等待文件锁，查询需串行执行
+}
+
+// NewCKGTool 创建代码知识图谱工具
+func NewCKGTool() *CKGTool {
+	parameters := []ToolParameter{
+		{
+			Name:        "command",
+			Type:        "string",
+			Description: "查询命令：search_function、search_class 或 search_class_method",
+			Enum:        []string{"search_function", "search_class", "search_class_method"},
+			Required:    true,
+		},
+		{
+			Name:        "path",
+			Type:        "string",
+			Description: "要检索的代码库目录的绝对路径",
+			Required:    true,
+		},
+		{
+			Name:        "identifier",
+			Type:        "string",
+			Description: "要查找的函数、类或方法名称",
+			Required:    true,
+		},
+		{
+			Name:        "print_body",
+			Type:        "boolean",
+			Description: "是否输出符号的完整代码，默认true",
+			Required:    false,
+		},
+	}
+
+	return &CKGTool{
+		BaseTool: NewBaseTool(
+			"ckg",
+			"查询代码库的代码知识图谱，按名称查找Go、Python、JavaScript/TypeScript中的函数、类和类方法。索引会持久化并按文件修改时间和哈希增量更新",
+			"",
+			parameters,
+		),
+		cacheDir: ckg.DefaultCacheDir(),
+	}
+}
+
+// SetCacheDir 设置索引缓存目录
+func (ct *CKGTool) SetCacheDir(cacheDir string) {
+	ct.cacheDir = cacheDir
+}
+
+// SetWorkingDir 设置相对路径的解析目录
+func (ct *CKGTool) SetWorkingDir(dir string) {
+	ct.workingDir = dir
+}
+
+// Execute 执行代码知识图谱查询
+func (ct *CKGTool) Execute(ctx context.Context, args ToolCallArguments) (*ToolResult, error) {
+	if err := ct.ValidateArgs(args); err != nil {
+		return nil, err
+	}
+
+	command, _ := args["command"].(string)
+	path, _ := args["path"].(string)
+	path = resolvePath(ct.workingDir, path)
+	identifier, _ := args["identifier"].(string)
+
+	printBody := true
+	if raw, exists := args["print_body"]; exists {
+		if b, ok := raw.(bool); ok {
+			printBody = b
+		}
+	}
+
+	var kind ckg.SymbolKind
+	var label string
+	switch command {
+	case "search_function":
+		kind, label = ckg.SymbolKindFunction, "functions"
+	case "search_class":
+		kind, label = ckg.SymbolKindClass, "classes"
+	case "search_class_method":
+		kind, label = ckg.SymbolKindClassMethod, "class methods"
+	}
+
+	symbols, err := ct.search(path, kind, identifier)
+	if err != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+
+	return &ToolResult{
+		Success: true,
+		Result:  formatSymbols(symbols, label, identifier, printBody),
+	}, nil
+}
+
+// search 打开代码库的索引，增量更新后查询并关闭。索引文件在查询之间不保持打开，
+// 其他代理（如同一工作目录中的批量任务）可以使用同一个索引
+func (ct *CKGTool) search(path string, kind ckg.SymbolKind, identifier string) ([]ckg.Symbol, error) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+
+	index, err := ckg.OpenIndex(path, ct.cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	defer index.Close()
+
+	// 查询前同步代码库的变更，大小和修改时间未变化的文件不会被重新读取
+	if _, err := index.Update(); err != nil {
+		return nil, err
+	}
+	return index.Search(kind, identifier)
+}
+
+// ValidateArgs 验证参数
+func (ct *CKGTool) ValidateArgs(args ToolCallArguments) error {
+	if err := ct.BaseTool.ValidateArgs(args); err != nil {
+		return err
+	}
+
+	command, ok := args["command"].(string)
+	if !ok {
+		return &ToolError{Message: "command must be a string", Code: 400}
+	}
+	switch command {
+	case "search_function", "search_class", "search_class_method":
+	default:
+		return &ToolError{
+			Message: fmt.Sprintf("unsupported command: %s", command),
+			Code:    400,
+		}
+	}
+
+	for _, name := range []string{"path", "identifier"} {
+		value, ok := args[name].(string)
+		if !ok || strings.TrimSpace(value) == "" {
+			return &ToolError{
+				Message: fmt.Sprintf("%s must be a non-empty string", name),
+				Code:    400,
+			}
+		}
+	}
+
+	return nil
+}
+
+// formatSymbols 格式化查询结果
+func formatSymbols(symbols []ckg.Symbol, label, identifier string, printBody bool) string {
+	if len(symbols) == 0 {
+		return fmt.Sprintf("No %s named %s found.", label, identifier)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Found %d %s named %s:\n", len(symbols), label, identifier)
+	for i, symbol := range symbols {
+		entry := fmt.Sprintf("%d. %s:%d-%d", i+1, symbol.FilePath, symbol.StartLine, symbol.EndLine)
+		if symbol.ParentClass != "" {
+			entry += fmt.Sprintf(" (class %s)", symbol.ParentClass)
+		}
+		entry += "\n"
+		if printBody {
+			entry += symbol.Body + "\n\n"
+		}
+
+		if sb.Len()+len(entry) > ckgMaxResponseLen {
+			fmt.Fprintf(&sb, "... %d more result(s) truncated\n", len(symbols)-i)
+			break
+		}
+		sb.WriteString(entry)
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
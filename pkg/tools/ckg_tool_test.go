@@ -0,0 +1,35 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCKGTool_SequentialToolsShareIndex(t *testing.T) {
+	codebase := t.TempDir()
+	cacheDir := t.TempDir()
+	source := "package demo\n\nfunc Add(a, b int) int { return a + b }\n"
+	if err := os.WriteFile(filepath.Join(codebase, "demo.go"), []byte(source), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// 同一工作目录中依次运行的任务各自创建工具，前一个工具不应占用索引文件
+	for i := 0; i < 2; i++ {
+		tool := NewCKGTool()
+		tool.SetCacheDir(cacheDir)
+		result, err := tool.Execute(context.Background(), ToolCallArguments{
+			"command":    "search_function",
+			"path":       codebase,
+			"identifier": "Add",
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !result.Success || !strings.Contains(result.Result, "demo.go") {
+			t.Errorf("Expected query %d to find Add, got %+v", i+1, result)
+		}
+	}
+}
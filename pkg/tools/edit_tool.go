@@ -12,6 +12,7 @@ import (
 // EditTool 文件编辑工具
 type EditTool struct {
 	*BaseTool
+	workingDir string
 }
 
 // NewEditTool 创建编辑工具
@@ -53,6 +54,11 @@ func NewEditTool() *EditTool {
 	}
 }
 
+// SetWorkingDir 设置相对路径的解析目录
+func (et *EditTool) SetWorkingDir(dir string) {
+	et.workingDir = dir
+}
+
 // Execute 执行文件编辑
 func (et *EditTool) Execute(ctx context.Context, args ToolCallArguments) (*ToolResult, error) {
 	// 验证参数
@@ -61,6 +67,7 @@ func (et *EditTool) Execute(ctx context.Context, args ToolCallArguments) (*ToolR
 	}
 
 	filePath, _ := args["file_path"].(string)
+	filePath = resolvePath(et.workingDir, filePath)
 	content, _ := args["content"].(string)
 	
 	// 获取编辑模式，默认为替换
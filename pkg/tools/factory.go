@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"trage-agent-go/pkg/config"
+)
+
+// Factory 根据工具配置创建一个新的工具实例，每个代理使用各自的实例
+type Factory func(toolConfig config.ToolConfig) (Tool, error)
+
+// DefaultToolNames 代理没有配置tools时使用的工具
+var DefaultToolNames = []string{"bash", "edit_file", "sequential_thinking", "task_done"}
+
+var (
+	factoriesMutex sync.RWMutex
+	factories      = map[string]Factory{
+		"bash": func(toolConfig config.ToolConfig) (Tool, error) {
+			tool := NewBashTool()
+			if toolConfig.Timeout > 0 {
+				tool.SetTimeout(time.Duration(toolConfig.Timeout) * time.Second)
+			}
+			tool.SetAllowedCommands(toolConfig.AllowedCommands)
+			return tool, nil
+		},
+		"edit_file": func(config.ToolConfig) (Tool, error) {
+			return NewEditTool(), nil
+		},
+		"sequential_thinking": func(config.ToolConfig) (Tool, error) {
+			return NewSequentialThinkingTool(), nil
+		},
+		"task_done": func(config.ToolConfig) (Tool, error) {
+			return NewTaskDoneTool(), nil
+		},
+		"ckg": func(config.ToolConfig) (Tool, error) {
+			return NewCKGTool(), nil
+		},
+		"go_test": func(toolConfig config.ToolConfig) (Tool, error) {
+			tool := NewGoTestTool()
+			if toolConfig.Timeout > 0 {
+				tool.SetTimeout(time.Duration(toolConfig.Timeout) * time.Second)
+			}
+			return tool, nil
+		},
+	}
+)
+
+// RegisterFactory 注册名为name的工具，之后代理可以在tools中使用该名称；已存在时替换
+func RegisterFactory(name string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+	factories[name] = factory
+}
+
+// FactoryNames 返回所有已注册的工具名称，按名称排序
+func FactoryNames() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewTool 使用toolConfig创建名为name的工具
+func NewTool(name string, toolConfig config.ToolConfig) (Tool, error) {
+	factoriesMutex.RLock()
+	factory, exists := factories[name]
+	factoriesMutex.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unknown tool '%s', available tools: %s", name, strings.Join(FactoryNames(), ", "))
+	}
+
+	tool, err := factory(toolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tool '%s': %w", name, err)
+	}
+	return tool, nil
+}
+
+// NewTools 按names的顺序创建工具，names为空时使用DefaultToolNames，toolConfigs中是每个工具的配置
+func NewTools(names []string, toolConfigs map[string]config.ToolConfig) ([]Tool, error) {
+	if len(names) == 0 {
+		names = DefaultToolNames
+	}
+
+	created := make([]Tool, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		tool, err := NewTool(name, toolConfigs[name])
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, tool)
+	}
+	return created, nil
+}
@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"trage-agent-go/pkg/config"
+)
+
+func TestNewTools(t *testing.T) {
+	// 没有配置工具时使用默认工具
+	defaults, err := NewTools(nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(defaults) != len(DefaultToolNames) {
+		t.Errorf("Expected %d default tools, got %d", len(DefaultToolNames), len(defaults))
+	}
+
+	// 按配置的顺序创建，重复的名称只创建一次，并应用工具配置
+	toolConfigs := map[string]config.ToolConfig{
+		"bash":    {Timeout: 30, AllowedCommands: []string{"go"}},
+		"go_test": {Timeout: 60},
+	}
+	created, err := NewTools([]string{"go_test", "bash", "go_test"}, toolConfigs)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(created) != 2 || created[0].GetName() != "go_test" || created[1].GetName() != "bash" {
+		t.Fatalf("Expected go_test and bash, got %v", created)
+	}
+	if timeout := created[0].(*GoTestTool).timeout; timeout != 60*time.Second {
+		t.Errorf("Expected go_test timeout 60s, got %v", timeout)
+	}
+	bash := created[1].(*BashTool)
+	if bash.GetTimeout() != 30*time.Second || !bash.allowedCommands["go"] {
+		t.Errorf("Expected bash config to be applied, got timeout %v allowed %v", bash.GetTimeout(), bash.allowedCommands)
+	}
+
+	// 每次都创建新的实例，代理之间不共享工具状态
+	again, _ := NewTools([]string{"bash"}, nil)
+	if again[0] == created[1] {
+		t.Error("Expected a new tool instance")
+	}
+
+	// 未知工具的错误列出可用的工具
+	_, err = NewTools([]string{"bash", "browser"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown tool 'browser'") || !strings.Contains(err.Error(), "go_test") {
+		t.Errorf("Expected unknown tool error listing available tools, got %v", err)
+	}
+}
+
+func TestRegisterFactory(t *testing.T) {
+	RegisterFactory("test_echo", func(toolConfig config.ToolConfig) (Tool, error) {
+		return NewTaskDoneTool(), nil
+	})
+	defer func() {
+		factoriesMutex.Lock()
+		delete(factories, "test_echo")
+		factoriesMutex.Unlock()
+	}()
+
+	if _, err := NewTool("test_echo", config.ToolConfig{}); err != nil {
+		t.Errorf("Expected registered tool to be created, got %v", err)
+	}
+	found := false
+	for _, name := range FactoryNames() {
+		found = found || name == "test_echo"
+	}
+	if !found {
+		t.Errorf("Expected test_echo in %v", FactoryNames())
+	}
+}
@@ -0,0 +1,539 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// goTestMaxOutputLines 每个失败测试保留的最大输出行数
+	goTestMaxOutputLines = 40
+	// goTestMaxCompileErrors 最多报告的编译错误数
+	goTestMaxCompileErrors = 30
+)
+
+// CompileError 编译或vet错误
+type CompileError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (ce CompileError) String() string {
+	if ce.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", ce.File, ce.Line, ce.Column, ce.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", ce.File, ce.Line, ce.Message)
+}
+
+// TestFailure 失败的测试
+type TestFailure struct {
+	Package string  `json:"package"`
+	Test    string  `json:"test"`
+	Elapsed float64 `json:"elapsed"`
+	Output  string  `json:"output"`
+}
+
+// PackageTestResult 包级测试结果
+type PackageTestResult struct {
+	Package  string   `json:"package"`
+	Status   string   `json:"status"` // pass, fail, skip
+	Passed   int      `json:"passed"`
+	Failed   int      `json:"failed"`
+	Skipped  int      `json:"skipped"`
+	Elapsed  float64  `json:"elapsed"`
+	Coverage *float64 `json:"coverage,omitempty"`
+	Output   string   `json:"output,omitempty"` // 非测试输出（如构建失败、panic）
+}
+
+// GoTestReport go test -json 的解析结果
+type GoTestReport struct {
+	Packages    []*PackageTestResult `json:"packages"`
+	Failures    []TestFailure        `json:"failures"`
+	BuildErrors []CompileError       `json:"build_errors,omitempty"`
+}
+
+// goTestEvent go test -json 输出的事件
+type goTestEvent struct {
+	Time    time.Time `json:"Time"`
+	Action  string    `json:"Action"`
+	Package string    `json:"Package"`
+	Test    string    `json:"Test"`
+	Elapsed float64   `json:"Elapsed"`
+	Output  string    `json:"Output"`
+}
+
+var (
+	compileErrorPattern = regexp.MustCompile(`^(?:vet: )?([^\s:][^:]*\.go):(\d+)(?::(\d+))?: (.+)$`)
+	coveragePattern     = regexp.MustCompile(`coverage: ([0-9.]+)% of statements`)
+)
+
+// ParseCompileErrors 从go build/go vet的输出中提取带文件位置的错误
+func ParseCompileErrors(output string) []CompileError {
+	errors := make([]CompileError, 0)
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		m := compileErrorPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		ce := CompileError{File: m[1], Message: m[4]}
+		fmt.Sscanf(m[2], "%d", &ce.Line)
+		if m[3] != "" {
+			fmt.Sscanf(m[3], "%d", &ce.Column)
+		}
+		if seen[ce.String()] {
+			continue
+		}
+		seen[ce.String()] = true
+		errors = append(errors, ce)
+	}
+	return errors
+}
+
+// ParseGoTestJSON 解析go test -json的事件流
+func ParseGoTestJSON(r io.Reader) (*GoTestReport, error) {
+	report := &GoTestReport{
+		Packages: make([]*PackageTestResult, 0),
+		Failures: make([]TestFailure, 0),
+	}
+	packages := make(map[string]*PackageTestResult)
+	testOutput := make(map[string]*strings.Builder)
+	pkgOutput := make(map[string]*strings.Builder)
+	nonJSON := &strings.Builder{}
+
+	getPackage := func(name string) *PackageTestResult {
+		if pkg, exists := packages[name]; exists {
+			return pkg
+		}
+		pkg := &PackageTestResult{Package: name}
+		packages[name] = pkg
+		report.Packages = append(report.Packages, pkg)
+		return pkg
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var event goTestEvent
+		if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &event) != nil {
+			// 构建失败时go test会输出非JSON内容
+			nonJSON.Write(line)
+			nonJSON.WriteByte('\n')
+			continue
+		}
+		// Go 1.24+ 将构建输出也编码为JSON事件
+		if event.Action == "build-output" {
+			nonJSON.WriteString(event.Output)
+			continue
+		}
+		if event.Package == "" {
+			continue
+		}
+
+		pkg := getPackage(event.Package)
+		key := event.Package + "\x00" + event.Test
+
+		switch event.Action {
+		case "output":
+			if event.Test != "" {
+				if testOutput[key] == nil {
+					testOutput[key] = &strings.Builder{}
+				}
+				testOutput[key].WriteString(event.Output)
+			} else {
+				if m := coveragePattern.FindStringSubmatch(event.Output); m != nil {
+					var coverage float64
+					fmt.Sscanf(m[1], "%f", &coverage)
+					pkg.Coverage = &coverage
+				}
+				if pkgOutput[event.Package] == nil {
+					pkgOutput[event.Package] = &strings.Builder{}
+				}
+				pkgOutput[event.Package].WriteString(event.Output)
+			}
+		case "pass", "fail", "skip":
+			if event.Test == "" {
+				pkg.Status = event.Action
+				pkg.Elapsed = event.Elapsed
+				continue
+			}
+			switch event.Action {
+			case "pass":
+				pkg.Passed++
+			case "skip":
+				pkg.Skipped++
+			case "fail":
+				pkg.Failed++
+				output := ""
+				if testOutput[key] != nil {
+					output = testOutput[key].String()
+				}
+				report.Failures = append(report.Failures, TestFailure{
+					Package: event.Package,
+					Test:    event.Test,
+					Elapsed: event.Elapsed,
+					Output:  output,
+				})
+			}
+			delete(testOutput, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read go test output: %w", err)
+	}
+
+	// 失败但没有失败测试的包（构建失败、panic、TestMain错误等）保留包级输出
+	for _, pkg := range report.Packages {
+		if pkg.Status == "fail" && pkg.Failed == 0 && pkgOutput[pkg.Package] != nil {
+			pkg.Output = pkgOutput[pkg.Package].String()
+		}
+	}
+	report.BuildErrors = ParseCompileErrors(nonJSON.String())
+
+	// 子测试失败时父测试也会失败，保留最具体的失败
+	report.Failures = dropParentFailures(report.Failures)
+
+	return report, nil
+}
+
+// dropParentFailures 去掉仅因子测试失败而失败的父测试
+func dropParentFailures(failures []TestFailure) []TestFailure {
+	result := make([]TestFailure, 0, len(failures))
+	for _, f := range failures {
+		hasChild := false
+		for _, other := range failures {
+			if other.Package == f.Package && strings.HasPrefix(other.Test, f.Test+"/") {
+				hasChild = true
+				break
+			}
+		}
+		if !hasChild {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// GoTestTool Go构建与测试工具，返回结构化摘要而不是原始输出
+type GoTestTool struct {
+	*BaseTool
+	timeout    time.Duration
+	workingDir string
+}
+
+// NewGoTestTool 创建Go测试工具
+func NewGoTestTool() *GoTestTool {
+	parameters := []ToolParameter{
+		{
+			Name:        "packages",
+			Type:        "array",
+			Description: "要检查的包模式列表，默认 [\"./...\"]",
+			Items:       map[string]interface{}{"type": "string"},
+			Required:    false,
+		},
+		{
+			Name:        "run",
+			Type:        "string",
+			Description: "只运行匹配该正则的测试（go test -run）",
+			Required:    false,
+		},
+		{
+			Name:        "build",
+			Type:        "boolean",
+			Description: "是否先执行go build，默认true",
+			Required:    false,
+		},
+		{
+			Name:        "vet",
+			Type:        "boolean",
+			Description: "是否执行go vet，默认true",
+			Required:    false,
+		},
+		{
+			Name:        "test",
+			Type:        "boolean",
+			Description: "是否执行go test，默认true",
+			Required:    false,
+		},
+		{
+			Name:        "coverage",
+			Type:        "boolean",
+			Description: "是否统计覆盖率，默认false",
+			Required:    false,
+		},
+		{
+			Name:        "working_dir",
+			Type:        "string",
+			Description: "执行命令的目录（包含go.mod的目录），默认当前目录",
+			Required:    false,
+		},
+	}
+
+	return &GoTestTool{
+		BaseTool: NewBaseTool(
+			"go_test",
+			"运行go build、go vet和go test -json，返回结构化摘要：编译错误（文件:行号）、失败测试及其输出、覆盖率。通过的测试只计数不输出",
+			"",
+			parameters,
+		),
+		timeout: 10 * time.Minute,
+	}
+}
+
+// SetTimeout 设置超时时间
+func (gt *GoTestTool) SetTimeout(timeout time.Duration) {
+	gt.timeout = timeout
+}
+
+// SetWorkingDir 设置默认的执行目录
+func (gt *GoTestTool) SetWorkingDir(dir string) {
+	gt.workingDir = dir
+}
+
+// ValidateArgs 验证参数，包模式不能以-开头，否则会被go命令当作选项
+func (gt *GoTestTool) ValidateArgs(args ToolCallArguments) error {
+	if err := gt.BaseTool.ValidateArgs(args); err != nil {
+		return err
+	}
+
+	for _, pkg := range stringSliceArg(args, "packages") {
+		if strings.HasPrefix(pkg, "-") {
+			return &ToolError{
+				Message: fmt.Sprintf("invalid package pattern: %s", pkg),
+				Code:    400,
+			}
+		}
+	}
+
+	return nil
+}
+
+// Execute 执行Go构建与测试
+func (gt *GoTestTool) Execute(ctx context.Context, args ToolCallArguments) (*ToolResult, error) {
+	if err := gt.ValidateArgs(args); err != nil {
+		return nil, err
+	}
+
+	packages := stringSliceArg(args, "packages")
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+	runPattern, _ := args["run"].(string)
+	workingDir, _ := args["working_dir"].(string)
+	if workingDir == "" {
+		workingDir = gt.workingDir
+	} else {
+		workingDir = resolvePath(gt.workingDir, workingDir)
+	}
+	doBuild := boolArg(args, "build", true)
+	doVet := boolArg(args, "vet", true)
+	doTest := boolArg(args, "test", true)
+	coverage := boolArg(args, "coverage", false)
+
+	ctx, cancel := context.WithTimeout(ctx, gt.timeout)
+	defer cancel()
+
+	var sb strings.Builder
+	success := true
+
+	if doBuild {
+		output, err := gt.runGo(ctx, workingDir, append([]string{"build"}, packages...)...)
+		if ctx.Err() != nil {
+			return gt.timeoutResult(), nil
+		}
+		if err != nil {
+			writeCompileErrors(&sb, "go build", output)
+			// 构建失败时测试必然失败，直接返回
+			return &ToolResult{Success: false, Result: strings.TrimSpace(sb.String())}, nil
+		}
+		sb.WriteString("go build: ok\n")
+	}
+
+	if doVet {
+		output, err := gt.runGo(ctx, workingDir, append([]string{"vet"}, packages...)...)
+		if ctx.Err() != nil {
+			return gt.timeoutResult(), nil
+		}
+		if err != nil {
+			success = false
+			writeCompileErrors(&sb, "go vet", output)
+		} else {
+			sb.WriteString("go vet: ok\n")
+		}
+	}
+
+	if doTest {
+		testArgs := []string{"test", "-json"}
+		if coverage {
+			testArgs = append(testArgs, "-cover")
+		}
+		if runPattern != "" {
+			testArgs = append(testArgs, "-run", runPattern)
+		}
+		testArgs = append(testArgs, packages...)
+
+		output, err := gt.runGo(ctx, workingDir, testArgs...)
+		if ctx.Err() != nil {
+			return gt.timeoutResult(), nil
+		}
+		report, parseErr := ParseGoTestJSON(bytes.NewReader(output))
+		if parseErr != nil {
+			return nil, &ToolError{Message: parseErr.Error(), Code: 500}
+		}
+		if err != nil {
+			success = false
+		}
+		writeTestReport(&sb, report)
+	}
+
+	return &ToolResult{
+		Success: success,
+		Result:  strings.TrimSpace(sb.String()),
+	}, nil
+}
+
+// runGo 执行go子命令，返回合并输出
+func (gt *GoTestTool) runGo(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	if dir != "" {
+		cmd.Dir = dir
+	}
+	return runCommand(cmd)
+}
+
+// timeoutResult 超时结果
+func (gt *GoTestTool) timeoutResult() *ToolResult {
+	return &ToolResult{
+		Success: false,
+		Error:   fmt.Sprintf("go command timed out after %v", gt.timeout),
+	}
+}
+
+// writeCompileErrors 写入编译错误摘要
+func writeCompileErrors(sb *strings.Builder, step string, output []byte) {
+	errors := ParseCompileErrors(string(output))
+	if len(errors) == 0 {
+		fmt.Fprintf(sb, "%s: failed\n%s\n", step, truncateLines(strings.TrimSpace(string(output)), goTestMaxOutputLines))
+		return
+	}
+
+	fmt.Fprintf(sb, "%s: %d error(s)\n", step, len(errors))
+	for i, ce := range errors {
+		if i >= goTestMaxCompileErrors {
+			fmt.Fprintf(sb, "  ... %d more\n", len(errors)-i)
+			break
+		}
+		fmt.Fprintf(sb, "  %s\n", ce)
+	}
+}
+
+// writeTestReport 写入测试摘要
+func writeTestReport(sb *strings.Builder, report *GoTestReport) {
+	passed, failed, skipped := 0, 0, 0
+	failedPkgs := make([]*PackageTestResult, 0)
+	for _, pkg := range report.Packages {
+		passed += pkg.Passed
+		failed += pkg.Failed
+		skipped += pkg.Skipped
+		if pkg.Status == "fail" {
+			failedPkgs = append(failedPkgs, pkg)
+		}
+	}
+
+	status := "ok"
+	if len(failedPkgs) > 0 || len(report.BuildErrors) > 0 {
+		status = "FAIL"
+	}
+	fmt.Fprintf(sb, "go test: %s (%d packages, %d passed, %d failed, %d skipped)\n",
+		status, len(report.Packages), passed, failed, skipped)
+
+	if len(report.BuildErrors) > 0 {
+		fmt.Fprintf(sb, "\nbuild errors:\n")
+		for i, ce := range report.BuildErrors {
+			if i >= goTestMaxCompileErrors {
+				fmt.Fprintf(sb, "  ... %d more\n", len(report.BuildErrors)-i)
+				break
+			}
+			fmt.Fprintf(sb, "  %s\n", ce)
+		}
+	}
+
+	for _, f := range report.Failures {
+		fmt.Fprintf(sb, "\n--- FAIL: %s (%s, %.2fs)\n", f.Test, f.Package, f.Elapsed)
+		sb.WriteString(truncateLines(strings.TrimRight(f.Output, "\n"), goTestMaxOutputLines))
+		sb.WriteString("\n")
+	}
+
+	for _, pkg := range failedPkgs {
+		if pkg.Output != "" {
+			fmt.Fprintf(sb, "\n--- FAIL: package %s\n", pkg.Package)
+			sb.WriteString(truncateLines(strings.TrimRight(pkg.Output, "\n"), goTestMaxOutputLines))
+			sb.WriteString("\n")
+		}
+	}
+
+	covered := make([]*PackageTestResult, 0)
+	for _, pkg := range report.Packages {
+		if pkg.Coverage != nil {
+			covered = append(covered, pkg)
+		}
+	}
+	if len(covered) > 0 {
+		sort.Slice(covered, func(i, j int) bool { return covered[i].Package < covered[j].Package })
+		sb.WriteString("\ncoverage:\n")
+		for _, pkg := range covered {
+			fmt.Fprintf(sb, "  %s: %.1f%%\n", pkg.Package, *pkg.Coverage)
+		}
+	}
+}
+
+// truncateLines 保留输出的最后若干行
+func truncateLines(text string, maxLines int) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) <= maxLines {
+		return text
+	}
+	omitted := len(lines) - maxLines
+	return fmt.Sprintf("... (%d lines omitted)\n%s", omitted, strings.Join(lines[omitted:], "\n"))
+}
+
+// stringSliceArg 读取字符串数组参数，也接受空格分隔的字符串
+func stringSliceArg(args ToolCallArguments, name string) []string {
+	switch v := args[name].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+				result = append(result, strings.TrimSpace(s))
+			}
+		}
+		return result
+	case string:
+		return strings.Fields(v)
+	default:
+		return nil
+	}
+}
+
+// boolArg 读取布尔参数
+func boolArg(args ToolCallArguments, name string, defaultValue bool) bool {
+	if b, ok := args[name].(bool); ok {
+		return b
+	}
+	return defaultValue
+}
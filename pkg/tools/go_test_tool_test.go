@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCompileErrors(t *testing.T) {
+	output := `# example.com/demo
+./main.go:10:2: undefined: foo
+./main.go:10:2: undefined: foo
+pkg/util.go:3: imported and not used: "os"
+vet: ./x.go:5:1: unreachable code
+some unrelated line
+`
+	errors := ParseCompileErrors(output)
+	if len(errors) != 3 {
+		t.Fatalf("Expected 3 errors, got %d: %v", len(errors), errors)
+	}
+
+	if errors[0].File != "./main.go" || errors[0].Line != 10 || errors[0].Column != 2 {
+		t.Errorf("Unexpected first error: %+v", errors[0])
+	}
+	if errors[1].Column != 0 || errors[1].Message != `imported and not used: "os"` {
+		t.Errorf("Unexpected second error: %+v", errors[1])
+	}
+	if errors[2].File != "./x.go" {
+		t.Errorf("Expected vet prefix to be stripped, got %s", errors[2].File)
+	}
+}
+
+func TestParseGoTestJSON(t *testing.T) {
+	stream := `{"Action":"run","Package":"demo","Test":"TestOK"}
+{"Action":"output","Package":"demo","Test":"TestOK","Output":"=== RUN   TestOK\n"}
+{"Action":"pass","Package":"demo","Test":"TestOK","Elapsed":0.01}
+{"Action":"run","Package":"demo","Test":"TestBad"}
+{"Action":"run","Package":"demo","Test":"TestBad/case"}
+{"Action":"output","Package":"demo","Test":"TestBad/case","Output":"    bad_test.go:12: expected 1, got 2\n"}
+{"Action":"fail","Package":"demo","Test":"TestBad/case","Elapsed":0}
+{"Action":"fail","Package":"demo","Test":"TestBad","Elapsed":0}
+{"Action":"skip","Package":"demo","Test":"TestSkip","Elapsed":0}
+{"Action":"output","Package":"demo","Output":"coverage: 75.5% of statements\n"}
+{"Action":"fail","Package":"demo","Elapsed":0.2}
+{"Action":"output","Package":"other","Output":"?   \tother\t[no test files]\n"}
+{"Action":"skip","Package":"other","Elapsed":0}
+`
+	report, err := ParseGoTestJSON(strings.NewReader(stream))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	if len(report.Packages) != 2 {
+		t.Fatalf("Expected 2 packages, got %d", len(report.Packages))
+	}
+
+	demo := report.Packages[0]
+	if demo.Status != "fail" || demo.Passed != 1 || demo.Failed != 2 || demo.Skipped != 1 {
+		t.Errorf("Unexpected package result: %+v", demo)
+	}
+	if demo.Coverage == nil || *demo.Coverage != 75.5 {
+		t.Errorf("Expected coverage 75.5, got %v", demo.Coverage)
+	}
+
+	// 父测试的失败应被子测试的失败取代
+	if len(report.Failures) != 1 {
+		t.Fatalf("Expected 1 failure, got %d", len(report.Failures))
+	}
+	if report.Failures[0].Test != "TestBad/case" || !strings.Contains(report.Failures[0].Output, "expected 1, got 2") {
+		t.Errorf("Unexpected failure: %+v", report.Failures[0])
+	}
+}
+
+func TestGoTestTool_Execute(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module demo\n\ngo 1.21\n",
+		"demo.go":      "package demo\n\nfunc Add(a, b int) int { return a - b }\n",
+		"demo_test.go": "package demo\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(1, 2) != 3 {\n\t\tt.Errorf(\"Add(1, 2) = %d\", Add(1, 2))\n\t}\n}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tool := NewGoTestTool()
+	result, err := tool.Execute(context.Background(), ToolCallArguments{
+		"working_dir": dir,
+		"packages":    []interface{}{"./..."},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result.Success {
+		t.Errorf("Expected failure for failing test")
+	}
+	if !strings.Contains(result.Result, "--- FAIL: TestAdd") || !strings.Contains(result.Result, "Add(1, 2) = -1") {
+		t.Errorf("Expected failing test output in summary, got:\n%s", result.Result)
+	}
+	if !strings.Contains(result.Result, "go build: ok") {
+		t.Errorf("Expected build step in summary, got:\n%s", result.Result)
+	}
+}
+
+func TestGoTestTool_RejectsFlagPackages(t *testing.T) {
+	tool := NewGoTestTool()
+	// 以-开头的包模式会被go命令当作选项，必须在执行前拒绝
+	for _, pkg := range []string{"-exec=sh", "-toolexec=touch /tmp/x", "--help"} {
+		_, err := tool.Execute(context.Background(), ToolCallArguments{
+			"working_dir": t.TempDir(),
+			"packages":    []interface{}{"./...", pkg},
+		})
+		var toolErr *ToolError
+		if !errors.As(err, &toolErr) || toolErr.Code != 400 {
+			t.Errorf("Expected package %q to be rejected, got %v", pkg, err)
+		}
+	}
+}
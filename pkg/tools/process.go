@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// processWaitDelay 命令退出或被终止后，等待残留子进程释放输出管道的最长时间
+const processWaitDelay = time.Second
+
+var (
+	// processGroups 工具启动的、仍在运行的进程组
+	processGroups = make(map[int]struct{})
+	processMutex  sync.Mutex
+)
+
+// runCommand 运行命令并返回合并的输出。命令在独立的进程组中运行：ctx被取消或超时时终止整个进程组，
+// 命令结束后仍在后台运行的子进程也会被终止
+func runCommand(cmd *exec.Cmd) ([]byte, error) {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.WaitDelay = processWaitDelay
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	pid := cmd.Process.Pid
+	trackProcessGroup(pid, true)
+	defer trackProcessGroup(pid, false)
+
+	err := cmd.Wait()
+	killProcessGroup(pid)
+	// 命令本身成功退出，只是后台子进程仍占用输出管道
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil
+	}
+	return output.Bytes(), err
+}
+
+// trackProcessGroup 记录或移除正在运行的进程组
+func trackProcessGroup(pid int, running bool) {
+	processMutex.Lock()
+	defer processMutex.Unlock()
+	if running {
+		processGroups[pid] = struct{}{}
+	} else {
+		delete(processGroups, pid)
+	}
+}
+
+// KillProcessGroups 终止工具启动的所有仍在运行的进程组，用于强制退出前清理子进程
+func KillProcessGroups() {
+	processMutex.Lock()
+	defer processMutex.Unlock()
+	for pid := range processGroups {
+		killProcessGroup(pid)
+	}
+}
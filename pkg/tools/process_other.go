@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package tools
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup 不支持进程组的平台上只终止命令本身
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup 终止进程
+func killProcessGroup(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
@@ -0,0 +1,71 @@
+//go:build linux
+
+package tools
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// processAlive 检查进程是否仍在运行（僵尸进程视为已结束）
+func processAlive(pid int) bool {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(data))
+	return len(fields) > 2 && fields[2] != "Z"
+}
+
+// waitForExit 等待进程结束
+func waitForExit(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected process %d to be killed", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestBashTool_KillsBackgroundProcesses(t *testing.T) {
+	result, err := NewBashTool().Execute(context.Background(), ToolCallArguments{"command": "sleep 30 & echo $!"})
+	if err != nil || !result.Success {
+		t.Fatalf("Expected success, got %+v (%v)", result, err)
+	}
+	pid, err := strconv.Atoi(result.Result)
+	if err != nil {
+		t.Fatalf("Expected pid in output, got %q", result.Result)
+	}
+	waitForExit(t, pid)
+}
+
+func TestBashTool_Cancel(t *testing.T) {
+	pidFile := t.TempDir() + "/pid"
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+
+	start := time.Now()
+	result, err := NewBashTool().Execute(ctx, ToolCallArguments{"command": "sleep 30 & echo $! > " + pidFile + "; sleep 30"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Success || result.Error != "command interrupted" {
+		t.Errorf("Expected interrupted result, got %+v", result)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected command to stop promptly, took %v", elapsed)
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("Failed to read pid file: %v", err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	waitForExit(t, pid)
+}
@@ -2,12 +2,46 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"trage-agent-go/pkg/llm"
 )
 
+// ThoughtData 单条思考记录
+type ThoughtData struct {
+	Thought           string `json:"thought"`
+	ThoughtNumber     int    `json:"thought_number"`
+	TotalThoughts     int    `json:"total_thoughts"`
+	NextThoughtNeeded bool   `json:"next_thought_needed"`
+	IsRevision        bool   `json:"is_revision,omitempty"`
+	RevisesThought    int    `json:"revises_thought,omitempty"`
+	BranchFromThought int    `json:"branch_from_thought,omitempty"`
+	BranchID          string `json:"branch_id,omitempty"`
+	NeedsMoreThoughts bool   `json:"needs_more_thoughts,omitempty"`
+}
+
+// ThinkingSummary 思考过程的结构化摘要
+type ThinkingSummary struct {
+	ThoughtNumber        int           `json:"thought_number"`
+	TotalThoughts        int           `json:"total_thoughts"`
+	NextThoughtNeeded    bool          `json:"next_thought_needed"`
+	Branches             []string      `json:"branches"`
+	ThoughtHistoryLength int           `json:"thought_history_length"`
+	ThoughtHistory       []ThoughtData `json:"thought_history,omitempty"` // 思考结束时返回完整历史
+}
+
 // SequentialThinkingTool 顺序思考工具实现
 type SequentialThinkingTool struct {
 	*BaseTool
+	thoughtHistory []ThoughtData
+	branches       map[string][]ThoughtData
+	recorder       llm.TrajectoryRecorder
+	mutex          sync.Mutex
 }
 
 // NewSequentialThinkingTool 创建顺序思考工具
@@ -16,19 +50,55 @@ func NewSequentialThinkingTool() *SequentialThinkingTool {
 		{
 			Name:        "thought",
 			Type:        "string",
-			Description: "思考内容，描述当前步骤的思考过程",
+			Description: "当前的思考内容：分析、假设、验证、对之前思考的修正等",
+			Required:    true,
+		},
+		{
+			Name:        "next_thought_needed",
+			Type:        "boolean",
+			Description: "是否还需要继续思考",
 			Required:    true,
 		},
 		{
-			Name:        "step_number",
+			Name:        "thought_number",
 			Type:        "integer",
-			Description: "当前步骤编号",
+			Description: "当前思考编号（从1开始，可以超过最初估计的总数）",
 			Required:    true,
 		},
 		{
-			Name:        "total_steps",
+			Name:        "total_thoughts",
+			Type:        "integer",
+			Description: "当前估计需要的思考总数，可随时上调或下调",
+			Required:    true,
+		},
+		{
+			Name:        "is_revision",
+			Type:        "boolean",
+			Description: "本次思考是否修正之前的思考",
+			Required:    false,
+		},
+		{
+			Name:        "revises_thought",
 			Type:        "integer",
-			Description: "总步骤数",
+			Description: "被修正的思考编号（is_revision为true时使用）",
+			Required:    false,
+		},
+		{
+			Name:        "branch_from_thought",
+			Type:        "integer",
+			Description: "分支起点的思考编号",
+			Required:    false,
+		},
+		{
+			Name:        "branch_id",
+			Type:        "string",
+			Description: "分支标识",
+			Required:    false,
+		},
+		{
+			Name:        "needs_more_thoughts",
+			Type:        "boolean",
+			Description: "到达预计终点后是否发现还需要更多思考",
 			Required:    false,
 		},
 	}
@@ -36,55 +106,304 @@ func NewSequentialThinkingTool() *SequentialThinkingTool {
 	return &SequentialThinkingTool{
 		BaseTool: NewBaseTool(
 			"sequential_thinking",
-			"记录顺序思考过程，帮助代理进行结构化思考",
+			`通过可动态调整、可修正的顺序思考来分析问题。
+- 可以随时调整total_thoughts，也可以在到达预计终点后继续思考
+- 可以通过is_revision/revises_thought修正之前的思考
+- 可以通过branch_from_thought/branch_id从某一步分出新的思路
+- 只有在得到满意的结论后才将next_thought_needed设为false`,
 			"sequential_thinking",
 			parameters,
 		),
+		thoughtHistory: make([]ThoughtData, 0),
+		branches:       make(map[string][]ThoughtData),
+	}
+}
+
+// SetTrajectoryRecorder 设置轨迹记录器，思考过程将写入轨迹
+func (stt *SequentialThinkingTool) SetTrajectoryRecorder(recorder llm.TrajectoryRecorder) {
+	stt.mutex.Lock()
+	defer stt.mutex.Unlock()
+	stt.recorder = recorder
+}
+
+// Reset 清空本次运行的思考历史
+func (stt *SequentialThinkingTool) Reset() {
+	stt.mutex.Lock()
+	defer stt.mutex.Unlock()
+	stt.thoughtHistory = make([]ThoughtData, 0)
+	stt.branches = make(map[string][]ThoughtData)
+}
+
+// thinkingState 随会话保存的思考状态
+type thinkingState struct {
+	ThoughtHistory []ThoughtData            `json:"thought_history"`
+	Branches       map[string][]ThoughtData `json:"branches,omitempty"`
+}
+
+// SaveState 导出思考历史和分支
+func (stt *SequentialThinkingTool) SaveState() (json.RawMessage, error) {
+	stt.mutex.Lock()
+	defer stt.mutex.Unlock()
+	return json.Marshal(thinkingState{ThoughtHistory: stt.thoughtHistory, Branches: stt.branches})
+}
+
+// RestoreState 恢复SaveState导出的思考历史和分支
+func (stt *SequentialThinkingTool) RestoreState(state json.RawMessage) error {
+	var restored thinkingState
+	if err := json.Unmarshal(state, &restored); err != nil {
+		return fmt.Errorf("invalid sequential thinking state: %w", err)
 	}
+	if restored.ThoughtHistory == nil {
+		restored.ThoughtHistory = make([]ThoughtData, 0)
+	}
+	if restored.Branches == nil {
+		restored.Branches = make(map[string][]ThoughtData)
+	}
+
+	stt.mutex.Lock()
+	defer stt.mutex.Unlock()
+	stt.thoughtHistory = restored.ThoughtHistory
+	stt.branches = restored.Branches
+	return nil
+}
+
+// GetThoughtHistory 获取思考历史
+func (stt *SequentialThinkingTool) GetThoughtHistory() []ThoughtData {
+	stt.mutex.Lock()
+	defer stt.mutex.Unlock()
+	history := make([]ThoughtData, len(stt.thoughtHistory))
+	copy(history, stt.thoughtHistory)
+	return history
+}
+
+// GetBranches 获取所有分支的标识
+func (stt *SequentialThinkingTool) GetBranches() []string {
+	stt.mutex.Lock()
+	defer stt.mutex.Unlock()
+	return stt.branchIDs()
 }
 
 // Execute 执行顺序思考
 func (stt *SequentialThinkingTool) Execute(ctx context.Context, args ToolCallArguments) (*ToolResult, error) {
-	// 验证参数
-	if err := stt.ValidateArgs(args); err != nil {
+	thought, err := stt.parseThought(args)
+	if err != nil {
 		return nil, err
 	}
 
-	thought, ok := args["thought"].(string)
-	if !ok {
-		return nil, &ToolError{
-			Message: "thought parameter must be a string",
-			Code:    400,
+	stt.mutex.Lock()
+	// 思考编号超过预估总数时自动上调
+	if thought.ThoughtNumber > thought.TotalThoughts {
+		thought.TotalThoughts = thought.ThoughtNumber
+	}
+
+	stt.thoughtHistory = append(stt.thoughtHistory, *thought)
+	if thought.BranchFromThought > 0 && thought.BranchID != "" {
+		stt.branches[thought.BranchID] = append(stt.branches[thought.BranchID], *thought)
+	}
+
+	summary := ThinkingSummary{
+		ThoughtNumber:        thought.ThoughtNumber,
+		TotalThoughts:        thought.TotalThoughts,
+		NextThoughtNeeded:    thought.NextThoughtNeeded,
+		Branches:             stt.branchIDs(),
+		ThoughtHistoryLength: len(stt.thoughtHistory),
+	}
+	if !thought.NextThoughtNeeded {
+		summary.ThoughtHistory = make([]ThoughtData, len(stt.thoughtHistory))
+		copy(summary.ThoughtHistory, stt.thoughtHistory)
+	}
+	recorder := stt.recorder
+	stt.mutex.Unlock()
+
+	// 思考内容写入轨迹而不是直接打印。思考已经保存到历史中，写入失败只输出警告，
+	// 否则模型会认为思考失败而重复提交
+	if recorder != nil {
+		if err := recorder.RecordMessage(thoughtMessage(thought)); err != nil {
+			llm.Warnf(ctx, "failed to record thought: %v", err)
 		}
 	}
 
-	stepNumber, ok := args["step_number"].(float64)
-	if !ok {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
 		return nil, &ToolError{
-			Message: "step_number parameter must be a number",
-			Code:    400,
+			Message: fmt.Sprintf("failed to marshal thinking summary: %v", err),
+			Code:    500,
 		}
 	}
 
-	totalSteps := "未知"
-	if totalStepsRaw, exists := args["total_steps"]; exists {
-		if total, ok := totalStepsRaw.(float64); ok {
-			totalSteps = fmt.Sprintf("%.0f", total)
+	return &ToolResult{
+		Success: true,
+		Result:  fmt.Sprintf("Sequential thinking step completed.\n\nStatus:\n%s", data),
+	}, nil
+}
+
+// ValidateArgs 验证参数
+func (stt *SequentialThinkingTool) ValidateArgs(args ToolCallArguments) error {
+	_, err := stt.parseThought(args)
+	return err
+}
+
+// parseThought 解析并验证思考参数
+func (stt *SequentialThinkingTool) parseThought(args ToolCallArguments) (*ThoughtData, error) {
+	// 兼容旧版参数名
+	args = withLegacyThinkingArgs(args)
+
+	if err := stt.BaseTool.ValidateArgs(args); err != nil {
+		return nil, err
+	}
+
+	thought, ok := args["thought"].(string)
+	if !ok || strings.TrimSpace(thought) == "" {
+		return nil, &ToolError{Message: "thought must be a non-empty string", Code: 400}
+	}
+
+	data := &ThoughtData{Thought: thought}
+
+	var err error
+	if data.NextThoughtNeeded, err = thinkingBool(args, "next_thought_needed"); err != nil {
+		return nil, err
+	}
+	if data.ThoughtNumber, err = thinkingInt(args, "thought_number", 1); err != nil {
+		return nil, err
+	}
+	if data.TotalThoughts, err = thinkingInt(args, "total_thoughts", 1); err != nil {
+		return nil, err
+	}
+
+	if _, exists := args["is_revision"]; exists {
+		if data.IsRevision, err = thinkingBool(args, "is_revision"); err != nil {
+			return nil, err
+		}
+	}
+	if _, exists := args["needs_more_thoughts"]; exists {
+		if data.NeedsMoreThoughts, err = thinkingBool(args, "needs_more_thoughts"); err != nil {
+			return nil, err
+		}
+	}
+	if _, exists := args["revises_thought"]; exists {
+		if data.RevisesThought, err = thinkingInt(args, "revises_thought", 1); err != nil {
+			return nil, err
+		}
+	}
+	if _, exists := args["branch_from_thought"]; exists {
+		if data.BranchFromThought, err = thinkingInt(args, "branch_from_thought", 1); err != nil {
+			return nil, err
+		}
+	}
+	if raw, exists := args["branch_id"]; exists && raw != nil {
+		branchID, ok := raw.(string)
+		if !ok {
+			return nil, &ToolError{Message: "branch_id must be a string", Code: 400}
 		}
+		data.BranchID = branchID
 	}
 
-	// 格式化思考输出
-	output := fmt.Sprintf("🤔 思考步骤 %.0f", stepNumber)
-	if totalSteps != "未知" {
-		output += fmt.Sprintf("/%s", totalSteps)
+	if data.RevisesThought > 0 && !data.IsRevision {
+		data.IsRevision = true
 	}
-	output += fmt.Sprintf(": %s", thought)
 
-	// 记录思考过程（这里可以扩展为保存到文件或数据库）
-	fmt.Println(output)
+	return data, nil
+}
 
-	return &ToolResult{
-		Success: true,
-		Result:  output,
-	}, nil
+// branchIDs 获取排序后的分支标识（调用方需持有锁）
+func (stt *SequentialThinkingTool) branchIDs() []string {
+	ids := make([]string, 0, len(stt.branches))
+	for id := range stt.branches {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// thoughtMessage 构建写入轨迹的思考消息
+func thoughtMessage(thought *ThoughtData) llm.LLMMessage {
+	metadata := map[string]interface{}{
+		"thought_number":      thought.ThoughtNumber,
+		"total_thoughts":      thought.TotalThoughts,
+		"next_thought_needed": thought.NextThoughtNeeded,
+	}
+	if thought.IsRevision {
+		metadata["is_revision"] = true
+		metadata["revises_thought"] = thought.RevisesThought
+	}
+	if thought.BranchID != "" {
+		metadata["branch_id"] = thought.BranchID
+		metadata["branch_from_thought"] = thought.BranchFromThought
+	}
+	if thought.NeedsMoreThoughts {
+		metadata["needs_more_thoughts"] = true
+	}
+
+	return llm.LLMMessage{
+		Role:     "thought",
+		Name:     "sequential_thinking",
+		Content:  thought.Thought,
+		Metadata: metadata,
+	}
+}
+
+// withLegacyThinkingArgs 将旧版的step_number/total_steps映射为新参数
+func withLegacyThinkingArgs(args ToolCallArguments) ToolCallArguments {
+	_, hasStep := args["step_number"]
+	_, hasTotal := args["total_steps"]
+	if !hasStep && !hasTotal {
+		return args
+	}
+
+	mapped := make(ToolCallArguments, len(args))
+	for key, value := range args {
+		mapped[key] = value
+	}
+	if _, exists := mapped["thought_number"]; !exists && hasStep {
+		mapped["thought_number"] = args["step_number"]
+	}
+	if _, exists := mapped["total_thoughts"]; !exists {
+		if hasTotal {
+			mapped["total_thoughts"] = args["total_steps"]
+		} else {
+			mapped["total_thoughts"] = mapped["thought_number"]
+		}
+	}
+	if _, exists := mapped["next_thought_needed"]; !exists {
+		mapped["next_thought_needed"] = true
+	}
+	return mapped
+}
+
+// thinkingInt 读取整数参数并检查下限
+func thinkingInt(args ToolCallArguments, name string, min int) (int, error) {
+	var value int
+	switch v := args[name].(type) {
+	case float64:
+		value = int(v)
+	case int:
+		value = v
+	case string:
+		parsed, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return 0, &ToolError{Message: fmt.Sprintf("%s must be an integer", name), Code: 400}
+		}
+		value = parsed
+	default:
+		return 0, &ToolError{Message: fmt.Sprintf("%s must be an integer", name), Code: 400}
+	}
+
+	if value < min {
+		return 0, &ToolError{Message: fmt.Sprintf("%s must be at least %d", name, min), Code: 400}
+	}
+	return value, nil
+}
+
+// thinkingBool 读取布尔参数
+func thinkingBool(args ToolCallArguments, name string) (bool, error) {
+	switch v := args[name].(type) {
+	case bool:
+		return v, nil
+	case string:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err == nil {
+			return parsed, nil
+		}
+	}
+	return false, &ToolError{Message: fmt.Sprintf("%s must be a boolean", name), Code: 400}
 }
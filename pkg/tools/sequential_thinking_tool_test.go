@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"trage-agent-go/pkg/llm"
+)
+
+// mockThoughtRecorder 记录思考消息的模拟轨迹记录器
+type mockThoughtRecorder struct {
+	messages []llm.LLMMessage
+	err      error // 不为空时写入总是失败
+}
+
+func (m *mockThoughtRecorder) RecordMessage(message llm.LLMMessage) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.messages = append(m.messages, message)
+	return nil
+}
+func (m *mockThoughtRecorder) RecordToolCall(toolCall llm.ToolCall) error { return nil }
+func (m *mockThoughtRecorder) RecordToolResult(toolCall llm.ToolCall, result llm.ToolExecutionResult) error {
+	return nil
+}
+func (m *mockThoughtRecorder) RecordStep(step llm.StepRecord) error            { return nil }
+func (m *mockThoughtRecorder) RecordError(source string, message string) error { return nil }
+func (m *mockThoughtRecorder) RecordLakeview(record llm.LakeviewRecord) error  { return nil }
+func (m *mockThoughtRecorder) RecordLLMInteraction(interaction llm.LLMInteraction) error {
+	return nil
+}
+func (m *mockThoughtRecorder) RecordExecution(execution interface{}) error { return nil }
+func (m *mockThoughtRecorder) AddMetadata(key string, value interface{})   {}
+func (m *mockThoughtRecorder) Save() error                                 { return nil }
+func (m *mockThoughtRecorder) GetTrajectoryPath() string                   { return "" }
+
+// parseThinkingSummary 从工具结果中解析摘要
+func parseThinkingSummary(t *testing.T, result *ToolResult) ThinkingSummary {
+	t.Helper()
+	idx := strings.Index(result.Result, "{")
+	if idx < 0 {
+		t.Fatalf("Expected JSON summary in result, got %s", result.Result)
+	}
+	var summary ThinkingSummary
+	if err := json.Unmarshal([]byte(result.Result[idx:]), &summary); err != nil {
+		t.Fatalf("Failed to parse summary: %v", err)
+	}
+	return summary
+}
+
+func TestSequentialThinkingTool_History(t *testing.T) {
+	tool := NewSequentialThinkingTool()
+	recorder := &mockThoughtRecorder{}
+	tool.SetTrajectoryRecorder(recorder)
+	ctx := context.Background()
+
+	steps := []ToolCallArguments{
+		{"thought": "分析问题", "thought_number": float64(1), "total_thoughts": float64(2), "next_thought_needed": true},
+		{"thought": "修正第一步", "thought_number": float64(2), "total_thoughts": float64(2), "next_thought_needed": true, "is_revision": true, "revises_thought": float64(1)},
+		{"thought": "尝试另一种思路", "thought_number": float64(3), "total_thoughts": float64(2), "next_thought_needed": true, "branch_from_thought": float64(1), "branch_id": "alt"},
+	}
+	for _, args := range steps {
+		if _, err := tool.Execute(ctx, args); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	result, err := tool.Execute(ctx, ToolCallArguments{
+		"thought": "得出结论", "thought_number": float64(4), "total_thoughts": float64(4), "next_thought_needed": false,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	summary := parseThinkingSummary(t, result)
+	if summary.ThoughtHistoryLength != 4 || len(summary.ThoughtHistory) != 4 {
+		t.Errorf("Expected 4 thoughts in history, got %d/%d", summary.ThoughtHistoryLength, len(summary.ThoughtHistory))
+	}
+	if len(summary.Branches) != 1 || summary.Branches[0] != "alt" {
+		t.Errorf("Expected branch 'alt', got %v", summary.Branches)
+	}
+
+	// 思考编号超过总数时应自动上调
+	if summary.ThoughtHistory[2].TotalThoughts != 3 {
+		t.Errorf("Expected total_thoughts to be adjusted to 3, got %d", summary.ThoughtHistory[2].TotalThoughts)
+	}
+	if !summary.ThoughtHistory[1].IsRevision || summary.ThoughtHistory[1].RevisesThought != 1 {
+		t.Errorf("Expected second thought to revise thought 1, got %+v", summary.ThoughtHistory[1])
+	}
+
+	if len(recorder.messages) != 4 || recorder.messages[0].Role != "thought" {
+		t.Errorf("Expected 4 thought messages in trajectory, got %d", len(recorder.messages))
+	}
+
+	tool.Reset()
+	if len(tool.GetThoughtHistory()) != 0 || len(tool.GetBranches()) != 0 {
+		t.Errorf("Expected history to be cleared after reset")
+	}
+}
+
+func TestSequentialThinkingTool_RecordFailure(t *testing.T) {
+	tool := NewSequentialThinkingTool()
+	tool.SetTrajectoryRecorder(&mockThoughtRecorder{err: errors.New("disk full")})
+	var warnings []string
+	ctx := llm.WithWarningHandler(context.Background(), func(message string) { warnings = append(warnings, message) })
+
+	// 思考已保存到历史中，轨迹写入失败只输出警告，仍然返回摘要
+	result, err := tool.Execute(ctx, ToolCallArguments{
+		"thought": "分析问题", "thought_number": float64(1), "total_thoughts": float64(1), "next_thought_needed": false,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if summary := parseThinkingSummary(t, result); !result.Success || summary.ThoughtHistoryLength != 1 {
+		t.Errorf("Expected summary with 1 thought, got %+v", result)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "disk full") {
+		t.Errorf("Expected a warning about the recording failure, got %v", warnings)
+	}
+}
+
+func TestSequentialThinkingTool_InvalidArgs(t *testing.T) {
+	tool := NewSequentialThinkingTool()
+
+	cases := []ToolCallArguments{
+		{"thought": "x", "thought_number": float64(1), "total_thoughts": float64(1)},
+		{"thought": "", "thought_number": float64(1), "total_thoughts": float64(1), "next_thought_needed": true},
+		{"thought": "x", "thought_number": float64(0), "total_thoughts": float64(1), "next_thought_needed": true},
+		{"thought": "x", "thought_number": float64(1), "total_thoughts": float64(1), "next_thought_needed": true, "revises_thought": float64(0)},
+	}
+	for i, args := range cases {
+		if err := tool.ValidateArgs(args); err == nil {
+			t.Errorf("Case %d: expected validation error", i)
+		}
+	}
+
+	// 旧版参数名仍然可用
+	if err := tool.ValidateArgs(ToolCallArguments{"thought": "x", "step_number": float64(1)}); err != nil {
+		t.Errorf("Expected legacy arguments to be accepted, got %v", err)
+	}
+}
+
+func TestSequentialThinkingTool_State(t *testing.T) {
+	tool := NewSequentialThinkingTool()
+	ctx := context.Background()
+	for _, args := range []ToolCallArguments{
+		{"thought": "分析问题", "thought_number": float64(1), "total_thoughts": float64(2), "next_thought_needed": true},
+		{"thought": "另一种思路", "thought_number": float64(2), "total_thoughts": float64(2), "next_thought_needed": true, "branch_from_thought": float64(1), "branch_id": "alt"},
+	} {
+		if _, err := tool.Execute(ctx, args); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	state, err := tool.SaveState()
+	if err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	restored := NewSequentialThinkingTool()
+	if err := restored.RestoreState(state); err != nil {
+		t.Fatalf("Failed to restore state: %v", err)
+	}
+	history := restored.GetThoughtHistory()
+	if len(history) != 2 || history[0].Thought != "分析问题" {
+		t.Errorf("Expected restored thought history, got %+v", history)
+	}
+	if branches := restored.GetBranches(); len(branches) != 1 || branches[0] != "alt" {
+		t.Errorf("Expected restored branch 'alt', got %v", branches)
+	}
+
+	if err := restored.RestoreState([]byte("not json")); err == nil {
+		t.Errorf("Expected error for invalid state")
+	}
+}
@@ -0,0 +1,12 @@
+package tools
+
+import "encoding/json"
+
+// StatefulTool 状态可以随会话保存和恢复的工具
+type StatefulTool interface {
+	// SaveState 导出工具状态
+	SaveState() (json.RawMessage, error)
+
+	// RestoreState 恢复SaveState导出的状态
+	RestoreState(state json.RawMessage) error
+}
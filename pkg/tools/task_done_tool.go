@@ -86,9 +86,6 @@ func (tdt *TaskDoneTool) Execute(ctx context.Context, args ToolCallArguments) (*
 		result += fmt.Sprintf("\n输出: %s", output)
 	}
 
-	// 打印完成消息
-	fmt.Println(result)
-
 	return &ToolResult{
 		Success: true,
 		Result:  result,
@@ -0,0 +1,18 @@
+package tools
+
+import "path/filepath"
+
+// WorkspaceAwareTool 需要知道工作目录的工具，命令和相对路径都在该目录下执行和解析，
+// 同一进程中的多个代理因此可以各自使用独立的工作目录
+type WorkspaceAwareTool interface {
+	// SetWorkingDir 设置工作目录，为空时使用进程的当前目录
+	SetWorkingDir(dir string)
+}
+
+// resolvePath 将相对路径解析到工作目录下
+func resolvePath(workingDir, path string) string {
+	if workingDir == "" || path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(workingDir, path)
+}
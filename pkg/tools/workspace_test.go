@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolvePath(t *testing.T) {
+	tests := []struct {
+		workingDir string
+		path       string
+		expected   string
+	}{
+		{"", "a.txt", "a.txt"},
+		{"/work", "a.txt", "/work/a.txt"},
+		{"/work", "sub/a.txt", "/work/sub/a.txt"},
+		{"/work", "/abs/a.txt", "/abs/a.txt"},
+		{"/work", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := resolvePath(tt.workingDir, tt.path); got != tt.expected {
+			t.Errorf("resolvePath(%q, %q) = %q, expected %q", tt.workingDir, tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestWorkspaceAwareTools(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// 命令在工作目录下执行
+	bashTool := NewBashTool()
+	bashTool.SetWorkingDir(dir)
+	result, err := bashTool.Execute(ctx, ToolCallArguments{"command": "pwd"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resolved, _ := filepath.EvalSymlinks(dir)
+	if got := strings.TrimSpace(result.Result); got != dir && got != resolved {
+		t.Errorf("Expected command to run in %s, got %s", dir, got)
+	}
+
+	// 相对路径写入工作目录
+	editTool := NewEditTool()
+	editTool.SetWorkingDir(dir)
+	if _, err := editTool.Execute(ctx, ToolCallArguments{"file_path": "notes.txt", "content": "hello", "backup": false}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "notes.txt"))
+	if err != nil || string(content) != "hello" {
+		t.Errorf("Expected notes.txt in working dir, got %q (%v)", content, err)
+	}
+
+	for _, tool := range []Tool{bashTool, editTool, NewCKGTool(), NewGoTestTool()} {
+		if _, ok := tool.(WorkspaceAwareTool); !ok {
+			t.Errorf("Expected %s to implement WorkspaceAwareTool", tool.GetName())
+		}
+	}
+}
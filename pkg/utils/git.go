@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// RunGit 在dir中执行git命令，失败时错误中包含git的输出
+func RunGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	return runGit(ctx, dir, nil, args...)
+}
+
+// GitPatch 生成工作目录相对base的补丁，包含未跟踪的文件；base为空时相对HEAD。
+// 使用临时索引，不影响工作目录中的git状态
+func GitPatch(ctx context.Context, dir, base string) ([]byte, error) {
+	if base == "" {
+		if _, err := runGit(ctx, dir, nil, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+			base = "HEAD"
+		}
+	}
+
+	// 没有任何提交时，所有文件都视为新增
+	treeArgs := []string{"read-tree", "--empty"}
+	diffArgs := []string{"diff", "--cached", "--binary"}
+	if base != "" {
+		treeArgs = []string{"read-tree", base}
+		diffArgs = append(diffArgs, base)
+	}
+
+	var patch []byte
+	err := withTempIndex(func(env []string) error {
+		if _, err := runGit(ctx, dir, env, treeArgs...); err != nil {
+			return err
+		}
+		if _, err := runGit(ctx, dir, env, "add", "--all"); err != nil {
+			return err
+		}
+		var err error
+		patch, err = runGit(ctx, dir, env, diffArgs...)
+		return err
+	})
+	return patch, err
+}
+
+// GitSnapshot 将工作目录的当前内容（包括未跟踪、但不包括忽略的文件）写入git对象库，
+// 返回树对象的哈希，不影响工作目录中的git状态
+func GitSnapshot(ctx context.Context, dir string) (string, error) {
+	var tree []byte
+	err := withTempIndex(func(env []string) error {
+		if _, err := runGit(ctx, dir, env, "read-tree", "--empty"); err != nil {
+			return err
+		}
+		if _, err := runGit(ctx, dir, env, "add", "--all"); err != nil {
+			return err
+		}
+		var err error
+		tree, err = runGit(ctx, dir, env, "write-tree")
+		return err
+	})
+	return strings.TrimSpace(string(tree)), err
+}
+
+// GitRestoreSnapshot 将工作目录恢复为GitSnapshot记录的内容：还原修改和删除的文件，
+// 删除快照之后新增的文件，被忽略的文件保持不变。快照总是包含整个仓库，
+// dir是仓库的子目录时同样恢复整个仓库
+func GitRestoreSnapshot(ctx context.Context, dir, tree string) error {
+	// diff-tree输出的路径相对于仓库根目录
+	toplevel, err := runGit(ctx, dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	root := strings.TrimSpace(string(toplevel))
+
+	current, err := GitSnapshot(ctx, root)
+	if err != nil {
+		return err
+	}
+	added, err := runGit(ctx, root, nil, "diff-tree", "-r", "-z", "--name-only", "--no-renames", "--diff-filter=A", tree, current)
+	if err != nil {
+		return err
+	}
+	for _, name := range strings.Split(string(added), "\x00") {
+		if name == "" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(root, filepath.FromSlash(name))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+
+	return withTempIndex(func(env []string) error {
+		if _, err := runGit(ctx, root, env, "read-tree", tree); err != nil {
+			return err
+		}
+		_, err := runGit(ctx, root, env, "checkout-index", "--all", "--force")
+		return err
+	})
+}
+
+// withTempIndex 使用临时索引文件执行fn，env中包含GIT_INDEX_FILE
+func withTempIndex(fn func(env []string) error) error {
+	indexDir, err := os.MkdirTemp("", "trage-git-index-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	defer os.RemoveAll(indexDir)
+	return fn([]string{"GIT_INDEX_FILE=" + filepath.Join(indexDir, "index")})
+}
+
+// runGit 使用额外的环境变量执行git命令
+func runGit(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
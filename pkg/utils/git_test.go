@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitPatch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// 没有提交时，所有文件都作为新增
+	if _, err := RunGit(ctx, dir, "init", "--quiet"); err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	patch, err := GitPatch(ctx, dir, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(string(patch), "+++ b/a.txt") {
+		t.Errorf("Expected a.txt to be added, got %q", patch)
+	}
+
+	// 提交后只包含之后的修改和未跟踪的文件
+	for _, args := range [][]string{
+		{"-c", "user.email=test@example.com", "-c", "user.name=test", "add", "."},
+		{"-c", "user.email=test@example.com", "-c", "user.name=test", "commit", "--quiet", "-m", "base"},
+	} {
+		if _, err := RunGit(ctx, dir, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("changed\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b\n"), 0644)
+
+	patch, err = GitPatch(ctx, dir, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, expected := range []string{"-a", "+changed", "+++ b/b.txt"} {
+		if !strings.Contains(string(patch), expected) {
+			t.Errorf("Expected patch to contain %q, got %q", expected, patch)
+		}
+	}
+
+	// 补丁使用临时索引，不应修改仓库的暂存区
+	status, err := RunGit(ctx, dir, "status", "--porcelain")
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if !strings.Contains(string(status), "?? b.txt") {
+		t.Errorf("Expected b.txt to stay untracked, got %q", status)
+	}
+}
+
+func TestGitSnapshotRestore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	if _, err := RunGit(ctx, dir, "init", "--quiet"); err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write(".gitignore", "*.log\n")
+	write("a.txt", "a\n")
+	write("sub/b.txt", "b\n")
+
+	tree, err := GitSnapshot(ctx, dir)
+	if err != nil || tree == "" {
+		t.Fatalf("Expected snapshot tree, got %q (%v)", tree, err)
+	}
+
+	// 修改、删除、新增文件，被忽略的文件不受快照影响
+	write("a.txt", "changed\n")
+	os.Remove(filepath.Join(dir, "sub", "b.txt"))
+	write("c.txt", "c\n")
+	write("new/d.txt", "d\n")
+	write("debug.log", "log\n")
+
+	if err := GitRestoreSnapshot(ctx, dir, tree); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for name, expected := range map[string]string{"a.txt": "a\n", "sub/b.txt": "b\n", "debug.log": "log\n"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(content) != expected {
+			t.Errorf("Expected %s to contain %q, got %q (%v)", name, expected, content, err)
+		}
+	}
+	for _, name := range []string{"c.txt", "new/d.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", name, err)
+		}
+	}
+
+	// 快照不改变仓库的索引
+	status, _ := RunGit(ctx, dir, "status", "--porcelain")
+	if !strings.Contains(string(status), "?? a.txt") {
+		t.Errorf("Expected files to remain untracked, got %q", status)
+	}
+}
+
+func TestGitSnapshotRestore_Subdirectory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	if _, err := RunGit(ctx, dir, "init", "--quiet"); err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("a.txt", "a\n")
+	write("sub/b.txt", "b\n")
+	// 与仓库根目录的相对路径拼接到子目录上会得到这个文件，它不应被删除
+	write("sub/sub/new.txt", "keep\n")
+
+	sub := filepath.Join(dir, "sub")
+	tree, err := GitSnapshot(ctx, sub)
+	if err != nil {
+		t.Fatalf("Expected snapshot tree, got %q (%v)", tree, err)
+	}
+
+	// 在子目录中运行的任务新增和修改文件
+	write("sub/new.txt", "new\n")
+	write("sub/b.txt", "changed\n")
+	write("a.txt", "changed\n")
+
+	if err := GitRestoreSnapshot(ctx, sub, tree); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "sub", "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected sub/new.txt to be removed, got %v", err)
+	}
+	for name, expected := range map[string]string{"a.txt": "a\n", "sub/b.txt": "b\n", "sub/sub/new.txt": "keep\n"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(content) != expected {
+			t.Errorf("Expected %s to contain %q, got %q (%v)", name, expected, content, err)
+		}
+	}
+}
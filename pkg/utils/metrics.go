@@ -2,6 +2,8 @@ package utils
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -382,31 +384,46 @@ func (pm *PerformanceMonitor) GetMetrics() map[string]Metric {
 
 // ExportMetrics 导出指标为Prometheus格式
 func (pm *PerformanceMonitor) ExportMetrics() string {
-	metrics := pm.collector.GetAllMetrics()
-	var result string
-	
-	for name, metric := range metrics {
-		result += fmt.Sprintf("# HELP %s %s\n", name, metric.GetDescription())
-		result += fmt.Sprintf("# TYPE %s %s\n", name, metric.GetType())
-		
+	return pm.collector.ExportPrometheus()
+}
+
+// ExportPrometheus 按名称顺序导出为Prometheus文本格式
+func (mc *MetricsCollector) ExportPrometheus() string {
+	metrics := mc.GetAllMetrics()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		metric := metrics[name]
+		fmt.Fprintf(&sb, "# HELP %s %s\n", name, metric.GetDescription())
+		fmt.Fprintf(&sb, "# TYPE %s %s\n", name, metric.GetType())
+
 		switch m := metric.(type) {
 		case *Counter:
-			result += fmt.Sprintf("%s %v\n", name, m.GetValue())
+			fmt.Fprintf(&sb, "%s %v\n", name, m.GetValue())
 		case *Gauge:
-			result += fmt.Sprintf("%s %v\n", name, m.GetValue())
+			fmt.Fprintf(&sb, "%s %v\n", name, m.GetValue())
 		case *Histogram:
 			value := m.GetValue().(map[string]interface{})
 			buckets := value["buckets"].([]float64)
 			counts := value["counts"].([]int64)
-			
+
+			// Prometheus的桶计数是累计值
+			cumulative := int64(0)
 			for i, bucket := range buckets {
-				result += fmt.Sprintf("%s_bucket{le=\"%g\"} %d\n", name, bucket, counts[i])
+				cumulative += counts[i]
+				fmt.Fprintf(&sb, "%s_bucket{le=\"%g\"} %d\n", name, bucket, cumulative)
 			}
-			result += fmt.Sprintf("%s_sum %g\n", name, value["sum"])
-			result += fmt.Sprintf("%s_count %d\n", name, value["total"])
+			fmt.Fprintf(&sb, "%s_bucket{le=\"+Inf\"} %d\n", name, value["total"])
+			fmt.Fprintf(&sb, "%s_sum %g\n", name, value["sum"])
+			fmt.Fprintf(&sb, "%s_count %d\n", name, value["total"])
 		}
-		result += "\n"
+		sb.WriteString("\n")
 	}
-	
-	return result
+
+	return sb.String()
 }
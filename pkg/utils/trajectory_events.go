@@ -0,0 +1,423 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"trage-agent-go/pkg/llm"
+)
+
+// TrajectorySchemaVersion 当前支持的轨迹事件格式版本
+const TrajectorySchemaVersion = 1
+
+// TrajectoryFormat 轨迹文件格式
+type TrajectoryFormat string
+
+const (
+	// TrajectoryFormatJSON 旧版单文档JSON格式
+	TrajectoryFormatJSON TrajectoryFormat = "json"
+	// TrajectoryFormatJSONL 追加写入的JSONL事件格式
+	TrajectoryFormatJSONL TrajectoryFormat = "jsonl"
+)
+
+// EventType 轨迹事件类型
+type EventType string
+
+const (
+	EventSessionStart EventType = "session_start"
+	EventMetadata     EventType = "metadata"
+	EventMessage      EventType = "message"
+	EventLLMCall      EventType = "llm_call"
+	EventToolCall     EventType = "tool_call"
+	EventToolResult   EventType = "tool_result"
+	EventStep         EventType = "step"
+	EventError        EventType = "error"
+	EventLakeview     EventType = "lakeview"
+	EventExecution    EventType = "execution"
+)
+
+// SessionInfo 会话开始信息
+type SessionInfo struct {
+	StartTime time.Time              `json:"start_time"`
+	Source    string                 `json:"source,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// MetadataEntry 元数据条目
+type MetadataEntry struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// ErrorRecord 错误记录
+type ErrorRecord struct {
+	Source  string `json:"source"`
+	Message string `json:"message"`
+}
+
+// ExecutionRecord 代理最终执行结果的通用视图
+type ExecutionRecord struct {
+	Success   bool                   `json:"success"`
+	Output    string                 `json:"output,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Cancelled bool                   `json:"cancelled,omitempty"`
+	Duration  time.Duration          `json:"duration"`
+	Steps     []json.RawMessage      `json:"steps,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// TrajectoryEvent 轨迹事件，每行一个；只有与Type对应的字段会被填充
+type TrajectoryEvent struct {
+	SchemaVersion int       `json:"schema_version"`
+	Seq           int       `json:"seq"`
+	Type          EventType `json:"type"`
+	Timestamp     time.Time `json:"timestamp"`
+
+	Session    *SessionInfo             `json:"session,omitempty"`
+	Metadata   *MetadataEntry           `json:"metadata,omitempty"`
+	Message    *llm.LLMMessage          `json:"message,omitempty"`
+	LLMCall    *llm.LLMInteraction      `json:"llm_call,omitempty"`
+	ToolCall   *llm.ToolCall            `json:"tool_call,omitempty"`
+	ToolResult *llm.ToolExecutionResult `json:"tool_result,omitempty"`
+	Step       *llm.StepRecord          `json:"step,omitempty"`
+	Error      *ErrorRecord             `json:"error,omitempty"`
+	Lakeview   *llm.LakeviewRecord      `json:"lakeview,omitempty"`
+	Execution  json.RawMessage          `json:"execution,omitempty"`
+}
+
+// DecodeExecution 解析execution事件中的执行结果
+func (e *TrajectoryEvent) DecodeExecution() (*ExecutionRecord, error) {
+	if e.Type != EventExecution || len(e.Execution) == 0 {
+		return nil, fmt.Errorf("event %d is not an execution event", e.Seq)
+	}
+	var record ExecutionRecord
+	if err := json.Unmarshal(e.Execution, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode execution: %w", err)
+	}
+	return &record, nil
+}
+
+// TrajectoryReader 逐行读取JSONL轨迹事件
+type TrajectoryReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewTrajectoryReader 创建轨迹事件读取器
+func NewTrajectoryReader(r io.Reader) *TrajectoryReader {
+	scanner := bufio.NewScanner(r)
+	// 单个事件可能包含完整的LLM请求，放宽行长度限制
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	return &TrajectoryReader{scanner: scanner}
+}
+
+// Next 读取下一个事件，读完时返回io.EOF
+func (r *TrajectoryReader) Next() (*TrajectoryEvent, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var event TrajectoryEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, fmt.Errorf("line %d: invalid trajectory event: %w", r.line, err)
+		}
+		if event.SchemaVersion > TrajectorySchemaVersion {
+			return nil, fmt.Errorf("line %d: unsupported schema version %d (max %d)",
+				r.line, event.SchemaVersion, TrajectorySchemaVersion)
+		}
+		return &event, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	return nil, io.EOF
+}
+
+// ReadAll 读取剩余的全部事件
+func (r *TrajectoryReader) ReadAll() ([]TrajectoryEvent, error) {
+	events := make([]TrajectoryEvent, 0)
+	for {
+		event, err := r.Next()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+}
+
+// DetectTrajectoryFormat 根据文件内容判断轨迹格式
+func DetectTrajectoryFormat(data []byte) TrajectoryFormat {
+	trimmed := bytes.TrimSpace(data)
+	firstLine := trimmed
+	if idx := bytes.IndexByte(trimmed, '\n'); idx >= 0 {
+		firstLine = trimmed[:idx]
+	}
+
+	// JSONL的首行是带schema_version的完整事件
+	var probe struct {
+		SchemaVersion *int      `json:"schema_version"`
+		Type          EventType `json:"type"`
+	}
+	if json.Unmarshal(firstLine, &probe) == nil && probe.SchemaVersion != nil && probe.Type != "" {
+		return TrajectoryFormatJSONL
+	}
+	return TrajectoryFormatJSON
+}
+
+// ReadTrajectoryEvents 读取轨迹文件中的全部事件，旧版JSON文件会在内存中转换
+func ReadTrajectoryEvents(path string) ([]TrajectoryEvent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trajectory file: %w", err)
+	}
+	if DetectTrajectoryFormat(data) == TrajectoryFormatJSONL {
+		return NewTrajectoryReader(bytes.NewReader(data)).ReadAll()
+	}
+	return legacyTrajectoryEvents(data)
+}
+
+// ConvertJSONToJSONL 将旧版JSON轨迹转换为JSONL事件文件，返回写入的事件数
+func ConvertJSONToJSONL(inputPath, outputPath string) (int, error) {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read trajectory file: %w", err)
+	}
+	if DetectTrajectoryFormat(data) == TrajectoryFormatJSONL {
+		return 0, fmt.Errorf("%s is already in JSONL format", inputPath)
+	}
+
+	events, err := legacyTrajectoryEvents(data)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := writeEvents(&buf, events); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write trajectory file: %w", err)
+	}
+	return len(events), nil
+}
+
+// writeEvents 将事件逐行写入
+func writeEvents(w io.Writer, events []TrajectoryEvent) error {
+	for i := range events {
+		line, err := json.Marshal(&events[i])
+		if err != nil {
+			return fmt.Errorf("failed to marshal trajectory event: %w", err)
+		}
+		line = append(line, '\n')
+		if _, err := w.Write(line); err != nil {
+			return fmt.Errorf("failed to write trajectory event: %w", err)
+		}
+	}
+	return nil
+}
+
+// legacyTrajectory 旧版JSON轨迹文档
+type legacyTrajectory struct {
+	Metadata        map[string]interface{} `json:"metadata"`
+	Messages        []llm.LLMMessage       `json:"messages"`
+	ToolCalls       []llm.ToolCall         `json:"tool_calls"`
+	ToolResults     []json.RawMessage      `json:"tool_results"`
+	LLMInteractions []llm.LLMInteraction   `json:"llm_interactions"`
+	Steps           []llm.StepRecord       `json:"steps"`
+	Errors          []legacyError          `json:"errors"`
+	Lakeview        []llm.LakeviewRecord   `json:"lakeview,omitempty"`
+	CustomMetadata  map[string]interface{} `json:"custom_metadata"`
+	Execution       json.RawMessage        `json:"agent_execution"`
+}
+
+// legacyError 旧版JSON中的错误条目
+type legacyError struct {
+	ErrorRecord
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// legacyToolResult 旧版JSON中包装过的工具结果
+type legacyToolResult struct {
+	ToolCallID string          `json:"tool_call_id"`
+	ToolName   string          `json:"tool_name"`
+	Result     json.RawMessage `json:"result"`
+	Timestamp  time.Time       `json:"timestamp"`
+}
+
+// decodeLegacyToolResult 解析旧版工具结果，兼容包装与未包装两种写法
+func decodeLegacyToolResult(raw json.RawMessage) (llm.ToolExecutionResult, time.Time) {
+	var wrapped legacyToolResult
+	if json.Unmarshal(raw, &wrapped) == nil && wrapped.ToolCallID != "" {
+		var result llm.ToolExecutionResult
+		if err := json.Unmarshal(wrapped.Result, &result); err != nil {
+			// 早期结果可能是任意值，保留原始文本
+			result.Result = string(wrapped.Result)
+		}
+		result.CallID = wrapped.ToolCallID
+		result.Name = wrapped.ToolName
+		return result, wrapped.Timestamp
+	}
+
+	var result llm.ToolExecutionResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		result.Result = string(raw)
+	}
+	return result, time.Time{}
+}
+
+// legacyTrajectoryEvents 将旧版JSON轨迹展开为事件序列
+func legacyTrajectoryEvents(data []byte) ([]TrajectoryEvent, error) {
+	var doc legacyTrajectory
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal trajectory: %w", err)
+	}
+
+	startTime := time.Time{}
+	if value, ok := doc.Metadata["start_time"].(string); ok {
+		if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+			startTime = parsed
+		}
+	}
+	// 旧格式中没有时间戳的记录沿用前一条记录的时间，排序时保持原来的相对位置
+	last := startTime
+	stamp := func(t time.Time) time.Time {
+		if t.IsZero() {
+			return last
+		}
+		last = t
+		return t
+	}
+
+	events := make([]TrajectoryEvent, 0)
+	add := func(event TrajectoryEvent) {
+		event.Timestamp = stamp(event.Timestamp)
+		events = append(events, event)
+	}
+
+	// 元数据只以metadata事件输出，加载时由这些事件恢复
+	add(TrajectoryEvent{
+		Type:    EventSessionStart,
+		Session: &SessionInfo{StartTime: startTime, Source: "converted"},
+	})
+
+	// 元数据按键排序，保证转换结果稳定
+	keys := make([]string, 0, len(doc.CustomMetadata))
+	for key := range doc.CustomMetadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add(TrajectoryEvent{Type: EventMetadata, Metadata: &MetadataEntry{Key: key, Value: doc.CustomMetadata[key]}})
+	}
+
+	for i := range doc.LLMInteractions {
+		interaction := doc.LLMInteractions[i]
+		add(TrajectoryEvent{Type: EventLLMCall, Timestamp: interaction.Timestamp, LLMCall: &interaction})
+	}
+
+	// 工具调用与对应结果按调用ID配对输出
+	results := make(map[string][]llm.ToolExecutionResult)
+	resultTimes := make(map[string][]time.Time)
+	var orphans []llm.ToolExecutionResult
+	for _, raw := range doc.ToolResults {
+		result, timestamp := decodeLegacyToolResult(raw)
+		if result.CallID == "" {
+			orphans = append(orphans, result)
+			continue
+		}
+		results[result.CallID] = append(results[result.CallID], result)
+		resultTimes[result.CallID] = append(resultTimes[result.CallID], timestamp)
+	}
+	for i := range doc.ToolCalls {
+		toolCall := doc.ToolCalls[i]
+		pending := results[toolCall.ID]
+		if len(pending) == 0 {
+			add(TrajectoryEvent{Type: EventToolCall, ToolCall: &toolCall})
+			continue
+		}
+		// 旧格式的工具调用没有时间戳，使用对应结果的时间，排序后仍在结果之前
+		result, timestamp := pending[0], resultTimes[toolCall.ID][0]
+		add(TrajectoryEvent{Type: EventToolCall, Timestamp: timestamp, ToolCall: &toolCall})
+		add(TrajectoryEvent{Type: EventToolResult, Timestamp: timestamp, ToolResult: &result})
+		results[toolCall.ID] = pending[1:]
+		resultTimes[toolCall.ID] = resultTimes[toolCall.ID][1:]
+	}
+	// 没有匹配到调用的结果附在最后，避免丢失
+	callIDs := make([]string, 0, len(results))
+	for callID := range results {
+		callIDs = append(callIDs, callID)
+	}
+	sort.Strings(callIDs)
+	for _, callID := range callIDs {
+		orphans = append(orphans, results[callID]...)
+	}
+	for i := range orphans {
+		add(TrajectoryEvent{Type: EventToolResult, ToolResult: &orphans[i]})
+	}
+
+	for i := range doc.Messages {
+		message := doc.Messages[i]
+		add(TrajectoryEvent{Type: EventMessage, Message: &message})
+	}
+	for i := range doc.Steps {
+		step := doc.Steps[i]
+		add(TrajectoryEvent{Type: EventStep, Timestamp: step.Timestamp, Step: &step})
+	}
+	for i := range doc.Errors {
+		record := doc.Errors[i].ErrorRecord
+		add(TrajectoryEvent{Type: EventError, Timestamp: doc.Errors[i].Timestamp, Error: &record})
+	}
+	for i := range doc.Lakeview {
+		record := doc.Lakeview[i]
+		add(TrajectoryEvent{Type: EventLakeview, Timestamp: record.Timestamp, Lakeview: &record})
+	}
+
+	// 会话开始之后的事件按时间稳定排序，时间相同时按步骤排序
+	body := events[1:]
+	sort.SliceStable(body, func(i, j int) bool {
+		if !body[i].Timestamp.Equal(body[j].Timestamp) {
+			return body[i].Timestamp.Before(body[j].Timestamp)
+		}
+		return eventStep(body[i]) < eventStep(body[j])
+	})
+
+	// 执行结果总在最后
+	if len(doc.Execution) > 0 && !bytes.Equal(bytes.TrimSpace(doc.Execution), []byte("null")) {
+		end := events[len(events)-1].Timestamp
+		events = append(events, TrajectoryEvent{Type: EventExecution, Timestamp: end, Execution: doc.Execution})
+	}
+
+	for i := range events {
+		events[i].SchemaVersion = TrajectorySchemaVersion
+		events[i].Seq = i + 1
+	}
+	return events, nil
+}
+
+// eventStep 获取事件所属的步骤，没有步骤信息的事件返回0
+func eventStep(event TrajectoryEvent) int {
+	switch {
+	case event.Step != nil:
+		return event.Step.StepNumber
+	case event.Lakeview != nil:
+		return event.Lakeview.StepNumber
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"trage-agent-go/pkg/llm"
+)
+
+func TestTrajectoryRecorder_JSONLStreaming(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "trajectory.jsonl")
+	recorder := NewTrajectoryRecorder(filePath)
+	if recorder.GetFormat() != TrajectoryFormatJSONL {
+		t.Fatalf("Expected JSONL format for .jsonl path, got %s", recorder.GetFormat())
+	}
+
+	toolCall := llm.ToolCall{ID: "call_1", Function: llm.ToolCallFunction{Name: "bash"}}
+	recorder.AddMetadata("task", "list files")
+	if err := recorder.RecordToolCall(toolCall); err != nil {
+		t.Fatalf("Failed to record tool call: %v", err)
+	}
+
+	// 每个事件写入后即可读取
+	events, err := ReadTrajectoryEvents(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read events: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events after tool call, got %d", len(events))
+	}
+	if events[0].Type != EventSessionStart || events[1].Type != EventMetadata || events[2].Type != EventToolCall {
+		t.Errorf("Unexpected event order: %s, %s, %s", events[0].Type, events[1].Type, events[2].Type)
+	}
+
+	if err := recorder.RecordToolResult(toolCall, llm.ToolExecutionResult{Success: true, Result: "a.txt"}); err != nil {
+		t.Fatalf("Failed to record tool result: %v", err)
+	}
+	if err := recorder.RecordStep(llm.StepRecord{StepNumber: 1, Action: "tool_execution", ToolCallID: "call_1"}); err != nil {
+		t.Fatalf("Failed to record step: %v", err)
+	}
+	if err := recorder.RecordError("tool", "boom"); err != nil {
+		t.Fatalf("Failed to record error: %v", err)
+	}
+	if err := recorder.RecordExecution(map[string]interface{}{"success": true, "output": "done"}); err != nil {
+		t.Fatalf("Failed to record execution: %v", err)
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 7 {
+		t.Errorf("Expected 7 lines without duplicates, got %d", lines)
+	}
+
+	loaded, err := LoadTrajectory(filePath)
+	if err != nil {
+		t.Fatalf("Failed to load trajectory: %v", err)
+	}
+	results := loaded.GetToolResults()
+	if len(results) != 1 || results[0].CallID != "call_1" || results[0].Name != "bash" {
+		t.Errorf("Expected typed tool result filled from call, got %+v", results)
+	}
+	if len(loaded.GetSteps()) != 1 || len(loaded.GetErrors()) != 1 {
+		t.Errorf("Expected 1 step and 1 error, got %d/%d", len(loaded.GetSteps()), len(loaded.GetErrors()))
+	}
+	if execution := loaded.GetExecution(); execution == nil || !execution.Success || execution.Output != "done" {
+		t.Errorf("Expected execution to be restored, got %+v", execution)
+	}
+	if value, _ := loaded.GetMetadata("task"); value != "list files" {
+		t.Errorf("Expected metadata to be restored, got %v", value)
+	}
+}
+
+func TestTrajectoryReader(t *testing.T) {
+	input := `{"schema_version":1,"seq":1,"type":"session_start","timestamp":"2024-01-01T00:00:00Z","session":{"start_time":"2024-01-01T00:00:00Z"}}
+
+{"schema_version":1,"seq":2,"type":"error","timestamp":"2024-01-01T00:00:01Z","error":{"source":"llm","message":"timeout"}}
+`
+	reader := NewTrajectoryReader(strings.NewReader(input))
+	first, err := reader.Next()
+	if err != nil || first.Type != EventSessionStart {
+		t.Fatalf("Expected session_start event, got %v, %v", first, err)
+	}
+	second, err := reader.Next()
+	if err != nil || second.Error == nil || second.Error.Message != "timeout" {
+		t.Fatalf("Expected error event, got %v, %v", second, err)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF at end, got %v", err)
+	}
+
+	// 拒绝更新版本的事件
+	future := NewTrajectoryReader(strings.NewReader(`{"schema_version":99,"seq":1,"type":"step"}`))
+	if _, err := future.Next(); err == nil {
+		t.Error("Expected error for unsupported schema version")
+	}
+
+	invalid := NewTrajectoryReader(strings.NewReader("not json\n"))
+	if _, err := invalid.Next(); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected line number in error, got %v", err)
+	}
+}
+
+func TestConvertJSONToJSONL(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "trajectory.json")
+	recorder := NewTrajectoryRecorder(jsonPath)
+
+	toolCall := llm.ToolCall{ID: "call_1", Function: llm.ToolCallFunction{Name: "bash"}}
+	recorder.AddMetadata("model", "gpt-4o")
+	if err := recorder.RecordLLMInteraction(llm.LLMInteraction{Timestamp: time.Now(), Model: "gpt-4o"}); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+	if err := recorder.RecordToolCall(toolCall); err != nil {
+		t.Fatalf("Failed to record tool call: %v", err)
+	}
+	if err := recorder.RecordToolResult(toolCall, llm.ToolExecutionResult{Success: true}); err != nil {
+		t.Fatalf("Failed to record tool result: %v", err)
+	}
+	if err := recorder.RecordMessage(llm.LLMMessage{Role: "user", Content: "hi"}); err != nil {
+		t.Fatalf("Failed to record message: %v", err)
+	}
+	if err := recorder.RecordExecution(map[string]interface{}{"success": true}); err != nil {
+		t.Fatalf("Failed to record execution: %v", err)
+	}
+
+	jsonlPath := filepath.Join(dir, "trajectory.jsonl")
+	count, err := ConvertJSONToJSONL(jsonPath, jsonlPath)
+	if err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+
+	events, err := ReadTrajectoryEvents(jsonlPath)
+	if err != nil {
+		t.Fatalf("Failed to read converted events: %v", err)
+	}
+	if count != len(events) {
+		t.Errorf("Expected count %d to match events %d", count, len(events))
+	}
+
+	expected := []EventType{EventSessionStart, EventMetadata, EventLLMCall, EventToolCall, EventToolResult, EventMessage, EventExecution}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(events))
+	}
+	for i, eventType := range expected {
+		if events[i].Type != eventType {
+			t.Errorf("Event %d: expected %s, got %s", i, eventType, events[i].Type)
+		}
+		if events[i].Seq != i+1 {
+			t.Errorf("Event %d: expected seq %d, got %d", i, i+1, events[i].Seq)
+		}
+	}
+	if _, err := events[6].DecodeExecution(); err != nil {
+		t.Errorf("Expected execution to decode, got %v", err)
+	}
+
+	// 已是JSONL的文件不再转换
+	if _, err := ConvertJSONToJSONL(jsonlPath, filepath.Join(dir, "again.jsonl")); err == nil {
+		t.Error("Expected error when converting a JSONL file")
+	}
+}
+
+func TestLegacyTrajectoryEvents_Order(t *testing.T) {
+	// 旧格式按记录类型分组保存，转换后应按时间交错排列
+	data := []byte(`{
+		"metadata": {"start_time": "2025-01-01T10:00:00Z"},
+		"llm_interactions": [
+			{"timestamp": "2025-01-01T10:00:01Z", "model": "first"},
+			{"timestamp": "2025-01-01T10:00:05Z", "model": "second"}
+		],
+		"tool_calls": [{"id": "call_1", "function": {"name": "bash"}}],
+		"tool_results": [{"tool_call_id": "call_1", "tool_name": "bash", "result": {"success": true}, "timestamp": "2025-01-01T10:00:02Z"}],
+		"steps": [
+			{"step_number": 2, "action": "second step", "timestamp": "2025-01-01T10:00:06Z"},
+			{"step_number": 1, "action": "first step", "timestamp": "2025-01-01T10:00:03Z"}
+		],
+		"lakeview": [{"step_number": 1, "summary": "first", "timestamp": "2025-01-01T10:00:03Z"}],
+		"errors": [{"source": "tool", "message": "boom", "timestamp": "2025-01-01T10:00:04Z"}],
+		"custom_metadata": {"model": "gpt-4o"},
+		"agent_execution": {"success": true}
+	}`)
+
+	events, err := legacyTrajectoryEvents(data)
+	if err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+
+	types := make([]string, 0, len(events))
+	for i, event := range events {
+		types = append(types, string(event.Type))
+		if event.Seq != i+1 {
+			t.Errorf("Event %d: expected seq %d, got %d", i, i+1, event.Seq)
+		}
+		if i > 0 && event.Timestamp.Before(events[i-1].Timestamp) {
+			t.Errorf("Event %d (%s) is earlier than the previous event", i, event.Type)
+		}
+	}
+	expected := "session_start,metadata,llm_call,tool_call,tool_result,step,lakeview,error,llm_call,step,execution"
+	if got := strings.Join(types, ","); got != expected {
+		t.Errorf("Expected events %s, got %s", expected, got)
+	}
+
+	// 元数据只以metadata事件出现一次
+	if events[0].Session.Metadata != nil {
+		t.Errorf("Expected session_start without metadata, got %v", events[0].Session.Metadata)
+	}
+	if events[1].Metadata.Key != "model" || events[1].Metadata.Value != "gpt-4o" {
+		t.Errorf("Expected model metadata event, got %+v", events[1].Metadata)
+	}
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"trage-agent-go/pkg/llm"
+)
+
+// newExportFixture 构造一条包含命令和文件修改的轨迹
+func newExportFixture(t *testing.T) (*TrajectoryRecorder, string) {
+	t.Helper()
+	dir := t.TempDir()
+	recorder := NewTrajectoryRecorder(filepath.Join(dir, "trajectory.jsonl"))
+	recorder.AddMetadata("task", "Fix the build\nand add a test")
+	recorder.AddMetadata("model", "gpt-4o")
+	recorder.AddMetadata("provider", "openai")
+
+	calls := []llm.ToolCall{
+		{ID: "call_1", Function: llm.ToolCallFunction{Name: "bash", Arguments: map[string]interface{}{"command": "go build ./..."}}},
+		{ID: "call_2", Function: llm.ToolCallFunction{Name: "edit_file", Arguments: map[string]interface{}{"file_path": "main.go", "content": "package main"}}},
+		{ID: "call_3", Function: llm.ToolCallFunction{Name: "edit_file", Arguments: map[string]interface{}{"file_path": "main.go", "content": "package main\n"}}},
+	}
+	recorder.RecordLLMInteraction(llm.LLMInteraction{Usage: &llm.Usage{PromptTokens: 30, CompletionTokens: 12, TotalTokens: 42}})
+	for _, toolCall := range calls {
+		recorder.RecordToolCall(toolCall)
+		recorder.RecordToolResult(toolCall, llm.ToolExecutionResult{Success: toolCall.ID != "call_1", Error: "exit 1"})
+	}
+	recorder.RecordExecution(map[string]interface{}{"success": true, "output": "Build fixed ```ok```"})
+	return recorder, dir
+}
+
+func TestTrajectoryRecorder_ExportJSONAndYAML(t *testing.T) {
+	recorder, dir := newExportFixture(t)
+
+	jsonPath := filepath.Join(dir, "out", "trajectory.json")
+	if err := recorder.ExportToFormat("json", jsonPath); err != nil {
+		t.Fatalf("Failed to export json: %v", err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("Expected json to be written to output path: %v", err)
+	}
+	var jsonDoc map[string]interface{}
+	if err := json.Unmarshal(data, &jsonDoc); err != nil {
+		t.Fatalf("Expected valid JSON: %v", err)
+	}
+
+	yamlPath := filepath.Join(dir, "out", "trajectory.yaml")
+	if err := recorder.ExportToFormat("yaml", yamlPath); err != nil {
+		t.Fatalf("Failed to export yaml: %v", err)
+	}
+	data, err = os.ReadFile(yamlPath)
+	if err != nil {
+		t.Fatalf("Failed to read yaml: %v", err)
+	}
+	var yamlDoc map[string]interface{}
+	if err := yaml.Unmarshal(data, &yamlDoc); err != nil {
+		t.Fatalf("Expected valid YAML: %v", err)
+	}
+
+	// YAML与JSON字段一致
+	for key := range jsonDoc {
+		if _, exists := yamlDoc[key]; !exists {
+			t.Errorf("Expected key %q in YAML export", key)
+		}
+	}
+	if calls, ok := yamlDoc["tool_calls"].([]interface{}); !ok || len(calls) != 3 {
+		t.Errorf("Expected 3 tool calls in YAML, got %v", yamlDoc["tool_calls"])
+	}
+
+	// 导出JSON不应改动原轨迹文件
+	events, err := ReadTrajectoryEvents(recorder.GetTrajectoryPath())
+	if err != nil || DetectTrajectoryFormat(mustRead(t, recorder.GetTrajectoryPath())) != TrajectoryFormatJSONL {
+		t.Errorf("Expected original trajectory to remain JSONL, got %v", err)
+	}
+	if len(events) == 0 {
+		t.Error("Expected original trajectory events to be preserved")
+	}
+}
+
+func TestTrajectoryRecorder_ExportMarkdown(t *testing.T) {
+	recorder, dir := newExportFixture(t)
+
+	mdPath := filepath.Join(dir, "summary.md")
+	if err := recorder.ExportToFormat("md", mdPath); err != nil {
+		t.Fatalf("Failed to export markdown: %v", err)
+	}
+	markdown := string(mustRead(t, mdPath))
+
+	checks := []string{
+		"> Fix the build\n> and add a test",
+		"- 结果: ✅ 成功",
+		"- 模型: `gpt-4o`（openai）",
+		"- 令牌: 42（输入 30 / 输出 12）",
+		"1. ❌ `bash` — `go build ./...`",
+		"2. ✅ `edit_file` — `main.go`",
+		"```bash\ngo build ./...\n```",
+		"### 修改的文件\n\n- `main.go`\n\n",
+		"````\nBuild fixed ```ok```\n````",
+	}
+	for _, check := range checks {
+		if !strings.Contains(markdown, check) {
+			t.Errorf("Expected markdown to contain %q, got:\n%s", check, markdown)
+		}
+	}
+
+	if err := recorder.ExportToFormat("pdf", filepath.Join(dir, "x.pdf")); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+// mustRead 读取文件内容
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return data
+}
@@ -0,0 +1,523 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+
+	"trage-agent-go/pkg/llm"
+	"trage-agent-go/pkg/textdiff"
+)
+
+// htmlReport HTML报告的数据模型
+type htmlReport struct {
+	Title            string
+	Task             string
+	Model            string
+	Provider         string
+	StartTime        string
+	Duration         string
+	Outcome          *ExecutionRecord
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	ToolCalls        int
+	ErrorCount       int
+	Preamble         []*htmlItem
+	Steps            []*htmlStep
+	TokenChart       template.HTML
+	LatencyChart     template.HTML
+	GeneratedAt      string
+}
+
+// htmlStep 一次LLM调用及其引发的工具调用
+type htmlStep struct {
+	Number       int
+	Timestamp    string
+	Usage        *llm.Usage
+	Latency      time.Duration
+	FinishReason string
+	Error        string
+	Content      string
+	Items        []*htmlItem
+	toolIDs      map[string]bool
+}
+
+// htmlItem 时间线中的一个条目
+type htmlItem struct {
+	Kind      string
+	Title     string
+	Content   string
+	Args      template.HTML
+	Diff      []htmlDiffLine
+	DiffNote  string
+	HasResult bool
+	Success   bool
+	Result    string
+	Error     string
+	Duration  time.Duration
+}
+
+// htmlDiffLine 差异中的一行
+type htmlDiffLine struct {
+	Class string
+	Text  string
+}
+
+// exportToHTML 导出为自包含的HTML报告
+func (tr *TrajectoryRecorder) exportToHTML(outputPath string) error {
+	report := tr.buildHTMLReport()
+
+	var buf bytes.Buffer
+	if err := htmlReportTemplate.Execute(&buf, report); err != nil {
+		return fmt.Errorf("failed to render html report: %w", err)
+	}
+	return os.WriteFile(outputPath, buf.Bytes(), 0644)
+}
+
+// buildHTMLReport 从轨迹事件构建报告数据
+func (tr *TrajectoryRecorder) buildHTMLReport() *htmlReport {
+	events := tr.GetEvents()
+
+	tr.mutex.Lock()
+	report := &htmlReport{
+		Title:       "Trae Agent 执行报告",
+		StartTime:   tr.startTime.Format("2006-01-02 15:04:05"),
+		ToolCalls:   len(tr.toolCalls),
+		ErrorCount:  len(tr.errors),
+		GeneratedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	report.Task, _ = tr.metadata["task"].(string)
+	report.Model, _ = tr.metadata["model"].(string)
+	report.Provider, _ = tr.metadata["provider"].(string)
+	tr.mutex.Unlock()
+
+	if execution := tr.GetExecution(); execution != nil {
+		report.Outcome = execution
+		report.Duration = execution.Duration.Round(time.Millisecond).String()
+	}
+
+	var current *htmlStep
+	toolItems := make(map[string]*htmlItem)
+	files := make(map[string]string)
+
+	// 工具调用按ID归属到发起它的LLM调用，兼容旧格式中事件不按时间交错的情况
+	stepForTool := func(callID string) *htmlStep {
+		for _, step := range report.Steps {
+			if step.toolIDs[callID] {
+				return step
+			}
+		}
+		return current
+	}
+	appendItem := func(step *htmlStep, item *htmlItem) {
+		if step == nil {
+			report.Preamble = append(report.Preamble, item)
+			return
+		}
+		step.Items = append(step.Items, item)
+	}
+
+	for _, event := range events {
+		switch event.Type {
+		case EventLLMCall:
+			call := event.LLMCall
+			current = &htmlStep{
+				Number:       len(report.Steps) + 1,
+				Timestamp:    call.Timestamp.Format("15:04:05"),
+				Usage:        call.Usage,
+				Latency:      call.Latency,
+				FinishReason: call.FinishReason,
+				Error:        call.Error,
+				toolIDs:      make(map[string]bool),
+			}
+			if call.Response != nil {
+				current.Content = call.Response.Content
+				for _, toolCall := range call.Response.ToolCalls {
+					current.toolIDs[toolCall.ID] = true
+				}
+			}
+			if call.Usage != nil {
+				report.PromptTokens += call.Usage.PromptTokens
+				report.CompletionTokens += call.Usage.CompletionTokens
+				report.TotalTokens += call.Usage.TotalTokens
+			}
+			report.Steps = append(report.Steps, current)
+
+		case EventToolCall:
+			toolCall := event.ToolCall
+			item := &htmlItem{
+				Kind:  "tool",
+				Title: toolCall.Function.Name,
+				Args:  highlightJSON(toolCall.Function.Arguments),
+			}
+			item.Diff, item.DiffNote = editDiff(toolCall.Function.Arguments, files)
+			toolItems[toolCall.ID] = item
+			appendItem(stepForTool(toolCall.ID), item)
+
+		case EventToolResult:
+			result := event.ToolResult
+			item, exists := toolItems[result.CallID]
+			if !exists {
+				item = &htmlItem{Kind: "tool", Title: result.Name}
+				appendItem(stepForTool(result.CallID), item)
+			}
+			item.HasResult = true
+			item.Success = result.Success
+			item.Result = result.Result
+			item.Error = result.Error
+			item.Duration = result.Duration
+
+		case EventError:
+			appendItem(current, &htmlItem{Kind: "error", Title: event.Error.Source, Content: event.Error.Message})
+
+		case EventMessage:
+			// 助手与工具消息已体现在LLM调用和工具结果中
+			switch event.Message.Role {
+			case "user":
+				appendItem(current, &htmlItem{Kind: "user", Title: "用户", Content: event.Message.Content})
+			case "thought":
+				appendItem(current, &htmlItem{Kind: "thought", Title: "思考", Content: event.Message.Content})
+			}
+		}
+	}
+
+	report.TokenChart = tokenChart(report.Steps)
+	report.LatencyChart = latencyChart(report.Steps)
+	return report
+}
+
+// editDiff 根据文件编辑类工具的参数生成差异，files记录轨迹中已知的文件内容
+func editDiff(args map[string]interface{}, files map[string]string) ([]htmlDiffLine, string) {
+	path, _ := args["file_path"].(string)
+	if path == "" {
+		path, _ = args["path"].(string)
+	}
+
+	var oldText, newText, note string
+	if oldStr, ok := args["old_str"].(string); ok {
+		newStr, _ := args["new_str"].(string)
+		oldText, newText = oldStr, newStr
+	} else if content, ok := args["content"].(string); ok && path != "" {
+		previous, known := files[path]
+		mode, _ := args["mode"].(string)
+		if mode == "append" {
+			if known {
+				newText = previous + "\n" + content
+				oldText = previous
+			} else {
+				newText = content
+				note = "追加内容（原文件内容未记录）"
+			}
+		} else {
+			oldText, newText = previous, content
+			if !known {
+				note = "新文件或原内容未记录"
+			}
+		}
+		if known || mode != "append" {
+			files[path] = newText
+		}
+	} else if fileText, ok := args["file_text"].(string); ok && path != "" {
+		newText = fileText
+		files[path] = fileText
+		note = "创建文件"
+	} else {
+		return nil, ""
+	}
+
+	hunks := textdiff.Hunks(textdiff.Lines(textdiff.SplitLines(oldText), textdiff.SplitLines(newText)), 3)
+	lines := make([]htmlDiffLine, 0)
+	for i, hunk := range hunks {
+		if i > 0 {
+			lines = append(lines, htmlDiffLine{Class: "gap", Text: "…"})
+		}
+		for _, op := range hunk {
+			class := "ctx"
+			switch op.Kind {
+			case textdiff.OpInsert:
+				class = "add"
+			case textdiff.OpDelete:
+				class = "del"
+			}
+			lines = append(lines, htmlDiffLine{Class: class, Text: string(op.Kind) + op.Text})
+		}
+	}
+	return lines, note
+}
+
+// highlightJSON 将值格式化为带语法高亮的JSON
+func highlightJSON(value interface{}) template.HTML {
+	// 保留原始的<>&字符，转义统一在输出时处理
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return template.HTML(html.EscapeString(fmt.Sprintf("%v", value)))
+	}
+
+	var sb strings.Builder
+	text := strings.TrimSuffix(buf.String(), "\n")
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '"':
+			// 找到字符串结尾，跳过转义字符
+			j := i + 1
+			for j < len(text) && text[j] != '"' {
+				if text[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(text) {
+				j++
+			}
+			class := "str"
+			if rest := strings.TrimLeft(text[j:], " "); strings.HasPrefix(rest, ":") {
+				class = "key"
+			}
+			sb.WriteString(`<span class="` + class + `">` + html.EscapeString(text[i:j]) + `</span>`)
+			i = j
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(text) && strings.IndexByte("0123456789.eE+-", text[j]) >= 0 {
+				j++
+			}
+			sb.WriteString(`<span class="num">` + text[i:j] + `</span>`)
+			i = j
+		case strings.HasPrefix(text[i:], "true"), strings.HasPrefix(text[i:], "null"):
+			sb.WriteString(`<span class="lit">` + text[i:i+4] + `</span>`)
+			i += 4
+		case strings.HasPrefix(text[i:], "false"):
+			sb.WriteString(`<span class="lit">false</span>`)
+			i += 5
+		default:
+			sb.WriteString(html.EscapeString(string(c)))
+			i++
+		}
+	}
+	return template.HTML(sb.String())
+}
+
+// chartBar 图表中的一根柱子
+type chartBar struct {
+	label  string
+	values []float64
+	title  string
+}
+
+// renderBarChart 生成内联SVG柱状图，values按颜色顺序堆叠
+func renderBarChart(bars []chartBar, colors []string, unit string) template.HTML {
+	if len(bars) == 0 {
+		return template.HTML(`<p class="muted">没有数据</p>`)
+	}
+
+	maxValue := 0.0
+	for _, bar := range bars {
+		total := 0.0
+		for _, v := range bar.values {
+			total += v
+		}
+		if total > maxValue {
+			maxValue = total
+		}
+	}
+	if maxValue == 0 {
+		maxValue = 1
+	}
+
+	const height, top, bottom, left = 160.0, 16.0, 20.0, 48.0
+	barWidth := 24.0
+	gap := 8.0
+	width := left + float64(len(bars))*(barWidth+gap) + gap
+	plot := height - top - bottom
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg class="chart" viewBox="0 0 %.0f %.0f" width="%.0f" height="%.0f">`, width, height, width, height)
+	fmt.Fprintf(&sb, `<line x1="%.0f" y1="%.0f" x2="%.0f" y2="%.0f" class="axis"/>`, left, top+plot, width, top+plot)
+	fmt.Fprintf(&sb, `<text x="%.0f" y="%.0f" class="axis-label" text-anchor="end">%s</text>`, left-4, top+4, html.EscapeString(formatChartValue(maxValue, unit)))
+	fmt.Fprintf(&sb, `<text x="%.0f" y="%.0f" class="axis-label" text-anchor="end">0</text>`, left-4, top+plot)
+
+	for i, bar := range bars {
+		x := left + gap + float64(i)*(barWidth+gap)
+		y := top + plot
+		fmt.Fprintf(&sb, `<g><title>%s</title>`, html.EscapeString(bar.title))
+		for k, v := range bar.values {
+			h := v / maxValue * plot
+			y -= h
+			fmt.Fprintf(&sb, `<rect x="%.1f" y="%.1f" width="%.0f" height="%.1f" fill="%s"/>`, x, y, barWidth, h, colors[k%len(colors)])
+		}
+		fmt.Fprintf(&sb, `</g><text x="%.1f" y="%.0f" class="axis-label" text-anchor="middle">%s</text>`, x+barWidth/2, height-4, html.EscapeString(bar.label))
+	}
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
+
+// formatChartValue 格式化坐标轴数值
+func formatChartValue(value float64, unit string) string {
+	if unit == "s" {
+		return fmt.Sprintf("%.1fs", value)
+	}
+	return fmt.Sprintf("%.0f", value)
+}
+
+// tokenChart 每步令牌用量图
+func tokenChart(steps []*htmlStep) template.HTML {
+	bars := make([]chartBar, 0, len(steps))
+	for _, step := range steps {
+		prompt, completion := 0, 0
+		if step.Usage != nil {
+			prompt, completion = step.Usage.PromptTokens, step.Usage.CompletionTokens
+		}
+		bars = append(bars, chartBar{
+			label:  fmt.Sprintf("%d", step.Number),
+			values: []float64{float64(prompt), float64(completion)},
+			title:  fmt.Sprintf("步骤 %d: 输入 %d, 输出 %d", step.Number, prompt, completion),
+		})
+	}
+	return renderBarChart(bars, []string{"#6b8fd6", "#e3a04f"}, "")
+}
+
+// latencyChart 每步延迟图
+func latencyChart(steps []*htmlStep) template.HTML {
+	bars := make([]chartBar, 0, len(steps))
+	for _, step := range steps {
+		bars = append(bars, chartBar{
+			label:  fmt.Sprintf("%d", step.Number),
+			values: []float64{step.Latency.Seconds()},
+			title:  fmt.Sprintf("步骤 %d: %s", step.Number, step.Latency.Round(time.Millisecond)),
+		})
+	}
+	return renderBarChart(bars, []string{"#5aa469"}, "s")
+}
+
+// htmlReportTemplate HTML报告模板，样式与脚本全部内联，便于离线查看
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms": func(d time.Duration) string { return d.Round(time.Millisecond).String() },
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "PingFang SC", sans-serif; margin: 0; background: #f6f7f9; color: #1f2328; }
+main { max-width: 1100px; margin: 0 auto; padding: 24px; }
+h1 { margin: 0 0 8px; font-size: 22px; }
+h2 { font-size: 17px; margin: 28px 0 12px; }
+.card { background: #fff; border: 1px solid #d8dee4; border-radius: 6px; padding: 16px; margin-bottom: 12px; }
+.summary { display: grid; grid-template-columns: repeat(auto-fit, minmax(150px, 1fr)); gap: 12px; }
+.metric .value { font-size: 20px; font-weight: 600; }
+.metric .label, .muted { color: #656d76; font-size: 13px; }
+.task { white-space: pre-wrap; }
+.ok { color: #1a7f37; } .fail { color: #cf222e; }
+.charts { display: flex; flex-wrap: wrap; gap: 24px; }
+.chart { max-width: 100%; overflow: visible; }
+.axis { stroke: #8c959f; } .axis-label { font-size: 10px; fill: #656d76; }
+.legend span { display: inline-block; width: 10px; height: 10px; margin: 0 4px 0 12px; }
+details { background: #fff; border: 1px solid #d8dee4; border-radius: 6px; margin-bottom: 8px; }
+details > summary { cursor: pointer; padding: 10px 14px; list-style: none; display: flex; gap: 12px; align-items: baseline; }
+details > summary::-webkit-details-marker { display: none; }
+details > summary::before { content: "▸"; color: #656d76; }
+details[open] > summary::before { content: "▾"; }
+details .body { padding: 0 14px 12px; }
+details details { margin: 8px 0; }
+.badge { font-size: 12px; padding: 1px 6px; border-radius: 10px; background: #eaeef2; }
+.badge.error { background: #ffebe9; color: #cf222e; } .badge.tool { background: #ddf4ff; color: #0969da; }
+.badge.thought { background: #fbefff; color: #8250df; } .badge.user { background: #dafbe1; color: #1a7f37; }
+pre { background: #f6f8fa; border-radius: 6px; padding: 10px; overflow-x: auto; white-space: pre-wrap; word-break: break-word; font-size: 12.5px; margin: 6px 0; }
+.key { color: #0550ae; } .str { color: #0a3069; } .num { color: #953800; } .lit { color: #8250df; }
+.diff div { font-family: ui-monospace, Menlo, monospace; white-space: pre-wrap; font-size: 12.5px; padding: 0 6px; }
+.diff .add { background: #dafbe1; } .diff .del { background: #ffebe9; } .diff .gap { color: #8c959f; }
+.toolbar { margin: 8px 0 12px; } .toolbar button { margin-right: 8px; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+<div class="muted">开始于 {{.StartTime}}{{if .Duration}} · 耗时 {{.Duration}}{{end}}{{if .Model}} · {{.Provider}} / {{.Model}}{{end}}</div>
+
+{{if .Task}}<h2>任务</h2><div class="card task">{{.Task}}</div>{{end}}
+
+<h2>结果</h2>
+<div class="card">
+{{if .Outcome}}
+  {{if .Outcome.Success}}<div class="ok"><strong>✅ 成功</strong></div>{{else}}<div class="fail"><strong>❌ 失败</strong></div>{{end}}
+  {{if .Outcome.Output}}<pre>{{.Outcome.Output}}</pre>{{end}}
+  {{if .Outcome.Error}}<pre class="fail">{{.Outcome.Error}}</pre>{{end}}
+{{else}}
+  <div class="muted">轨迹中没有记录最终结果（运行可能被中断）</div>
+{{end}}
+</div>
+
+<div class="summary">
+  <div class="card metric"><div class="value">{{len .Steps}}</div><div class="label">LLM调用</div></div>
+  <div class="card metric"><div class="value">{{.ToolCalls}}</div><div class="label">工具调用</div></div>
+  <div class="card metric"><div class="value">{{.TotalTokens}}</div><div class="label">令牌（输入 {{.PromptTokens}} / 输出 {{.CompletionTokens}}）</div></div>
+  <div class="card metric"><div class="value">{{.ErrorCount}}</div><div class="label">错误</div></div>
+</div>
+
+<h2>每步用量</h2>
+<div class="card charts">
+  <div><div class="muted legend">令牌<span style="background:#6b8fd6"></span>输入<span style="background:#e3a04f"></span>输出</div>{{.TokenChart}}</div>
+  <div><div class="muted legend">延迟</div>{{.LatencyChart}}</div>
+</div>
+
+<h2>时间线</h2>
+<div class="toolbar">
+  <button type="button" onclick="toggleAll(true)">全部展开</button>
+  <button type="button" onclick="toggleAll(false)">全部折叠</button>
+</div>
+{{range .Preamble}}{{template "item" .}}{{end}}
+{{range .Steps}}
+<details>
+  <summary>
+    <strong>步骤 {{.Number}}</strong>
+    <span class="muted">{{.Timestamp}}</span>
+    {{if .Usage}}<span class="badge">{{.Usage.TotalTokens}} tokens</span>{{end}}
+    <span class="badge">{{ms .Latency}}</span>
+    {{if .Error}}<span class="badge error">错误</span>{{end}}
+    {{if .Items}}<span class="muted">{{len .Items}} 项</span>{{end}}
+  </summary>
+  <div class="body">
+    {{if .Error}}<pre class="fail">{{.Error}}</pre>{{end}}
+    {{if .Content}}<pre>{{.Content}}</pre>{{end}}
+    {{if .FinishReason}}<div class="muted">finish_reason: {{.FinishReason}}</div>{{end}}
+    {{range .Items}}{{template "item" .}}{{end}}
+  </div>
+</details>
+{{end}}
+
+<p class="muted">生成于 {{.GeneratedAt}}</p>
+</main>
+<script>
+function toggleAll(open) {
+  document.querySelectorAll("details").forEach(function (d) { d.open = open; });
+}
+</script>
+</body>
+</html>
+{{define "item"}}
+<details{{if eq .Kind "error"}} open{{end}}>
+  <summary>
+    <span class="badge {{.Kind}}">{{if eq .Kind "tool"}}工具{{else if eq .Kind "error"}}错误{{else}}{{.Title}}{{end}}</span>
+    {{if eq .Kind "tool"}}<strong>{{.Title}}</strong>
+      {{if .HasResult}}{{if .Success}}<span class="ok">成功</span>{{else}}<span class="fail">失败</span>{{end}}{{if .Duration}} <span class="muted">{{ms .Duration}}</span>{{end}}{{end}}
+    {{else if eq .Kind "error"}}<strong>{{.Title}}</strong>{{end}}
+  </summary>
+  <div class="body">
+    {{if .Content}}<pre>{{.Content}}</pre>{{end}}
+    {{if .Args}}<div class="muted">参数</div><pre>{{.Args}}</pre>{{end}}
+    {{if .Diff}}<div class="muted">文件变更{{if .DiffNote}}（{{.DiffNote}}）{{end}}</div><div class="diff">{{range .Diff}}<div class="{{.Class}}">{{.Text}}</div>{{end}}</div>{{end}}
+    {{if .Result}}<div class="muted">输出</div><pre>{{.Result}}</pre>{{end}}
+    {{if .Error}}<div class="muted">错误</div><pre class="fail">{{.Error}}</pre>{{end}}
+  </div>
+</details>
+{{end}}`))
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"trage-agent-go/pkg/llm"
+)
+
+func TestTrajectoryRecorder_ExportHTML(t *testing.T) {
+	dir := t.TempDir()
+	recorder := NewTrajectoryRecorder(filepath.Join(dir, "trajectory.jsonl"))
+	recorder.AddMetadata("task", "create <main.go>")
+
+	write := llm.ToolCall{ID: "call_1", Function: llm.ToolCallFunction{Name: "edit_file", Arguments: map[string]interface{}{
+		"file_path": "main.go", "content": "package main\n\nfunc main() {}\n",
+	}}}
+	rewrite := llm.ToolCall{ID: "call_2", Function: llm.ToolCallFunction{Name: "edit_file", Arguments: map[string]interface{}{
+		"file_path": "main.go", "content": "package main\n\nfunc main() { println(1) }\n",
+	}}}
+
+	for i, toolCall := range []llm.ToolCall{write, rewrite} {
+		if err := recorder.RecordLLMInteraction(llm.LLMInteraction{
+			Timestamp: time.Now(),
+			Response:  &llm.LLMMessage{Role: "assistant", ToolCalls: []llm.ToolCall{toolCall}},
+			Usage:     &llm.Usage{PromptTokens: 100 * (i + 1), CompletionTokens: 20, TotalTokens: 100*(i+1) + 20},
+			Latency:   time.Duration(i+1) * time.Second,
+		}); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+		recorder.RecordToolCall(toolCall)
+		recorder.RecordToolResult(toolCall, llm.ToolExecutionResult{Success: true, Result: "ok"})
+	}
+	recorder.RecordError("tool", "something <broke>")
+	recorder.RecordExecution(map[string]interface{}{"success": true, "output": "all done", "duration": int64(time.Second)})
+
+	outputPath := filepath.Join(dir, "report", "report.html")
+	if err := recorder.ExportToFormat("html", outputPath); err != nil {
+		t.Fatalf("Failed to export html: %v", err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	report := string(data)
+
+	checks := []string{
+		"create &lt;main.go&gt;",                       // 任务内容被转义
+		"something &lt;broke&gt;",                      // 错误内容被转义
+		`<span class="key">&#34;file_path&#34;</span>`, // 参数高亮
+		`<div class="del">-func main() {}</div>`,       // 第二次编辑显示差异
+		`<div class="add">&#43;func main() { println(1) }</div>`,
+		"<svg class=\"chart\"",
+		"all done",
+		"步骤 2",
+	}
+	for _, check := range checks {
+		if !strings.Contains(report, check) {
+			t.Errorf("Expected report to contain %q", check)
+		}
+	}
+	// 报告应可离线查看，不引用外部资源
+	if strings.Contains(report, "<script src") || strings.Contains(report, "<link ") {
+		t.Error("Expected no external resources in report")
+	}
+}
+
+func TestHighlightJSON(t *testing.T) {
+	got := string(highlightJSON(map[string]interface{}{"a": "x\"<y", "b": 1.5, "c": true, "d": nil}))
+	for _, check := range []string{
+		`<span class="key">&#34;a&#34;</span>`,
+		`<span class="str">&#34;x\&#34;&lt;y&#34;</span>`,
+		`<span class="num">1.5</span>`,
+		`<span class="lit">true</span>`,
+		`<span class="lit">null</span>`,
+	} {
+		if !strings.Contains(got, check) {
+			t.Errorf("Expected %q in %s", check, got)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"trage-agent-go/pkg/llm"
+)
+
+// markdownMaxOutput Markdown中单段输出的最大字符数，避免PR描述过长
+const markdownMaxOutput = 2000
+
+// exportToMarkdown 导出为适合粘贴到PR描述的Markdown
+func (tr *TrajectoryRecorder) exportToMarkdown(outputPath string) error {
+	return os.WriteFile(outputPath, []byte(tr.RenderMarkdown()), 0644)
+}
+
+// RenderMarkdown 生成Markdown格式的执行摘要
+func (tr *TrajectoryRecorder) RenderMarkdown() string {
+	execution := tr.GetExecution()
+
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("## Trae Agent 执行摘要\n\n")
+
+	// 任务
+	if task, _ := tr.metadata["task"].(string); task != "" {
+		sb.WriteString("### 任务\n\n")
+		for _, line := range strings.Split(strings.TrimSpace(task), "\n") {
+			sb.WriteString("> " + line + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	// 摘要
+	sb.WriteString("### 摘要\n\n")
+	switch {
+	case execution == nil:
+		sb.WriteString("- 结果: ⚠️ 未完成（轨迹中没有最终结果）\n")
+	case execution.Success:
+		sb.WriteString("- 结果: ✅ 成功\n")
+	default:
+		sb.WriteString("- 结果: ❌ 失败\n")
+	}
+	if model, _ := tr.metadata["model"].(string); model != "" {
+		provider, _ := tr.metadata["provider"].(string)
+		fmt.Fprintf(&sb, "- 模型: `%s`", model)
+		if provider != "" {
+			fmt.Fprintf(&sb, "（%s）", provider)
+		}
+		sb.WriteString("\n")
+	}
+	usage := llm.Usage{}
+	for _, interaction := range tr.llmInteractions {
+		if interaction.Usage != nil {
+			usage.PromptTokens += interaction.Usage.PromptTokens
+			usage.CompletionTokens += interaction.Usage.CompletionTokens
+			usage.TotalTokens += interaction.Usage.TotalTokens
+		}
+	}
+	fmt.Fprintf(&sb, "- LLM调用: %d，工具调用: %d\n", len(tr.llmInteractions), len(tr.toolCalls))
+	fmt.Fprintf(&sb, "- 令牌: %d（输入 %d / 输出 %d）\n", usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens)
+	duration := tr.endTimeLocked().Sub(tr.startTime)
+	if execution != nil && execution.Duration > 0 {
+		duration = execution.Duration
+	}
+	fmt.Fprintf(&sb, "- 耗时: %s\n", duration.Round(time.Second))
+	if len(tr.errors) > 0 {
+		fmt.Fprintf(&sb, "- 错误: %d\n", len(tr.errors))
+	}
+	sb.WriteString("\n")
+
+	// 步骤
+	results := make(map[string]llm.ToolExecutionResult)
+	for _, result := range tr.toolResults {
+		results[result.CallID] = result
+	}
+	if len(tr.toolCalls) > 0 {
+		sb.WriteString("### 步骤\n\n")
+		for i, toolCall := range tr.toolCalls {
+			status := "⏳"
+			if result, ok := results[toolCall.ID]; ok {
+				status = "✅"
+				if !result.Success {
+					status = "❌"
+				}
+			}
+			fmt.Fprintf(&sb, "%d. %s `%s`", i+1, status, toolCall.Function.Name)
+			if summary := summarizeToolCall(toolCall); summary != "" {
+				sb.WriteString(" — " + summary)
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	// 执行的命令
+	commands := make([]string, 0)
+	for _, toolCall := range tr.toolCalls {
+		if command, ok := toolCall.Function.Arguments["command"].(string); ok && toolCall.Function.Name == "bash" {
+			commands = append(commands, command)
+		}
+	}
+	if len(commands) > 0 {
+		sb.WriteString("### 执行的命令\n\n```bash\n")
+		for _, command := range commands {
+			sb.WriteString(strings.TrimRight(command, "\n") + "\n")
+		}
+		sb.WriteString("```\n\n")
+	}
+
+	// 修改的文件
+	if files := changedFiles(tr.toolCalls); len(files) > 0 {
+		sb.WriteString("### 修改的文件\n\n")
+		for _, file := range files {
+			sb.WriteString("- `" + file + "`\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	// 输出
+	if execution != nil && (execution.Output != "" || execution.Error != "") {
+		sb.WriteString("### 输出\n\n")
+		text := execution.Output
+		if !execution.Success && execution.Error != "" {
+			text = execution.Error
+		}
+		sb.WriteString(fenced(truncateText(text, markdownMaxOutput)))
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// summarizeToolCall 用一行描述工具调用
+func summarizeToolCall(toolCall llm.ToolCall) string {
+	args := toolCall.Function.Arguments
+	for _, key := range []string{"command", "file_path", "path", "identifier", "thought"} {
+		if value, ok := args[key].(string); ok && value != "" {
+			value = strings.Join(strings.Fields(value), " ")
+			if len([]rune(value)) > 80 {
+				value = string([]rune(value)[:80]) + "…"
+			}
+			// 行内代码中的反引号会截断格式
+			return "`" + strings.ReplaceAll(value, "`", "'") + "`"
+		}
+	}
+	return ""
+}
+
+// changedFiles 收集编辑类工具修改过的文件，按首次出现的顺序
+func changedFiles(toolCalls []llm.ToolCall) []string {
+	seen := make(map[string]bool)
+	files := make([]string, 0)
+	for _, toolCall := range toolCalls {
+		args := toolCall.Function.Arguments
+		path, _ := args["file_path"].(string)
+		if path == "" {
+			path, _ = args["path"].(string)
+		}
+		if path == "" {
+			continue
+		}
+
+		// 只统计会写文件的调用
+		_, hasContent := args["content"]
+		_, hasNewStr := args["new_str"]
+		_, hasFileText := args["file_text"]
+		if !hasContent && !hasNewStr && !hasFileText {
+			continue
+		}
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// fenced 用足够长的反引号包裹代码块，避免内容中的```提前结束代码块
+func fenced(text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + "\n" + strings.TrimRight(text, "\n") + "\n" + fence + "\n"
+}
+
+// truncateText 截断过长的文本
+func truncateText(text string, maxLen int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return text
+	}
+	return string(runes[:maxLen]) + fmt.Sprintf("\n... (省略 %d 个字符)", len(runes)-maxLen)
+}
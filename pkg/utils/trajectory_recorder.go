@@ -1,23 +1,43 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"trage-agent-go/pkg/llm"
+
+	"gopkg.in/yaml.v3"
 )
 
 // TrajectoryRecorder 轨迹记录器
+//
+// 文件扩展名为.jsonl时按事件逐行追加写入，便于实时查看；
+// 其他扩展名沿用旧版的单文档JSON格式。
 type TrajectoryRecorder struct {
-	filePath    string
-	messages    []llm.LLMMessage
-	toolCalls   []llm.ToolCall
-	toolResults []interface{}
-	metadata    map[string]interface{}
-	startTime   time.Time
+	filePath        string
+	format          TrajectoryFormat
+	messages        []llm.LLMMessage
+	toolCalls       []llm.ToolCall
+	toolResults     []llm.ToolExecutionResult
+	llmInteractions []llm.LLMInteraction
+	steps           []llm.StepRecord
+	errors          []legacyError
+	lakeview        []llm.LakeviewRecord
+	execution       json.RawMessage
+	metadata        map[string]interface{}
+	startTime       time.Time
+	events          []TrajectoryEvent
+	seq             int
+	written         int
+	writeErr        error
+	autoSave        bool
+	mutex           sync.Mutex
 }
 
 // NewTrajectoryRecorder 创建新的轨迹记录器
@@ -25,70 +45,278 @@ func NewTrajectoryRecorder(filePath string) *TrajectoryRecorder {
 	if filePath == "" {
 		filePath = generateTrajectoryPath()
 	}
+	// 使用绝对路径，避免切换工作目录后写到其他位置
+	if absPath, err := filepath.Abs(filePath); err == nil {
+		filePath = absPath
+	}
+
+	tr := &TrajectoryRecorder{
+		filePath: filePath,
+		format:   formatForPath(filePath),
+		autoSave: true,
+	}
+	tr.resetLocked()
+	return tr
+}
 
-	return &TrajectoryRecorder{
-		filePath:    filePath,
-		messages:    make([]llm.LLMMessage, 0),
-		toolCalls:   make([]llm.ToolCall, 0),
-		toolResults: make([]interface{}, 0),
-		metadata:    make(map[string]interface{}),
-		startTime:   time.Now(),
+// formatForPath 根据扩展名选择轨迹格式
+func formatForPath(filePath string) TrajectoryFormat {
+	if strings.EqualFold(filepath.Ext(filePath), ".jsonl") {
+		return TrajectoryFormatJSONL
 	}
+	return TrajectoryFormatJSON
+}
+
+// SetAutoSave 设置是否在每次记录后立即保存
+func (tr *TrajectoryRecorder) SetAutoSave(autoSave bool) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.autoSave = autoSave
+}
+
+// GetFormat 获取轨迹文件格式
+func (tr *TrajectoryRecorder) GetFormat() TrajectoryFormat {
+	return tr.format
 }
 
 // RecordMessage 记录消息
 func (tr *TrajectoryRecorder) RecordMessage(message llm.LLMMessage) error {
-	tr.messages = append(tr.messages, message)
-	return nil
+	return tr.record(TrajectoryEvent{Type: EventMessage, Message: &message})
 }
 
 // RecordToolCall 记录工具调用
 func (tr *TrajectoryRecorder) RecordToolCall(toolCall llm.ToolCall) error {
-	tr.toolCalls = append(tr.toolCalls, toolCall)
-	return nil
+	return tr.record(TrajectoryEvent{Type: EventToolCall, ToolCall: &toolCall})
 }
 
 // RecordToolResult 记录工具结果
-func (tr *TrajectoryRecorder) RecordToolResult(toolCall llm.ToolCall, result interface{}) error {
-	tr.toolResults = append(tr.toolResults, result)
-	return nil
+func (tr *TrajectoryRecorder) RecordToolResult(toolCall llm.ToolCall, result llm.ToolExecutionResult) error {
+	if result.CallID == "" {
+		result.CallID = toolCall.ID
+	}
+	if result.Name == "" {
+		result.Name = toolCall.Function.Name
+	}
+	return tr.record(TrajectoryEvent{Type: EventToolResult, ToolResult: &result})
+}
+
+// RecordStep 记录代理执行步骤
+func (tr *TrajectoryRecorder) RecordStep(step llm.StepRecord) error {
+	if step.Timestamp.IsZero() {
+		step.Timestamp = time.Now()
+	}
+	return tr.record(TrajectoryEvent{Type: EventStep, Timestamp: step.Timestamp, Step: &step})
+}
+
+// RecordError 记录执行过程中的错误
+func (tr *TrajectoryRecorder) RecordError(source string, message string) error {
+	return tr.record(TrajectoryEvent{Type: EventError, Error: &ErrorRecord{Source: source, Message: message}})
+}
+
+// RecordLakeview 记录Lakeview的步骤总结
+func (tr *TrajectoryRecorder) RecordLakeview(record llm.LakeviewRecord) error {
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+	return tr.record(TrajectoryEvent{Type: EventLakeview, Timestamp: record.Timestamp, Lakeview: &record})
+}
+
+// RecordLLMInteraction 记录LLM调用
+func (tr *TrajectoryRecorder) RecordLLMInteraction(interaction llm.LLMInteraction) error {
+	return tr.record(TrajectoryEvent{Type: EventLLMCall, Timestamp: interaction.Timestamp, LLMCall: &interaction})
+}
+
+// RecordExecution 记录代理的最终执行结果
+func (tr *TrajectoryRecorder) RecordExecution(execution interface{}) error {
+	data, err := json.Marshal(execution)
+	if err != nil {
+		return fmt.Errorf("failed to marshal execution: %w", err)
+	}
+	return tr.record(TrajectoryEvent{Type: EventExecution, Execution: data})
+}
+
+// record 追加一个事件并按需写入文件
+func (tr *TrajectoryRecorder) record(event TrajectoryEvent) error {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.appendEventLocked(event)
+	return tr.autoSaveLocked()
+}
+
+// appendEventLocked 补全事件头并更新内存中的视图（调用方需持有锁）
+func (tr *TrajectoryRecorder) appendEventLocked(event TrajectoryEvent) {
+	tr.seq++
+	event.SchemaVersion = TrajectorySchemaVersion
+	event.Seq = tr.seq
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	tr.applyEventLocked(event)
+	tr.events = append(tr.events, event)
+}
+
+// applyEventLocked 将事件应用到内存中的视图（调用方需持有锁）
+func (tr *TrajectoryRecorder) applyEventLocked(event TrajectoryEvent) {
+	switch event.Type {
+	case EventSessionStart:
+		if event.Session != nil && !event.Session.StartTime.IsZero() {
+			tr.startTime = event.Session.StartTime
+		}
+	case EventMetadata:
+		if event.Metadata != nil {
+			tr.metadata[event.Metadata.Key] = event.Metadata.Value
+		}
+	case EventMessage:
+		if event.Message != nil {
+			tr.messages = append(tr.messages, *event.Message)
+		}
+	case EventLLMCall:
+		if event.LLMCall != nil {
+			tr.llmInteractions = append(tr.llmInteractions, *event.LLMCall)
+		}
+	case EventToolCall:
+		if event.ToolCall != nil {
+			tr.toolCalls = append(tr.toolCalls, *event.ToolCall)
+		}
+	case EventToolResult:
+		if event.ToolResult != nil {
+			tr.toolResults = append(tr.toolResults, *event.ToolResult)
+		}
+	case EventStep:
+		if event.Step != nil {
+			tr.steps = append(tr.steps, *event.Step)
+		}
+	case EventError:
+		if event.Error != nil {
+			tr.errors = append(tr.errors, legacyError{ErrorRecord: *event.Error, Timestamp: event.Timestamp})
+		}
+	case EventLakeview:
+		if event.Lakeview != nil {
+			tr.lakeview = append(tr.lakeview, *event.Lakeview)
+		}
+	case EventExecution:
+		tr.execution = event.Execution
+	}
 }
 
 // Save 保存轨迹
 func (tr *TrajectoryRecorder) Save() error {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.saveLocked()
+}
+
+// autoSaveLocked 开启自动保存时写入文件（调用方需持有锁）
+func (tr *TrajectoryRecorder) autoSaveLocked() error {
+	if !tr.autoSave {
+		return nil
+	}
+	return tr.saveLocked()
+}
+
+// saveLocked 保存轨迹（调用方需持有锁）
+func (tr *TrajectoryRecorder) saveLocked() error {
 	// 确保目录存在
 	dir := filepath.Dir(tr.filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	// 构建轨迹数据
+	if tr.format == TrajectoryFormatJSONL {
+		return tr.flushEventsLocked()
+	}
+	return tr.saveJSONLocked()
+}
+
+// flushEventsLocked 将尚未写入的事件追加到JSONL文件（调用方需持有锁）
+func (tr *TrajectoryRecorder) flushEventsLocked() error {
+	// AddMetadata无法返回错误，之前写入失败的错误在这里报告
+	if tr.writeErr != nil {
+		err := tr.writeErr
+		tr.writeErr = nil
+		return err
+	}
+	if tr.written >= len(tr.events) {
+		return nil
+	}
+
+	file, err := os.OpenFile(tr.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open trajectory file: %w", err)
+	}
+	defer file.Close()
+
+	// 先在内存中拼好再一次写入，减少出现半行的可能
+	var buf bytes.Buffer
+	if err := writeEvents(&buf, tr.events[tr.written:]); err != nil {
+		return err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write trajectory file: %w", err)
+	}
+	tr.written = len(tr.events)
+	return nil
+}
+
+// saveJSONLocked 以旧版单文档格式保存轨迹（调用方需持有锁）
+func (tr *TrajectoryRecorder) saveJSONLocked() error {
+	data, err := json.MarshalIndent(tr.jsonDocumentLocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal trajectory: %w", err)
+	}
+	return writeFileAtomic(tr.filePath, data)
+}
+
+// jsonDocumentLocked 构建旧版单文档格式的轨迹数据（调用方需持有锁）
+func (tr *TrajectoryRecorder) jsonDocumentLocked() map[string]interface{} {
+	endTime := tr.endTimeLocked()
 	trajectory := map[string]interface{}{
 		"metadata": map[string]interface{}{
-			"start_time":   tr.startTime.Format(time.RFC3339),
-			"end_time":     time.Now().Format(time.RFC3339),
-			"duration":     time.Since(tr.startTime).String(),
-			"total_messages": len(tr.messages),
-			"total_tool_calls": len(tr.toolCalls),
-			"total_tool_results": len(tr.toolResults),
+			"schema_version":         TrajectorySchemaVersion,
+			"start_time":             tr.startTime.Format(time.RFC3339),
+			"end_time":               endTime.Format(time.RFC3339),
+			"duration":               endTime.Sub(tr.startTime).String(),
+			"total_messages":         len(tr.messages),
+			"total_tool_calls":       len(tr.toolCalls),
+			"total_tool_results":     len(tr.toolResults),
+			"total_llm_interactions": len(tr.llmInteractions),
 		},
-		"messages":     tr.messages,
-		"tool_calls":  tr.toolCalls,
-		"tool_results": tr.toolResults,
-		"custom_metadata": tr.metadata,
+		"messages":         tr.messages,
+		"tool_calls":       tr.toolCalls,
+		"tool_results":     tr.toolResults,
+		"llm_interactions": tr.llmInteractions,
+		"steps":            tr.steps,
+		"errors":           tr.errors,
+		"custom_metadata":  tr.metadata,
 	}
+	if len(tr.lakeview) > 0 {
+		trajectory["lakeview"] = tr.lakeview
+	}
+	if tr.execution != nil {
+		trajectory["agent_execution"] = tr.execution
+	}
+	return trajectory
+}
 
-	// 序列化为JSON
-	data, err := json.MarshalIndent(trajectory, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal trajectory: %w", err)
+// endTimeLocked 获取最后一个事件的时间，加载的轨迹不应以当前时间作为结束时间（调用方需持有锁）
+func (tr *TrajectoryRecorder) endTimeLocked() time.Time {
+	if len(tr.events) > 0 {
+		if last := tr.events[len(tr.events)-1].Timestamp; last.After(tr.startTime) {
+			return last
+		}
 	}
+	return time.Now()
+}
 
-	// 写入文件
-	if err := os.WriteFile(tr.filePath, data, 0644); err != nil {
+// writeFileAtomic 先写临时文件再重命名，避免进程中途退出时留下损坏的文件
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write trajectory file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
 		return fmt.Errorf("failed to write trajectory file: %w", err)
 	}
-
 	return nil
 }
 
@@ -99,15 +327,95 @@ func (tr *TrajectoryRecorder) GetTrajectoryPath() string {
 
 // AddMetadata 添加元数据
 func (tr *TrajectoryRecorder) AddMetadata(key string, value interface{}) {
-	tr.metadata[key] = value
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.appendEventLocked(TrajectoryEvent{Type: EventMetadata, Metadata: &MetadataEntry{Key: key, Value: value}})
+
+	// JSONL格式下元数据同样实时写入；旧格式保持在下次保存时写入
+	if tr.format == TrajectoryFormatJSONL && tr.autoSave {
+		if err := tr.saveLocked(); err != nil {
+			tr.writeErr = err
+		}
+	}
 }
 
 // GetMetadata 获取元数据
 func (tr *TrajectoryRecorder) GetMetadata(key string) (interface{}, bool) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
 	value, exists := tr.metadata[key]
 	return value, exists
 }
 
+// GetEvents 获取全部轨迹事件
+func (tr *TrajectoryRecorder) GetEvents() []TrajectoryEvent {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	events := make([]TrajectoryEvent, len(tr.events))
+	copy(events, tr.events)
+	return events
+}
+
+// GetMessages 获取消息记录
+func (tr *TrajectoryRecorder) GetMessages() []llm.LLMMessage {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.messages
+}
+
+// GetLLMInteractions 获取LLM调用记录
+func (tr *TrajectoryRecorder) GetLLMInteractions() []llm.LLMInteraction {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.llmInteractions
+}
+
+// GetToolResults 获取工具结果记录
+func (tr *TrajectoryRecorder) GetToolResults() []llm.ToolExecutionResult {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.toolResults
+}
+
+// GetSteps 获取执行步骤记录
+func (tr *TrajectoryRecorder) GetSteps() []llm.StepRecord {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.steps
+}
+
+// GetLakeview 获取Lakeview步骤总结
+func (tr *TrajectoryRecorder) GetLakeview() []llm.LakeviewRecord {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.lakeview
+}
+
+// GetErrors 获取错误记录
+func (tr *TrajectoryRecorder) GetErrors() []ErrorRecord {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	records := make([]ErrorRecord, 0, len(tr.errors))
+	for _, entry := range tr.errors {
+		records = append(records, entry.ErrorRecord)
+	}
+	return records
+}
+
+// GetExecution 获取最终执行结果，尚未记录时返回nil
+func (tr *TrajectoryRecorder) GetExecution() *ExecutionRecord {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	if len(tr.execution) == 0 {
+		return nil
+	}
+	var record ExecutionRecord
+	if err := json.Unmarshal(tr.execution, &record); err != nil {
+		return nil
+	}
+	return &record
+}
+
 // GetMessageCount 获取消息数量
 func (tr *TrajectoryRecorder) GetMessageCount() int {
 	return len(tr.messages)
@@ -123,73 +431,78 @@ func (tr *TrajectoryRecorder) GetDuration() time.Duration {
 	return time.Since(tr.startTime)
 }
 
-// Reset 重置记录器
+// Reset 重置记录器，JSONL格式下新会话会继续追加到同一文件
 func (tr *TrajectoryRecorder) Reset() {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.resetLocked()
+}
+
+// resetLocked 清空记录并开始新会话（调用方需持有锁）
+func (tr *TrajectoryRecorder) resetLocked() {
 	tr.messages = make([]llm.LLMMessage, 0)
 	tr.toolCalls = make([]llm.ToolCall, 0)
-	tr.toolResults = make([]interface{}, 0)
+	tr.toolResults = make([]llm.ToolExecutionResult, 0)
+	tr.llmInteractions = make([]llm.LLMInteraction, 0)
+	tr.steps = make([]llm.StepRecord, 0)
+	tr.errors = make([]legacyError, 0)
+	tr.lakeview = make([]llm.LakeviewRecord, 0)
+	tr.execution = nil
 	tr.metadata = make(map[string]interface{})
 	tr.startTime = time.Now()
+	tr.events = make([]TrajectoryEvent, 0)
+	tr.written = 0
+	tr.appendEventLocked(TrajectoryEvent{Type: EventSessionStart, Session: &SessionInfo{StartTime: tr.startTime}})
 }
 
 // generateTrajectoryPath 生成轨迹文件路径
 func generateTrajectoryPath() string {
 	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("trajectory_%s.json", timestamp)
-	
+	filename := fmt.Sprintf("trajectory_%s.jsonl", timestamp)
+
 	// 创建trajectories目录
 	dir := "trajectories"
 	if err := os.MkdirAll(dir, 0755); err == nil {
 		return filepath.Join(dir, filename)
 	}
-	
+
 	// 如果无法创建目录，返回当前目录下的文件名
 	return filename
 }
 
-// LoadTrajectory 加载轨迹文件
+// LoadTrajectory 加载轨迹文件，自动识别JSONL与旧版JSON格式
 func LoadTrajectory(filePath string) (*TrajectoryRecorder, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read trajectory file: %w", err)
 	}
 
-	var trajectory map[string]interface{}
-	if err := json.Unmarshal(data, &trajectory); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal trajectory: %w", err)
+	format := DetectTrajectoryFormat(data)
+	var events []TrajectoryEvent
+	if format == TrajectoryFormatJSONL {
+		events, err = NewTrajectoryReader(bytes.NewReader(data)).ReadAll()
+	} else {
+		events, err = legacyTrajectoryEvents(data)
 	}
-
-	// 创建新的记录器
-	recorder := NewTrajectoryRecorder(filePath)
-
-	// 恢复消息
-	if messagesData, exists := trajectory["messages"]; exists {
-		if messagesBytes, err := json.Marshal(messagesData); err == nil {
-			var messages []llm.LLMMessage
-			if err := json.Unmarshal(messagesBytes, &messages); err == nil {
-				recorder.messages = messages
-			}
-		}
+	if err != nil {
+		return nil, err
 	}
 
-	// 恢复工具调用
-	if toolCallsData, exists := trajectory["tool_calls"]; exists {
-		if toolCallsBytes, err := json.Marshal(toolCallsData); err == nil {
-			var toolCalls []llm.ToolCall
-			if err := json.Unmarshal(toolCallsBytes, &toolCalls); err == nil {
-				recorder.toolCalls = toolCalls
-			}
+	// 创建新的记录器，加载的轨迹不自动回写
+	recorder := NewTrajectoryRecorder(filePath)
+	recorder.format = format
+	recorder.autoSave = false
+	recorder.events = make([]TrajectoryEvent, 0, len(events))
+	for _, event := range events {
+		recorder.applyEventLocked(event)
+		recorder.events = append(recorder.events, event)
+		if event.Seq > recorder.seq {
+			recorder.seq = event.Seq
 		}
 	}
-
-	// 恢复元数据
-	if metadataData, exists := trajectory["custom_metadata"]; exists {
-		if metadataBytes, err := json.Marshal(metadataData); err == nil {
-			var metadata map[string]interface{}
-			if err := json.Unmarshal(metadataBytes, &metadata); err == nil {
-				recorder.metadata = metadata
-			}
-		}
+	// 已在文件中的事件不再重复写入
+	if format == TrajectoryFormatJSONL {
+		recorder.written = len(recorder.events)
 	}
 
 	return recorder, nil
@@ -209,28 +522,61 @@ func (tr *TrajectoryRecorder) GetTrajectorySummary() map[string]interface{} {
 
 // ExportToFormat 导出为指定格式
 func (tr *TrajectoryRecorder) ExportToFormat(format string, outputPath string) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
 	switch format {
 	case "json":
 		return tr.exportToJSON(outputPath)
-	case "yaml":
+	case "yaml", "yml":
 		return tr.exportToYAML(outputPath)
 	case "txt":
 		return tr.exportToText(outputPath)
+	case "md", "markdown":
+		return tr.exportToMarkdown(outputPath)
+	case "html":
+		return tr.exportToHTML(outputPath)
 	default:
 		return fmt.Errorf("unsupported format: %s", format)
 	}
 }
 
+// ExportFormats 支持的导出格式
+func ExportFormats() []string {
+	return []string{"json", "yaml", "md", "html", "txt"}
+}
+
 // exportToJSON 导出为JSON格式
 func (tr *TrajectoryRecorder) exportToJSON(outputPath string) error {
-	return tr.Save()
+	tr.mutex.Lock()
+	data, err := json.MarshalIndent(tr.jsonDocumentLocked(), "", "  ")
+	tr.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal trajectory: %w", err)
+	}
+	return writeFileAtomic(outputPath, data)
 }
 
 // exportToYAML 导出为YAML格式
 func (tr *TrajectoryRecorder) exportToYAML(outputPath string) error {
-	// 这里需要实现YAML导出
-	// 暂时返回错误
-	return fmt.Errorf("YAML export not implemented yet")
+	tr.mutex.Lock()
+	data, err := json.Marshal(tr.jsonDocumentLocked())
+	tr.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal trajectory: %w", err)
+	}
+
+	// 经JSON中转，保证字段名与JSON格式一致
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("failed to marshal trajectory: %w", err)
+	}
+	yamlData, err := yaml.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trajectory to yaml: %w", err)
+	}
+	return writeFileAtomic(outputPath, yamlData)
 }
 
 // exportToText 导出为文本格式
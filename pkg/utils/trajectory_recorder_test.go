@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"trage-agent-go/pkg/llm"
+)
+
+func TestTrajectoryRecorder_IncrementalSave(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "trajectory.json")
+	recorder := NewTrajectoryRecorder(filePath)
+
+	toolCall := llm.ToolCall{
+		ID:       "call_1",
+		Function: llm.ToolCallFunction{Name: "bash", Arguments: map[string]interface{}{"command": "ls"}},
+	}
+
+	if err := recorder.RecordLLMInteraction(llm.LLMInteraction{
+		Timestamp: time.Now(),
+		Provider:  "openai",
+		Model:     "gpt-4o",
+		Response:  &llm.LLMMessage{Role: "assistant", ToolCalls: []llm.ToolCall{toolCall}},
+		Usage:     &llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		Latency:   time.Second,
+	}); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	// 每次记录后文件都应可用
+	if _, err := os.Stat(filePath); err != nil {
+		t.Fatalf("Expected trajectory file to be written after first record: %v", err)
+	}
+
+	if err := recorder.RecordToolCall(toolCall); err != nil {
+		t.Fatalf("Failed to record tool call: %v", err)
+	}
+	if err := recorder.RecordToolResult(toolCall, llm.ToolExecutionResult{Success: true, Result: "file.txt"}); err != nil {
+		t.Fatalf("Failed to record tool result: %v", err)
+	}
+	if err := recorder.RecordExecution(map[string]interface{}{"success": true}); err != nil {
+		t.Fatalf("Failed to record execution: %v", err)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read trajectory: %v", err)
+	}
+	var trajectory map[string]interface{}
+	if err := json.Unmarshal(data, &trajectory); err != nil {
+		t.Fatalf("Expected valid JSON trajectory: %v", err)
+	}
+	if _, exists := trajectory["agent_execution"]; !exists {
+		t.Error("Expected agent_execution in trajectory")
+	}
+
+	loaded, err := LoadTrajectory(filePath)
+	if err != nil {
+		t.Fatalf("Failed to load trajectory: %v", err)
+	}
+	interactions := loaded.GetLLMInteractions()
+	if len(interactions) != 1 {
+		t.Fatalf("Expected 1 interaction, got %d", len(interactions))
+	}
+	if interactions[0].Usage == nil || interactions[0].Usage.TotalTokens != 15 {
+		t.Errorf("Expected usage to be preserved, got %+v", interactions[0].Usage)
+	}
+	if interactions[0].Latency != time.Second {
+		t.Errorf("Expected latency 1s, got %v", interactions[0].Latency)
+	}
+	if loaded.GetToolCallCount() != 1 {
+		t.Errorf("Expected 1 tool call, got %d", loaded.GetToolCallCount())
+	}
+}
+
+func TestTrajectoryRecorder_AutoSaveDisabled(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "trajectory.json")
+	recorder := NewTrajectoryRecorder(filePath)
+	recorder.SetAutoSave(false)
+
+	if err := recorder.RecordMessage(llm.LLMMessage{Role: "user", Content: "hi"}); err != nil {
+		t.Fatalf("Failed to record message: %v", err)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Errorf("Expected no file before Save when auto save is disabled")
+	}
+
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		t.Errorf("Expected file after Save: %v", err)
+	}
+}
@@ -0,0 +1,393 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loopThreshold 同一轨迹中相同工具调用重复多少次视为循环
+const loopThreshold = 3
+
+// ModelPrice 模型价格（美元/百万令牌）
+type ModelPrice struct {
+	Input  float64 `yaml:"input" json:"input"`
+	Output float64 `yaml:"output" json:"output"`
+}
+
+// DefaultModelPrices 内置的常见模型价格，可通过价格文件覆盖
+func DefaultModelPrices() map[string]ModelPrice {
+	return map[string]ModelPrice{
+		"gpt-4o":        {Input: 2.5, Output: 10},
+		"gpt-4o-mini":   {Input: 0.15, Output: 0.6},
+		"gpt-4.1":       {Input: 2, Output: 8},
+		"gpt-4.1-mini":  {Input: 0.4, Output: 1.6},
+		"gpt-4.1-nano":  {Input: 0.1, Output: 0.4},
+		"gpt-4-turbo":   {Input: 10, Output: 30},
+		"gpt-3.5-turbo": {Input: 0.5, Output: 1.5},
+		"o3-mini":       {Input: 1.1, Output: 4.4},
+	}
+}
+
+// LoadModelPrices 从YAML文件加载价格并合并到内置价格上
+func LoadModelPrices(path string) (map[string]ModelPrice, error) {
+	prices := DefaultModelPrices()
+	if path == "" {
+		return prices, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price file: %w", err)
+	}
+	var custom map[string]ModelPrice
+	if err := yaml.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("failed to parse price file: %w", err)
+	}
+	for model, price := range custom {
+		prices[model] = price
+	}
+	return prices, nil
+}
+
+// lookupPrice 查找模型价格，带版本后缀的模型名按最长前缀匹配
+func lookupPrice(prices map[string]ModelPrice, model string) (ModelPrice, bool) {
+	if price, ok := prices[model]; ok {
+		return price, true
+	}
+	best := ""
+	for name := range prices {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return prices[best], true
+}
+
+// ToolStats 单个工具的统计
+type ToolStats struct {
+	Name        string        `json:"name"`
+	Calls       int           `json:"calls"`
+	Failures    int           `json:"failures"`
+	FailureRate float64       `json:"failure_rate"`
+	Duration    time.Duration `json:"duration"`
+}
+
+// ErrorCount 错误消息及出现次数
+type ErrorCount struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// CommandLoop 同一轨迹中重复执行的工具调用
+type CommandLoop struct {
+	File    string `json:"file"`
+	Tool    string `json:"tool"`
+	Args    string `json:"args"`
+	Repeats int    `json:"repeats"`
+}
+
+// StepSummary 每个任务步数的分布
+type StepSummary struct {
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+}
+
+// StatsReport 汇总后的统计结果
+type StatsReport struct {
+	Trajectories     int               `json:"trajectories"`
+	Succeeded        int               `json:"succeeded"`
+	Failed           int               `json:"failed"`
+	Incomplete       int               `json:"incomplete"`
+	Steps            StepSummary       `json:"steps"`
+	LLMCalls         int               `json:"llm_calls"`
+	PromptTokens     int               `json:"prompt_tokens"`
+	CompletionTokens int               `json:"completion_tokens"`
+	TotalTokens      int               `json:"total_tokens"`
+	Cost             float64           `json:"cost_usd"`
+	UnpricedModels   []string          `json:"unpriced_models,omitempty"`
+	LLMTime          time.Duration     `json:"llm_time"`
+	ToolTime         time.Duration     `json:"tool_time"`
+	Tools            []ToolStats       `json:"tools"`
+	TopErrors        []ErrorCount      `json:"top_errors"`
+	Loops            []CommandLoop     `json:"loops"`
+	LoadErrors       map[string]string `json:"load_errors,omitempty"`
+}
+
+// TrajectoryStats 多个轨迹的统计聚合器
+type TrajectoryStats struct {
+	prices     map[string]ModelPrice
+	report     StatsReport
+	steps      []int
+	tools      map[string]*ToolStats
+	errors     map[string]int
+	unpriced   map[string]bool
+	loadErrors map[string]string
+}
+
+// NewTrajectoryStats 创建统计聚合器，prices为nil时使用内置价格
+func NewTrajectoryStats(prices map[string]ModelPrice) *TrajectoryStats {
+	if prices == nil {
+		prices = DefaultModelPrices()
+	}
+	return &TrajectoryStats{
+		prices:     prices,
+		tools:      make(map[string]*ToolStats),
+		errors:     make(map[string]int),
+		unpriced:   make(map[string]bool),
+		loadErrors: make(map[string]string),
+	}
+}
+
+// AddFile 读取并统计一个轨迹文件，读取失败的文件记录在报告中
+func (s *TrajectoryStats) AddFile(path string) error {
+	events, err := ReadTrajectoryEvents(path)
+	if err != nil {
+		s.loadErrors[path] = err.Error()
+		return err
+	}
+	s.Add(path, events)
+	return nil
+}
+
+// Add 统计一个轨迹的事件，file用于在循环报告中标识轨迹
+func (s *TrajectoryStats) Add(file string, events []TrajectoryEvent) {
+	s.report.Trajectories++
+
+	llmCalls, stepEvents := 0, 0
+	var execution *ExecutionRecord
+	toolNames := make(map[string]string)
+	repeats := make(map[string]int)
+	repeatArgs := make(map[string]string)
+
+	for i := range events {
+		event := &events[i]
+		switch event.Type {
+		case EventLLMCall:
+			llmCalls++
+			s.addLLMCall(event)
+
+		case EventToolCall:
+			toolCall := event.ToolCall
+			toolNames[toolCall.ID] = toolCall.Function.Name
+			s.tool(toolCall.Function.Name).Calls++
+
+			args, _ := json.Marshal(toolCall.Function.Arguments)
+			key := toolCall.Function.Name + "\x00" + string(args)
+			repeats[key]++
+			repeatArgs[key] = string(args)
+
+		case EventToolResult:
+			result := event.ToolResult
+			name := result.Name
+			if name == "" {
+				name = toolNames[result.CallID]
+			}
+			stats := s.tool(name)
+			stats.Duration += result.Duration
+			s.report.ToolTime += result.Duration
+			if !result.Success {
+				stats.Failures++
+				if result.Error != "" {
+					s.errors[normalizeErrorMessage(result.Error)]++
+				}
+			}
+
+		case EventError:
+			// LLM和工具的错误已分别从调用记录和工具结果中统计，避免重复计数
+			if event.Error.Source != "llm" && event.Error.Source != "tool" {
+				s.errors[normalizeErrorMessage(event.Error.Message)]++
+			}
+
+		case EventStep:
+			stepEvents++
+
+		case EventExecution:
+			if record, err := event.DecodeExecution(); err == nil {
+				execution = record
+			}
+		}
+	}
+
+	// 优先使用代理记录的步骤数，旧轨迹没有步骤事件时用LLM调用次数代替
+	steps := stepEvents
+	if steps == 0 {
+		steps = llmCalls
+	}
+	s.steps = append(s.steps, steps)
+
+	switch {
+	case execution == nil:
+		s.report.Incomplete++
+	case execution.Success:
+		s.report.Succeeded++
+	default:
+		s.report.Failed++
+	}
+
+	for key, count := range repeats {
+		if count >= loopThreshold {
+			s.report.Loops = append(s.report.Loops, CommandLoop{
+				File:    file,
+				Tool:    strings.SplitN(key, "\x00", 2)[0],
+				Args:    repeatArgs[key],
+				Repeats: count,
+			})
+		}
+	}
+}
+
+// addLLMCall 统计一次LLM调用的用量、费用和耗时
+func (s *TrajectoryStats) addLLMCall(event *TrajectoryEvent) {
+	call := event.LLMCall
+	s.report.LLMCalls++
+	s.report.LLMTime += call.Latency
+	if call.Error != "" {
+		s.errors[normalizeErrorMessage(call.Error)]++
+	}
+	if call.Usage == nil {
+		return
+	}
+
+	s.report.PromptTokens += call.Usage.PromptTokens
+	s.report.CompletionTokens += call.Usage.CompletionTokens
+	s.report.TotalTokens += call.Usage.TotalTokens
+
+	price, ok := lookupPrice(s.prices, call.Model)
+	if !ok {
+		s.unpriced[call.Model] = true
+		return
+	}
+	s.report.Cost += float64(call.Usage.PromptTokens)/1e6*price.Input +
+		float64(call.Usage.CompletionTokens)/1e6*price.Output
+}
+
+// tool 获取或创建工具统计
+func (s *TrajectoryStats) tool(name string) *ToolStats {
+	if name == "" {
+		name = "unknown"
+	}
+	stats, exists := s.tools[name]
+	if !exists {
+		stats = &ToolStats{Name: name}
+		s.tools[name] = stats
+	}
+	return stats
+}
+
+// Report 生成统计报告，topErrors为保留的常见错误条数
+func (s *TrajectoryStats) Report(topErrors int) *StatsReport {
+	report := s.report
+	report.Steps = summarizeSteps(s.steps)
+
+	report.Tools = make([]ToolStats, 0, len(s.tools))
+	for _, stats := range s.tools {
+		tool := *stats
+		if tool.Calls > 0 {
+			tool.FailureRate = float64(tool.Failures) / float64(tool.Calls)
+		}
+		report.Tools = append(report.Tools, tool)
+	}
+	sort.Slice(report.Tools, func(i, j int) bool {
+		if report.Tools[i].Calls != report.Tools[j].Calls {
+			return report.Tools[i].Calls > report.Tools[j].Calls
+		}
+		return report.Tools[i].Name < report.Tools[j].Name
+	})
+
+	report.TopErrors = make([]ErrorCount, 0, len(s.errors))
+	for message, count := range s.errors {
+		report.TopErrors = append(report.TopErrors, ErrorCount{Message: message, Count: count})
+	}
+	sort.Slice(report.TopErrors, func(i, j int) bool {
+		if report.TopErrors[i].Count != report.TopErrors[j].Count {
+			return report.TopErrors[i].Count > report.TopErrors[j].Count
+		}
+		return report.TopErrors[i].Message < report.TopErrors[j].Message
+	})
+	if topErrors > 0 && len(report.TopErrors) > topErrors {
+		report.TopErrors = report.TopErrors[:topErrors]
+	}
+
+	report.Loops = append([]CommandLoop{}, s.report.Loops...)
+	sort.Slice(report.Loops, func(i, j int) bool {
+		if report.Loops[i].Repeats != report.Loops[j].Repeats {
+			return report.Loops[i].Repeats > report.Loops[j].Repeats
+		}
+		if report.Loops[i].File != report.Loops[j].File {
+			return report.Loops[i].File < report.Loops[j].File
+		}
+		return report.Loops[i].Args < report.Loops[j].Args
+	})
+
+	for model := range s.unpriced {
+		report.UnpricedModels = append(report.UnpricedModels, model)
+	}
+	sort.Strings(report.UnpricedModels)
+
+	if len(s.loadErrors) > 0 {
+		report.LoadErrors = make(map[string]string, len(s.loadErrors))
+		for path, message := range s.loadErrors {
+			report.LoadErrors[path] = message
+		}
+	}
+	return &report
+}
+
+// summarizeSteps 计算步数分布
+func summarizeSteps(steps []int) StepSummary {
+	if len(steps) == 0 {
+		return StepSummary{}
+	}
+	sorted := append([]int(nil), steps...)
+	sort.Ints(sorted)
+
+	total := 0
+	for _, n := range sorted {
+		total += n
+	}
+	summary := StepSummary{
+		Min:  sorted[0],
+		Max:  sorted[len(sorted)-1],
+		Mean: float64(total) / float64(len(sorted)),
+	}
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		summary.Median = float64(sorted[mid-1]+sorted[mid]) / 2
+	} else {
+		summary.Median = float64(sorted[mid])
+	}
+	return summary
+}
+
+var (
+	// errorNumberPattern 错误消息中的数字（行号、端口、耗时等）
+	errorNumberPattern = regexp.MustCompile(`\d+`)
+	// errorPathPattern 错误消息中的临时目录路径
+	errorPathPattern = regexp.MustCompile(`/tmp/[^\s:'"]+`)
+)
+
+// normalizeErrorMessage 归一化错误消息，使只有数字或临时路径不同的错误归为一类
+func normalizeErrorMessage(message string) string {
+	message = strings.TrimSpace(message)
+	if idx := strings.IndexByte(message, '\n'); idx >= 0 {
+		message = message[:idx]
+	}
+	message = errorPathPattern.ReplaceAllString(message, "/tmp/…")
+	message = errorNumberPattern.ReplaceAllString(message, "N")
+	if runes := []rune(message); len(runes) > 160 {
+		message = string(runes[:160]) + "…"
+	}
+	return message
+}
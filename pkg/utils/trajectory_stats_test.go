@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"trage-agent-go/pkg/llm"
+)
+
+// statsEvents 构造用于统计的事件序列
+func statsEvents(success bool, repeatCommand int) []TrajectoryEvent {
+	events := []TrajectoryEvent{
+		{Type: EventLLMCall, LLMCall: &llm.LLMInteraction{
+			Model:   "gpt-4o-2024-08-06",
+			Usage:   &llm.Usage{PromptTokens: 1000000, CompletionTokens: 100000, TotalTokens: 1100000},
+			Latency: 2 * time.Second,
+		}},
+	}
+	for i := 0; i < repeatCommand; i++ {
+		toolCall := llm.ToolCall{ID: string(rune('a' + i)), Function: llm.ToolCallFunction{Name: "bash", Arguments: map[string]interface{}{"command": "go test ./..."}}}
+		events = append(events,
+			TrajectoryEvent{Type: EventToolCall, ToolCall: &toolCall},
+			TrajectoryEvent{Type: EventToolResult, ToolResult: &llm.ToolExecutionResult{
+				CallID: toolCall.ID, Name: "bash", Success: false, Error: "exit status 1 at line " + string(rune('0'+i)), Duration: time.Second,
+			}},
+		)
+	}
+	execution, _ := json.Marshal(map[string]interface{}{"success": success})
+	events = append(events, TrajectoryEvent{Type: EventExecution, Execution: execution})
+	return events
+}
+
+func TestTrajectoryStats(t *testing.T) {
+	stats := NewTrajectoryStats(nil)
+	stats.Add("a.jsonl", statsEvents(true, 1))
+	stats.Add("b.jsonl", statsEvents(false, 3))
+	stats.Add("c.jsonl", []TrajectoryEvent{{Type: EventLLMCall, LLMCall: &llm.LLMInteraction{
+		Model: "custom-model", Usage: &llm.Usage{TotalTokens: 10},
+	}}})
+
+	report := stats.Report(5)
+	if report.Trajectories != 3 || report.Succeeded != 1 || report.Failed != 1 || report.Incomplete != 1 {
+		t.Errorf("Unexpected outcome counts: %+v", report)
+	}
+	if report.Steps.Min != 1 || report.Steps.Max != 1 {
+		t.Errorf("Expected 1 step per task, got %+v", report.Steps)
+	}
+
+	// 带日期后缀的模型按前缀计价：2次 × (1M输入×$2.5 + 0.1M输出×$10) = $7
+	if report.Cost < 6.99 || report.Cost > 7.01 {
+		t.Errorf("Expected cost of $7, got %f", report.Cost)
+	}
+	if len(report.UnpricedModels) != 1 || report.UnpricedModels[0] != "custom-model" {
+		t.Errorf("Expected custom-model to be unpriced, got %v", report.UnpricedModels)
+	}
+	if report.LLMTime != 4*time.Second || report.ToolTime != 4*time.Second {
+		t.Errorf("Expected 4s LLM and 4s tool time, got %s/%s", report.LLMTime, report.ToolTime)
+	}
+
+	if len(report.Tools) != 1 || report.Tools[0].Calls != 4 || report.Tools[0].FailureRate != 1 {
+		t.Errorf("Unexpected tool stats: %+v", report.Tools)
+	}
+	// 只有数字不同的错误归为一类
+	if len(report.TopErrors) != 1 || report.TopErrors[0].Count != 4 || report.TopErrors[0].Message != "exit status N at line N" {
+		t.Errorf("Expected errors to be grouped, got %+v", report.TopErrors)
+	}
+	if len(report.Loops) != 1 || report.Loops[0].File != "b.jsonl" || report.Loops[0].Repeats != 3 {
+		t.Errorf("Expected one loop in b.jsonl, got %+v", report.Loops)
+	}
+}
+
+func TestTrajectoryStats_LoadErrorsAndPrices(t *testing.T) {
+	dir := t.TempDir()
+	badPath := filepath.Join(dir, "bad.jsonl")
+	if err := os.WriteFile(badPath, []byte(`{"schema_version":1,"seq":1,"type":"step"}`+"\nnot json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pricePath := filepath.Join(dir, "prices.yaml")
+	if err := os.WriteFile(pricePath, []byte("custom-model: {input: 1, output: 2}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prices, err := LoadModelPrices(pricePath)
+	if err != nil {
+		t.Fatalf("Failed to load prices: %v", err)
+	}
+	if _, ok := prices["gpt-4o"]; !ok {
+		t.Error("Expected built-in prices to be kept")
+	}
+
+	stats := NewTrajectoryStats(prices)
+	if err := stats.AddFile(badPath); err == nil {
+		t.Error("Expected error for corrupt trajectory")
+	}
+	stats.Add("ok.jsonl", []TrajectoryEvent{{Type: EventLLMCall, LLMCall: &llm.LLMInteraction{
+		Model: "custom-model", Usage: &llm.Usage{PromptTokens: 1000000, TotalTokens: 1000000},
+	}}})
+
+	report := stats.Report(0)
+	if _, exists := report.LoadErrors[badPath]; !exists {
+		t.Errorf("Expected load error for %s, got %v", badPath, report.LoadErrors)
+	}
+	if report.Cost != 1 || len(report.UnpricedModels) != 0 {
+		t.Errorf("Expected custom price to apply, got cost=%f unpriced=%v", report.Cost, report.UnpricedModels)
+	}
+}